@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/purl"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type artifactResult struct {
+	GroupID     string
+	ArtifactID  string
+	Version     string
+	SHA1        string
+	ArchiveType types.ArchiveType
+	PURL        string
+}
+
+// artifact lists the indexes Trivy considers for a jar named `artifactID`-`version` without a known digest.
+func artifact(w io.Writer, conf *types.DBConfig, artifactID, version string, fileType types.ArchiveType) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	indexes, err := dbc.SelectIndexesByArtifactIDAndFileType(artifactID, version, fileType)
+	if err != nil {
+		return xerrors.Errorf("artifact lookup error: %w", err)
+	}
+	return writeIndexes(w, indexes)
+}
+
+// writeIndexes prints GAVs of `indexes` in the output format.
+func writeIndexes(w io.Writer, indexes []types.Index) error {
+	results := lo.Map(indexes, func(index types.Index, _ int) artifactResult {
+		return artifactResult{
+			GroupID:     index.GroupID,
+			ArtifactID:  index.ArtifactID,
+			Version:     index.Version,
+			SHA1:        hex.EncodeToString(index.SHA1),
+			ArchiveType: index.ArchiveType,
+			PURL:        purl.FromIndex(index),
+		}
+	})
+
+	switch outputFormat {
+	case jsonOutput:
+		return writeJSON(w, results)
+	case templateOutput:
+		return writeTemplate(w, results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GAV\tTYPE\tSHA1")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s:%s:%s\t%s\t%s\n", r.GroupID, r.ArtifactID, r.Version, r.ArchiveType, r.SHA1)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type confusionResult struct {
+	Namespace string
+	// Published is set when the internal namespace is also published, so it's a dependency-confusion target.
+	Published bool
+	// Artifacts are only listed by DB checks.
+	Artifacts []types.Artifact `json:",omitempty"`
+}
+
+// namespaceCheck reports whether `namespace` is published.
+type namespaceCheck func(namespace string) (confusionResult, error)
+
+// dbNamespaceCheck looks up artifacts of the namespace and its subgroups in `dbc`, e.g. a DB built from Maven Central.
+func dbNamespaceCheck(dbc db.DB) namespaceCheck {
+	return func(namespace string) (confusionResult, error) {
+		artifacts, err := dbc.SelectArtifactsInNamespace(namespace)
+		if err != nil {
+			return confusionResult{}, xerrors.Errorf("namespace lookup error (%s): %w", namespace, err)
+		}
+		return confusionResult{
+			Namespace: namespace,
+			Published: len(artifacts) > 0,
+			Artifacts: artifacts,
+		}, nil
+	}
+}
+
+// liveNamespaceCheck checks if the repository of `c` has a dir for the namespace.
+func liveNamespaceCheck(ctx context.Context, c *crawler.Crawler) namespaceCheck {
+	return func(namespace string) (confusionResult, error) {
+		published, err := c.GroupExists(ctx, namespace)
+		if err != nil {
+			return confusionResult{}, xerrors.Errorf("namespace check error (%s): %w", namespace, err)
+		}
+		return confusionResult{
+			Namespace: namespace,
+			Published: published,
+		}, nil
+	}
+}
+
+// auditConfusion checks internal `namespaces` and fails if any of them is published.
+func auditConfusion(w io.Writer, namespaces []string, check namespaceCheck) error {
+	var results []confusionResult
+	for _, namespace := range namespaces {
+		result, err := check(namespace)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+	}
+
+	if err := writeConfusionResults(w, results); err != nil {
+		return err
+	}
+
+	if published := lo.CountBy(results, func(r confusionResult) bool { return r.Published }); published > 0 {
+		return &exitError{
+			code: exitCodeConfusion,
+			msg:  fmt.Sprintf("%d of %d internal namespaces are published", published, len(results)),
+		}
+	}
+	return nil
+}
+
+func writeConfusionResults(w io.Writer, results []confusionResult) error {
+	if outputFormat == jsonOutput {
+		return writeJSON(w, results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tPUBLISHED\tARTIFACT")
+	for _, r := range results {
+		if len(r.Artifacts) == 0 {
+			fmt.Fprintf(tw, "%s\t%t\t-\n", r.Namespace, r.Published)
+			continue
+		}
+		for _, a := range r.Artifacts {
+			fmt.Fprintf(tw, "%s\t%t\t%s:%s\n", r.Namespace, r.Published, a.GroupID, a.ArtifactID)
+		}
+	}
+	return tw.Flush()
+}
+
+// readNamespaces reads group ids from the file, one per line. Empty lines and lines starting with `#` are skipped.
+func readNamespaces(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open namespaces file: %w", err)
+	}
+	defer f.Close()
+
+	var namespaces []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		namespaces = append(namespaces, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, xerrors.Errorf("unable to read namespaces file: %w", err)
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/dbtest"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func TestAuditConfusion(t *testing.T) {
+	dbc, err := dbtest.InitDB(t, []types.Index{
+		{GroupID: "com.example", ArtifactID: "lib", Version: "1.0", SHA1: bytes.Repeat([]byte{1}, 20), ArchiveType: types.JarType},
+		{GroupID: "com.example.internal", ArtifactID: "api", Version: "1.0", SHA1: bytes.Repeat([]byte{2}, 20), ArchiveType: types.JarType},
+		{GroupID: "com.examples", ArtifactID: "other", Version: "1.0", SHA1: bytes.Repeat([]byte{3}, 20), ArchiveType: types.JarType},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       string
+		wantCode   int
+	}{
+		{
+			name:       "published with subgroups",
+			namespaces: []string{"com.example"},
+			want: `NAMESPACE    PUBLISHED  ARTIFACT
+com.example  true       com.example:lib
+com.example  true       com.example.internal:api
+`,
+			wantCode: exitCodeConfusion,
+		},
+		{
+			name:       "not published",
+			namespaces: []string{"com.acme"},
+			want: `NAMESPACE  PUBLISHED  ARTIFACT
+com.acme   false      -
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := auditConfusion(&buf, tt.namespaces, dbNamespaceCheck(dbc))
+			assert.Equal(t, tt.want, buf.String())
+
+			if tt.wantCode == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var exitErr *exitError
+			require.True(t, errors.As(err, &exitErr))
+			assert.Equal(t, tt.wantCode, exitErr.code)
+		})
+	}
+}
+
+func TestReadNamespaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "namespaces.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# internal groups\ncom.example\n\n  com.acme  \n"), 0600))
+
+	got, err := readNamespaces(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"com.example", "com.acme"}, got)
+}
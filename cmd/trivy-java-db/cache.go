@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/cache"
+)
+
+// listCache prints the groups in the cache dir with their number of artifacts and versions.
+func listCache(w io.Writer) error {
+	groups, err := cache.Groups(cacheDir)
+	if err != nil {
+		return xerrors.Errorf("cache error: %w", err)
+	}
+	if outputFormat == jsonOutput {
+		return writeJSON(w, groups)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP\tARTIFACTS\tVERSIONS")
+	for _, g := range groups {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", g.GroupID, g.Artifacts, g.Versions)
+	}
+	return tw.Flush()
+}
+
+// showCache prints the versions in the index files of `groupID` as the builder reads them.
+func showCache(w io.Writer, groupID string) error {
+	indexes, err := cache.Indexes(cacheDir, groupID)
+	if err != nil {
+		return xerrors.Errorf("cache error: %w", err)
+	} else if len(indexes) == 0 {
+		return xerrors.Errorf("no index files of %s in %s", groupID, cacheDir)
+	}
+	if outputFormat == jsonOutput {
+		return writeJSON(w, indexes)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ARTIFACT\tVERSION\tTYPE\tSHA1\tMD5")
+	for _, index := range indexes {
+		for _, ver := range index.Versions {
+			archiveType := index.ArchiveType
+			if ver.ArchiveType != "" {
+				archiveType = ver.ArchiveType
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", index.ArtifactID, ver.Version, archiveType,
+				hex.EncodeToString(ver.SHA1), hex.EncodeToString(ver.MD5))
+		}
+	}
+	return tw.Flush()
+}
+
+// gcCache removes files which builds don't need from the cache dir and prints the reclaimed space.
+func gcCache(w io.Writer) error {
+	stats, err := cache.GC(cacheDir, cache.GCOption{
+		ExcludeGroups: gcExcludeGroups,
+		Retention:     gcRetention,
+		DryRun:        gcDryRun,
+	})
+	if err != nil {
+		return xerrors.Errorf("cache gc error: %w", err)
+	}
+	if outputFormat == jsonOutput {
+		return writeJSON(w, stats)
+	}
+
+	verb := "Removed"
+	if gcDryRun {
+		verb = "Would remove"
+	}
+	_, err = fmt.Fprintf(w, "%s %d index files of excluded groups, %d expired files and %d temp files, %.1f MiB\n",
+		verb, stats.Excluded, stats.Expired, stats.TempFiles, float64(stats.Bytes)/(1<<20))
+	return err
+}
+
+// checkCache prints index files which the builder skips or reads wrongly.
+// It returns exitCodeCache when there are any.
+func checkCache(w io.Writer) error {
+	problems, err := cache.Check(cacheDir)
+	if err != nil {
+		return xerrors.Errorf("cache error: %w", err)
+	}
+	if outputFormat == jsonOutput {
+		err = writeJSON(w, problems)
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "FILE\tPROBLEM")
+		for _, p := range problems {
+			fmt.Fprintf(tw, "%s\t%s\n", p.Path, p.Reason)
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return &exitError{code: exitCodeCache, msg: fmt.Sprintf("%d broken index files", len(problems))}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/builder"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/notify"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+var (
+	changeFeedPath string
+	changeFeedURLs []string
+)
+
+// changeFeed lists what a build added to the DB, so downstream caches and bots can react without diffing dumps.
+type changeFeed struct {
+	Generation   int
+	NewGroups    []string
+	NewArtifacts []string
+	NewVersions  []changedVersion
+}
+
+type changedVersion struct {
+	GAV         string
+	SHA1        string `json:",omitempty"`
+	ArchiveType types.ArchiveType
+}
+
+// publishChangeFeed writes the changes of the build to `changeFeedPath` and posts them to `changeFeedURLs`.
+// The first build of a DB has no feed, since everything is new.
+func publishChangeFeed(ctx context.Context, conf *types.DBConfig, stats builder.Stats) error {
+	if changeFeedPath == "" && len(changeFeedURLs) == 0 {
+		return nil
+	} else if stats.Generation <= 1 {
+		log.Println("First build of the DB, no change feed")
+		return nil
+	}
+
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	cl, err := dbc.SelectChangelog(stats.Generation-1, stats.Generation)
+	if err != nil {
+		return xerrors.Errorf("changelog error: %w", err)
+	}
+	// lo.Map returns empty slices for nil, so JSON has [] instead of null
+	feed := changeFeed{
+		Generation: stats.Generation,
+		NewGroups:  lo.Map(cl.NewGroups, func(groupID string, _ int) string { return groupID }),
+		NewArtifacts: lo.Map(cl.NewArtifacts, func(a types.Artifact, _ int) string {
+			return fmt.Sprintf("%s:%s", a.GroupID, a.ArtifactID)
+		}),
+		NewVersions: lo.Map(cl.NewVersions, func(index types.Index, _ int) changedVersion {
+			return changedVersion{
+				GAV:         fmt.Sprintf("%s:%s:%s", index.GroupID, index.ArtifactID, index.Version),
+				SHA1:        hex.EncodeToString(index.SHA1),
+				ArchiveType: index.ArchiveType,
+			}
+		}),
+	}
+	log.Printf("Change feed: %d new groups, %d new artifacts, %d new versions",
+		len(feed.NewGroups), len(feed.NewArtifacts), len(feed.NewVersions))
+
+	if changeFeedPath != "" {
+		if err = fileutil.WriteJSON(changeFeedPath, feed); err != nil {
+			return xerrors.Errorf("change feed write error: %w", err)
+		}
+	}
+	if len(changeFeedURLs) > 0 {
+		body, err := json.Marshal(feed)
+		if err != nil {
+			return xerrors.Errorf("json marshal error: %w", err)
+		}
+		n := notify.NewNotifier(notify.Option{URLs: changeFeedURLs})
+		if err = n.Post(ctx, body); err != nil {
+			return xerrors.Errorf("change feed post error: %w", err)
+		}
+	}
+	return nil
+}
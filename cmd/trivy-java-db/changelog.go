@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type changelogResult struct {
+	From         int
+	To           int `json:",omitempty"`
+	NewGroups    []string
+	NewArtifacts []string
+	NewVersions  []string
+}
+
+func changelog(w io.Writer, conf *types.DBConfig, from, to int) error {
+	if to != 0 && to <= from {
+		return xerrors.Errorf("--to (%d) must be greater than --from (%d)", to, from)
+	}
+
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	cl, err := dbc.SelectChangelog(from, to)
+	if err != nil {
+		return xerrors.Errorf("changelog error: %w", err)
+	}
+
+	// lo.Map returns empty slices for nil, so JSON has [] instead of null
+	result := changelogResult{
+		From: from,
+		To:   to,
+		NewGroups: lo.Map(cl.NewGroups, func(groupID string, _ int) string {
+			return groupID
+		}),
+		NewArtifacts: lo.Map(cl.NewArtifacts, func(a types.Artifact, _ int) string {
+			return fmt.Sprintf("%s:%s", a.GroupID, a.ArtifactID)
+		}),
+		NewVersions: lo.Map(cl.NewVersions, func(index types.Index, _ int) string {
+			return fmt.Sprintf("%s:%s:%s", index.GroupID, index.ArtifactID, index.Version)
+		}),
+	}
+
+	switch outputFormat {
+	case jsonOutput:
+		return writeJSON(w, result)
+	case csvOutput:
+		return writeCSV(w, []string{"change", "group_id", "artifact_id", "version"}, changelogRows(cl))
+	}
+
+	fmt.Fprintf(w, "Changes since build %d", from)
+	if to != 0 {
+		fmt.Fprintf(w, " up to build %d", to)
+	}
+	fmt.Fprintln(w)
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"New groups", result.NewGroups},
+		{"New artifacts", result.NewArtifacts},
+		{"New versions", result.NewVersions},
+	} {
+		fmt.Fprintf(w, "\n%s (%d):\n", section.title, len(section.items))
+		for _, item := range section.items {
+			fmt.Fprintf(w, "  %s\n", item)
+		}
+	}
+	return nil
+}
+
+// changelogRows flattens the changelog into one row per new group, artifact and version.
+func changelogRows(cl types.Changelog) [][]string {
+	var rows [][]string
+	for _, groupID := range cl.NewGroups {
+		rows = append(rows, []string{"group", groupID, "", ""})
+	}
+	for _, a := range cl.NewArtifacts {
+		rows = append(rows, []string{"artifact", a.GroupID, a.ArtifactID, ""})
+	}
+	for _, index := range cl.NewVersions {
+		rows = append(rows, []string{"version", index.GroupID, index.ArtifactID, index.Version})
+	}
+	return rows
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type collisionResult struct {
+	SHA1 string
+	// GAVs lists all coordinates sharing the sha1. The first one is stored in the DB.
+	GAVs []string
+}
+
+func collisions(w io.Writer, conf *types.DBConfig) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	cs, err := dbc.SelectCollisions()
+	if err != nil {
+		return xerrors.Errorf("collisions error: %w", err)
+	}
+
+	results := lo.Map(cs, func(c types.Collision, _ int) collisionResult {
+		return collisionResult{
+			SHA1: hex.EncodeToString(c.SHA1),
+			GAVs: lo.Map(c.Indexes, func(index types.Index, _ int) string {
+				return fmt.Sprintf("%s:%s:%s", index.GroupID, index.ArtifactID, index.Version)
+			}),
+		}
+	})
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SHA1\tGAV\tSTORED")
+	for _, r := range results {
+		for i, gav := range r.GAVs {
+			fmt.Fprintf(tw, "%s\t%s\t%t\n", r.SHA1, gav, i == 0)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type compactResult struct {
+	// Compacted is the number of artifacts merged into or renamed to their canonical ids.
+	Compacted int
+}
+
+// compact merges artifacts stored with ids which builds now normalize, e.g. with surrounding whitespace.
+func compact(w io.Writer, conf *types.DBConfig) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	n, err := dbc.CompactArtifacts()
+	if err != nil {
+		return xerrors.Errorf("compact error: %w", err)
+	}
+	if n > 0 {
+		if err = dbc.VacuumDB(); err != nil {
+			return xerrors.Errorf("db vacuum error: %w", err)
+		}
+		if err = updateRowCounts(dbc); err != nil {
+			return err
+		}
+	}
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, compactResult{Compacted: n})
+	}
+	fmt.Fprintf(w, "Compacted %d artifacts\n", n)
+	return nil
+}
+
+// updateRowCounts recounts the rows in the DB info written by the last build, keeping its schema version and build time.
+func updateRowCounts(dbc db.DB) error {
+	info, err := dbc.SelectDBInfo()
+	if errors.Is(err, db.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return xerrors.Errorf("db info error: %w", err)
+	}
+	if err = dbc.UpdateDBInfo(info.SchemaVersion, info.BuiltAt); err != nil {
+		return xerrors.Errorf("db info update error: %w", err)
+	}
+	return nil
+}
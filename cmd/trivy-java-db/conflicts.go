@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type conflictResult struct {
+	GAV         string
+	ArchiveType types.ArchiveType
+	Sources     []conflictSource
+}
+
+type conflictSource struct {
+	Repository string
+	SHA1       string
+}
+
+func conflicts(w io.Writer, conf *types.DBConfig) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	cs, err := dbc.SelectConflictingGAVs()
+	if err != nil {
+		return xerrors.Errorf("conflicts error: %w", err)
+	}
+
+	results := lo.Map(cs, func(c types.Conflict, _ int) conflictResult {
+		return conflictResult{
+			GAV:         fmt.Sprintf("%s:%s:%s", c.GroupID, c.ArtifactID, c.Version),
+			ArchiveType: c.ArchiveType,
+			Sources: lo.Map(c.Indexes, func(index types.Index, _ int) conflictSource {
+				return conflictSource{
+					Repository: index.Repository,
+					SHA1:       hex.EncodeToString(index.SHA1),
+				}
+			}),
+		}
+	})
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GAV\tTYPE\tREPOSITORY\tSHA1")
+	for _, r := range results {
+		for _, s := range r.Sources {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.GAV, r.ArchiveType, s.Repository, s.SHA1)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/builder"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+const (
+	sqliteBackend = "sqlite"
+	mysqlBackend  = "mysql"
+)
+
+// convertDB loads the DB of the `from` backend into the DB of the `to` backend.
+// Sqlite files, e.g. DBs built by aquasecurity/trivy-java-db, can be loaded into mysql, and mysql can be snapshotted
+// into a sqlite file.
+func convertDB(from, to, sqliteFile string) (builder.Stats, error) {
+	if sqliteFile == "" {
+		return builder.Stats{}, xerrors.New("--sqlite-file is required")
+	}
+	if dbConnectURL == "" {
+		return builder.Stats{}, xerrors.New("--db-connect-url is required")
+	}
+	conf := &types.DBConfig{MysqlDBConfig: mysqlConfig()}
+
+	switch {
+	case from == sqliteBackend && to == mysqlBackend:
+		return sqliteToMysql(conf, sqliteFile)
+	case from == mysqlBackend && to == sqliteBackend:
+		return mysqlToSqlite(conf, sqliteFile)
+	}
+	return builder.Stats{}, xerrors.Errorf("unsupported conversion from %s to %s", from, to)
+}
+
+func sqliteToMysql(conf *types.DBConfig, sqliteFile string) (builder.Stats, error) {
+	src, err := builder.NewSqliteFileSource(sqliteFile)
+	if err != nil {
+		return builder.Stats{}, xerrors.Errorf("source db error: %w", err)
+	}
+
+	dbDir := filepath.Join(cacheDir, "db")
+	log.Printf("Converting %s to mysql", sqliteFile)
+	dbc, err := db.New(dbDir, conf)
+	if err != nil {
+		_ = src.Close()
+		return builder.Stats{}, xerrors.Errorf("db create error: %w", err)
+	}
+	defer dbc.Close()
+
+	if err = dbc.Init(); err != nil {
+		_ = src.Close()
+		return builder.Stats{}, xerrors.Errorf("db init error: %w", err)
+	}
+	return convert(dbc, dbDir, src)
+}
+
+// mysqlToSqlite snapshots mysql into a new sqlite file with metadata.json next to it. The file is built under a
+// temporary name and renamed when complete, so scanners reading `sqliteFile` never see a partial DB.
+func mysqlToSqlite(conf *types.DBConfig, sqliteFile string) (builder.Stats, error) {
+	dbDir := filepath.Join(cacheDir, "db")
+	srcDB, err := db.New(dbDir, conf)
+	if err != nil {
+		return builder.Stats{}, xerrors.Errorf("source db error: %w", err)
+	}
+	defer srcDB.Close()
+
+	metaDir := filepath.Dir(sqliteFile)
+	if err = os.MkdirAll(metaDir, 0700); err != nil {
+		return builder.Stats{}, xerrors.Errorf("failed to mkdir: %w", err)
+	}
+	tmpFile := sqliteFile + ".tmp"
+	if err = os.Remove(tmpFile); err != nil && !os.IsNotExist(err) {
+		return builder.Stats{}, xerrors.Errorf("failed to remove %s: %w", tmpFile, err)
+	}
+
+	log.Printf("Converting mysql to %s", sqliteFile)
+	dbc, err := db.New(dbDir, &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: tmpFile}})
+	if err != nil {
+		return builder.Stats{}, xerrors.Errorf("db create error: %w", err)
+	}
+	defer dbc.Close()
+
+	if err = dbc.Init(); err != nil {
+		return builder.Stats{}, xerrors.Errorf("db init error: %w", err)
+	}
+	stats, err := convert(dbc, metaDir, builder.NewDBSource(mysqlBackend, srcDB))
+	if err != nil {
+		return stats, err
+	}
+	if err = dbc.Close(); err != nil {
+		return stats, xerrors.Errorf("db close error: %w", err)
+	}
+	if err = os.Rename(tmpFile, sqliteFile); err != nil {
+		return stats, xerrors.Errorf("failed to rename %s: %w", tmpFile, err)
+	}
+	return stats, nil
+}
+
+// convert builds `dbc` from `src` and writes the metadata to `metaDir`.
+func convert(dbc db.DB, metaDir string, src builder.IndexSource) (builder.Stats, error) {
+	b := builder.NewBuilder(dbc, db.NewMetadata(metaDir), builder.Option{
+		SchemaVersion:  db.SchemaVersion,
+		UpdateInterval: updateInterval,
+	})
+	if err := b.BuildFrom(src); err != nil {
+		return b.Stats(), xerrors.Errorf("db convert error: %w", err)
+	}
+	return b.Stats(), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// showDBInfo prints the schema version, build and vacuum times and row counts stored in the DB by the last build.
+func showDBInfo(w io.Writer, conf *types.DBConfig) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	info, err := dbc.SelectDBInfo()
+	if err != nil {
+		return xerrors.Errorf("db info error: %w", err)
+	}
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, info)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Schema version:\t%d\n", info.SchemaVersion)
+	fmt.Fprintf(tw, "Built at:\t%s\n", info.BuiltAt.Format(time.RFC3339))
+	if info.VacuumedAt != nil {
+		fmt.Fprintf(tw, "Vacuumed at:\t%s\n", info.VacuumedAt.Format(time.RFC3339))
+	}
+	tables := lo.Keys(info.RowCounts)
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Fprintf(tw, "Rows of %s:\t%d\n", table, info.RowCounts[table])
+	}
+	return tw.Flush()
+}
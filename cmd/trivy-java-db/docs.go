@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra/doc"
+	"golang.org/x/xerrors"
+)
+
+func genDocs(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return xerrors.Errorf("unable to create a directory: %w", err)
+	}
+	header := &doc.GenManHeader{
+		Title:   "TRIVY-JAVA-DB",
+		Section: "1",
+	}
+	if err := doc.GenManTree(rootCmd, header, dir); err != nil {
+		return xerrors.Errorf("man page generation error: %w", err)
+	}
+	return nil
+}
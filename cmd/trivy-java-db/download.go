@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/download"
+)
+
+// downloadDB downloads the published DB at `dbURL`, resuming earlier partial downloads.
+func downloadDB(ctx context.Context, w io.Writer, dbURL string) error {
+	u, err := url.Parse(dbURL)
+	if err != nil || !isHTTPURL(dbURL) {
+		return xerrors.Errorf("invalid URL: %s", dbURL)
+	}
+	dst := downloadOutput
+	if dst == "" {
+		dst = filepath.Join(cacheDir, "db", path.Base(u.Path))
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return xerrors.Errorf("unable to create a directory: %w", err)
+	}
+
+	d := download.NewDownloader(download.Option{Retries: downloadRetries})
+	result, err := d.Download(ctx, dbURL, dst)
+	if err != nil {
+		return xerrors.Errorf("download error: %w", err)
+	}
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, result)
+	}
+	switch {
+	case result.NotModified:
+		fmt.Fprintf(w, "%s is up to date\n", dst)
+	case result.Resumed:
+		fmt.Fprintf(w, "Resumed the download of %s (%d bytes)\n", dst, result.Bytes)
+	default:
+		fmt.Fprintf(w, "Downloaded %s (%d bytes)\n", dst, result.Bytes)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/enrich"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// enrichArtifacts stores metadata of artifacts from deps.dev in the DB.
+func enrichArtifacts(ctx context.Context, conf *types.DBConfig) (enrich.Stats, error) {
+	dbDir := filepath.Join(cacheDir, "db")
+	log.Printf("Database path: %s", dbDir)
+	dbc, err := db.New(dbDir, conf)
+	if err != nil {
+		return enrich.Stats{}, xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	// Creates the enrichments table in DBs built by older versions
+	if err = dbc.Init(); err != nil {
+		return enrich.Stats{}, xerrors.Errorf("db init error: %w", err)
+	}
+
+	opt := enrich.Option{
+		URL:   depsDevURL,
+		Rate:  enrichRate,
+		Limit: enrichLimit,
+	}
+	if enrichPOM {
+		opt.POMURL = pomRepoURL
+	}
+	e := enrich.NewEnricher(dbc, opt)
+	if err = e.Enrich(ctx); err != nil {
+		return e.Stats(), xerrors.Errorf("enrich error: %w", err)
+	}
+	return e.Stats(), nil
+}
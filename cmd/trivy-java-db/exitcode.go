@@ -0,0 +1,29 @@
+package main
+
+import "fmt"
+
+// Exit codes returned by policy flags (e.g. `lookup --fail-on-missing`), so CI jobs can gate on them.
+// Any other error exits with 1.
+const (
+	exitCodeMissing   = 2
+	exitCodeStale     = 3
+	exitCodeConfusion = 4
+	// exitCodeQuarantine is returned by `verify` when the last build quarantined versions.
+	exitCodeQuarantine = 5
+	// exitCodeCache is returned by `cache check` when index files are broken.
+	exitCodeCache = 6
+	// exitCodeMismatch is returned by `verify --against-cache` when sampled versions aren't stored as cached.
+	exitCodeMismatch = 7
+	// exitCodePolicy is returned by `lookup --policy` when found artifacts violate the policy.
+	exitCodePolicy = 8
+)
+
+// exitError is returned when a command succeeded, but its result violates a requested policy.
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("%s (exit code %d)", e.msg, e.code)
+}
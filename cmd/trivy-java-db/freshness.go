@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+)
+
+type freshnessResult struct {
+	Version    int
+	UpdatedAt  time.Time
+	NextUpdate time.Time
+	Stale      bool
+}
+
+// checkFreshness compares NextUpdate in the metadata of `dbDir` with `now`.
+func checkFreshness(w io.Writer, dbDir string, now time.Time) error {
+	client := db.NewMetadata(dbDir)
+	meta, err := client.Get()
+	if err != nil {
+		return xerrors.Errorf("metadata error: %w", err)
+	}
+
+	result := freshnessResult{
+		Version:    meta.Version,
+		UpdatedAt:  meta.UpdatedAt,
+		NextUpdate: meta.NextUpdate,
+		Stale:      !now.Before(meta.NextUpdate),
+	}
+	if outputFormat == jsonOutput {
+		err = writeJSON(w, result)
+	} else {
+		status := "fresh"
+		if result.Stale {
+			status = "stale"
+		}
+		_, err = fmt.Fprintf(w, "DB is %s (updated at %s, next update %s)\n",
+			status, result.UpdatedAt.Format(time.RFC3339), result.NextUpdate.Format(time.RFC3339))
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.Stale {
+		return &exitError{
+			code: exitCodeStale,
+			msg:  fmt.Sprintf("DB is stale since %s", result.NextUpdate.Format(time.RFC3339)),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+)
+
+func TestCheckFreshness(t *testing.T) {
+	dir := t.TempDir()
+	client := db.NewMetadata(dir)
+	require.NoError(t, client.Update(db.Metadata{
+		Version:    db.SchemaVersion,
+		UpdatedAt:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		NextUpdate: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+	}))
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		want     string
+		wantCode int
+	}{
+		{
+			name: "fresh",
+			now:  time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+			want: "DB is fresh (updated at 2026-01-01T00:00:00Z, next update 2026-01-04T00:00:00Z)\n",
+		},
+		{
+			name:     "stale",
+			now:      time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+			want:     "DB is stale (updated at 2026-01-01T00:00:00Z, next update 2026-01-04T00:00:00Z)\n",
+			wantCode: exitCodeStale,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := checkFreshness(&buf, dir, tt.now)
+			if tt.wantCode == 0 {
+				require.NoError(t, err)
+			} else {
+				var exitErr *exitError
+				require.True(t, errors.As(err, &exitErr))
+				assert.Equal(t, tt.wantCode, exitErr.code)
+			}
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+
+	err := checkFreshness(&bytes.Buffer{}, t.TempDir(), time.Now())
+	assert.ErrorContains(t, err, "metadata error")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/builder"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// runPostBuildHooks runs `postBuildCmds` one by one with the build metadata in the environment.
+// The build fails on the first failing command.
+func runPostBuildHooks(conf *types.DBConfig, stats builder.Stats) error {
+	if len(postBuildCmds) == 0 {
+		return nil
+	}
+
+	dbDir := filepath.Join(cacheDir, "db")
+	meta := db.NewMetadata(dbDir)
+	metadata, err := meta.Get()
+	if err != nil {
+		return xerrors.Errorf("metadata error: %w", err)
+	}
+
+	env := append(os.Environ(),
+		"TRIVY_JAVA_DB_DIR="+dbDir,
+		fmt.Sprintf("TRIVY_JAVA_DB_SCHEMA_VERSION=%d", metadata.Version),
+		"TRIVY_JAVA_DB_UPDATED_AT="+metadata.UpdatedAt.Format(time.RFC3339),
+		"TRIVY_JAVA_DB_NEXT_UPDATE="+metadata.NextUpdate.Format(time.RFC3339),
+		fmt.Sprintf("TRIVY_JAVA_DB_GENERATION=%d", stats.Generation),
+		fmt.Sprintf("TRIVY_JAVA_DB_VERSIONS=%d", stats.Versions),
+	)
+	if conf.SqliteDBConfig != nil {
+		env = append(env, "TRIVY_JAVA_DB_PATH="+conf.SqliteDBConfig.DBPath)
+	}
+
+	for _, command := range postBuildCmds {
+		log.Printf("Post-build hook: %s", command)
+		cmd := shellCommand(command)
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err = cmd.Run(); err != nil {
+			return xerrors.Errorf("post-build hook error (%s): %w", command, err)
+		}
+	}
+	return nil
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// addLabels attaches the labels to the artifact.
+func addLabels(w io.Writer, conf *types.DBConfig, groupID, artifactID string, labels []string) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	// Creates the labels table in DBs built by older versions
+	if err = dbc.Init(); err != nil {
+		return xerrors.Errorf("db init error: %w", err)
+	}
+	if err = dbc.InsertLabels(artifactLabels(groupID, artifactID, labels)); err != nil {
+		return xerrors.Errorf("insert labels error: %w", err)
+	}
+	fmt.Fprintf(w, "Labeled %s:%s with %v\n", groupID, artifactID, labels)
+	return nil
+}
+
+// removeLabels detaches the labels from the artifact.
+func removeLabels(w io.Writer, conf *types.DBConfig, groupID, artifactID string, labels []string) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	if err = dbc.Init(); err != nil {
+		return xerrors.Errorf("db init error: %w", err)
+	}
+	n, err := dbc.DeleteLabels(artifactLabels(groupID, artifactID, labels))
+	if err != nil {
+		return xerrors.Errorf("delete labels error: %w", err)
+	}
+	fmt.Fprintf(w, "Removed %d labels from %s:%s\n", n, groupID, artifactID)
+	return nil
+}
+
+// listLabels lists the labeled artifacts, e.g. to export the allowlist of approved artifacts with `-o json`.
+func listLabels(w io.Writer, conf *types.DBConfig, label string) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	labels, err := dbc.SelectLabeledArtifacts(label)
+	if err != nil {
+		return xerrors.Errorf("select labels error: %w", err)
+	}
+
+	switch outputFormat {
+	case jsonOutput:
+		return writeJSON(w, labels)
+	case csvOutput:
+		return writeCSV(w, []string{"group_id", "artifact_id", "label"}, lo.Map(labels, func(l types.Label, _ int) []string {
+			return []string{l.GroupID, l.ArtifactID, l.Label}
+		}))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP ID\tARTIFACT ID\tLABEL")
+	for _, l := range labels {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", l.GroupID, l.ArtifactID, l.Label)
+	}
+	return tw.Flush()
+}
+
+func artifactLabels(groupID, artifactID string, labels []string) []types.Label {
+	return lo.Map(lo.Uniq(labels), func(label string, _ int) types.Label {
+		return types.Label{GroupID: groupID, ArtifactID: artifactID, Label: label}
+	})
+}
+
+// hasLabels returns true if the artifact has all `labels`.
+func hasLabels(dbc db.DB, groupID, artifactID string, labels []string) (bool, error) {
+	if len(labels) == 0 {
+		return true, nil
+	}
+	attached, err := dbc.SelectLabels(groupID, artifactID)
+	if err != nil {
+		return false, xerrors.Errorf("select labels error (%s:%s): %w", groupID, artifactID, err)
+	}
+	return lo.Every(attached, labels), nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/lock"
+)
+
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&waitLock, "wait", false, "wait for other processes using the cache dir instead of failing")
+}
+
+// lockCacheDir locks the cache dir, so that crawls, builds and other writers of the cache dir and its DB dir don't run at
+// the same time. The returned func releases the lock.
+func lockCacheDir(ctx context.Context) (func(), error) {
+	l, err := lock.Acquire(ctx, filepath.Join(cacheDir, lock.File), waitLock)
+	if errors.Is(err, lock.ErrLocked) {
+		return nil, xerrors.Errorf("the cache dir is in use, retry later or pass --wait: %w", err)
+	} else if err != nil {
+		return nil, xerrors.Errorf("cache dir lock error: %w", err)
+	}
+	return func() {
+		if err := l.Release(); err != nil {
+			log.Printf("Unable to release the cache dir lock: %s", err)
+		}
+	}, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/policy"
+	"github.com/h7hac9/trivy-java-db/pkg/purl"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type lookupResult struct {
+	Digest      string
+	Found       bool
+	MatchType   types.MatchType   `json:",omitempty"`
+	GroupID     string            `json:",omitempty"`
+	ArtifactID  string            `json:",omitempty"`
+	Version     string            `json:",omitempty"`
+	SHA1        string            `json:",omitempty"`
+	MD5         string            `json:",omitempty"`
+	ArchiveType types.ArchiveType `json:",omitempty"`
+	Classifier  string            `json:",omitempty"`
+	Platform    string            `json:",omitempty"`
+	PURL        string            `json:",omitempty"`
+	Generation  int               `json:",omitempty"`
+	RemovedAt   *time.Time        `json:",omitempty"`
+	Deprecated  bool              `json:",omitempty"`
+	// Replacement and DeprecationReason come from the deprecation feed.
+	Replacement       string `json:",omitempty"`
+	DeprecationReason string `json:",omitempty"`
+	// Violations are the rules of --policy matched by the artifact.
+	Violations []policy.Violation `json:",omitempty"`
+}
+
+func lookup(w io.Writer, conf *types.DBConfig, digests []string) error {
+	var p *policy.Policy
+	if policyFile != "" {
+		var err error
+		if p, err = policy.Load(policyFile); err != nil {
+			return xerrors.Errorf("policy load error: %w", err)
+		}
+	}
+
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	var results []lookupResult
+	for _, digest := range digests {
+		index, matchType, err := dbc.SelectIndexBySha1OrMd5(digest)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return xerrors.Errorf("lookup error (%s): %w", digest, err)
+		}
+		result := lookupResult{
+			Digest: digest,
+			Found:  err == nil,
+		}
+		if result.Found {
+			// Indexes of artifacts without the labels of --label are left out
+			ok, err := hasLabels(dbc, index.GroupID, index.ArtifactID, labelFilter)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			result.MatchType = matchType
+			result.GroupID = index.GroupID
+			result.ArtifactID = index.ArtifactID
+			result.Version = index.Version
+			result.SHA1 = hex.EncodeToString(index.SHA1)
+			result.MD5 = hex.EncodeToString(index.MD5)
+			result.ArchiveType = index.ArchiveType
+			result.Classifier = index.Classifier
+			result.Platform = index.Platform
+			result.PURL = purl.FromIndex(index)
+			result.Generation = index.Generation
+			result.RemovedAt = index.RemovedAt
+
+			deprecation, err := dbc.SelectDeprecation(index.GroupID, index.ArtifactID)
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				return xerrors.Errorf("deprecation lookup error (%s): %w", digest, err)
+			}
+			result.Deprecated = err == nil
+			result.Replacement = deprecation.Replacement
+			result.DeprecationReason = deprecation.Reason
+
+			if p != nil {
+				if result.Violations, err = evaluatePolicy(dbc, p, index); err != nil {
+					return xerrors.Errorf("policy evaluation error (%s): %w", digest, err)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	if err = writeLookupResults(w, results); err != nil {
+		return err
+	}
+
+	if violating := lo.CountBy(results, func(r lookupResult) bool { return len(r.Violations) > 0 }); violating > 0 {
+		return &exitError{
+			code: exitCodePolicy,
+			msg:  fmt.Sprintf("%d of %d digests violate the policy", violating, len(results)),
+		}
+	}
+	if failOnMiss {
+		if missing := lo.CountBy(results, func(r lookupResult) bool { return !r.Found }); missing > 0 {
+			return &exitError{
+				code: exitCodeMissing,
+				msg:  fmt.Sprintf("%d of %d digests not found", missing, len(results)),
+			}
+		}
+	}
+	return nil
+}
+
+func writeLookupResults(w io.Writer, results []lookupResult) error {
+	switch outputFormat {
+	case jsonOutput:
+		return writeJSON(w, results)
+	case templateOutput:
+		return writeTemplate(w, results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIGEST\tGAV\tTYPE\tMATCH")
+	for _, r := range results {
+		if !r.Found {
+			fmt.Fprintf(tw, "%s\t-\t-\tnot found\n", r.Digest)
+			continue
+		}
+		match := string(r.MatchType)
+		if r.RemovedAt != nil {
+			match += fmt.Sprintf(" (removed %s)", r.RemovedAt.Format("2006-01-02"))
+		}
+		if r.Deprecated {
+			match += " (deprecated" + lo.Ternary(r.Replacement != "", ", use "+r.Replacement, "") + ")"
+		}
+		if len(r.Violations) > 0 {
+			match += " (violates " + strings.Join(lo.Map(r.Violations, func(v policy.Violation, _ int) string { return v.Rule }), ", ") + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s:%s:%s\t%s\t%s\n", r.Digest, r.GroupID, r.ArtifactID, r.Version, r.ArchiveType, match)
+	}
+	return tw.Flush()
+}
+
+// evaluatePolicy returns the rules of the policy matched by the artifact of the index.
+func evaluatePolicy(dbc db.DB, p *policy.Policy, index types.Index) ([]policy.Violation, error) {
+	subject := policy.Subject{
+		GroupID:    index.GroupID,
+		ArtifactID: index.ArtifactID,
+		Version:    index.Version,
+	}
+	if p.NeedsLabels() {
+		labels, err := dbc.SelectLabels(index.GroupID, index.ArtifactID)
+		if err != nil {
+			return nil, xerrors.Errorf("select labels error: %w", err)
+		}
+		subject.Labels = labels
+	}
+	return p.Evaluate(subject), nil
+}
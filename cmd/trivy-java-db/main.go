@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/h7hac9/trivy-java-db/pkg/status"
 	"github.com/h7hac9/trivy-java-db/pkg/types"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"golang.org/x/xerrors"
 
@@ -18,43 +23,679 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// knownArchiveTypes are the archive types crawled into index files.
+var knownArchiveTypes = []string{types.JarType, types.AarType, types.WarType, types.KlibType, types.PomType}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			log.Println(exitErr)
+			os.Exit(exitErr.code)
+		}
 		log.Fatalf("%+v", err)
 	}
 }
 
 var (
 	// Used for flags.
-	cacheDir string
-	limit    int
+	cacheDir      string
+	limit         int
+	artifactLimit int
+	md5           bool
+	signatures    bool
+	gradle        bool
+	poms          bool
+	httpCache     bool
+	maxBodySize   int64
+
+	coverageThreshold float64
+	coverageWarnOnly  bool
+	source            string
+	crawlGroups       []string
+	crawlShard        string
+	crawlMaxDuration  time.Duration
+	crawlMaxRequests  int64
+	outputFormat      string
+	failOnMiss        bool
+	asOf              int
+	fromGen           int
+	toGen             int
+	docsDir           string
+	dbDir             string
+	archiveType       string
+	groupID           string
+	scalaVersion      string
+	nsFile            string
+	liveCheck         bool
+	repoURL           string
+	history           bool
+	againstCache      bool
+	verifySample      int
+	convertFrom       string
+	convertTo         string
+	sqliteFile        string
+	labelFilter       []string
+	policyFile        string
+	templateText      string
+
+	releaseRepo   string
+	githubAPIURL  string
+	publicKey     string
+	skipSignature bool
+
+	// Used for build flags.
+	extraCacheDirs  []string
+	rankingFeed     string
+	popularityFeed  string
+	deprecationFeed string
+	postBuildCmds   []string
+	trivyLayout     bool
+	updateInterval  time.Duration
+	markRemoved     bool
+	forceBuild      bool
+	waitLock        bool
+	qualityReport   string
+	dedupWindow     int
+	archiveTypes    []string
+	maxVersions     int
+	downloadOutput  string
+	downloadRetries int
+	torrentTrackers []string
+	torrentWebSeeds []string
+	torrentOutput   string
+	gcExcludeGroups []string
+	gcRetention     time.Duration
+	gcDryRun        bool
+
+	// Used for enrich flags.
+	depsDevURL  string
+	enrichRate  int
+	enrichLimit int
+	enrichPOM   bool
+	pomRepoURL  string
 
 	// mysql config
 	dbConnectURL string
+	dbTimeout    time.Duration
+	dbRetries    int
+	dbReadURLs   []string
+	dbSchema     string
+	tablePrefix  string
+	utf8mb4      bool
+	mysqlCompat  bool
 	// sqlite config
-	dbPath string
+	dbPath          string
+	fts             bool
+	vacuumMode      string
+	busyTimeout     time.Duration
+	copyOnWrite     bool
+	flatSchema      bool
+	explain         bool
+	caseInsensitive bool
+	// connection pool
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+	// query instrumentation
+	slowQueryThreshold time.Duration
+	queryMetrics       string
 
 	rootCmd = &cobra.Command{
 		Use:   "trivy-java-db",
 		Short: "Build Java DB to store maven indexes",
+		// Errors are printed once by main, and usage only with --help, since most errors aren't caused by flags.
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOutputFormat(cmd, outputFormat); err != nil {
+				return err
+			}
+			return validateWebhookFlags()
+		},
 	}
 	crawlCmd = &cobra.Command{
 		Use:   "crawl",
 		Short: "Crawl maven indexes and save them into files",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return crawl(cmd.Context())
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			start := time.Now()
+			st := status.NewReporter(cacheDir, "crawl")
+			stats, err := crawl(cmd.Context(), st)
+			st.Finish(err)
+			notifyCompletion(cmd.Context(), "crawl", start, stats, err)
+			if err == nil && outputFormat == jsonOutput {
+				return writeJSON(cmd.OutOrStdout(), stats)
+			}
+			return err
 		},
 	}
 	buildCmd = &cobra.Command{
 		Use:   "build",
 		Short: "Build Java DB",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if updateInterval <= 0 {
+				return xerrors.Errorf("--update-interval must be positive: %s", updateInterval)
+			}
+			if maxVersions < 0 {
+				return xerrors.Errorf("--max-versions must not be negative: %d", maxVersions)
+			}
+			for _, t := range archiveTypes {
+				if !lo.Contains(knownArchiveTypes, t) {
+					return xerrors.Errorf("--archive-types must be in %s: %q", strings.Join(knownArchiveTypes, ", "), t)
+				}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var conf *types.DBConfig
+			var err error
+			if trivyLayout {
+				conf, err = trivyLayoutConfig()
+			} else {
+				conf, err = dbConfig()
+			}
+			if err != nil {
+				return err
+			}
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			start := time.Now()
+			st := status.NewReporter(cacheDir, "build")
+			stats, err := build(conf, st)
+			if err == nil && !stats.Skipped {
+				err = runPostBuildHooks(conf, stats)
+			}
+			if err == nil && !stats.Skipped {
+				err = publishChangeFeed(cmd.Context(), conf, stats)
+			}
+			st.Finish(err)
+			notifyCompletion(cmd.Context(), "build", start, stats, err)
+			if err == nil && outputFormat == jsonOutput {
+				return writeJSON(cmd.OutOrStdout(), stats)
+			}
+			return err
+		},
+	}
+	enrichCmd = &cobra.Command{
+		Use:   "enrich",
+		Short: "Store the default version and homepage of artifacts from deps.dev in the DB",
+		Long: `Store the default version and homepage of artifacts from deps.dev in the DB.
+Artifacts are stored as soon as they are fetched and skipped by later runs, so interrupted runs resume where they stopped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := dbConfig()
+			if err != nil {
+				return err
+			}
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			start := time.Now()
+			stats, err := enrichArtifacts(cmd.Context(), conf)
+			notifyCompletion(cmd.Context(), "enrich", start, stats, err)
+			if err == nil && outputFormat == jsonOutput {
+				return writeJSON(cmd.OutOrStdout(), stats)
+			}
+			return err
+		},
+	}
+	lookupCmd = &cobra.Command{
+		Use:   "lookup [digest]...",
+		Short: "Look up indexes by sha1 or md5 digests",
+		Long: `Look up indexes by sha1 or md5 digests.
+The algorithm is guessed from the digest length unless the digest is prefixed with it, e.g. 'sha1:<hex>'.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return lookup(cmd.OutOrStdout(), conf, args)
+		},
+		Annotations: map[string]string{formatsAnnotation: templateOutput},
+	}
+	artifactCmd = &cobra.Command{
+		Use:   "artifact [artifact id] [version]",
+		Short: "List indexes of all groups with the artifact id if the version exists for them",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return artifact(cmd.OutOrStdout(), conf, args[0], args[1], types.ArchiveType(archiveType))
+		},
+		Annotations: map[string]string{formatsAnnotation: templateOutput},
+	}
+	purlCmd = &cobra.Command{
+		Use:   "purl [purl]...",
+		Short: "Look up indexes by Maven package URLs",
+		Long: `Look up indexes by Maven package URLs, e.g. 'pkg:maven/androidx.core/core@1.12.0?type=aar'.
+The 'type' qualifier selects the archive type (default: jar) and 'classifier' the classified file.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return lookupPURLs(cmd.OutOrStdout(), conf, args)
+		},
+		Annotations: map[string]string{formatsAnnotation: templateOutput},
+	}
+	versionsCmd = &cobra.Command{
+		Use:   "versions [group id] [artifact id]",
+		Short: "List indexes of all versions of the artifact in Maven version order",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return versions(cmd.OutOrStdout(), conf, args[0], args[1])
+		},
+		Annotations: map[string]string{formatsAnnotation: templateOutput},
+	}
+	searchCmd = &cobra.Command{
+		Use:   "search [query]",
+		Short: "Search artifacts by group and artifact id (DB built with --fts)",
+		Long: `Search artifacts by group and artifact id with the full-text search index.
+The query uses the SQLite FTS5 syntax, e.g. "log4j", "apache AND logging" or "spring*".
+The DB must be built with --fts.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return search(cmd.OutOrStdout(), conf, args[0])
+		},
+		Annotations: map[string]string{formatsAnnotation: templateOutput + "," + csvOutput},
+	}
+	labelCmd = &cobra.Command{
+		Use:   "label",
+		Short: "Attach labels to artifacts, e.g. to keep an allowlist of approved artifacts",
+		Long: `Attach labels to artifacts, e.g. "approved", "banned" or "internal".
+Labels are stored in the DB and filter lookup and search with --label.`,
+	}
+	labelAddCmd = &cobra.Command{
+		Use:   "add [group id] [artifact id] [label]...",
+		Short: "Attach labels to the artifact",
+		Args:  cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := dbConfig()
+			if err != nil {
+				return err
+			}
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return addLabels(cmd.OutOrStdout(), conf, args[0], args[1], args[2:])
+		},
+	}
+	labelRmCmd = &cobra.Command{
+		Use:   "rm [group id] [artifact id] [label]...",
+		Short: "Detach labels from the artifact",
+		Args:  cobra.MinimumNArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := dbConfig()
+			if err != nil {
+				return err
+			}
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return removeLabels(cmd.OutOrStdout(), conf, args[0], args[1], args[2:])
+		},
+	}
+	labelLsCmd = &cobra.Command{
+		Use:   "ls [label]",
+		Short: "List the artifacts with the label, or all labeled artifacts",
+		Long: `List the artifacts with the label, or all labeled artifacts.
+Use -o json to export them, e.g. as the allowlist of approved artifacts.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			var label string
+			if len(args) > 0 {
+				label = args[0]
+			}
+			return listLabels(cmd.OutOrStdout(), conf, label)
+		},
+		Annotations: map[string]string{formatsAnnotation: csvOutput},
+	}
+	unsignedCmd = &cobra.Command{
+		Use:   "unsigned",
+		Short: "List indexes without a PGP signature (crawled with --signatures)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return unsigned(cmd.OutOrStdout(), conf, groupID)
+		},
+		Annotations: map[string]string{formatsAnnotation: templateOutput},
+	}
+	scalaCmd = &cobra.Command{
+		Use:   "scala [base artifact id]",
+		Short: "List Scala cross-built artifacts, e.g. cats-core_2.13 and cats-core_3 for cats-core",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return scalaArtifacts(cmd.OutOrStdout(), conf, groupID, args[0], scalaVersion)
+		},
+	}
+	collisionsCmd = &cobra.Command{
+		Use:   "collisions",
+		Short: "List sha1s shared by several GAVs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return collisions(cmd.OutOrStdout(), conf)
+		},
+	}
+	downloadCmd = &cobra.Command{
+		Use:   "download [url]",
+		Short: "Download a published DB over HTTP",
+		Long: `Download a published DB, e.g. the compressed DB of a build, over HTTP.
+Broken transfers are resumed with range requests, also by the next run after a failure, as long as the file didn't change.
+The download is skipped when the file was already downloaded with the current ETag.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return downloadDB(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	}
+	torrentCmd = &cobra.Command{
+		Use:   "torrent [file]",
+		Short: "Create a .torrent file of a built DB (experimental)",
+		Long: `Create a .torrent file of a built DB, e.g. the compressed DB, so that edge sites fetch it peer-to-peer with any BitTorrent client.
+Web seeds let clients download from the published DB when there are no seeders. The magnet link is printed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return createTorrent(cmd.OutOrStdout(), args[0])
+		},
+	}
+	compactCmd = &cobra.Command{
+		Use:   "compact",
+		Short: "Merge artifacts duplicated by id normalization of newer builds",
+		Long: `Merge artifacts stored by older builds with ids which builds now normalize, e.g. group ids with surrounding whitespace,
+into the artifact with normalized ids. Their indexes are moved to it, and artifacts without a normalized counterpart are renamed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := dbConfig()
+			if err != nil {
+				return err
+			}
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return compact(cmd.OutOrStdout(), conf)
+		},
+	}
+	verifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "List versions quarantined by the last build",
+		Long: `List versions quarantined by the last build, e.g. with sha1s of the wrong length or absurd version strings.
+Exits with code 5 when there are quarantined versions.
+
+With --against-cache, check instead that the versions of --sample randomly chosen index files of the cache dir are
+stored in the DB as cached, catching builds which silently drop or change rows. Exits with code 7 on mismatches.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if dbPath != "" {
-				return build(&types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: dbPath}})
-			} else if dbConnectURL != "" {
-				return build(&types.DBConfig{MysqlDBConfig: &types.MysqlDBConfig{DBConnectURL: dbConnectURL}})
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			if againstCache {
+				return verifyAgainstCache(cmd.OutOrStdout(), conf, verifySample)
+			}
+			return verify(cmd.OutOrStdout(), conf)
+		},
+	}
+	dbInfoCmd = &cobra.Command{
+		Use:   "db-info",
+		Short: "Show the schema version, build time and row counts stored in the DB",
+		Long: `Show the schema version, build time, vacuum time and row counts which builds store in the db_info table.
+Consumers with only the DB file, without metadata.json, can validate what they received with them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return showDBInfo(cmd.OutOrStdout(), conf)
+		},
+	}
+	convertCmd = &cobra.Command{
+		Use:   "convert",
+		Short: "Load a DB of another backend into the DB",
+		Long: `Load a DB of another backend into the DB.
+With --from sqlite --to mysql, the sqlite file at --sqlite-file is loaded into mysql. The file may be built by
+aquasecurity/trivy-java-db, which only stores GAVs, sha1s and archive types, or by this tool.
+With --from mysql --to sqlite, mysql is snapshotted into a new sqlite file at --sqlite-file, with metadata.json next to it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+
+			stats, err := convertDB(convertFrom, convertTo, sqliteFile)
+			if err == nil && outputFormat == jsonOutput {
+				return writeJSON(cmd.OutOrStdout(), stats)
 			}
-			return fmt.Errorf("must use --sqlite or --mysql")
+			return err
+		},
+	}
+	statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Show artifacts and indexes added per day and cache dir",
+		Long: `Show artifacts and indexes added by builds per day and cache dir.
+Days without new indexes are logged, since they usually mean that the crawler silently broke.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return dailyStats(cmd.OutOrStdout(), conf, history)
+		},
+		Annotations: map[string]string{formatsAnnotation: csvOutput},
+	}
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show what the crawl or build running in the cache dir is doing",
+		Long: `Show what the crawl or build running in the cache dir is doing.
+Crawls and builds write their phase and progress into status.json in the cache dir, so no log access is needed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printStatus(cmd.OutOrStdout())
+		},
+	}
+	conflictsCmd = &cobra.Command{
+		Use:   "conflicts",
+		Short: "List GAVs built with different sha1s from several cache dirs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return conflicts(cmd.OutOrStdout(), conf)
+		},
+	}
+	changelogCmd = &cobra.Command{
+		Use:   "changelog",
+		Short: "List groups, artifacts and versions added between two build generations",
+		Long: `List groups, artifacts and versions added between two build generations.
+Only additions are listed. Builds never delete indexes from the DB, so nothing can be removed between generations.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			return changelog(cmd.OutOrStdout(), conf, fromGen, toGen)
+		},
+		Annotations: map[string]string{formatsAnnotation: csvOutput},
+	}
+	checkFreshnessCmd = &cobra.Command{
+		Use:   "check-freshness",
+		Short: "Check if the DB is past NextUpdate in its metadata",
+		Long: fmt.Sprintf(`Check if the DB is past NextUpdate in its metadata.
+Exits with code %d if the DB is stale, so scanner wrappers and cron jobs can refresh it.`, exitCodeStale),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := dbDir
+			if dir == "" {
+				dir = filepath.Join(cacheDir, "db")
+			}
+			return checkFreshness(cmd.OutOrStdout(), dir, time.Now())
+		},
+	}
+	cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect the index files in the cache dir",
+	}
+	cacheLsCmd = &cobra.Command{
+		Use:   "ls",
+		Short: "List the groups in the cache dir with their number of artifacts and versions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listCache(cmd.OutOrStdout())
+		},
+	}
+	cacheShowCmd = &cobra.Command{
+		Use:   "show [group id]",
+		Short: "Show the versions in the index files of a group as build reads them",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showCache(cmd.OutOrStdout(), args[0])
+		},
+	}
+	cacheCheckCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check the index files in the cache dir for broken files",
+		Long: fmt.Sprintf(`Check the index files in the cache dir for empty or invalid files, files without versions,
+files at the wrong path, which crawls never update, and duplicate versions.
+Versions themselves are validated by build, see verify.
+Exits with code %d if any file is broken.`, exitCodeCache),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return checkCache(cmd.OutOrStdout())
+		},
+	}
+	cacheGCCmd = &cobra.Command{
+		Use:   "gc",
+		Short: "Remove index files of excluded groups, expired files and temp files from the cache dir",
+		Long: `Remove files which builds don't need from the cache dir and print the reclaimed space:
+index files of --exclude-group and their subgroups, index files and HTTP cache entries
+not written or used by crawls within --retention, and temp files left by interrupted crawls.
+The DB dir isn't touched.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unlock, err := lockCacheDir(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			return gcCache(cmd.OutOrStdout())
+		},
+	}
+	auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Audit artifacts for supply-chain risks",
+	}
+	auditConfusionCmd = &cobra.Command{
+		Use:   "confusion [group id...]",
+		Short: "Check if internal group ids are published on Maven Central",
+		Long: fmt.Sprintf(`Check if internal group ids (and their subgroups) are published on Maven Central.
+Published group ids are potential dependency-confusion targets.
+The DB (built from Maven Central) is checked by default, --live checks the repository instead.
+Exits with code %d if any group id is published.`, exitCodeConfusion),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespaces := args
+			if nsFile != "" {
+				ns, err := readNamespaces(nsFile)
+				if err != nil {
+					return err
+				}
+				namespaces = append(namespaces, ns...)
+			}
+			if len(namespaces) == 0 {
+				return xerrors.New("no group ids given, pass them as arguments or with --namespaces")
+			}
+
+			if liveCheck {
+				c := crawler.NewCrawler(crawler.Option{RootUrl: repoURL, Limit: 1, CacheDir: cacheDir})
+				return auditConfusion(cmd.OutOrStdout(), namespaces, liveNamespaceCheck(cmd.Context(), &c))
+			}
+
+			conf, err := readDBConfig()
+			if err != nil {
+				return err
+			}
+			dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+			if err != nil {
+				return xerrors.Errorf("db open error: %w", err)
+			}
+			defer dbc.Close()
+			return auditConfusion(cmd.OutOrStdout(), namespaces, dbNamespaceCheck(dbc))
+		},
+	}
+	versionCmd = &cobra.Command{
+		Use:   "version",
+		Short: "Print the version and build info",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printVersion(cmd.OutOrStdout())
+		},
+	}
+	selfUpdateCmd = &cobra.Command{
+		Use:   "self-update",
+		Short: "Replace the binary with the latest release",
+		Long: `Replace the binary with the latest release.
+The sha256 of the downloaded binary is checked against the checksums of the release, and the ed25519 signature of the checksums against the public key.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return selfUpdate(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+	genDocsCmd = &cobra.Command{
+		Use:    "gen-docs",
+		Short:  "Generate man pages",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return genDocs(docsDir)
 		},
 	}
 )
@@ -68,49 +709,473 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", filepath.Join(userCacheDir, "trivy-java-db"),
 		"cache dir")
 	rootCmd.PersistentFlags().IntVar(&limit, "limit", 1000, "max parallelism")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", tableOutput, "output format (table, json, template, csv)")
+	rootCmd.PersistentFlags().StringVar(&templateText, "template", "",
+		"Go template of -o template executed with the results of -o json, or @<file> to read it from the file (lookup, search, purl, artifact, versions and unsigned)")
+
+	addWebhookFlags(crawlCmd)
+	addLockFlags(crawlCmd)
+	crawlCmd.Flags().IntVar(&artifactLimit, "artifact-limit", 1, "max parallelism of checksum fetching in version dirs of one artifact")
+	crawlCmd.Flags().BoolVar(&md5, "md5", false, "also fetch md5 checksums of jars")
+	crawlCmd.Flags().StringVar(&source, "source", "",
+		fmt.Sprintf("crawl a registered custom source instead of Maven Central %v", crawler.Sources()))
+	crawlCmd.Flags().StringSliceVar(&crawlGroups, "group", nil,
+		"only crawl these group ids, keeping the index files of other groups. The crawl report isn't written")
+	crawlCmd.MarkFlagsMutuallyExclusive("group", "source")
+	crawlCmd.Flags().StringVar(&crawlShard, "shard", "",
+		"only crawl this part of the repository, e.g. 3/10 for the third of 10 disjoint shards. Merge the shard caches with build --extra-cache-dir")
+	crawlCmd.MarkFlagsMutuallyExclusive("shard", "group")
+	crawlCmd.MarkFlagsMutuallyExclusive("shard", "source")
+	crawlCmd.Flags().DurationVar(&crawlMaxDuration, "max-duration", 0,
+		"stop crawling after this time, save a checkpoint and resume from it in the next crawl (0 for no limit)")
+	crawlCmd.Flags().Int64Var(&crawlMaxRequests, "max-requests", 0,
+		"stop crawling after this number of HTTP requests, save a checkpoint and resume from it in the next crawl (0 for no limit)")
+	for _, budget := range []string{"max-duration", "max-requests"} {
+		crawlCmd.MarkFlagsMutuallyExclusive(budget, "group")
+		crawlCmd.MarkFlagsMutuallyExclusive(budget, "source")
+	}
+	crawlCmd.Flags().BoolVar(&signatures, "signatures", false, "fetch PGP signatures of jars to record signing keys")
+	crawlCmd.Flags().BoolVar(&gradle, "gradle-modules", false, "fetch Gradle module metadata to index variant jars listed there")
+	crawlCmd.Flags().BoolVar(&poms, "poms", false, "index poms of artifacts without jars, e.g. BOMs and parent poms")
+	crawlCmd.Flags().Float64Var(&coverageThreshold, "coverage-threshold", 0,
+		"fail when groups or artifacts drop by more than this percentage compared to the last complete crawl (0 disables the check)")
+	crawlCmd.Flags().BoolVar(&coverageWarnOnly, "coverage-warn-only", false, "only log drops over --coverage-threshold")
+	crawlCmd.Flags().Int64Var(&maxBodySize, "max-body-size", 64<<20, "max size of responses in bytes. Checksum files over 1 KiB are skipped as malformed")
+	crawlCmd.Flags().BoolVar(&httpCache, "http-cache", false, "store responses in the cache dir and send conditional requests in later crawls")
+
+	addDBFlags(buildCmd)
+	addLockFlags(buildCmd)
+	addWebhookFlags(buildCmd)
+	buildCmd.Flags().BoolVar(&fts, "fts", false, "build full-text search index over artifacts (sqlite only)")
+	addVacuumFlag(buildCmd)
+	buildCmd.Flags().BoolVar(&copyOnWrite, "copy-on-write", false,
+		"build into a copy of the sqlite DB and replace the DB with it when the build completes, so readers are never blocked")
+	buildCmd.MarkFlagsMutuallyExclusive("copy-on-write", "mysql")
+	buildCmd.Flags().StringSliceVar(&extraCacheDirs, "extra-cache-dir", nil,
+		"additional cache dirs to merge into the DB. Later dirs override --cache-dir and earlier dirs on sha1 conflict")
+	buildCmd.Flags().StringVar(&rankingFeed, "ranking-feed", "",
+		"CSV file with artifact priorities (group_id,artifact_id,priority) used to list canonical artifacts first")
+	buildCmd.Flags().StringVar(&popularityFeed, "popularity-feed", "",
+		"CSV file with download statistics (group_id,artifact_id,downloads)")
+	buildCmd.Flags().StringVar(&deprecationFeed, "deprecation-feed", "",
+		"CSV file with deprecated artifacts (group_id,artifact_id,replacement,reason). Replaces the deprecations of previous builds")
+	buildCmd.Flags().BoolVar(&trivyLayout, "trivy-layout", false,
+		"build a sqlite DB in <cache-dir>/db with the file layout Trivy expects for its java-db cache dir")
+	buildCmd.MarkFlagsMutuallyExclusive("trivy-layout", "mysql")
+	buildCmd.MarkFlagsMutuallyExclusive("trivy-layout", "sqlite")
+	buildCmd.MarkFlagsMutuallyExclusive("trivy-layout", "flat-schema")
+	buildCmd.Flags().DurationVar(&updateInterval, "update-interval", 72*time.Hour,
+		"time until the next scheduled build, written to NextUpdate in metadata.json. Consumers consider the DB stale after it")
+	buildCmd.Flags().BoolVar(&markRemoved, "mark-removed", false,
+		"mark indexes whose versions are no longer in the index files of their artifact as removed")
+	buildCmd.Flags().StringVar(&qualityReport, "quality-report", "",
+		"path of the report of upstream data anomalies found by the build (default: <cache-dir>/db/quality-report.json)")
+	buildCmd.Flags().IntVar(&dedupWindow, "dedup-window", 1000000,
+		"max number of inserted indexes remembered to skip duplicates from --extra-cache-dir before they reach the DB (0 to disable)")
+	buildCmd.Flags().StringSliceVar(&archiveTypes, "archive-types", nil,
+		"archive types stored in the DB, e.g. jar,aar (default: all)")
+	buildCmd.Flags().IntVar(&maxVersions, "max-versions", 0,
+		"max number of versions stored per artifact, keeping the newest in Maven version order (0 for all)")
+	buildCmd.Flags().BoolVar(&forceBuild, "force", false,
+		"build even if the cache dirs, feeds and options didn't change since the last build")
+	buildCmd.Flags().StringVar(&changeFeedPath, "change-feed", "",
+		"write the groups, artifacts and versions added by the build to this JSON file")
+	buildCmd.Flags().StringSliceVar(&changeFeedURLs, "change-feed-url", nil,
+		"URLs to POST the groups, artifacts and versions added by the build to")
+	buildCmd.Flags().StringArrayVar(&postBuildCmds, "post-build-cmd", nil,
+		"shell command to run after a successful build. Build metadata is passed in TRIVY_JAVA_DB_* environment variables")
+
+	addDBFlags(enrichCmd)
+	addWebhookFlags(enrichCmd)
+	addLockFlags(enrichCmd)
+	enrichCmd.Flags().StringVar(&depsDevURL, "deps-dev-url", "https://api.deps.dev", "root of the deps.dev API")
+	enrichCmd.Flags().IntVar(&enrichRate, "rate", 10, "max number of requests per second")
+	enrichCmd.Flags().IntVar(&enrichLimit, "limit", 0, "max number of artifacts enriched by this run (default: all)")
+	enrichCmd.Flags().BoolVar(&enrichPOM, "pom", false, "store the description and project url from the POM of the default version")
+	enrichCmd.Flags().StringVar(&pomRepoURL, "pom-repo-url", "https://repo.maven.apache.org/maven2", "root of the Maven repository POMs are fetched from with --pom")
+
+	addDBFlags(lookupCmd)
+	lookupCmd.Flags().IntVar(&asOf, "as-of", 0, "look up indexes as of the build generation (default: latest)")
+	lookupCmd.Flags().BoolVar(&failOnMiss, "fail-on-missing", false,
+		fmt.Sprintf("exit with code %d if any digest is not found", exitCodeMissing))
+
+	lookupCmd.Flags().StringSliceVar(&labelFilter, "label", nil, "only show indexes of artifacts with all these labels")
+	lookupCmd.Flags().StringVar(&policyFile, "policy", "",
+		fmt.Sprintf("YAML file with rules banning groups, artifacts, versions or labels. Exit with code %d if any found artifact matches a rule", exitCodePolicy))
+
+	addDBFlags(purlCmd)
+	purlCmd.Flags().IntVar(&asOf, "as-of", 0, "look up indexes as of the build generation (default: latest)")
+
+	addDBFlags(artifactCmd)
+	artifactCmd.Flags().IntVar(&asOf, "as-of", 0, "look up indexes as of the build generation (default: latest)")
+	artifactCmd.Flags().StringVar(&archiveType, "type", string(types.JarType), "archive type (jar, aar, war, klib, pom)")
+
+	addDBFlags(versionsCmd)
+	versionsCmd.Flags().IntVar(&asOf, "as-of", 0, "list versions as of the build generation (default: latest)")
+
+	addDBFlags(searchCmd)
+	searchCmd.Flags().StringSliceVar(&labelFilter, "label", nil, "only show artifacts with all these labels")
+
+	addDBFlags(labelAddCmd)
+	addLockFlags(labelAddCmd)
+	labelCmd.AddCommand(labelAddCmd)
+	addDBFlags(labelRmCmd)
+	addLockFlags(labelRmCmd)
+	labelCmd.AddCommand(labelRmCmd)
+	addDBFlags(labelLsCmd)
+	labelCmd.AddCommand(labelLsCmd)
+
+	addDBFlags(unsignedCmd)
+	unsignedCmd.Flags().StringVar(&groupID, "group", "", "only list indexes of the group (default: all groups)")
+	unsignedCmd.Flags().IntVar(&asOf, "as-of", 0, "list indexes as of the build generation (default: latest)")
+
+	addDBFlags(scalaCmd)
+	scalaCmd.Flags().StringVar(&groupID, "group", "", "only list artifacts of the group (default: all groups)")
+	scalaCmd.Flags().StringVar(&scalaVersion, "scala-version", "", "only list artifacts for the Scala binary version, e.g. 2.13 (default: all versions)")
+
+	addDBFlags(collisionsCmd)
+
+	addDBFlags(conflictsCmd)
+
+	addDBFlags(verifyCmd)
+	verifyCmd.Flags().BoolVar(&againstCache, "against-cache", false, "check that sampled versions of the cache dir are stored in the DB")
+	verifyCmd.Flags().IntVar(&verifySample, "sample", 1000, "number of index files checked with --against-cache, 0 for all")
+
+	downloadCmd.Flags().StringVar(&downloadOutput, "output", "", "path of the downloaded file (default: <cache-dir>/db/<file name of the url>)")
+	downloadCmd.Flags().IntVar(&downloadRetries, "retries", 5, "max number of times a broken transfer is resumed")
+
+	torrentCmd.Flags().StringSliceVar(&torrentTrackers, "tracker", nil, "announce URL of a tracker")
+	torrentCmd.Flags().StringSliceVar(&torrentWebSeeds, "web-seed", nil, "HTTP URL the file is published at")
+	torrentCmd.Flags().StringVar(&torrentOutput, "output", "", "path of the .torrent file (default: <file>.torrent)")
+
+	addDBFlags(compactCmd)
+	addVacuumFlag(compactCmd)
+	addLockFlags(compactCmd)
 
-	buildCmd.Flags().Bool("mysql", false, "use mysql db")
-	buildCmd.Flags().StringVar(&dbConnectURL, "db-connect-url", "", "database connect url")
-	buildCmd.MarkFlagsRequiredTogether("mysql", "db-connect-url")
+	addDBFlags(statsCmd)
 
-	buildCmd.Flags().Bool("sqlite", false, "use sqlite db")
-	buildCmd.Flags().StringVar(&dbPath, "db-path", "", "database path")
-	buildCmd.MarkFlagsRequiredTogether("sqlite", "db-path")
+	addMysqlFlags(convertCmd)
+	addLockFlags(convertCmd)
+	convertCmd.Flags().StringVar(&convertFrom, "from", sqliteBackend, "backend of the DB to load")
+	convertCmd.Flags().StringVar(&convertTo, "to", mysqlBackend, "backend of the DB to load into")
+	convertCmd.Flags().StringVar(&sqliteFile, "sqlite-file", "", "path of the sqlite DB")
+	convertCmd.Flags().DurationVar(&updateInterval, "update-interval", 72*time.Hour,
+		"time until the next scheduled conversion, written to NextUpdate in metadata.json")
 
-	buildCmd.MarkFlagsMutuallyExclusive("mysql", "sqlite")
+	addDBFlags(dbInfoCmd)
+	statsCmd.Flags().BoolVar(&history, "history", false, "show all days instead of the latest one")
+
+	addDBFlags(changelogCmd)
+	changelogCmd.Flags().IntVar(&fromGen, "from", 0, "build generation to compare from")
+	changelogCmd.Flags().IntVar(&toGen, "to", 0, "build generation to compare to (default: latest)")
+	_ = changelogCmd.MarkFlagRequired("from")
+
+	addDBFlags(auditConfusionCmd)
+	auditConfusionCmd.Flags().StringVar(&nsFile, "namespaces", "", "file with internal group ids, one per line")
+	auditConfusionCmd.Flags().BoolVar(&liveCheck, "live", false, "check the repository instead of the DB")
+	auditConfusionCmd.Flags().StringVar(&repoURL, "repo-url", "", "repository checked with --live (default: Maven Central)")
+	auditCmd.AddCommand(auditConfusionCmd)
+
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheCmd.AddCommand(cacheCheckCmd)
+	cacheGCCmd.Flags().StringSliceVar(&gcExcludeGroups, "exclude-group", nil, "remove index files of these group ids and their subgroups")
+	cacheGCCmd.Flags().DurationVar(&gcRetention, "retention", 0,
+		"remove index files and HTTP cache entries not written or used by crawls within this period (default: keep them)")
+	cacheGCCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "only print what would be removed")
+	addLockFlags(cacheGCCmd)
+	cacheCmd.AddCommand(cacheGCCmd)
+
+	checkFreshnessCmd.Flags().StringVar(&dbDir, "db-dir", "", "dir with metadata.json (default: <cache-dir>/db)")
+
+	selfUpdateCmd.Flags().StringVar(&releaseRepo, "repo", "h7hac9/trivy-java-db", "GitHub repository publishing the releases")
+	selfUpdateCmd.Flags().StringVar(&githubAPIURL, "github-api-url", "https://api.github.com", "GitHub API url, e.g. of a GitHub Enterprise mirror")
+	selfUpdateCmd.Flags().StringVar(&publicKey, "public-key", releasePublicKey, "base64 encoded ed25519 key verifying the checksums of releases")
+	selfUpdateCmd.Flags().BoolVar(&skipSignature, "skip-signature", false, "only verify checksums of releases when no public key is set")
+
+	genDocsCmd.Flags().StringVar(&docsDir, "dir", filepath.Join("docs", "man"), "output dir for man pages")
 
 	rootCmd.AddCommand(crawlCmd)
 	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(enrichCmd)
+	rootCmd.AddCommand(lookupCmd)
+	rootCmd.AddCommand(artifactCmd)
+	rootCmd.AddCommand(purlCmd)
+	rootCmd.AddCommand(versionsCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(labelCmd)
+	rootCmd.AddCommand(unsignedCmd)
+	rootCmd.AddCommand(scalaCmd)
+	rootCmd.AddCommand(collisionsCmd)
+	rootCmd.AddCommand(conflictsCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(dbInfoCmd)
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(compactCmd)
+	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(torrentCmd)
+	rootCmd.AddCommand(changelogCmd)
+	rootCmd.AddCommand(checkFreshnessCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
+	rootCmd.AddCommand(genDocsCmd)
+}
+
+func addDBFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("mysql", false, "use mysql db")
+	addMysqlFlags(cmd)
+	cmd.Flags().StringSliceVar(&dbReadURLs, "db-read-connect-url", nil, "mysql read replica connect urls. Lookups are spread across them")
+	cmd.MarkFlagsRequiredTogether("mysql", "db-connect-url")
+
+	cmd.Flags().Bool("sqlite", false, "use sqlite db")
+	cmd.Flags().StringVar(&dbPath, "db-path", "", "database path")
+	cmd.Flags().BoolVar(&flatSchema, "flat-schema", false, "use the denormalized schema with GAV and digests in one table (sqlite only)")
+	cmd.Flags().DurationVar(&busyTimeout, "db-busy-timeout", 5*time.Second, "how long sqlite queries wait for locks held by other processes (0 to fail immediately)")
+	cmd.MarkFlagsRequiredTogether("sqlite", "db-path")
+
+	cmd.MarkFlagsMutuallyExclusive("mysql", "sqlite")
+
+	cmd.Flags().BoolVar(&explain, "explain", false, "log query plans of lookups (debug)")
+	cmd.Flags().IntVar(&maxOpenConns, "db-max-open-conns", 0, "maximum number of open DB connections (default: unlimited)")
+	cmd.Flags().IntVar(&maxIdleConns, "db-max-idle-conns", 0, "maximum number of idle DB connections (default: 2)")
+	cmd.Flags().DurationVar(&connMaxLifetime, "db-conn-max-lifetime", 0, "maximum time a DB connection is reused (default: unlimited)")
+	cmd.Flags().DurationVar(&slowQueryThreshold, "slow-query-threshold", 0, "log DB calls taking at least this long (default: disabled)")
+	cmd.Flags().StringVar(&queryMetrics, "query-metrics", "", "write latency histograms of DB calls to the JSON file")
+	cmd.Flags().BoolVar(&caseInsensitive, "case-insensitive", false, "ignore the case of group and artifact ids in GAV lookups")
+}
+
+func addVacuumFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&vacuumMode, "vacuum", string(types.VacuumFull),
+		"how free pages of the sqlite DB are reclaimed: full (needs temp space of the DB size), incremental or none")
+}
+
+// addMysqlFlags adds the flags connecting to mysql and naming its tables.
+func addMysqlFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&dbConnectURL, "db-connect-url", "", "database connect url")
+	cmd.Flags().DurationVar(&dbTimeout, "db-timeout", 0, "mysql connect, read and write timeout (default: timeouts from --db-connect-url)")
+	cmd.Flags().IntVar(&dbRetries, "db-retries", 5, "number of mysql reconnect attempts on connection loss")
+	cmd.Flags().StringVar(&dbSchema, "db-schema", "", "mysql database of the tables (default: the database of --db-connect-url)")
+	cmd.Flags().StringVar(&tablePrefix, "table-prefix", "", "prefix of all mysql table names")
+	cmd.Flags().BoolVar(&mysqlCompat, "mysql-compat", false, "create mysql tables without foreign keys and blob prefix indexes for TiDB and SingleStore")
+	cmd.Flags().BoolVar(&utf8mb4, "mysql-utf8mb4", false, "use the utf8mb4 charset with the binary collation for mysql tables. Existing utf8 tables are converted by build")
 }
 
-func crawl(ctx context.Context) error {
+func dbConfig() (*types.DBConfig, error) {
+	conf := &types.DBConfig{SlowQueryThreshold: slowQueryThreshold, QueryMetricsPath: queryMetrics}
+	if dbPath != "" {
+		conf.SqliteDBConfig = &types.SqliteDBConfig{
+			DBPath:          dbPath,
+			FTS:             fts,
+			Flat:            flatSchema,
+			Explain:         explain,
+			AsOf:            asOf,
+			CaseInsensitive: caseInsensitive,
+			Vacuum:          types.VacuumMode(vacuumMode),
+			BusyTimeout:     busyTimeout,
+			MaxOpenConns:    maxOpenConns,
+			MaxIdleConns:    maxIdleConns,
+			ConnMaxLifetime: connMaxLifetime,
+		}
+		return conf, nil
+	} else if dbConnectURL != "" {
+		conf.MysqlDBConfig = mysqlConfig()
+		return conf, nil
+	}
+	return nil, fmt.Errorf("must use --sqlite or --mysql")
+}
+
+func mysqlConfig() *types.MysqlDBConfig {
+	return &types.MysqlDBConfig{
+		DBConnectURL:    dbConnectURL,
+		Timeout:         dbTimeout,
+		Retries:         dbRetries,
+		ReadConnectURLs: dbReadURLs,
+		Explain:         explain,
+		AsOf:            asOf,
+		CaseInsensitive: caseInsensitive,
+		Schema:          dbSchema,
+		TablePrefix:     tablePrefix,
+		UTF8MB4:         utf8mb4,
+		Compat:          mysqlCompat,
+		MaxOpenConns:    maxOpenConns,
+		MaxIdleConns:    maxIdleConns,
+		ConnMaxLifetime: connMaxLifetime,
+	}
+}
+
+// readDBConfig returns the config for commands that only read an existing DB.
+func readDBConfig() (*types.DBConfig, error) {
+	conf, err := dbConfig()
+	if err != nil {
+		return nil, err
+	}
+	if conf.SqliteDBConfig != nil {
+		conf.SqliteDBConfig.ReadOnly = true
+	}
+	return conf, nil
+}
+
+// trivyLayoutConfig returns the sqlite config to build `trivy-java.db` next to `metadata.json` in <cache-dir>/db.
+func trivyLayoutConfig() (*types.DBConfig, error) {
+	dbDir := filepath.Join(cacheDir, "db")
+	// Trivy expects a fresh DB, so generations of previous builds are not kept.
+	if err := db.Reset(dbDir); err != nil {
+		return nil, xerrors.Errorf("db reset error: %w", err)
+	}
+	return &types.DBConfig{
+		SqliteDBConfig:     &types.SqliteDBConfig{DBPath: db.Path(dbDir), FTS: fts, Vacuum: types.VacuumMode(vacuumMode)},
+		SlowQueryThreshold: slowQueryThreshold,
+		QueryMetricsPath:   queryMetrics,
+	}, nil
+}
+
+func crawl(ctx context.Context, st *status.Reporter) (crawler.Stats, error) {
+	shard, shards, err := parseShard(crawlShard)
+	if err != nil {
+		return crawler.Stats{}, err
+	}
 	c := crawler.NewCrawler(crawler.Option{
-		Limit:    int64(limit),
-		CacheDir: cacheDir,
+		Limit:         int64(limit),
+		ArtifactLimit: int64(artifactLimit),
+		CacheDir:      cacheDir,
+		MD5:           md5,
+		Signatures:    signatures,
+		GradleModules: gradle,
+		Poms:          poms,
+		HTTPCache:     httpCache,
+		MaxBodySize:   maxBodySize,
+
+		CoverageThreshold: coverageThreshold,
+		CoverageWarnOnly:  coverageWarnOnly,
+
+		Status:      st,
+		Shard:       shard,
+		Shards:      shards,
+		MaxDuration: crawlMaxDuration,
+		MaxRequests: crawlMaxRequests,
 	})
+	if source != "" {
+		src, err := crawler.LookupSource(source)
+		if err != nil {
+			return crawler.Stats{}, err
+		}
+		if err = c.CrawlSource(ctx, src); err != nil {
+			return c.Stats(), xerrors.Errorf("crawl error: %w", err)
+		}
+		return c.Stats(), nil
+	}
+	if len(crawlGroups) > 0 {
+		if err := c.CrawlGroups(ctx, crawlGroups); err != nil {
+			return c.Stats(), xerrors.Errorf("crawl error: %w", err)
+		}
+		return c.Stats(), nil
+	}
 	if err := c.Crawl(ctx); err != nil {
-		return xerrors.Errorf("crawl error: %w", err)
+		return c.Stats(), xerrors.Errorf("crawl error: %w", err)
 	}
-	return nil
+	return c.Stats(), nil
 }
 
-func build(conf *types.DBConfig) error {
-	if err := db.Reset(cacheDir); err != nil {
-		return xerrors.Errorf("db reset error: %w", err)
+// parseShard parses `--shard`, e.g. `3/10`. It returns zeros for an empty value.
+func parseShard(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	var shard, shards int
+	if _, err := fmt.Sscanf(s, "%d/%d", &shard, &shards); err != nil || fmt.Sprintf("%d/%d", shard, shards) != s {
+		return 0, 0, xerrors.Errorf("--shard must be <shard>/<shards>, e.g. 3/10: %q", s)
 	}
+	if shards < 1 || shard < 1 || shard > shards {
+		return 0, 0, xerrors.Errorf("--shard must be between 1/%d and %d/%d: %q", shards, shards, shards, s)
+	}
+	return shard, shards, nil
+}
+
+func build(conf *types.DBConfig, st *status.Reporter) (builder.Stats, error) {
 	dbDir := filepath.Join(cacheDir, "db")
+	schemaVersion := db.SchemaVersion
+	if conf.SqliteDBConfig != nil && conf.SqliteDBConfig.Flat {
+		// Trivy can't read the flat schema, so it must not accept the DB
+		schemaVersion = db.FlatSchemaVersion
+	}
+	opt := builder.Option{
+		RankingFeed:     rankingFeed,
+		PopularityFeed:  popularityFeed,
+		DeprecationFeed: deprecationFeed,
+		SchemaVersion:   schemaVersion,
+		UpdateInterval:  updateInterval,
+		MarkRemoved:     markRemoved,
+		Status:          st,
+		QualityReport:   qualityReport,
+		DedupWindow:     dedupWindow,
+		ArchiveTypes:    lo.Map(archiveTypes, func(t string, _ int) types.ArchiveType { return types.ArchiveType(t) }),
+		MaxVersions:     maxVersions,
+	}
+	if opt.QualityReport == "" {
+		opt.QualityReport = filepath.Join(dbDir, "quality-report.json")
+	}
+	cacheDirs := append([]string{cacheDir}, extraCacheDirs...)
+	if !forceBuild {
+		unchanged, err := cacheUnchanged(conf, dbDir, opt, cacheDirs)
+		if err != nil {
+			return builder.Stats{}, err
+		} else if unchanged {
+			log.Println("The cache didn't change since the last build, skipping the build. Use --force to build anyway")
+			return builder.Stats{Skipped: true}, nil
+		}
+	}
+
+	if err := db.Reset(cacheDir); err != nil {
+		return builder.Stats{}, xerrors.Errorf("db reset error: %w", err)
+	}
+	publish := func() error { return nil }
+	if copyOnWrite && conf.SqliteDBConfig != nil {
+		var err error
+		if publish, err = db.StageSqlite(conf.SqliteDBConfig); err != nil {
+			return builder.Stats{}, xerrors.Errorf("db copy error: %w", err)
+		}
+	}
 	log.Printf("Database path: %s", dbDir)
 	dbc, err := db.New(dbDir, conf)
 	if err != nil {
-		return xerrors.Errorf("db create error: %w", err)
+		return builder.Stats{}, xerrors.Errorf("db create error: %w", err)
 	}
+	defer dbc.Close()
+
 	if err = dbc.Init(); err != nil {
-		return xerrors.Errorf("db init error: %w", err)
+		return builder.Stats{}, xerrors.Errorf("db init error: %w", err)
 	}
+	b := builder.NewBuilder(dbc, db.NewMetadata(dbDir), opt)
+	if err = b.Build(cacheDirs...); err != nil {
+		return b.Stats(), xerrors.Errorf("db build error: %w", err)
+	}
+	if err = dbc.Close(); err != nil {
+		return b.Stats(), xerrors.Errorf("db close error: %w", err)
+	}
+	return b.Stats(), publish()
+}
+
+// cacheUnchanged reports whether the last build in `dbDir` was built from the same cache dirs, feeds and options.
+// Sqlite DBs must still exist, since a build with an unchanged cache would only recreate them.
+func cacheUnchanged(conf *types.DBConfig, dbDir string, opt builder.Option, cacheDirs []string) (bool, error) {
 	meta := db.NewMetadata(dbDir)
-	b := builder.NewBuilder(dbc, meta)
-	if err = b.Build(cacheDir); err != nil {
-		return xerrors.Errorf("db build error: %w", err)
+	metadata, err := meta.Get()
+	if err != nil || metadata.CacheDigest == "" {
+		// No previous build
+		return false, nil
+	}
+	if conf.SqliteDBConfig != nil {
+		if _, err = os.Stat(conf.SqliteDBConfig.DBPath); err != nil {
+			return false, nil
+		}
+	}
+	digest, err := builder.CacheDigest(opt, cacheDirs...)
+	if err != nil {
+		return false, xerrors.Errorf("cache digest error: %w", err)
 	}
-	return nil
+	return digest == metadata.CacheDigest, nil
 }
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+
+	_ "modernc.org/sqlite"
+)
+
+// writeTestIndex writes one index into a new cache dir and sets it as --cache-dir.
+func writeTestIndex(t *testing.T) {
+	cacheDir = t.TempDir()
+	err := fileutil.WriteJSON(filepath.Join(cacheDir, "indexes", "jstl", "jstl.json"), crawler.Index{
+		GroupID:     "jstl",
+		ArtifactID:  "jstl",
+		Versions:    []crawler.Version{{Version: "1.0", SHA1: []byte("01234567890123456789")}},
+		ArchiveType: types.JarType,
+	})
+	require.NoError(t, err)
+}
+
+func TestBuildTrivyLayout(t *testing.T) {
+	writeTestIndex(t)
+
+	conf, err := trivyLayoutConfig()
+	require.NoError(t, err)
+	_, err = build(conf, nil)
+	require.NoError(t, err)
+
+	// Trivy reads both files from its java-db cache dir
+	for _, name := range []string{"trivy-java.db", "metadata.json"} {
+		_, err = os.Stat(filepath.Join(cacheDir, "db", name))
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestBuildFlatSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		flat bool
+		want int
+	}{
+		{name: "normalized", flat: false, want: db.SchemaVersion},
+		{name: "flat", flat: true, want: db.FlatSchemaVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeTestIndex(t)
+			conf := &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{
+				DBPath: filepath.Join(t.TempDir(), "trivy-java.db"),
+				Flat:   tt.flat,
+			}}
+			_, err := build(conf, nil)
+			require.NoError(t, err)
+
+			client := db.NewMetadata(filepath.Join(cacheDir, "db"))
+			meta, err := client.Get()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, meta.Version)
+		})
+	}
+}
+
+func TestBuildSkipsUnchangedCache(t *testing.T) {
+	writeTestIndex(t)
+	t.Cleanup(func() { forceBuild = false })
+	conf := &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: filepath.Join(t.TempDir(), "trivy-java.db")}}
+
+	stats, err := build(conf, nil)
+	require.NoError(t, err)
+	assert.False(t, stats.Skipped)
+
+	stats, err = build(conf, nil)
+	require.NoError(t, err)
+	assert.True(t, stats.Skipped)
+
+	forceBuild = true
+	stats, err = build(conf, nil)
+	require.NoError(t, err)
+	assert.False(t, stats.Skipped)
+	forceBuild = false
+
+	// A missing DB is rebuilt
+	require.NoError(t, os.Remove(conf.SqliteDBConfig.DBPath))
+	stats, err = build(conf, nil)
+	require.NoError(t, err)
+	assert.False(t, stats.Skipped)
+
+	// A changed cache is rebuilt
+	require.NoError(t, fileutil.WriteJSON(filepath.Join(cacheDir, "indexes", "junit", "junit.json"), crawler.Index{
+		GroupID:     "junit",
+		ArtifactID:  "junit",
+		Versions:    []crawler.Version{{Version: "4.13", SHA1: []byte("98765432109876543210")}},
+		ArchiveType: types.JarType,
+	}))
+	stats, err = build(conf, nil)
+	require.NoError(t, err)
+	assert.False(t, stats.Skipped)
+}
+
+func TestTemplateOutput(t *testing.T) {
+	t.Cleanup(func() { templateText = "" })
+
+	templateText = ""
+	assert.ErrorContains(t, validateOutputFormat(lookupCmd, templateOutput), "--template is required")
+
+	templateText = "{{range .}}{{.GroupID}}:{{.ArtifactID}}:{{.Version}} {{join .Labels \",\"}}\n{{end}}"
+	assert.ErrorContains(t, validateOutputFormat(statsCmd, templateOutput), "doesn't support")
+	require.NoError(t, validateOutputFormat(lookupCmd, templateOutput))
+
+	var sb strings.Builder
+	results := []struct {
+		GroupID, ArtifactID, Version string
+		Labels                       []string
+	}{
+		{GroupID: "jstl", ArtifactID: "jstl", Version: "1.2", Labels: []string{"approved", "internal"}},
+	}
+	require.NoError(t, writeTemplate(&sb, results))
+	assert.Equal(t, "jstl:jstl:1.2 approved,internal\n", sb.String())
+
+	templateFile := filepath.Join(t.TempDir(), "output.tmpl")
+	require.NoError(t, os.WriteFile(templateFile, []byte("{{len .}} results"), 0o600))
+	templateText = "@" + templateFile
+	require.NoError(t, validateOutputFormat(searchCmd, templateOutput))
+	sb.Reset()
+	require.NoError(t, writeTemplate(&sb, results))
+	assert.Equal(t, "1 results", sb.String())
+}
+
+func TestCSVOutput(t *testing.T) {
+	require.NoError(t, validateOutputFormat(statsCmd, csvOutput))
+	assert.ErrorContains(t, validateOutputFormat(lookupCmd, csvOutput), "doesn't support")
+
+	var sb strings.Builder
+	require.NoError(t, writeCSV(&sb, []string{"group_id", "description"}, [][]string{
+		{"jstl", "JSP Standard Tag Library, \"JSTL\""},
+		{"io.netty", "line\nbreak"},
+	}))
+	assert.Equal(t, "group_id,description\njstl,\"JSP Standard Tag Library, \"\"JSTL\"\"\"\nio.netty,\"line\nbreak\"\n", sb.String())
+}
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		in      string
+		shard   int
+		shards  int
+		wantErr bool
+	}{
+		{in: ""},
+		{in: "3/10", shard: 3, shards: 10},
+		{in: "1/1", shard: 1, shards: 1},
+		{in: "0/10", wantErr: true},
+		{in: "11/10", wantErr: true},
+		{in: "3/", wantErr: true},
+		{in: "3/10x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			shard, shards, err := parseShard(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.shard, shard)
+			assert.Equal(t, tt.shards, shards)
+		})
+	}
+}
+
+func TestPublishChangeFeed(t *testing.T) {
+	writeTestIndex(t)
+	conf := &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: filepath.Join(t.TempDir(), "trivy-java.db")}}
+
+	var posted []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted, _ = io.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+	changeFeedPath = filepath.Join(t.TempDir(), "change-feed.json")
+	changeFeedURLs = []string{ts.URL}
+	t.Cleanup(func() {
+		changeFeedPath = ""
+		changeFeedURLs = nil
+	})
+
+	// The first build has no feed
+	stats, err := build(conf, nil)
+	require.NoError(t, err)
+	require.NoError(t, publishChangeFeed(context.Background(), conf, stats))
+	assert.NoFileExists(t, changeFeedPath)
+
+	require.NoError(t, fileutil.WriteJSON(filepath.Join(cacheDir, "indexes", "junit", "junit.json"), crawler.Index{
+		GroupID:     "junit",
+		ArtifactID:  "junit",
+		Versions:    []crawler.Version{{Version: "4.13", SHA1: []byte("98765432109876543210")}},
+		ArchiveType: types.JarType,
+	}))
+	stats, err = build(conf, nil)
+	require.NoError(t, err)
+	require.NoError(t, publishChangeFeed(context.Background(), conf, stats))
+
+	want := `{
+		"Generation": 2,
+		"NewGroups": ["junit"],
+		"NewArtifacts": ["junit:junit"],
+		"NewVersions": [{"GAV": "junit:junit:4.13", "SHA1": "3938373635343332313039383736353433323130", "ArchiveType": "jar"}]
+	}`
+	got, err := os.ReadFile(changeFeedPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, want, string(got))
+	assert.JSONEq(t, want, string(posted))
+}
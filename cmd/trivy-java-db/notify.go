@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/notify"
+)
+
+var (
+	webhookURLs   []string
+	webhookFormat string
+)
+
+func addWebhookFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&webhookURLs, "webhook-url", nil, "URLs to POST a summary to when the command completes")
+	cmd.Flags().StringVar(&webhookFormat, "webhook-format", string(notify.JSONFormat), "webhook payload format (json, slack)")
+}
+
+// validateWebhookFlags checks webhook flags before the command runs, since notifications are only sent at the end.
+func validateWebhookFlags() error {
+	switch notify.Format(webhookFormat) {
+	case notify.JSONFormat, notify.SlackFormat:
+	default:
+		return xerrors.Errorf("unknown webhook format: %q", webhookFormat)
+	}
+	for _, webhookURL := range webhookURLs {
+		if !isHTTPURL(webhookURL) {
+			return xerrors.Errorf("invalid webhook URL: %q", webhookURL)
+		}
+	}
+	for _, feedURL := range changeFeedURLs {
+		if !isHTTPURL(feedURL) {
+			return xerrors.Errorf("invalid change feed URL: %q", feedURL)
+		}
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// notifyCompletion sends the command result to webhooks. Webhook errors are only logged so that they don't hide the command result.
+func notifyCompletion(ctx context.Context, command string, start time.Time, stats any, err error) {
+	n := notify.NewNotifier(notify.Option{
+		URLs:   webhookURLs,
+		Format: notify.Format(webhookFormat),
+	})
+	if nerr := n.Notify(ctx, notify.NewEvent(command, start, stats, err)); nerr != nil {
+		log.Printf("Notification error: %s", nerr)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWebhookFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		urls      []string
+		format    string
+		assertErr assert.ErrorAssertionFunc
+	}{
+		{name: "no webhooks", format: "json", assertErr: assert.NoError},
+		{name: "slack", urls: []string{"https://hooks.slack.com/services/T0/B0/x"}, format: "slack", assertErr: assert.NoError},
+		{name: "unknown format", format: "xml", assertErr: assert.Error},
+		{name: "missing scheme", urls: []string{"example.com/hook"}, format: "json", assertErr: assert.Error},
+		{name: "unsupported scheme", urls: []string{"ftp://example.com/hook"}, format: "json", assertErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhookURLs, webhookFormat = tt.urls, tt.format
+			t.Cleanup(func() { webhookURLs, webhookFormat = nil, "json" })
+			tt.assertErr(t, validateWebhookFlags())
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+const (
+	tableOutput    = "table"
+	jsonOutput     = "json"
+	templateOutput = "template"
+	csvOutput      = "csv"
+
+	// formatsAnnotation lists the output formats supported by a command besides table and json, e.g. "template,csv".
+	formatsAnnotation = "output-formats"
+)
+
+// outputTemplate is the parsed --template of `-o template`.
+var outputTemplate *template.Template
+
+func validateOutputFormat(cmd *cobra.Command, format string) error {
+	switch format {
+	case tableOutput, jsonOutput:
+		return nil
+	case templateOutput, csvOutput:
+		if !lo.Contains(strings.Split(cmd.Annotations[formatsAnnotation], ","), format) {
+			return xerrors.Errorf("%s doesn't support the %q output format", cmd.CommandPath(), format)
+		}
+		if format == templateOutput {
+			return parseOutputTemplate(templateText)
+		}
+		return nil
+	}
+	return xerrors.Errorf("unknown output format: %q", format)
+}
+
+// parseOutputTemplate parses the Go template of `-o template`. Templates prefixed with `@` are read from the file.
+func parseOutputTemplate(text string) error {
+	if text == "" {
+		return xerrors.New("--template is required with -o template")
+	}
+	if strings.HasPrefix(text, "@") {
+		b, err := os.ReadFile(strings.TrimPrefix(text, "@"))
+		if err != nil {
+			return xerrors.Errorf("unable to read the template file: %w", err)
+		}
+		text = string(b)
+	}
+	t, err := template.New("output").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return xerrors.Errorf("template parse error: %w", err)
+	}
+	outputTemplate = t
+	return nil
+}
+
+// writeTemplate executes the template of `-o template` with the results, which have the fields of `-o json`.
+func writeTemplate(w io.Writer, v any) error {
+	if err := outputTemplate.Execute(w, v); err != nil {
+		return xerrors.Errorf("template execute error: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes `v` as indented JSON.
+// Field names of the written structs are part of the CLI contract and must not be renamed.
+func writeJSON(w io.Writer, v any) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	if err := e.Encode(v); err != nil {
+		return xerrors.Errorf("json encode error: %w", err)
+	}
+	return nil
+}
+
+// writeCSV writes the header and rows as RFC 4180 CSV, quoting fields with commas, quotes or line breaks.
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return xerrors.Errorf("csv write error: %w", err)
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return xerrors.Errorf("csv write error: %w", err)
+	}
+	return nil
+}
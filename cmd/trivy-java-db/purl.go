@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/purl"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// lookupPURLs lists the indexes of Maven package URLs. The `type` and `classifier` qualifiers select the file.
+func lookupPURLs(w io.Writer, conf *types.DBConfig, purls []string) error {
+	var pkgs []purl.PackageURL
+	for _, s := range purls {
+		p, err := purl.Parse(s)
+		if err != nil {
+			return xerrors.Errorf("purl parse error: %w", err)
+		}
+		if p.Version == "" {
+			return xerrors.Errorf("purl has no version: %s", s)
+		}
+		pkgs = append(pkgs, p)
+	}
+
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	indexes, err := dbc.SelectIndexesByGAVs(lo.Map(pkgs, func(p purl.PackageURL, _ int) types.GAV {
+		return p.GAV()
+	}))
+	if err != nil {
+		return xerrors.Errorf("purl lookup error: %w", err)
+	}
+	return writeIndexes(w, lo.Filter(indexes, func(index types.Index, _ int) bool {
+		return lo.SomeBy(pkgs, func(p purl.PackageURL) bool {
+			return p.Matches(index)
+		})
+	}))
+}
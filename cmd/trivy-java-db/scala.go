@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func scalaArtifacts(w io.Writer, conf *types.DBConfig, groupID, baseArtifactID, scalaVersion string) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	artifacts, err := dbc.SelectScalaArtifacts(groupID, baseArtifactID, scalaVersion)
+	if err != nil {
+		return xerrors.Errorf("scala artifacts error: %w", err)
+	}
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, artifacts)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP ID\tARTIFACT ID\tSCALA\tDOWNLOADS")
+	for _, a := range artifacts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", a.GroupID, a.ArtifactID, a.ScalaVersion, a.Downloads)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func search(w io.Writer, conf *types.DBConfig, query string) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	artifacts, err := dbc.SearchArtifactsFTS(query)
+	if err != nil {
+		return xerrors.Errorf("search error: %w", err)
+	}
+	if len(labelFilter) > 0 {
+		var labeled []types.Artifact
+		for _, a := range artifacts {
+			ok, err := hasLabels(dbc, a.GroupID, a.ArtifactID, labelFilter)
+			if err != nil {
+				return err
+			}
+			if ok {
+				labeled = append(labeled, a)
+			}
+		}
+		artifacts = labeled
+	}
+
+	switch outputFormat {
+	case jsonOutput:
+		return writeJSON(w, artifacts)
+	case templateOutput:
+		return writeTemplate(w, artifacts)
+	case csvOutput:
+		return writeCSV(w, []string{"group_id", "artifact_id", "downloads", "url", "description"},
+			lo.Map(artifacts, func(a types.Artifact, _ int) []string {
+				return []string{a.GroupID, a.ArtifactID, strconv.FormatInt(a.Downloads, 10), a.ProjectURL, a.Description}
+			}))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP ID\tARTIFACT ID\tDOWNLOADS\tURL\tDESCRIPTION")
+	for _, a := range artifacts {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", a.GroupID, a.ArtifactID, a.Downloads, a.ProjectURL, truncate(a.Description, 80))
+	}
+	return tw.Flush()
+}
+
+// truncate shortens `s` to `n` runes, so that long descriptions don't break the table.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/selfupdate"
+)
+
+// releasePublicKey is the base64 encoded ed25519 key signing the checksums of releases.
+// It's set by the linker, e.g. `-ldflags "-X main.releasePublicKey=..."`.
+var releasePublicKey = ""
+
+// selfUpdate replaces the running binary with the binary of the latest release.
+func selfUpdate(ctx context.Context, w io.Writer) error {
+	opt := selfupdate.Option{
+		APIURL: githubAPIURL,
+		Repo:   releaseRepo,
+	}
+	switch {
+	case publicKey != "":
+		key, err := selfupdate.ParsePublicKey(publicKey)
+		if err != nil {
+			return xerrors.Errorf("public key error: %w", err)
+		}
+		opt.PublicKey = key
+	case !skipSignature:
+		return xerrors.New("no public key to verify releases, set --public-key or --skip-signature")
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return xerrors.Errorf("executable path error: %w", err)
+	}
+	if exePath, err = filepath.EvalSymlinks(exePath); err != nil {
+		return xerrors.Errorf("executable path error: %w", err)
+	}
+
+	release, err := selfupdate.NewUpdater(opt).Update(ctx, version, exePath)
+	if err != nil {
+		return xerrors.Errorf("self-update error: %w", err)
+	}
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, release)
+	}
+	if !release.Updated {
+		fmt.Fprintf(w, "%s is the latest version\n", release.Version)
+		return nil
+	}
+	fmt.Fprintf(w, "Updated %s from %s to %s (sha256 %s)\n", exePath, version, release.Version, release.SHA256)
+	return nil
+}
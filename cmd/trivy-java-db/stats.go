@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// dailyStats prints artifacts and indexes added per day and repository.
+// Only the latest day is printed unless `history` is set.
+func dailyStats(w io.Writer, conf *types.DBConfig, history bool) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	stats, err := dbc.SelectDailyStats()
+	if err != nil {
+		return xerrors.Errorf("daily stats error: %w", err)
+	}
+	if !history && len(stats) > 0 {
+		latest := stats[len(stats)-1].Day
+		stats = lo.Filter(stats, func(s types.DailyStats, _ int) bool { return s.Day == latest })
+	}
+	for _, s := range stats {
+		if s.NewIndexes == 0 {
+			log.Printf("No new indexes from %s on %s, check the crawler", s.Repository, s.Day)
+		}
+	}
+
+	switch outputFormat {
+	case jsonOutput:
+		return writeJSON(w, stats)
+	case csvOutput:
+		return writeCSV(w, []string{"day", "repository", "new_artifacts", "new_indexes"},
+			lo.Map(stats, func(s types.DailyStats, _ int) []string {
+				return []string{s.Day, s.Repository, strconv.Itoa(s.NewArtifacts), strconv.Itoa(s.NewIndexes)}
+			}))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DAY\tREPOSITORY\tNEW ARTIFACTS\tNEW INDEXES")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\n", s.Day, s.Repository, s.NewArtifacts, s.NewIndexes)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/status"
+)
+
+// printStatus prints the status of the last crawl or build in the cache dir.
+func printStatus(w io.Writer) error {
+	s, err := status.Read(cacheDir)
+	if err != nil {
+		return xerrors.Errorf("status error: %w", err)
+	}
+	if outputFormat == jsonOutput {
+		return writeJSON(w, s)
+	}
+
+	if s.Command == "" {
+		_, err = fmt.Fprintln(w, "Idle, nothing ran in the cache dir")
+		return err
+	}
+	if s.Phase == status.Idle {
+		result := "succeeded"
+		if s.Error != "" {
+			result = "failed: " + s.Error
+		}
+		_, err = fmt.Fprintf(w, "Idle, the last %s %s at %s\n", s.Command, result, s.UpdatedAt.Format(time.RFC3339))
+		return err
+	}
+
+	progress := ""
+	switch {
+	case s.Total > 0:
+		progress = fmt.Sprintf(" %d of %d", s.Done, s.Total)
+	case s.Done > 0:
+		progress = fmt.Sprintf(" %d", s.Done)
+	}
+	detail := ""
+	if s.Detail != "" {
+		detail = fmt.Sprintf(" (%s)", s.Detail)
+	}
+	_, err = fmt.Fprintf(w, "%s (pid %d): %s%s%s, started at %s, updated at %s\n", s.Command, s.PID, s.Phase, progress, detail,
+		s.StartedAt.Format(time.RFC3339), s.UpdatedAt.Format(time.RFC3339))
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/torrent"
+)
+
+type torrentResult struct {
+	torrent.Torrent
+	Path string
+}
+
+// createTorrent writes the .torrent file of `filePath`, e.g. the compressed DB, for peer-to-peer distribution.
+func createTorrent(w io.Writer, filePath string) error {
+	t, err := torrent.Create(filePath, torrent.Option{
+		Trackers:     torrentTrackers,
+		WebSeeds:     torrentWebSeeds,
+		CreatedBy:    "trivy-java-db " + version,
+		CreationDate: time.Now(),
+	})
+	if err != nil {
+		return xerrors.Errorf("torrent error: %w", err)
+	}
+
+	out := torrentOutput
+	if out == "" {
+		out = filePath + ".torrent"
+	}
+	if err = os.WriteFile(out, t.MetaInfo, 0644); err != nil {
+		return xerrors.Errorf("unable to write %s: %w", out, err)
+	}
+
+	if outputFormat == jsonOutput {
+		return writeJSON(w, torrentResult{Torrent: t, Path: out})
+	}
+	fmt.Fprintf(w, "Wrote %s\n%s\n", out, t.Magnet)
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func unsigned(w io.Writer, conf *types.DBConfig, groupID string) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	indexes, err := dbc.SelectUnsignedArtifacts(groupID)
+	if err != nil {
+		return xerrors.Errorf("unsigned artifacts error: %w", err)
+	}
+	return writeIndexes(w, indexes)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/builder"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type quarantineResult struct {
+	GAV        string
+	Repository string
+	Reason     string
+}
+
+// verify reports versions quarantined by the last build.
+// It returns exitCodeQuarantine when there are any, so CI jobs can gate on them.
+func verify(w io.Writer, conf *types.DBConfig) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	quarantined, err := dbc.SelectQuarantine()
+	if err != nil {
+		return xerrors.Errorf("quarantine error: %w", err)
+	}
+
+	results := lo.Map(quarantined, func(index types.QuarantinedIndex, _ int) quarantineResult {
+		return quarantineResult{
+			GAV:        fmt.Sprintf("%s:%s:%s", index.GroupID, index.ArtifactID, index.Version),
+			Repository: index.Repository,
+			Reason:     index.Reason,
+		}
+	})
+
+	if outputFormat == jsonOutput {
+		if err = writeJSON(w, results); err != nil {
+			return err
+		}
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "GAV\tREPOSITORY\tREASON")
+		for _, r := range results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r.GAV, r.Repository, r.Reason)
+		}
+		if err = tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(results) > 0 {
+		return &exitError{code: exitCodeQuarantine, msg: fmt.Sprintf("%d quarantined versions", len(results))}
+	}
+	return nil
+}
+
+// verifyAgainstCache checks that the versions of `sample` random index files of the cache dir are stored in the DB.
+// It returns exitCodeMismatch when any of them is missing or different.
+func verifyAgainstCache(w io.Writer, conf *types.DBConfig, sample int) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	result, err := builder.Verify(dbc, builder.NewCacheDirSource(cacheDir), sample, rng)
+	if err != nil {
+		return xerrors.Errorf("verify error: %w", err)
+	}
+
+	if outputFormat == jsonOutput {
+		if err = writeJSON(w, result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(w, "Checked %d versions of %d artifacts\n", result.Versions, result.Artifacts)
+		if len(result.Mismatches) > 0 {
+			tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "GAV\tCLASSIFIER\tREPOSITORY\tREASON")
+			for _, m := range result.Mismatches {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.GAV, m.Classifier, m.Repository, m.Reason)
+			}
+			if err = tw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(result.Mismatches) > 0 {
+		return &exitError{code: exitCodeMismatch, msg: fmt.Sprintf("%d versions differ from the cache", len(result.Mismatches))}
+	}
+	return nil
+}
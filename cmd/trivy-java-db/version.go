@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+)
+
+// Build info is set by the linker, e.g. `-ldflags "-X main.version=v0.1.0"`. See Makefile.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+type versionInfo struct {
+	Version       string
+	Commit        string
+	BuildDate     string
+	GoVersion     string
+	Platform      string
+	CGOEnabled    bool
+	SchemaVersion int
+}
+
+// newVersionInfo returns the build info of the binary.
+// The commit and cgo setting are read from the Go build info when they aren't set by the linker.
+func newVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:       version,
+		Commit:        commit,
+		BuildDate:     buildDate,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		SchemaVersion: db.SchemaVersion,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "CGO_ENABLED":
+				info.CGOEnabled = s.Value == "1"
+			}
+		}
+	}
+	return info
+}
+
+func printVersion(w io.Writer) error {
+	info := newVersionInfo()
+	if outputFormat == jsonOutput {
+		return writeJSON(w, info)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Version:\t%s\n", info.Version)
+	fmt.Fprintf(tw, "Commit:\t%s\n", info.Commit)
+	fmt.Fprintf(tw, "Build date:\t%s\n", info.BuildDate)
+	fmt.Fprintf(tw, "Go version:\t%s\n", info.GoVersion)
+	fmt.Fprintf(tw, "Platform:\t%s\n", info.Platform)
+	fmt.Fprintf(tw, "CGO enabled:\t%t\n", info.CGOEnabled)
+	fmt.Fprintf(tw, "Schema version:\t%d\n", info.SchemaVersion)
+	return tw.Flush()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintVersion(t *testing.T) {
+	version, commit, buildDate = "v1.2.3", "abc123", "2024-01-02T03:04:05Z"
+	outputFormat = jsonOutput
+	t.Cleanup(func() {
+		version, commit, buildDate = "dev", "", ""
+		outputFormat = tableOutput
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, printVersion(&buf))
+
+	var got versionInfo
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "v1.2.3", got.Version)
+	assert.Equal(t, "abc123", got.Commit)
+	assert.Equal(t, "2024-01-02T03:04:05Z", got.BuildDate)
+	assert.NotEmpty(t, got.Platform)
+}
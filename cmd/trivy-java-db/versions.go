@@ -0,0 +1,26 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// versions lists indexes of all versions of `groupID`:`artifactID` in Maven version order.
+func versions(w io.Writer, conf *types.DBConfig, groupID, artifactID string) error {
+	dbc, err := db.New(filepath.Join(cacheDir, "db"), conf)
+	if err != nil {
+		return xerrors.Errorf("db open error: %w", err)
+	}
+	defer dbc.Close()
+
+	indexes, err := dbc.SelectVersionsByArtifactIDAndGroupID(artifactID, groupID)
+	if err != nil {
+		return xerrors.Errorf("versions lookup error: %w", err)
+	}
+	return writeIndexes(w, indexes)
+}
@@ -2,93 +2,463 @@ package builder
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cheggaaa/pb/v3"
+	"github.com/samber/lo"
 	"golang.org/x/xerrors"
 	"k8s.io/utils/clock"
 
 	"github.com/h7hac9/trivy-java-db/pkg/crawler"
 	"github.com/h7hac9/trivy-java-db/pkg/db"
-	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/status"
 	"github.com/h7hac9/trivy-java-db/pkg/types"
+	"github.com/h7hac9/trivy-java-db/pkg/version"
 )
 
-const updateInterval = time.Hour * 72 // 3 days
+const (
+	updateInterval = time.Hour * 72 // 3 days
+	batchSize      = 1000
+)
 
 type Builder struct {
 	db    db.DB
 	meta  db.Client
 	clock clock.Clock
+
+	rankingFeed     string
+	popularityFeed  string
+	deprecationFeed string
+	schemaVersion   int
+	updateInterval  time.Duration
+	markRemoved     bool
+	status          *status.Reporter
+	qualityReport   string
+	dedup           *dedupSet
+	batchSize       int
+	archiveTypes    []types.ArchiveType
+	maxVersions     int
+
+	stats Stats
+}
+
+// Stats is a summary of the build.
+type Stats struct {
+	Generation int
+	IndexFiles int
+	Versions   int
+	// Conflicts is the number of GAVs with different sha1s in several cache dirs.
+	Conflicts int
+	// Quarantined is the number of versions which failed validation.
+	Quarantined int
+	// Removed is the number of indexes marked as removed from their repository.
+	Removed int
+	// Deduplicated is the number of indexes skipped, since the same GAV with the same sha1 was inserted from another cache dir.
+	Deduplicated int
+	// Excluded is the number of indexes skipped, since their archive type isn't in Option.ArchiveTypes.
+	Excluded int
+	// Pruned is the number of indexes skipped, since their artifact has Option.MaxVersions newer versions.
+	Pruned int
+	// Anomalies is the number of anomalies in the quality report.
+	Anomalies int
+	// Skipped is set if the build was skipped, since the cache didn't change since the last build.
+	Skipped bool `json:",omitempty"`
+}
+
+type Option struct {
+	// RankingFeed is a path to the CSV file with artifact priorities.
+	// See loadRankingFeed for the format.
+	RankingFeed string
+	// PopularityFeed is a path to the CSV file with download statistics.
+	// See loadPopularityFeed for the format.
+	PopularityFeed string
+	// DeprecationFeed is a path to the CSV file with deprecated artifacts and their replacements.
+	// See loadDeprecationFeed for the format.
+	DeprecationFeed string
+	// SchemaVersion is saved in the metadata. Defaults to db.SchemaVersion.
+	SchemaVersion int
+	// UpdateInterval sets NextUpdate in the metadata, so consumers know when the DB becomes stale. Defaults to 3 days.
+	UpdateInterval time.Duration
+	// MarkRemoved sets `removed_at` of indexes whose versions are no longer in the index files of their artifact.
+	MarkRemoved bool
+	// Status receives the progress of the build.
+	Status *status.Reporter
+	// QualityReport is a path to write the quality report to. Anomalies are only logged if empty.
+	QualityReport string
+	// DedupWindow is the max number of inserted indexes remembered to skip duplicates from other cache dirs
+	// before they reach the DB. Each one takes about 50 bytes. Duplicates are only skipped by the DB if 0.
+	DedupWindow int
+	// BatchSize is the number of indexes inserted per DB transaction. Batches hold all versions of an index,
+	// so they may be larger. Defaults to 1000.
+	BatchSize int
+	// ArchiveTypes are the archive types stored in the DB, e.g. to leave out types a scanner never reads.
+	// All types are stored if empty.
+	ArchiveTypes []types.ArchiveType
+	// MaxVersions is the max number of versions stored per artifact, keeping the newest in Maven version order.
+	// Versions published with several archive types or classifiers count once. All versions are stored if 0.
+	MaxVersions int
+}
+
+func (opt Option) withDefaults() Option {
+	if opt.SchemaVersion == 0 {
+		opt.SchemaVersion = db.SchemaVersion
+	}
+	if opt.UpdateInterval == 0 {
+		opt.UpdateInterval = updateInterval
+	}
+	if opt.BatchSize == 0 {
+		opt.BatchSize = batchSize
+	}
+	return opt
 }
 
-func NewBuilder(db db.DB, meta db.Client) Builder {
+func NewBuilder(dbc db.DB, meta db.Client, opt Option) Builder {
+	opt = opt.withDefaults()
+	var dedup *dedupSet
+	if opt.DedupWindow > 0 {
+		dedup = newDedupSet(opt.DedupWindow)
+	}
 	return Builder{
-		db:    db,
+		db:    dbc,
 		meta:  meta,
 		clock: clock.RealClock{},
+
+		rankingFeed:     opt.RankingFeed,
+		popularityFeed:  opt.PopularityFeed,
+		deprecationFeed: opt.DeprecationFeed,
+		schemaVersion:   opt.SchemaVersion,
+		updateInterval:  opt.UpdateInterval,
+		markRemoved:     opt.MarkRemoved,
+		status:          opt.Status,
+		qualityReport:   opt.QualityReport,
+		dedup:           dedup,
+		batchSize:       opt.BatchSize,
+		archiveTypes:    opt.ArchiveTypes,
+		maxVersions:     opt.MaxVersions,
+	}
+}
+
+// Build inserts indexes from all `cacheDirs` into the DB.
+// When the same sha1 is found in several cache dirs, the index from the later dir is used.
+// When the same GAV is found with different sha1s, the conflict is logged.
+func (b *Builder) Build(cacheDirs ...string) error {
+	// The digest is taken before reading the cache, so changes during the build cause a rebuild next time
+	digest, err := CacheDigest(Option{
+		RankingFeed:     b.rankingFeed,
+		PopularityFeed:  b.popularityFeed,
+		DeprecationFeed: b.deprecationFeed,
+		SchemaVersion:   b.schemaVersion,
+		UpdateInterval:  b.updateInterval,
+		MarkRemoved:     b.markRemoved,
+	}, cacheDirs...)
+	if err != nil {
+		return xerrors.Errorf("failed to digest the cache: %w", err)
 	}
+	sources := lo.Map(cacheDirs, func(cacheDir string, _ int) IndexSource {
+		return NewCacheDirSource(cacheDir)
+	})
+	return b.build(sources, func() (db.Metadata, error) {
+		reports, err := crawlReports(cacheDirs)
+		if err != nil {
+			return db.Metadata{}, xerrors.Errorf("failed to read crawl reports: %w", err)
+		}
+		return db.Metadata{CrawlReports: reports, CacheDigest: digest}, nil
+	})
+}
+
+// BuildFrom inserts indexes from all `sources` into the DB, like Build does for cache dirs.
+// Sources are closed when the build ends. The metadata has no crawl reports and no cache digest.
+func (b *Builder) BuildFrom(sources ...IndexSource) error {
+	return b.build(sources, func() (db.Metadata, error) {
+		return db.Metadata{}, nil
+	})
 }
 
-func (b *Builder) Build(cacheDir string) error {
-	indexDir := filepath.Join(cacheDir, "indexes")
-	count, err := fileutil.Count(indexDir)
+// build inserts indexes from `sources` and saves the metadata returned by `sourceMeta` with the build times.
+func (b *Builder) build(sources []IndexSource, sourceMeta func() (db.Metadata, error)) error {
+	defer func() {
+		for _, src := range sources {
+			_ = src.Close()
+		}
+	}()
+
+	var count int
+	for _, src := range sources {
+		n, err := src.Len()
+		if err != nil {
+			return xerrors.Errorf("failed to count indexes of %s: %w", src.Name(), err)
+		}
+		count += n
+	}
+	builtAt := b.clock.Now().UTC()
+	generation, err := b.db.StartBuild(builtAt)
 	if err != nil {
-		return xerrors.Errorf("count error: %w", err)
+		return xerrors.Errorf("failed to start build: %w", err)
 	}
+	log.Printf("Build generation: %d", generation)
+	b.stats = Stats{Generation: generation, IndexFiles: count}
+
 	bar := pb.StartNew(count)
 	defer log.Println("Build completed")
 	defer bar.Finish()
 
-	var indexes []types.Index
-	if err := fileutil.Walk(indexDir, func(r io.Reader, path string) error {
-		index := &crawler.Index{}
-		if err := json.NewDecoder(r).Decode(index); err != nil {
-			return xerrors.Errorf("failed to decode index: %w", err)
-		}
-		for _, ver := range index.Versions {
-			indexes = append(indexes, types.Index{
-				GroupID:     index.GroupID,
-				ArtifactID:  index.ArtifactID,
-				Version:     ver.Version,
-				SHA1:        ver.SHA1,
-				ArchiveType: index.ArchiveType,
-			})
-		}
-		bar.Increment()
+	var indexes, pruned []types.Index
+	var quarantined []types.QuarantinedIndex
+	var done int
+	quality := newQualityChecker()
+	// The DB keeps the first inserted index for each sha1, so sources are inserted starting from the last one.
+	for i := len(sources) - 1; i >= 0; i-- {
+		src := sources[i]
+		log.Printf("Index source: %s", src.Name())
+		for {
+			index, err := src.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return xerrors.Errorf("failed to read %s: %w", src.Name(), err)
+			}
+			quality.check(index)
+			b.stats.Versions += len(index.Versions)
+			older := olderVersions(index, b.maxVersions)
+			for _, ver := range index.Versions {
+				idx := newIndex(index, ver, src.Name())
+				if reason := quarantineReason(idx); reason != "" {
+					quarantined = append(quarantined, types.QuarantinedIndex{Index: idx, Reason: reason})
+					continue
+				} else if _, ok := older[ver.Version]; ok {
+					pruned = append(pruned, idx)
+					continue
+				}
+				indexes = append(indexes, idx)
+			}
+			bar.Increment()
+			done++
+			b.status.Update(status.Inserting, src.Name(), done, count)
 
-		if len(indexes) > 1000 {
-			if err = b.db.InsertIndexes(indexes); err != nil {
-				return xerrors.Errorf("failed to insert index to db: %w", err)
+			if len(indexes) >= b.batchSize {
+				if err = b.insertIndexes(builtAt, indexes, pruned); err != nil {
+					return err
+				}
+				indexes, pruned = []types.Index{}, nil
 			}
-			indexes = []types.Index{}
 		}
-		return nil
-	}); err != nil {
-		return xerrors.Errorf("walk error: %w", err)
 	}
 
 	// Insert the remaining indexes
-	if err = b.db.InsertIndexes(indexes); err != nil {
-		return xerrors.Errorf("failed to insert index to db: %w", err)
+	if err := b.insertIndexes(builtAt, indexes, pruned); err != nil {
+		return err
+	}
+	b.status.Update(status.Finishing, "", done, count)
+	if b.stats.Removed > 0 {
+		log.Printf("Marked %d indexes as removed", b.stats.Removed)
+	}
+	if b.stats.Excluded > 0 {
+		log.Printf("Excluded %d indexes of other archive types", b.stats.Excluded)
+	}
+	if b.stats.Pruned > 0 {
+		log.Printf("Pruned %d indexes of versions older than the newest %d of their artifact", b.stats.Pruned, b.maxVersions)
+	}
+
+	if len(quarantined) > 0 {
+		log.Printf("Quarantined %d versions, run `verify` to list them", len(quarantined))
+	}
+	b.stats.Quarantined = len(quarantined)
+	if err := b.db.ReplaceQuarantine(quarantined); err != nil {
+		return xerrors.Errorf("failed to insert quarantined indexes: %w", err)
+	}
+
+	report := quality.finish()
+	b.stats.Anomalies = report.total()
+	if err := writeQualityReport(b.qualityReport, report); err != nil {
+		return err
+	}
+
+	repositories := lo.Map(sources, func(src IndexSource, _ int) string {
+		return src.Name()
+	})
+	if err := b.db.UpdateDailyStats(builtAt, repositories); err != nil {
+		return xerrors.Errorf("failed to update daily stats: %w", err)
+	}
+
+	if len(sources) > 1 {
+		if err := b.reportConflicts(); err != nil {
+			return xerrors.Errorf("failed to report conflicts: %w", err)
+		}
+	}
+
+	if b.rankingFeed != "" {
+		priorities, err := loadRankingFeed(b.rankingFeed)
+		if err != nil {
+			return xerrors.Errorf("failed to load ranking feed: %w", err)
+		}
+		if err = b.db.UpdateArtifactPriorities(priorities); err != nil {
+			return xerrors.Errorf("failed to update artifact priorities: %w", err)
+		}
+	}
+
+	if b.popularityFeed != "" {
+		popularity, err := loadPopularityFeed(b.popularityFeed)
+		if err != nil {
+			return xerrors.Errorf("failed to load popularity feed: %w", err)
+		}
+		if err = b.db.InsertPopularity(popularity); err != nil {
+			return xerrors.Errorf("failed to insert popularity: %w", err)
+		}
+	}
+
+	if b.deprecationFeed != "" {
+		deprecations, err := loadDeprecationFeed(b.deprecationFeed)
+		if err != nil {
+			return xerrors.Errorf("failed to load deprecation feed: %w", err)
+		}
+		if err = b.db.ReplaceDeprecations(deprecations); err != nil {
+			return xerrors.Errorf("failed to replace deprecations: %w", err)
+		}
+	}
+
+	if err := b.db.UpdateDBInfo(b.schemaVersion, builtAt); err != nil {
+		return xerrors.Errorf("failed to update db info: %w", err)
 	}
 
 	if err := b.db.VacuumDB(); err != nil {
 		return xerrors.Errorf("fauled to vacuum db: %w", err)
 	}
 
-	// save metadata
-	metaDB := db.Metadata{
-		Version:    db.SchemaVersion,
-		NextUpdate: b.clock.Now().UTC().Add(updateInterval),
-		UpdatedAt:  b.clock.Now().UTC(),
+	metaDB, err := sourceMeta()
+	if err != nil {
+		return err
 	}
+
+	// save metadata
+	now := b.clock.Now().UTC()
+	metaDB.Version = b.schemaVersion
+	metaDB.NextUpdate = now.Add(b.updateInterval)
+	metaDB.UpdatedAt = now
 	if err := b.meta.Update(metaDB); err != nil {
 		return xerrors.Errorf("failed to update metadata: %w", err)
 	}
 
 	return nil
 }
+
+// newIndex returns the DB index of version `ver` of the index file `index` read from `repository`.
+func newIndex(index *crawler.Index, ver crawler.Version, repository string) types.Index {
+	return types.Index{
+		GroupID:     db.CanonicalID(index.GroupID),
+		ArtifactID:  db.CanonicalID(index.ArtifactID),
+		Version:     ver.Version,
+		SHA1:        ver.SHA1,
+		MD5:         ver.MD5,
+		Size:        ver.Size,
+		Signed:      ver.Signed,
+		SigningKey:  ver.SigningKey,
+		ArchiveType: lo.Ternary(ver.ArchiveType != "", ver.ArchiveType, index.ArchiveType),
+		Classifier:  ver.Classifier,
+		Platform:    ver.Platform,
+		Repository:  repository,
+	}
+}
+
+// olderVersions returns the versions of `index` older than its newest `max` versions, or nil if `max` is 0.
+func olderVersions(index *crawler.Index, max int) map[string]struct{} {
+	if max == 0 {
+		return nil
+	}
+	versions := lo.Uniq(lo.Map(index.Versions, func(ver crawler.Version, _ int) string {
+		return ver.Version
+	}))
+	if len(versions) <= max {
+		return nil
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return version.Compare(versions[i], versions[j]) > 0
+	})
+	return lo.SliceToMap(versions[max:], func(v string) (string, struct{}) {
+		return v, struct{}{}
+	})
+}
+
+// insertIndexes inserts `indexes` and marks versions of their artifacts missing from `indexes` and `pruned` as removed.
+// Batches always hold all versions of an index file, so no artifact is split across batches.
+func (b *Builder) insertIndexes(builtAt time.Time, indexes, pruned []types.Index) error {
+	b.stats.Pruned += len(pruned)
+	// Removed indexes are still marked from all indexes, since duplicates and excluded archive types are crawled
+	// versions of their repository
+	included := indexes
+	if len(b.archiveTypes) > 0 {
+		included = lo.Filter(indexes, func(index types.Index, _ int) bool {
+			return lo.Contains(b.archiveTypes, index.ArchiveType)
+		})
+		b.stats.Excluded += len(indexes) - len(included)
+	}
+	unique, skipped := b.dedup.filter(included)
+	b.stats.Deduplicated += skipped
+	if err := b.db.InsertIndexes(unique); err != nil {
+		return xerrors.Errorf("failed to insert index to db: %w", err)
+	}
+	if !b.markRemoved {
+		return nil
+	}
+	n, err := b.db.MarkRemovedIndexes(builtAt, append(append([]types.Index{}, indexes...), pruned...))
+	if err != nil {
+		return xerrors.Errorf("failed to mark removed indexes: %w", err)
+	}
+	b.stats.Removed += n
+	return nil
+}
+
+// crawlReports reads the crawl reports of `cacheDirs`. Cache dirs without a report, e.g. from older crawlers, are logged.
+func crawlReports(cacheDirs []string) ([]types.CrawlReport, error) {
+	var reports []types.CrawlReport
+	for _, cacheDir := range cacheDirs {
+		b, err := os.ReadFile(filepath.Join(cacheDir, crawler.ReportFile))
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("No crawl report in %s", cacheDir)
+			continue
+		} else if err != nil {
+			return nil, xerrors.Errorf("unable to read the crawl report: %w", err)
+		}
+		var report types.CrawlReport
+		if err = json.Unmarshal(b, &report); err != nil {
+			return nil, xerrors.Errorf("unable to decode the crawl report of %s: %w", cacheDir, err)
+		}
+		if report.Partial {
+			log.Printf("Crawl of %s is partial, the next crawl resumes it", cacheDir)
+		} else if !report.Complete {
+			log.Printf("Crawl of %s didn't complete: %s", cacheDir, report.Error)
+		}
+		report.CacheDir = cacheDir
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// reportConflicts logs GAVs published with different sha1s in several cache dirs.
+// e.g. an internal artifact shadowed by a Maven Central one with the same coordinates (dependency confusion).
+func (b *Builder) reportConflicts() error {
+	conflicts, err := b.db.SelectConflictingGAVs()
+	if err != nil {
+		return xerrors.Errorf("failed to select conflicting GAVs: %w", err)
+	}
+	for _, c := range conflicts {
+		sources := lo.Map(c.Indexes, func(index types.Index, _ int) string {
+			return fmt.Sprintf("%s (%x)", index.Repository, index.SHA1)
+		})
+		log.Printf("GAV conflict: %s:%s:%s: %s", c.GroupID, c.ArtifactID, c.Version, strings.Join(sources, ", "))
+	}
+	b.stats.Conflicts = len(conflicts)
+	return nil
+}
+
+// Stats returns the summary of the last Build.
+func (b *Builder) Stats() Stats {
+	return b.stats
+}
@@ -0,0 +1,628 @@
+package builder_test
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/builder"
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/dbtest"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		cacheDirs []string
+		sha1      string
+		want      types.Index
+	}{
+		{
+			name:      "single cache dir",
+			cacheDirs: []string{"testdata/central"},
+			sha1:      "9c581de633e94be1e7a955bd4e8292f16e554387",
+			want: types.Index{
+				GroupID:     "jstl",
+				ArtifactID:  "jstl",
+				Version:     "1.0",
+				ArchiveType: types.JarType,
+				Generation:  1,
+			},
+		},
+		{
+			name:      "later cache dir overrides sha1",
+			cacheDirs: []string{"testdata/central", "testdata/internal"},
+			sha1:      "9c581de633e94be1e7a955bd4e8292f16e554387",
+			want: types.Index{
+				GroupID:     "javax.servlet",
+				ArtifactID:  "jstl",
+				Version:     "1.0",
+				ArchiveType: types.JarType,
+				Generation:  1,
+			},
+		},
+		{
+			name:      "sha1 only in earlier cache dir",
+			cacheDirs: []string{"testdata/central", "testdata/internal"},
+			sha1:      "74a160560017fb7948e68092d5247ac5755583e5",
+			want: types.Index{
+				GroupID:     "jstl",
+				ArtifactID:  "jstl",
+				Version:     "1.2",
+				ArchiveType: types.JarType,
+				Generation:  1,
+			},
+		},
+		{
+			name:      "klib version",
+			cacheDirs: []string{"testdata/klib"},
+			sha1:      "b5c1e2d3f4a5968778695a4b3c2d1e0f9a8b7c6d",
+			want: types.Index{
+				GroupID:     "abbot",
+				ArtifactID:  "abbot",
+				Version:     "1.4.0-iosarm64",
+				ArchiveType: types.KlibType,
+				Generation:  1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, nil)
+			require.NoError(t, err)
+
+			b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{})
+			err = b.Build(tt.cacheDirs...)
+			require.NoError(t, err)
+
+			got, err := dbc.SelectIndexBySha1(tt.sha1)
+			require.NoError(t, err)
+
+			got.SHA1 = nil
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBuildUpdateInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		want     time.Duration
+	}{
+		{name: "default", want: 72 * time.Hour},
+		{name: "daily", interval: 24 * time.Hour, want: 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, nil)
+			require.NoError(t, err)
+
+			meta := db.NewMetadata(t.TempDir())
+			b := builder.NewBuilder(dbc, meta, builder.Option{UpdateInterval: tt.interval})
+			require.NoError(t, b.Build("testdata/central"))
+
+			got, err := meta.Get()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.NextUpdate.Sub(got.UpdatedAt))
+		})
+	}
+}
+
+func TestBuildConflicts(t *testing.T) {
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.Build("testdata/central", "testdata/conflict"))
+	assert.Equal(t, 1, b.Stats().Conflicts)
+
+	conflicts, err := dbc.SelectConflictingGAVs()
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "jstl:jstl:1.2", fmt.Sprintf("%s:%s:%s", conflicts[0].GroupID, conflicts[0].ArtifactID, conflicts[0].Version))
+	assert.Equal(t, []string{"testdata/central", "testdata/conflict"}, lo.Map(conflicts[0].Indexes, func(index types.Index, _ int) string {
+		return index.Repository
+	}))
+}
+
+func TestBuildDailyStats(t *testing.T) {
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.Build("testdata/central"))
+
+	stats, err := dbc.SelectDailyStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "testdata/central", stats[0].Repository)
+	assert.Equal(t, 1, stats[0].NewArtifacts)
+	assert.Equal(t, 2, stats[0].NewIndexes)
+}
+
+func TestBuildCrawlReports(t *testing.T) {
+	cacheDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "indexes"), os.ModePerm))
+	require.NoError(t, fileutil.WriteJSON(filepath.Join(cacheDir, crawler.ReportFile), types.CrawlReport{
+		RepositoryURL: "https://repo.example.com/maven2/",
+		Complete:      true,
+		Artifacts:     1,
+		Errors:        map[string]int{"wrong_sha1": 2},
+	}))
+
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+
+	meta := db.NewMetadata(t.TempDir())
+	b := builder.NewBuilder(dbc, meta, builder.Option{})
+	// testdata/central has no crawl report
+	require.NoError(t, b.Build("testdata/central", cacheDir))
+
+	got, err := meta.Get()
+	require.NoError(t, err)
+	assert.Equal(t, []types.CrawlReport{
+		{
+			CacheDir:      cacheDir,
+			RepositoryURL: "https://repo.example.com/maven2/",
+			Complete:      true,
+			Artifacts:     1,
+			Errors:        map[string]int{"wrong_sha1": 2},
+		},
+	}, got.CrawlReports)
+}
+
+func TestBuildQuarantine(t *testing.T) {
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.Build("testdata/quarantine"))
+	assert.Equal(t, 2, b.Stats().Quarantined)
+
+	got, err := dbc.SelectQuarantine()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"org.example:broken:1.0.1: sha1 of 3 bytes",
+		"org.example:broken:1.0.2 see build log: invalid character ' ' in version",
+	}, lo.Map(got, func(index types.QuarantinedIndex, _ int) string {
+		return fmt.Sprintf("%s:%s:%s: %s", index.GroupID, index.ArtifactID, index.Version, index.Reason)
+	}))
+
+	// Valid versions are inserted
+	index, err := dbc.SelectIndexByArtifactIDAndGroupID("broken", "org.example")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", index.Version)
+}
+
+func TestBuildMarkRemoved(t *testing.T) {
+	sha1b10, _ := hex.DecodeString("9c581de633e94be1e7a955bd4e8292f16e554387")
+	sha1b12, _ := hex.DecodeString("74a160560017fb7948e68092d5247ac5755583e5")
+	cacheDir := t.TempDir()
+	indexPath := filepath.Join(cacheDir, "indexes", "jstl", "jstl.json")
+	jstl := crawler.Index{
+		GroupID:    "jstl",
+		ArtifactID: "jstl",
+		Versions: []crawler.Version{
+			{Version: "1.0", SHA1: sha1b10},
+			{Version: "1.2", SHA1: sha1b12},
+		},
+		ArchiveType: types.JarType,
+	}
+	require.NoError(t, fileutil.WriteJSON(indexPath, jstl))
+
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	meta := db.NewMetadata(t.TempDir())
+	removed := types.Digest{Algorithm: types.SHA1, Value: jstl.Versions[1].SHA1}
+
+	build := func(versions []crawler.Version) builder.Stats {
+		index := jstl
+		index.Versions = versions
+		require.NoError(t, fileutil.WriteJSON(indexPath, index))
+		b := builder.NewBuilder(dbc, meta, builder.Option{MarkRemoved: true})
+		require.NoError(t, b.Build(cacheDir))
+		return b.Stats()
+	}
+
+	assert.Equal(t, 0, build(jstl.Versions).Removed)
+
+	// 1.2 was yanked
+	assert.Equal(t, 1, build(jstl.Versions[:1]).Removed)
+	got, err := dbc.SelectIndexByDigest(removed)
+	require.NoError(t, err)
+	assert.NotNil(t, got.RemovedAt)
+
+	// Already marked indexes aren't counted again
+	assert.Equal(t, 0, build(jstl.Versions[:1]).Removed)
+
+	// 1.2 was published again
+	assert.Equal(t, 0, build(jstl.Versions).Removed)
+	got, err = dbc.SelectIndexByDigest(removed)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2", got.Version)
+	assert.Nil(t, got.RemovedAt)
+}
+
+func TestBuildDeprecationFeed(t *testing.T) {
+	feed := filepath.Join(t.TempDir(), "deprecations.csv")
+	require.NoError(t, os.WriteFile(feed, []byte(`# group_id,artifact_id,replacement,reason
+jstl, jstl, jakarta.servlet.jsp.jstl:jakarta.servlet.jsp.jstl-api, moved to Jakarta EE
+`), 0o644))
+
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{DeprecationFeed: feed})
+	require.NoError(t, b.Build("testdata/central"))
+
+	got, err := dbc.SelectDeprecation("jstl", "jstl")
+	require.NoError(t, err)
+	assert.Equal(t, types.Deprecation{
+		GroupID:     "jstl",
+		ArtifactID:  "jstl",
+		Replacement: "jakarta.servlet.jsp.jstl:jakarta.servlet.jsp.jstl-api",
+		Reason:      "moved to Jakarta EE",
+	}, got)
+}
+
+func TestCacheDigest(t *testing.T) {
+	cacheDir := t.TempDir()
+	indexPath := filepath.Join(cacheDir, "indexes", "jstl", "jstl.json")
+	jstl := crawler.Index{
+		GroupID:     "jstl",
+		ArtifactID:  "jstl",
+		Versions:    []crawler.Version{{Version: "1.0", SHA1: []byte("01234567890123456789")}},
+		ArchiveType: types.JarType,
+	}
+	require.NoError(t, fileutil.WriteJSON(indexPath, jstl))
+	feed := filepath.Join(t.TempDir(), "ranking.csv")
+	require.NoError(t, os.WriteFile(feed, []byte("jstl,jstl,1\n"), 0600))
+
+	digest := func(opt builder.Option) string {
+		d, err := builder.CacheDigest(opt, cacheDir)
+		require.NoError(t, err)
+		return d
+	}
+	first := digest(builder.Option{RankingFeed: feed})
+	assert.Equal(t, first, digest(builder.Option{RankingFeed: feed}))
+	// Defaults are applied
+	assert.Equal(t, first, digest(builder.Option{RankingFeed: feed, SchemaVersion: db.SchemaVersion}))
+
+	assert.NotEqual(t, first, digest(builder.Option{}), "no feed")
+	assert.NotEqual(t, first, digest(builder.Option{RankingFeed: feed, MarkRemoved: true}), "option")
+	aarJar := digest(builder.Option{RankingFeed: feed, ArchiveTypes: []types.ArchiveType{types.AarType, types.JarType}})
+	assert.NotEqual(t, first, aarJar, "archive types")
+	assert.Equal(t, aarJar, digest(builder.Option{RankingFeed: feed, ArchiveTypes: []types.ArchiveType{types.JarType, types.AarType}}))
+	assert.NotEqual(t, first, digest(builder.Option{RankingFeed: feed, MaxVersions: 20}), "max versions")
+
+	require.NoError(t, os.WriteFile(feed, []byte("jstl,jstl,2\n"), 0600))
+	second := digest(builder.Option{RankingFeed: feed})
+	assert.NotEqual(t, first, second, "feed")
+
+	jstl.Versions = append(jstl.Versions, crawler.Version{Version: "1.2", SHA1: []byte("98765432109876543210")})
+	require.NoError(t, fileutil.WriteJSON(indexPath, jstl))
+	assert.NotEqual(t, second, digest(builder.Option{RankingFeed: feed}), "index")
+
+	// The build saves the digest
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	meta := db.NewMetadata(t.TempDir())
+	b := builder.NewBuilder(dbc, meta, builder.Option{RankingFeed: feed})
+	require.NoError(t, b.Build(cacheDir))
+	got, err := meta.Get()
+	require.NoError(t, err)
+	assert.Equal(t, digest(builder.Option{RankingFeed: feed}), got.CacheDigest)
+}
+
+func TestBuildQualityReport(t *testing.T) {
+	cacheDir := t.TempDir()
+	longVersion := "1.0-" + strings.Repeat("a", 80)
+	for _, index := range []crawler.Index{
+		{
+			GroupID:     "jstl",
+			ArtifactID:  "jstl",
+			ArchiveType: types.JarType,
+			Versions: []crawler.Version{
+				{Version: "1.0", SHA1: []byte("01234567890123456789")},
+				{Version: "1.1"},
+				{Version: longVersion, SHA1: []byte("98765432109876543210")},
+			},
+		},
+		{GroupID: "jstl", ArtifactID: "empty", ArchiveType: types.JarType},
+	} {
+		require.NoError(t, fileutil.WriteJSON(filepath.Join(cacheDir, "indexes", index.GroupID, index.ArtifactID+".json"), index))
+	}
+
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	reportPath := filepath.Join(t.TempDir(), "quality-report.json")
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{QualityReport: reportPath})
+	require.NoError(t, b.Build(cacheDir))
+	assert.Equal(t, 3, b.Stats().Anomalies)
+
+	f, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	var got builder.QualityReport
+	require.NoError(t, json.Unmarshal(f, &got))
+	assert.Equal(t, map[string]int{
+		builder.AnomalyNoVersions:  1,
+		builder.AnomalyNoChecksum:  1,
+		builder.AnomalyLongVersion: 1,
+	}, got.Counts)
+	assert.ElementsMatch(t, []builder.Anomaly{
+		{Kind: builder.AnomalyNoVersions, Subject: "jstl:empty"},
+		{Kind: builder.AnomalyNoChecksum, Subject: "jstl:jstl:1.1"},
+		{Kind: builder.AnomalyLongVersion, Subject: "jstl:jstl:" + longVersion, Detail: "84 characters"},
+	}, got.Anomalies)
+}
+
+func TestBuildDedupWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		dedupWindow int
+		want        int
+	}{
+		{name: "disabled", dedupWindow: 0, want: 0},
+		// Cache dirs are inserted from the last one, so the window is filled by testdata/conflict
+		{name: "full window", dedupWindow: 1, want: 0},
+		{name: "large window", dedupWindow: 100, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, nil)
+			require.NoError(t, err)
+
+			b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{DedupWindow: tt.dedupWindow})
+			require.NoError(t, b.Build("testdata/central", "testdata/central", "testdata/conflict"))
+			assert.Equal(t, tt.want, b.Stats().Deduplicated)
+			// Different sha1s of a GAV always reach the DB
+			assert.Equal(t, 1, b.Stats().Conflicts)
+
+			indexes, err := dbc.SelectVersionsByArtifactIDAndGroupID("jstl", "jstl")
+			require.NoError(t, err)
+			assert.Len(t, indexes, 3)
+		})
+	}
+}
+
+func TestBuildArchiveTypes(t *testing.T) {
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+
+	catalog := builder.NewSliceSource("catalog", []crawler.Index{
+		{
+			GroupID:     "com.squareup.okio",
+			ArtifactID:  "okio",
+			ArchiveType: types.JarType,
+			Versions: []crawler.Version{
+				{Version: "3.0.0", SHA1: []byte("01234567890123456789")},
+				{Version: "3.0.0", SHA1: []byte("98765432109876543210"), ArchiveType: types.KlibType},
+			},
+		},
+		{
+			GroupID:     "org.apache.maven",
+			ArtifactID:  "maven-parent",
+			ArchiveType: types.PomType,
+			Versions: []crawler.Version{
+				{Version: "40", SHA1: []byte("abcdefghijabcdefghij")},
+			},
+		},
+	})
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{
+		ArchiveTypes: []types.ArchiveType{types.JarType, types.AarType},
+	})
+	require.NoError(t, b.BuildFrom(catalog))
+	assert.Equal(t, 2, b.Stats().Excluded)
+
+	versions, err := dbc.SelectVersionsByArtifactIDAndGroupID("okio", "com.squareup.okio")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.EqualValues(t, types.JarType, versions[0].ArchiveType)
+
+	versions, err = dbc.SelectVersionsByArtifactIDAndGroupID("maven-parent", "org.apache.maven")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func TestBuildMaxVersions(t *testing.T) {
+	catalog := []crawler.Index{
+		{
+			GroupID:     "io.netty",
+			ArtifactID:  "netty-tcnative",
+			ArchiveType: types.JarType,
+			Versions: []crawler.Version{
+				{Version: "2.0.9.Final", SHA1: []byte("01234567890123456789")},
+				{Version: "2.0.10.Final", SHA1: []byte("12345678901234567890")},
+				{Version: "2.0.10.Final", SHA1: []byte("23456789012345678901"), Classifier: "linux-x86_64"},
+				{Version: "2.0.8.Final", SHA1: []byte("34567890123456789012")},
+			},
+		},
+	}
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{MaxVersions: 2})
+	require.NoError(t, b.BuildFrom(builder.NewSliceSource("catalog", catalog)))
+	assert.Equal(t, 1, b.Stats().Pruned)
+
+	versions, err := dbc.SelectVersionsByArtifactIDAndGroupID("netty-tcnative", "io.netty")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2.0.9.Final", "2.0.10.Final", "2.0.10.Final"}, lo.Map(versions, func(index types.Index, _ int) string {
+		return index.Version
+	}))
+
+	// Pruned versions of an existing DB aren't marked as removed
+	dbc, err = dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	b = builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.BuildFrom(builder.NewSliceSource("catalog", catalog)))
+	b = builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{MaxVersions: 2, MarkRemoved: true})
+	require.NoError(t, b.BuildFrom(builder.NewSliceSource("catalog", catalog)))
+	assert.Equal(t, 1, b.Stats().Pruned)
+	assert.Zero(t, b.Stats().Removed)
+}
+
+func TestBuildFrom(t *testing.T) {
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+
+	catalog := builder.NewSliceSource("catalog", []crawler.Index{
+		{
+			GroupID:     "jstl",
+			ArtifactID:  "jstl",
+			ArchiveType: types.JarType,
+			Versions: []crawler.Version{
+				{Version: "1.0", SHA1: []byte("01234567890123456789")},
+				{Version: "1.1", SHA1: []byte("98765432109876543210")},
+			},
+		},
+		{
+			GroupID:     "javax.servlet",
+			ArtifactID:  "jstl",
+			ArchiveType: types.JarType,
+			Versions: []crawler.Version{
+				{Version: "1.2", SHA1: []byte("abcdefghijabcdefghij")},
+			},
+		},
+	})
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{BatchSize: 1})
+	require.NoError(t, b.BuildFrom(builder.NewCacheDirSource("testdata/central"), catalog))
+	assert.Equal(t, 3, b.Stats().IndexFiles)
+
+	index, err := dbc.SelectIndexBySha1(hex.EncodeToString([]byte("abcdefghijabcdefghij")))
+	require.NoError(t, err)
+	assert.Equal(t, "javax.servlet", index.GroupID)
+
+	versions, err := dbc.SelectVersionsByArtifactIDAndGroupID("jstl", "jstl")
+	require.NoError(t, err)
+	assert.Len(t, versions, 4)
+}
+
+func TestVerify(t *testing.T) {
+	jstl := crawler.Index{
+		GroupID:     "jstl",
+		ArtifactID:  "jstl",
+		ArchiveType: types.JarType,
+		Versions: []crawler.Version{
+			{Version: "1.0", SHA1: []byte("01234567890123456789"), Size: 100},
+			{Version: "1.1", SHA1: []byte("98765432109876543210"), Size: 200},
+		},
+	}
+	servlet := crawler.Index{
+		GroupID:     "javax.servlet",
+		ArtifactID:  "jstl",
+		ArchiveType: types.JarType,
+		Versions: []crawler.Version{
+			{Version: "1.2", SHA1: []byte("abcdefghijabcdefghij")},
+			{Version: "", SHA1: []byte("jihgfedcbajihgfedcba")}, // quarantined
+		},
+	}
+
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.BuildFrom(builder.NewSliceSource("catalog", []crawler.Index{jstl, servlet})))
+
+	rng := rand.New(rand.NewSource(1))
+	got, err := builder.Verify(dbc, builder.NewSliceSource("catalog", []crawler.Index{jstl, servlet}), 0, rng)
+	require.NoError(t, err)
+	assert.Equal(t, builder.VerifyResult{Artifacts: 2, Versions: 3}, got)
+
+	// The cache changed after the build
+	changed := jstl
+	changed.Versions = []crawler.Version{
+		{Version: "1.0", SHA1: []byte("01234567890123456789"), Size: 101},
+		{Version: "1.1", SHA1: []byte("98765432109876543210"), Size: 200},
+		{Version: "1.2", SHA1: []byte("abcdefghij0123456789")},
+	}
+	got, err = builder.Verify(dbc, builder.NewSliceSource("catalog", []crawler.Index{changed, servlet}), 0, rng)
+	require.NoError(t, err)
+	assert.Equal(t, builder.VerifyResult{
+		Artifacts: 2,
+		Versions:  4,
+		Mismatches: []builder.Mismatch{
+			{GAV: "jstl:jstl:1.0", Repository: "catalog", Reason: "different size"},
+			{GAV: "jstl:jstl:1.2", Repository: "catalog", Reason: "missing"},
+		},
+	}, got)
+
+	// Only the sample is checked
+	got, err = builder.Verify(dbc, builder.NewSliceSource("catalog", []crawler.Index{changed, servlet}), 1, rng)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Artifacts)
+}
+
+func TestSqliteFileSource(t *testing.T) {
+	// Schema of DBs built by aquasecurity/trivy-java-db
+	path := filepath.Join(t.TempDir(), "trivy-java.db")
+	upstream, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = upstream.Exec(`
+		CREATE TABLE artifacts(id INTEGER PRIMARY KEY, group_id TEXT, artifact_id TEXT);
+		CREATE TABLE indices(artifact_id INTEGER, version TEXT, sha1 BLOB, archive_type TEXT,
+			FOREIGN KEY (artifact_id) REFERENCES artifacts(id));
+		INSERT INTO artifacts VALUES (1, 'jstl', 'jstl'), (2, 'javax.servlet', 'jstl');
+		INSERT INTO indices VALUES
+			(1, '1.0', X'3031323334353637383930313233343536373839', 'jar'),
+			(2, '1.2', X'6162636465666768696a6162636465666768696a', 'jar'),
+			(1, '1.1', X'3938373635343332313039383736353433323130', 'aar');`)
+	require.NoError(t, err)
+	require.NoError(t, upstream.Close())
+
+	src, err := builder.NewSqliteFileSource(path)
+	require.NoError(t, err)
+	dbc, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	b := builder.NewBuilder(dbc, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.BuildFrom(src))
+	assert.Equal(t, 2, b.Stats().IndexFiles)
+
+	index, err := dbc.SelectIndexBySha1(hex.EncodeToString([]byte("98765432109876543210")))
+	require.NoError(t, err)
+	assert.Equal(t, "jstl", index.GroupID)
+	assert.Equal(t, "1.1", index.Version)
+	assert.EqualValues(t, types.AarType, index.ArchiveType)
+
+	versions, err := dbc.SelectVersionsByArtifactIDAndGroupID("jstl", "javax.servlet")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	_, err = builder.NewSqliteFileSource(filepath.Join(t.TempDir(), "missing.db"))
+	require.Error(t, err)
+}
+
+func TestDBSource(t *testing.T) {
+	src, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	b := builder.NewBuilder(src, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.BuildFrom(builder.NewCacheDirSource("testdata/central")))
+
+	dst, err := dbtest.InitDB(t, nil)
+	require.NoError(t, err)
+	b = builder.NewBuilder(dst, db.NewMetadata(t.TempDir()), builder.Option{})
+	require.NoError(t, b.BuildFrom(builder.NewDBSource("mysql", src)))
+	assert.Equal(t, 1, b.Stats().IndexFiles)
+
+	want, err := src.SelectVersionsByArtifactIDAndGroupID("jstl", "jstl")
+	require.NoError(t, err)
+	got, err := dst.SelectVersionsByArtifactIDAndGroupID("jstl", "jstl")
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// dbSource reads the indexes of a DB built by this repository, e.g. to copy it into another backend.
+type dbSource struct {
+	name string
+	dbc  db.DB
+
+	start sync.Once
+	items chan sourceItem
+	close sync.Once
+	done  chan struct{}
+}
+
+// NewDBSource returns a source of the indexes of `dbc` named `name`. Each artifact is read as one index file.
+// The source doesn't close `dbc`.
+func NewDBSource(name string, dbc db.DB) IndexSource {
+	return &dbSource{
+		name:  name,
+		dbc:   dbc,
+		items: make(chan sourceItem),
+		done:  make(chan struct{}),
+	}
+}
+
+func (s *dbSource) Name() string {
+	return s.name
+}
+
+// Len returns the number of artifacts stored in db_info by the last build, or 0 for DBs without it.
+func (s *dbSource) Len() (int, error) {
+	info, err := s.dbc.SelectDBInfo()
+	if errors.Is(err, db.ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, xerrors.Errorf("db info error: %w", err)
+	}
+	return int(info.RowCounts["artifacts"]), nil
+}
+
+func (s *dbSource) Next() (*crawler.Index, error) {
+	s.start.Do(func() {
+		go s.scan()
+	})
+	item, ok := <-s.items
+	if !ok {
+		return nil, io.EOF
+	}
+	return item.index, item.err
+}
+
+// scan sends the indexes of each artifact to `items` until they are all sent or the source is closed.
+func (s *dbSource) scan() {
+	defer close(s.items)
+	err := s.dbc.ScanIndexes(func(indexes []types.Index) error {
+		index := &crawler.Index{GroupID: indexes[0].GroupID, ArtifactID: indexes[0].ArtifactID}
+		for _, i := range indexes {
+			index.Versions = append(index.Versions, crawler.Version{
+				Version:     i.Version,
+				SHA1:        i.SHA1,
+				MD5:         i.MD5,
+				Size:        i.Size,
+				Signed:      i.Signed,
+				SigningKey:  i.SigningKey,
+				ArchiveType: i.ArchiveType,
+				Classifier:  i.Classifier,
+				Platform:    i.Platform,
+			})
+		}
+		select {
+		case s.items <- sourceItem{index: index}:
+			return nil
+		case <-s.done:
+			return errSourceClosed
+		}
+	})
+	if err != nil && !errors.Is(err, errSourceClosed) {
+		select {
+		case s.items <- sourceItem{err: xerrors.Errorf("scan error: %w", err)}:
+		case <-s.done:
+		}
+	}
+}
+
+func (s *dbSource) Close() error {
+	s.close.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
@@ -0,0 +1,61 @@
+package builder
+
+import (
+	"crypto/sha256"
+
+	"github.com/samber/lo"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// dedupKey identifies a GAV with its sha1. A truncated hash keeps every key at 16 bytes.
+type dedupKey [16]byte
+
+// dedupSet remembers inserted indexes, so that the same GAV with the same sha1 from a later cache dir is skipped before
+// the DB ignores it. It holds at most `max` keys. Once it's full, new indexes are only deduplicated by the DB.
+type dedupSet struct {
+	max  int
+	seen map[dedupKey]struct{}
+}
+
+func newDedupSet(max int) *dedupSet {
+	return &dedupSet{
+		max:  max,
+		seen: make(map[dedupKey]struct{}),
+	}
+}
+
+// filter returns the indexes of `indexes` which weren't seen before and the number of skipped ones.
+// Indexes without sha1 are always returned, and so are indexes sharing a sha1 with another GAV, since the DB records
+// them as collisions.
+func (d *dedupSet) filter(indexes []types.Index) ([]types.Index, int) {
+	if d == nil {
+		return indexes, 0
+	}
+	filtered := lo.Filter(indexes, func(index types.Index, _ int) bool {
+		if len(index.SHA1) == 0 {
+			return true
+		}
+		key := newDedupKey(index)
+		if _, ok := d.seen[key]; ok {
+			return false
+		}
+		if len(d.seen) < d.max {
+			d.seen[key] = struct{}{}
+		}
+		return true
+	})
+	return filtered, len(indexes) - len(filtered)
+}
+
+func newDedupKey(index types.Index) dedupKey {
+	h := sha256.New()
+	h.Write(index.SHA1)
+	for _, s := range []string{index.GroupID, index.ArtifactID, index.Version} {
+		h.Write([]byte{0})
+		h.Write([]byte(s))
+	}
+	var key dedupKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
@@ -0,0 +1,85 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// CacheDigest returns a digest of everything a build of `cacheDirs` with `opt` reads:
+// the index files and crawl reports of the cache dirs, the feeds and the options changing the DB.
+// Builds with the same digest produce the same DB, so an unchanged cache doesn't need to be rebuilt.
+func CacheDigest(opt Option, cacheDirs ...string) (string, error) {
+	opt = opt.withDefaults()
+	h := sha256.New()
+	fmt.Fprintf(h, "schema %d, update interval %s, mark removed %t\n", opt.SchemaVersion, opt.UpdateInterval, opt.MarkRemoved)
+	if len(opt.ArchiveTypes) > 0 {
+		// Options added later are only written when set, so that the digests of earlier builds stay valid
+		archiveTypes := lo.Map(opt.ArchiveTypes, func(t types.ArchiveType, _ int) string { return string(t) })
+		sort.Strings(archiveTypes)
+		fmt.Fprintf(h, "archive types %s\n", strings.Join(archiveTypes, ","))
+	}
+	if opt.MaxVersions > 0 {
+		fmt.Fprintf(h, "max versions %d\n", opt.MaxVersions)
+	}
+
+	for _, cacheDir := range cacheDirs {
+		// The cache dir is stored as the repository of its indexes
+		fmt.Fprintf(h, "cache dir %s\n", cacheDir)
+		indexDir := filepath.Join(cacheDir, "indexes")
+		if err := fileutil.Walk(indexDir, func(r io.Reader, path string) error {
+			rel, err := filepath.Rel(indexDir, path)
+			if err != nil {
+				return xerrors.Errorf("relative path error: %w", err)
+			}
+			fmt.Fprintf(h, "index %s\n", filepath.ToSlash(rel))
+			if _, err = io.Copy(h, r); err != nil {
+				return xerrors.Errorf("read error: %w", err)
+			}
+			return nil
+		}); err != nil {
+			return "", xerrors.Errorf("walk error: %w", err)
+		}
+		if err := digestFile(h, "crawl report", filepath.Join(cacheDir, crawler.ReportFile)); err != nil {
+			return "", err
+		}
+	}
+
+	for _, feed := range []string{opt.RankingFeed, opt.PopularityFeed, opt.DeprecationFeed} {
+		if err := digestFile(h, "feed", feed); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// digestFile writes `name` and the content of `path` into `w`. Missing files and empty paths are written as absent.
+func digestFile(w io.Writer, name, path string) error {
+	if path == "" {
+		fmt.Fprintf(w, "no %s\n", name)
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(w, "no %s %s\n", name, path)
+		return nil
+	} else if err != nil {
+		return xerrors.Errorf("unable to read the %s: %w", name, err)
+	}
+	fmt.Fprintf(w, "%s %s %d\n", name, path, len(b))
+	_, err = w.Write(b)
+	return err
+}
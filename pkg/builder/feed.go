@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// loadRankingFeed reads artifact priorities from the CSV file.
+// Each record is `group_id,artifact_id,priority`. Empty artifact_id applies the priority to the whole group.
+// Later records override earlier ones, so group-wide records should go first.
+// e.g.
+//
+//	# group_id,artifact_id,priority
+//	javax.servlet,,10
+//	javax.servlet,jstl,100
+func loadRankingFeed(path string) ([]types.ArtifactPriority, error) {
+	var priorities []types.ArtifactPriority
+	err := readFeed(path, 3, func(record []string) error {
+		priority, err := strconv.Atoi(record[2])
+		if err != nil {
+			return xerrors.Errorf("invalid priority for %s:%s: %w", record[0], record[1], err)
+		}
+		priorities = append(priorities, types.ArtifactPriority{
+			GroupID:    record[0],
+			ArtifactID: record[1],
+			Priority:   priority,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return priorities, nil
+}
+
+// loadPopularityFeed reads download statistics from the CSV file.
+// Each record is `group_id,artifact_id,downloads`.
+// e.g.
+//
+//	# group_id,artifact_id,downloads
+//	org.apache.logging.log4j,log4j-core,1250000
+func loadPopularityFeed(path string) ([]types.Popularity, error) {
+	var popularity []types.Popularity
+	err := readFeed(path, 3, func(record []string) error {
+		downloads, err := strconv.ParseInt(record[2], 10, 64)
+		if err != nil {
+			return xerrors.Errorf("invalid downloads for %s:%s: %w", record[0], record[1], err)
+		}
+		popularity = append(popularity, types.Popularity{
+			GroupID:    record[0],
+			ArtifactID: record[1],
+			Downloads:  downloads,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return popularity, nil
+}
+
+// loadDeprecationFeed reads deprecated artifacts from the CSV file.
+// Each record is `group_id,artifact_id,replacement,reason`. Empty artifact_id deprecates the whole group.
+// e.g.
+//
+//	# group_id,artifact_id,replacement,reason
+//	javax.servlet,servlet-api,jakarta.servlet:jakarta.servlet-api,moved to Jakarta EE
+//	commons-httpclient,,org.apache.httpcomponents:httpclient,end of life
+func loadDeprecationFeed(path string) ([]types.Deprecation, error) {
+	var deprecations []types.Deprecation
+	err := readFeed(path, 4, func(record []string) error {
+		if record[0] == "" {
+			return xerrors.Errorf("empty group_id for %s", record[1])
+		}
+		deprecations = append(deprecations, types.Deprecation{
+			GroupID:     record[0],
+			ArtifactID:  record[1],
+			Replacement: record[2],
+			Reason:      record[3],
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deprecations, nil
+}
+
+// readFeed calls `fn` for each record of the CSV feed with `fields` columns. Fields are trimmed, lines starting with `#` are skipped.
+func readFeed(path string, fields int, fn func(record []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return xerrors.Errorf("unable to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = fields
+	r.TrimLeadingSpace = true
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return xerrors.Errorf("csv read error: %w", err)
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+	}
+}
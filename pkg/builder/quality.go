@@ -0,0 +1,127 @@
+package builder
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+)
+
+const (
+	// suspiciousVersionLength is the length over which versions are reported.
+	// It's below maxVersionLength, so versions close to being quarantined show up before they are.
+	suspiciousVersionLength = 64
+	// largeGroupVersions is the number of versions over which groups are reported, e.g. groups flooded by spam releases.
+	largeGroupVersions = 100000
+	// maxAnomalySamples is the max number of anomalies of each kind listed in the report. All of them are counted.
+	maxAnomalySamples = 100
+)
+
+// Kinds of anomalies
+const (
+	AnomalyNoVersions  = "no_versions"
+	AnomalyNoChecksum  = "no_checksum"
+	AnomalyLongVersion = "long_version"
+	AnomalyLargeGroup  = "large_group"
+)
+
+// Anomaly is suspicious upstream data found by the build. It's reported, but doesn't fail the build.
+type Anomaly struct {
+	Kind string
+	// Subject is the group, artifact or GAV of the anomaly.
+	Subject string
+	Detail  string `json:",omitempty"`
+}
+
+// QualityReport lists anomalies found by the build, so upstream data issues are caught before the DB is published.
+type QualityReport struct {
+	// Counts are the numbers of anomalies by kind.
+	Counts map[string]int
+	// Anomalies are the first anomalies of each kind.
+	Anomalies []Anomaly
+}
+
+type qualityChecker struct {
+	report        QualityReport
+	groupVersions map[string]int
+}
+
+func newQualityChecker() *qualityChecker {
+	return &qualityChecker{
+		report:        QualityReport{Counts: make(map[string]int)},
+		groupVersions: make(map[string]int),
+	}
+}
+
+func (q *qualityChecker) add(kind, subject, detail string) {
+	q.report.Counts[kind]++
+	if q.report.Counts[kind] <= maxAnomalySamples {
+		q.report.Anomalies = append(q.report.Anomalies, Anomaly{Kind: kind, Subject: subject, Detail: detail})
+	}
+}
+
+// check records anomalies of an index file.
+func (q *qualityChecker) check(index *crawler.Index) {
+	artifact := fmt.Sprintf("%s:%s", index.GroupID, index.ArtifactID)
+	if len(index.Versions) == 0 {
+		q.add(AnomalyNoVersions, artifact, "")
+	}
+	q.groupVersions[index.GroupID] += len(index.Versions)
+	for _, ver := range index.Versions {
+		gav := artifact + ":" + ver.Version
+		if len(ver.SHA1) == 0 && len(ver.MD5) == 0 {
+			q.add(AnomalyNoChecksum, gav, "")
+		}
+		if len(ver.Version) > suspiciousVersionLength {
+			q.add(AnomalyLongVersion, gav, fmt.Sprintf("%d characters", len(ver.Version)))
+		}
+	}
+}
+
+// finish records anomalies of groups and returns the report.
+func (q *qualityChecker) finish() QualityReport {
+	var groups []string
+	for groupID, n := range q.groupVersions {
+		if n > largeGroupVersions {
+			groups = append(groups, groupID)
+		}
+	}
+	sort.Strings(groups)
+	for _, groupID := range groups {
+		q.add(AnomalyLargeGroup, groupID, fmt.Sprintf("%d versions", q.groupVersions[groupID]))
+	}
+	return q.report
+}
+
+// total returns the number of all anomalies.
+func (r QualityReport) total() int {
+	var n int
+	for _, count := range r.Counts {
+		n += count
+	}
+	return n
+}
+
+// writeQualityReport logs the counts of `report` and writes it to `path`.
+func writeQualityReport(path string, report QualityReport) error {
+	kinds := make([]string, 0, len(report.Counts))
+	for kind := range report.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		log.Printf("Data quality: %d %s", report.Counts[kind], kind)
+	}
+	if path == "" {
+		return nil
+	}
+	if err := fileutil.WriteJSON(path, report); err != nil {
+		return xerrors.Errorf("failed to write the quality report: %w", err)
+	}
+	log.Printf("Quality report: %s", path)
+	return nil
+}
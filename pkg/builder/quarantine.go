@@ -0,0 +1,40 @@
+package builder
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// maxVersionLength is the max length of a valid version.
+// Longer versions are mostly broken dir names, e.g. version dirs with a build log pasted in the name.
+const maxVersionLength = 128
+
+// quarantineReason returns why `index` must not be inserted, or an empty string for valid indexes.
+// Invalid indexes are kept in the quarantine table, so that `verify` can report them.
+func quarantineReason(index types.Index) string {
+	switch {
+	case index.GroupID == "" || index.ArtifactID == "":
+		return "empty group or artifact id"
+	case index.Version == "":
+		return "empty version"
+	case len(index.Version) > maxVersionLength:
+		return fmt.Sprintf("version of %d characters", len(index.Version))
+	case len(index.SHA1) == 0 && len(index.MD5) == 0:
+		return "no checksum"
+	case len(index.SHA1) != 0 && len(index.SHA1) != sha1.Size:
+		return fmt.Sprintf("sha1 of %d bytes", len(index.SHA1))
+	case len(index.MD5) != 0 && len(index.MD5) != md5.Size:
+		return fmt.Sprintf("md5 of %d bytes", len(index.MD5))
+	}
+	if i := strings.IndexFunc(index.Version, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || r == '/'
+	}); i >= 0 {
+		return fmt.Sprintf("invalid character %q in version", []rune(index.Version[i:])[0])
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+)
+
+// IndexSource iterates over the artifact indexes of one repository.
+// Applications embedding the builder implement it to build DBs from their own data, e.g. CSV exports or internal catalogs.
+type IndexSource interface {
+	// Name identifies the source in logs, and is stored as the repository of its indexes.
+	Name() string
+	// Len returns the number of indexes for progress reporting, or 0 if it's unknown.
+	Len() (int, error)
+	// Next returns the next index, or io.EOF when there are no more indexes.
+	// An index must hold all versions of its artifact, since versions missing from it are marked as removed.
+	Next() (*crawler.Index, error)
+	Close() error
+}
+
+var errSourceClosed = errors.New("source closed")
+
+type sourceItem struct {
+	index *crawler.Index
+	err   error
+}
+
+// cacheDirSource reads the index files crawled into a cache dir.
+type cacheDirSource struct {
+	cacheDir string
+	indexDir string
+
+	start sync.Once
+	items chan sourceItem
+	close sync.Once
+	done  chan struct{}
+}
+
+// NewCacheDirSource returns a source of the index files in `cacheDir`, named after the cache dir.
+func NewCacheDirSource(cacheDir string) IndexSource {
+	return &cacheDirSource{
+		cacheDir: cacheDir,
+		indexDir: fileutil.AbsPath(filepath.Join(cacheDir, "indexes")),
+		items:    make(chan sourceItem),
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *cacheDirSource) Name() string {
+	return s.cacheDir
+}
+
+func (s *cacheDirSource) Len() (int, error) {
+	n, err := fileutil.Count(s.indexDir)
+	if err != nil {
+		return 0, xerrors.Errorf("count error: %w", err)
+	}
+	return n, nil
+}
+
+func (s *cacheDirSource) Next() (*crawler.Index, error) {
+	s.start.Do(func() {
+		go s.walk()
+	})
+	item, ok := <-s.items
+	if !ok {
+		return nil, io.EOF
+	}
+	return item.index, item.err
+}
+
+// walk sends the index files to `items` until they are all sent or the source is closed.
+func (s *cacheDirSource) walk() {
+	defer close(s.items)
+	err := fileutil.Walk(s.indexDir, func(r io.Reader, path string) error {
+		index := &crawler.Index{}
+		if err := json.NewDecoder(r).Decode(index); err != nil {
+			return xerrors.Errorf("failed to decode index: %w", err)
+		}
+		select {
+		case s.items <- sourceItem{index: index}:
+			return nil
+		case <-s.done:
+			return errSourceClosed
+		}
+	})
+	if err != nil && !errors.Is(err, errSourceClosed) {
+		select {
+		case s.items <- sourceItem{err: xerrors.Errorf("walk error: %w", err)}:
+		case <-s.done:
+		}
+	}
+}
+
+func (s *cacheDirSource) Close() error {
+	s.close.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+// sliceSource serves indexes held in memory.
+type sliceSource struct {
+	name    string
+	indexes []crawler.Index
+	next    int
+}
+
+// NewSliceSource returns a source of `indexes` named `name`.
+func NewSliceSource(name string, indexes []crawler.Index) IndexSource {
+	return &sliceSource{
+		name:    name,
+		indexes: indexes,
+	}
+}
+
+func (s *sliceSource) Name() string {
+	return s.name
+}
+
+func (s *sliceSource) Len() (int, error) {
+	return len(s.indexes), nil
+}
+
+func (s *sliceSource) Next() (*crawler.Index, error) {
+	if s.next >= len(s.indexes) {
+		return nil, io.EOF
+	}
+	s.next++
+	return &s.indexes[s.next-1], nil
+}
+
+func (s *sliceSource) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"database/sql"
+	"io"
+	"os"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// sqliteFileSource reads the indexes of a sqlite DB file built by aquasecurity/trivy-java-db.
+type sqliteFileSource struct {
+	path   string
+	client *sql.DB
+	rows   *sql.Rows
+	// pending is the row read ahead, which belongs to the next artifact
+	pending *sqliteFileRow
+}
+
+type sqliteFileRow struct {
+	id          int64
+	groupID     string
+	artifactID  string
+	version     string
+	sha1        []byte
+	archiveType sql.NullString
+}
+
+// NewSqliteFileSource returns a source of the indexes of the sqlite DB at `path`, named after the path.
+// The DB must have the `artifacts` and `indices` tables of DBs built by aquasecurity/trivy-java-db, which only store
+// GAVs, sha1s and archive types. DBs built with the normalized schema of this repository have them as well.
+// The file is opened read-only with the "sqlite" driver, which must be registered by the caller.
+func NewSqliteFileSource(path string) (IndexSource, error) {
+	// sqlite creates missing DB files, even in read-only mode
+	if _, err := os.Stat(path); err != nil {
+		return nil, xerrors.Errorf("db not found: %w", err)
+	}
+	client, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, xerrors.Errorf("can't open %s: %w", path, err)
+	}
+	return &sqliteFileSource{
+		path:   path,
+		client: client,
+	}, nil
+}
+
+func (s *sqliteFileSource) Name() string {
+	return s.path
+}
+
+func (s *sqliteFileSource) Len() (int, error) {
+	var n int
+	if err := s.client.QueryRow("SELECT COUNT(*) FROM artifacts").Scan(&n); err != nil {
+		return 0, xerrors.Errorf("count artifacts error: %w", err)
+	}
+	return n, nil
+}
+
+// Next returns the versions of the next artifact. Rows are read ordered by artifact, so each artifact is read once.
+func (s *sqliteFileSource) Next() (*crawler.Index, error) {
+	if s.rows == nil {
+		rows, err := s.client.Query(`
+			SELECT a.id, a.group_id, a.artifact_id, i.version, i.sha1, i.archive_type
+			FROM indices i
+			JOIN artifacts a ON a.id = i.artifact_id
+			ORDER BY a.id`)
+		if err != nil {
+			return nil, xerrors.Errorf("select indexes error: %w", err)
+		}
+		s.rows = rows
+	}
+
+	var index *crawler.Index
+	var id int64
+	for {
+		row := s.pending
+		s.pending = nil
+		if row == nil {
+			if !s.rows.Next() {
+				if err := s.rows.Err(); err != nil {
+					return nil, xerrors.Errorf("rows error: %w", err)
+				}
+				if index == nil {
+					return nil, io.EOF
+				}
+				return index, nil
+			}
+			row = &sqliteFileRow{}
+			if err := s.rows.Scan(&row.id, &row.groupID, &row.artifactID, &row.version, &row.sha1, &row.archiveType); err != nil {
+				return nil, xerrors.Errorf("scan row error: %w", err)
+			}
+		}
+
+		if index == nil {
+			index = &crawler.Index{GroupID: row.groupID, ArtifactID: row.artifactID}
+			id = row.id
+		} else if row.id != id {
+			s.pending = row
+			return index, nil
+		}
+		index.Versions = append(index.Versions, crawler.Version{
+			Version:     row.version,
+			SHA1:        row.sha1,
+			ArchiveType: types.ArchiveType(row.archiveType.String),
+		})
+	}
+}
+
+func (s *sqliteFileSource) Close() error {
+	if s.rows != nil {
+		_ = s.rows.Close()
+	}
+	return s.client.Close()
+}
@@ -0,0 +1,154 @@
+package builder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// Mismatch is a version of an index file which isn't stored in the DB as the index file has it.
+type Mismatch struct {
+	GAV        string
+	Classifier string `json:",omitempty"`
+	Repository string
+	Reason     string
+}
+
+// VerifyResult is the result of Verify.
+type VerifyResult struct {
+	// Artifacts and Versions are the numbers of checked index files and versions.
+	Artifacts  int
+	Versions   int
+	Mismatches []Mismatch
+}
+
+// Verify checks that the versions of `sample` index files of `src`, chosen with `rng`, are stored in `dbc` as `src` has
+// them. It catches builder bugs which silently drop or change rows. All index files are checked if `sample` is 0.
+// Quarantined versions and versions whose sha1 is stored for another GAV are skipped, since builds don't store them.
+// Versions of a GAV published with other content in a later source of the build are reported as mismatches.
+func Verify(dbc db.DB, src IndexSource, sample int, rng *rand.Rand) (VerifyResult, error) {
+	defer src.Close()
+
+	indexes, err := sampleIndexes(src, sample, rng)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	collisions, err := dbc.SelectCollisions()
+	if err != nil {
+		return VerifyResult{}, xerrors.Errorf("failed to select collisions: %w", err)
+	}
+	shared := map[string]struct{}{}
+	for _, c := range collisions {
+		for _, index := range c.Indexes {
+			shared[collisionKey(index)] = struct{}{}
+		}
+	}
+
+	result := VerifyResult{Artifacts: len(indexes)}
+	for _, index := range indexes {
+		groupID, artifactID := db.CanonicalID(index.GroupID), db.CanonicalID(index.ArtifactID)
+		stored, err := dbc.SelectVersionsByArtifactIDAndGroupID(artifactID, groupID)
+		if err != nil {
+			return result, xerrors.Errorf("failed to select versions of %s:%s: %w", groupID, artifactID, err)
+		}
+		byKey := map[string]types.Index{}
+		for _, s := range stored {
+			byKey[versionKey(s)] = s
+		}
+
+		for _, ver := range index.Versions {
+			want := newIndex(index, ver, src.Name())
+			if quarantineReason(want) != "" {
+				continue
+			}
+			result.Versions++
+
+			reason := "missing"
+			if got, ok := byKey[versionKey(want)]; ok {
+				reason = diffIndex(want, got)
+			} else if _, ok = shared[collisionKey(want)]; ok {
+				continue
+			}
+			if reason != "" {
+				result.Mismatches = append(result.Mismatches, Mismatch{
+					GAV:        fmt.Sprintf("%s:%s:%s", want.GroupID, want.ArtifactID, want.Version),
+					Classifier: want.Classifier,
+					Repository: want.Repository,
+					Reason:     reason,
+				})
+			}
+		}
+	}
+	sort.Slice(result.Mismatches, func(i, j int) bool {
+		a, b := result.Mismatches[i], result.Mismatches[j]
+		if a.GAV != b.GAV {
+			return a.GAV < b.GAV
+		}
+		return a.Classifier < b.Classifier
+	})
+	return result, nil
+}
+
+// sampleIndexes returns `sample` index files of `src` chosen uniformly with reservoir sampling, or all of them if
+// `sample` is 0.
+func sampleIndexes(src IndexSource, sample int, rng *rand.Rand) ([]*crawler.Index, error) {
+	var indexes []*crawler.Index
+	for seen := 0; ; seen++ {
+		index, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return indexes, nil
+		} else if err != nil {
+			return nil, xerrors.Errorf("failed to read %s: %w", src.Name(), err)
+		}
+		if sample == 0 || len(indexes) < sample {
+			indexes = append(indexes, index)
+		} else if i := rng.Intn(seen + 1); i < sample {
+			indexes[i] = index
+		}
+	}
+}
+
+// versionKey identifies the file of a version. Versions can be published with several archive types and classifiers.
+func versionKey(index types.Index) string {
+	return strings.Join([]string{index.Version, string(index.ArchiveType), index.Classifier}, "\x00")
+}
+
+// collisionKey identifies a GAV with its sha1, as recorded in the collisions table.
+func collisionKey(index types.Index) string {
+	return strings.Join([]string{string(index.SHA1), index.GroupID, index.ArtifactID, index.Version}, "\x00")
+}
+
+// diffIndex returns the fields of the stored index `got` which differ from `want`, or an empty string.
+func diffIndex(want, got types.Index) string {
+	var fields []string
+	if !bytes.Equal(want.SHA1, got.SHA1) {
+		fields = append(fields, "sha1")
+	}
+	if !bytes.Equal(want.MD5, got.MD5) {
+		fields = append(fields, "md5")
+	}
+	if want.Size != got.Size {
+		fields = append(fields, "size")
+	}
+	if want.Signed != got.Signed || want.SigningKey != got.SigningKey {
+		fields = append(fields, "signature")
+	}
+	if want.Platform != got.Platform {
+		fields = append(fields, "platform")
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return "different " + strings.Join(fields, ", ")
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+)
+
+// Group is a summary of the index files of a group in the cache.
+type Group struct {
+	GroupID   string
+	Artifacts int
+	Versions  int
+}
+
+// Problem is an index file which the builder skips or reads wrongly.
+type Problem struct {
+	Path   string
+	Reason string
+}
+
+func indexDir(cacheDir string) string {
+	return filepath.Join(cacheDir, "indexes")
+}
+
+// Groups returns the groups of the index files in `cacheDir`, sorted by group id.
+// Dir names can't be used, since long group ids are shortened.
+func Groups(cacheDir string) ([]Group, error) {
+	groups := make(map[string]*Group)
+	if err := fileutil.Walk(indexDir(cacheDir), func(r io.Reader, path string) error {
+		var index crawler.Index
+		if err := json.NewDecoder(r).Decode(&index); err != nil {
+			return xerrors.Errorf("failed to decode %s: %w", path, err)
+		}
+		g, ok := groups[index.GroupID]
+		if !ok {
+			g = &Group{GroupID: index.GroupID}
+			groups[index.GroupID] = g
+		}
+		g.Artifacts++
+		g.Versions += len(index.Versions)
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("walk error: %w", err)
+	}
+
+	var result []Group
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GroupID < result[j].GroupID })
+	return result, nil
+}
+
+// Indexes returns the index files of `groupID` in `cacheDir`, sorted by artifact id.
+func Indexes(cacheDir, groupID string) ([]crawler.Index, error) {
+	var indexes []crawler.Index
+	dir := filepath.Join(indexDir(cacheDir), fileutil.ShortName(groupID))
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err := fileutil.Walk(dir, func(r io.Reader, path string) error {
+		var index crawler.Index
+		if err := json.NewDecoder(r).Decode(&index); err != nil {
+			return xerrors.Errorf("failed to decode %s: %w", path, err)
+		}
+		// Shortened names of different group ids may collide
+		if index.GroupID == groupID {
+			indexes = append(indexes, index)
+		}
+		return nil
+	}); err != nil {
+		return nil, xerrors.Errorf("walk error: %w", err)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].ArtifactID < indexes[j].ArtifactID })
+	return indexes, nil
+}
+
+// Check returns the problems of the index files in `cacheDir`.
+// Versions are validated by the builder, which quarantines invalid ones.
+func Check(cacheDir string) ([]Problem, error) {
+	var problems []Problem
+	root := indexDir(cacheDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return xerrors.Errorf("relative path error: %w", err)
+		}
+		if reason, err := checkFile(path, rel); err != nil {
+			return err
+		} else if reason != "" {
+			problems = append(problems, Problem{Path: rel, Reason: reason})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("walk error: %w", err)
+	}
+	return problems, nil
+}
+
+// checkFile returns why the index file at `path` is broken, or an empty string for valid files.
+func checkFile(path, rel string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", xerrors.Errorf("unable to read %s: %w", path, err)
+	}
+	if len(b) == 0 {
+		return "empty file", nil
+	}
+	var index crawler.Index
+	if err = json.Unmarshal(b, &index); err != nil {
+		return fmt.Sprintf("invalid JSON: %s", err), nil
+	}
+	switch {
+	case index.GroupID == "" || index.ArtifactID == "":
+		return "empty group or artifact id", nil
+	case len(index.Versions) == 0:
+		return "no versions", nil
+	}
+	// Crawls write index files to this path, so files elsewhere are never updated
+	want := filepath.Join(fileutil.ShortName(index.GroupID), fileutil.ShortName(index.ArtifactID+".json"))
+	if rel != want {
+		return fmt.Sprintf("%s:%s is expected at %s", index.GroupID, index.ArtifactID, filepath.ToSlash(want)), nil
+	}
+	seen := make(map[string]bool)
+	for _, ver := range index.Versions {
+		// Versions include the classifier
+		key := ver.Version + " " + string(ver.ArchiveType)
+		if seen[key] {
+			return fmt.Sprintf("duplicate version %s", ver.Version), nil
+		}
+		seen[key] = true
+	}
+	return "", nil
+}
@@ -0,0 +1,88 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/cache"
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func writeIndex(t *testing.T, cacheDir, path string, index crawler.Index) {
+	require.NoError(t, fileutil.WriteJSON(filepath.Join(cacheDir, "indexes", path), index))
+}
+
+func TestGroupsAndIndexes(t *testing.T) {
+	cacheDir := t.TempDir()
+	jstl := crawler.Index{
+		GroupID:     "jstl",
+		ArtifactID:  "jstl",
+		Versions:    []crawler.Version{{Version: "1.0"}, {Version: "1.2"}},
+		ArchiveType: types.JarType,
+	}
+	writeIndex(t, cacheDir, "jstl/jstl.json", jstl)
+	standard := crawler.Index{
+		GroupID:     "jstl",
+		ArtifactID:  "standard",
+		Versions:    []crawler.Version{{Version: "1.1.2"}},
+		ArchiveType: types.JarType,
+	}
+	writeIndex(t, cacheDir, "jstl/standard.json", standard)
+	writeIndex(t, cacheDir, "abbot/abbot.json", crawler.Index{
+		GroupID:     "abbot",
+		ArtifactID:  "abbot",
+		Versions:    []crawler.Version{{Version: "0.12.3"}},
+		ArchiveType: types.JarType,
+	})
+
+	groups, err := cache.Groups(cacheDir)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.Group{
+		{GroupID: "abbot", Artifacts: 1, Versions: 1},
+		{GroupID: "jstl", Artifacts: 2, Versions: 3},
+	}, groups)
+
+	indexes, err := cache.Indexes(cacheDir, "jstl")
+	require.NoError(t, err)
+	assert.Equal(t, []crawler.Index{jstl, standard}, indexes)
+
+	indexes, err = cache.Indexes(cacheDir, "javax.servlet")
+	require.NoError(t, err)
+	assert.Empty(t, indexes)
+}
+
+func TestCheck(t *testing.T) {
+	cacheDir := t.TempDir()
+	valid := crawler.Index{
+		GroupID:     "jstl",
+		ArtifactID:  "jstl",
+		Versions:    []crawler.Version{{Version: "1.0"}, {Version: "1.0", ArchiveType: types.KlibType}},
+		ArchiveType: types.JarType,
+	}
+	writeIndex(t, cacheDir, "jstl/jstl.json", valid)
+	writeIndex(t, cacheDir, "jstl/moved.json", valid)
+	writeIndex(t, cacheDir, "jstl/standard.json", crawler.Index{GroupID: "jstl", ArtifactID: "standard"})
+	writeIndex(t, cacheDir, "abbot/abbot.json", crawler.Index{
+		GroupID:    "abbot",
+		ArtifactID: "abbot",
+		Versions:   []crawler.Version{{Version: "0.12.3"}, {Version: "0.12.3"}},
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "indexes", "jstl", "empty.json"), nil, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "indexes", "jstl", "broken.json"), []byte(`{"GroupID":`), 0600))
+
+	got, err := cache.Check(cacheDir)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.Problem{
+		{Path: filepath.Join("abbot", "abbot.json"), Reason: "duplicate version 0.12.3"},
+		{Path: filepath.Join("jstl", "broken.json"), Reason: "invalid JSON: unexpected end of JSON input"},
+		{Path: filepath.Join("jstl", "empty.json"), Reason: "empty file"},
+		{Path: filepath.Join("jstl", "moved.json"), Reason: "jstl:jstl is expected at jstl/jstl.json"},
+		{Path: filepath.Join("jstl", "standard.json"), Reason: "no versions"},
+	}, got)
+}
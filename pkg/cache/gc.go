@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+)
+
+// tempFileAge is the min age of removed temp files.
+// Younger ones may be written by a running crawl.
+const tempFileAge = time.Hour
+
+type GCOption struct {
+	// ExcludeGroups are group ids whose index files are removed, including their subgroups.
+	ExcludeGroups []string
+	// Retention removes index files and HTTP cache entries not written or used by crawls within this period.
+	// Nothing expires if 0.
+	Retention time.Duration
+	// DryRun only reports what would be removed.
+	DryRun bool
+}
+
+// GCStats is a summary of removed files.
+type GCStats struct {
+	// Excluded is the number of index files of excluded groups.
+	Excluded int
+	// Expired is the number of index files and HTTP cache entries older than the retention.
+	Expired int
+	// TempFiles is the number of temp files left by interrupted writes.
+	TempFiles int
+	// Bytes is the size of all removed files.
+	Bytes int64
+}
+
+type collector struct {
+	now   time.Time
+	opt   GCOption
+	stats GCStats
+}
+
+// GC removes index files of excluded groups, expired index files and HTTP cache entries, and orphaned temp files
+// from `cacheDir`. The DB dir isn't touched.
+func GC(cacheDir string, opt GCOption) (GCStats, error) {
+	c := collector{now: time.Now(), opt: opt}
+	if err := c.indexes(indexDir(cacheDir)); err != nil {
+		return c.stats, xerrors.Errorf("index gc error: %w", err)
+	}
+	if err := c.httpCache(filepath.Join(cacheDir, "http")); err != nil {
+		return c.stats, xerrors.Errorf("http cache gc error: %w", err)
+	}
+	if err := c.tempFiles(cacheDir); err != nil {
+		return c.stats, xerrors.Errorf("temp file gc error: %w", err)
+	}
+	return c.stats, nil
+}
+
+func (c *collector) expired(info fs.FileInfo) bool {
+	return c.opt.Retention > 0 && c.now.Sub(info.ModTime()) > c.opt.Retention
+}
+
+func (c *collector) excluded(groupID string) bool {
+	for _, g := range c.opt.ExcludeGroups {
+		if groupID == g || strings.HasPrefix(groupID, g+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes `path` of `size` bytes and counts it with `counter`.
+func (c *collector) remove(path string, size int64, counter *int) error {
+	if !c.opt.DryRun {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return xerrors.Errorf("unable to remove %s: %w", path, err)
+		}
+	}
+	*counter++
+	c.stats.Bytes += size
+	return nil
+}
+
+// indexes removes index files of excluded groups and expired ones. Group dirs left empty are removed too.
+// Broken files are kept for `cache check`.
+func (c *collector) indexes(root string) error {
+	var dirs []string
+	err := walkFiles(root, func(path string, d fs.DirEntry, info fs.FileInfo) error {
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		} else if strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		if len(c.opt.ExcludeGroups) > 0 {
+			if groupID, ok := readGroupID(path); ok && c.excluded(groupID) {
+				return c.remove(path, info.Size(), &c.stats.Excluded)
+			}
+		}
+		if c.expired(info) {
+			return c.remove(path, info.Size(), &c.stats.Expired)
+		}
+		return nil
+	})
+	if err != nil || c.opt.DryRun {
+		return err
+	}
+	// Deeper dirs first. Dirs which still have files can't be removed.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		if dir != root {
+			_ = os.Remove(dir)
+		}
+	}
+	return nil
+}
+
+// httpCache removes HTTP cache entries which weren't used by crawls within the retention, and bodies without entries.
+// Crawls touch entries when they reuse them.
+func (c *collector) httpCache(root string) error {
+	return walkFiles(root, func(path string, d fs.DirEntry, info fs.FileInfo) error {
+		if d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		if strings.HasSuffix(path, ".json") {
+			if !c.expired(info) {
+				return nil
+			}
+			body := strings.TrimSuffix(path, ".json")
+			if bodyInfo, err := os.Stat(body); err == nil {
+				c.stats.Bytes += bodyInfo.Size()
+				if !c.opt.DryRun {
+					if err = os.Remove(body); err != nil {
+						return xerrors.Errorf("unable to remove %s: %w", body, err)
+					}
+				}
+			}
+			return c.remove(path, info.Size(), &c.stats.Expired)
+		}
+		// Bodies are stored before their entries, so young bodies may still get one
+		if _, err := os.Stat(path + ".json"); errors.Is(err, os.ErrNotExist) && c.now.Sub(info.ModTime()) > tempFileAge {
+			return c.remove(path, info.Size(), &c.stats.TempFiles)
+		}
+		return nil
+	})
+}
+
+// tempFiles removes `*.tmp` files left by interrupted writes.
+func (c *collector) tempFiles(cacheDir string) error {
+	dbDir := filepath.Join(cacheDir, "db")
+	return walkFiles(cacheDir, func(path string, d fs.DirEntry, info fs.FileInfo) error {
+		if d.IsDir() {
+			if path == dbDir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".tmp") && c.now.Sub(info.ModTime()) > tempFileAge {
+			return c.remove(path, info.Size(), &c.stats.TempFiles)
+		}
+		return nil
+	})
+}
+
+// walkFiles walks `root` like filepath.WalkDir, passing the info of each entry. A missing root is skipped.
+func walkFiles(root string, fn func(path string, d fs.DirEntry, info fs.FileInfo) error) error {
+	if _, err := os.Stat(root); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return xerrors.Errorf("file info error: %w", err)
+		}
+		return fn(path, d, info)
+	})
+}
+
+// readGroupID returns the group id of the index file at `path`. Broken files are logged.
+func readGroupID(path string) (string, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Unable to read %s: %s", path, err)
+		return "", false
+	}
+	var index crawler.Index
+	if err = json.Unmarshal(b, &index); err != nil {
+		log.Printf("Unable to decode %s, run `cache check`: %s", path, err)
+		return "", false
+	}
+	return index.GroupID, true
+}
@@ -0,0 +1,92 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/cache"
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+)
+
+func TestGC(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	setup := func(t *testing.T) string {
+		cacheDir := t.TempDir()
+		for _, index := range []crawler.Index{
+			{GroupID: "jstl", ArtifactID: "jstl", Versions: []crawler.Version{{Version: "1.0"}}},
+			{GroupID: "org.example", ArtifactID: "app", Versions: []crawler.Version{{Version: "1.0"}}},
+			{GroupID: "org.example.tools", ArtifactID: "cli", Versions: []crawler.Version{{Version: "1.0"}}},
+			{GroupID: "org.examples", ArtifactID: "demo", Versions: []crawler.Version{{Version: "1.0"}}},
+		} {
+			writeIndex(t, cacheDir, filepath.Join(index.GroupID, index.ArtifactID+".json"), index)
+		}
+		// Not crawled for 2 days
+		oldIndex := filepath.Join(cacheDir, "indexes", "org.examples", "demo.json")
+		require.NoError(t, os.Chtimes(oldIndex, old, old))
+
+		files := map[string]time.Time{
+			"http/ab/abcd":         old,
+			"http/ab/abcd.json":    old,
+			"http/cd/cdef":         time.Now(),
+			"http/cd/cdef.json":    time.Now(),
+			"http/ef/efgh":         old,
+			"status.json.tmp":      old,
+			"http/cd/cdef.123.tmp": time.Now(),
+			"db/trivy-java.db.tmp": old,
+		}
+		for name, mtime := range files {
+			path := filepath.Join(cacheDir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+			require.NoError(t, os.WriteFile(path, []byte("12345"), 0600))
+			require.NoError(t, os.Chtimes(path, mtime, mtime))
+		}
+		return cacheDir
+	}
+	opt := cache.GCOption{ExcludeGroups: []string{"org.example"}, Retention: 24 * time.Hour}
+
+	t.Run("dry run", func(t *testing.T) {
+		cacheDir := setup(t)
+		dryRun := opt
+		dryRun.DryRun = true
+		stats, err := cache.GC(cacheDir, dryRun)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Excluded)
+		assert.Equal(t, 2, stats.Expired)
+		assert.Equal(t, 2, stats.TempFiles)
+		assert.FileExists(t, filepath.Join(cacheDir, "indexes", "org.example", "app.json"))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		cacheDir := setup(t)
+		stats, err := cache.GC(cacheDir, opt)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Excluded)
+		assert.Equal(t, 2, stats.Expired)
+		assert.Equal(t, 2, stats.TempFiles)
+		assert.Positive(t, stats.Bytes)
+
+		groups, err := cache.Groups(cacheDir)
+		require.NoError(t, err)
+		assert.Equal(t, []cache.Group{{GroupID: "jstl", Artifacts: 1, Versions: 1}}, groups)
+		assert.NoDirExists(t, filepath.Join(cacheDir, "indexes", "org.example"))
+
+		for name, exists := range map[string]bool{
+			"http/ab/abcd":         false,
+			"http/ab/abcd.json":    false,
+			"http/cd/cdef":         true,
+			"http/cd/cdef.json":    true,
+			"http/ef/efgh":         false,
+			"status.json.tmp":      false,
+			"http/cd/cdef.123.tmp": true,
+			"db/trivy-java.db.tmp": true,
+		} {
+			_, err = os.Stat(filepath.Join(cacheDir, name))
+			assert.Equal(t, exists, err == nil, name)
+		}
+	})
+}
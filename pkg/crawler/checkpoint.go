@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+)
+
+// checkpointFile is the state of a crawl which stopped at its budget, in the cache dir.
+const checkpointFile = "crawl-checkpoint.json"
+
+// checkpoint holds the dirs left to visit and the counters of the crawl report, so that the next crawl continues the report.
+type checkpoint struct {
+	RepositoryURL string
+	Shard         string `json:",omitempty"`
+	StartedAt     time.Time
+	Pending       []string
+	VisitedURLs   int64
+	Groups        []string
+	Artifacts     int
+	Versions      int
+	Bytes         int64
+	Errors        map[string]int `json:",omitempty"`
+}
+
+func (c *Crawler) checkpointPath() string {
+	return filepath.Join(filepath.Dir(c.dir), checkpointFile)
+}
+
+// loadCheckpoint reads the checkpoint of the crawl. It returns nil if there is none or it's for another repository or shard.
+func (c *Crawler) loadCheckpoint() (*checkpoint, error) {
+	b, err := os.ReadFile(c.checkpointPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, xerrors.Errorf("unable to read the crawl checkpoint: %w", err)
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(b, &cp); err != nil {
+		return nil, xerrors.Errorf("unable to decode the crawl checkpoint: %w", err)
+	}
+	if cp.RepositoryURL != c.rootUrl || cp.Shard != c.shardName() || len(cp.Pending) == 0 {
+		log.Printf("Ignore the crawl checkpoint of %s %s", cp.RepositoryURL, cp.Shard)
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+// restore seeds the counters of the crawl with the checkpoint.
+func (c *Crawler) restore(cp *checkpoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	atomic.StoreInt64(&c.visited, cp.VisitedURLs)
+	atomic.StoreInt64(c.bytes, cp.Bytes)
+	for _, groupID := range cp.Groups {
+		c.groups[groupID] = struct{}{}
+	}
+	c.artifacts = cp.Artifacts
+	c.versions = cp.Versions
+	for errType, n := range cp.Errors {
+		c.errors[errType] = n
+	}
+}
+
+// saveCheckpoint writes the dirs not visited within the budget. A complete crawl removes the checkpoint.
+func (c *Crawler) saveCheckpoint(start time.Time) error {
+	if len(c.pending) == 0 {
+		if err := os.Remove(c.checkpointPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return xerrors.Errorf("unable to remove the crawl checkpoint: %w", err)
+		}
+		return nil
+	}
+
+	c.mu.Lock()
+	groups := lo.Keys(c.groups)
+	sort.Strings(groups)
+	cp := checkpoint{
+		RepositoryURL: c.rootUrl,
+		Shard:         c.shardName(),
+		StartedAt:     start.UTC(),
+		Pending:       c.pending,
+		VisitedURLs:   atomic.LoadInt64(&c.visited),
+		Groups:        groups,
+		Artifacts:     c.artifacts,
+		Versions:      c.versions,
+		Bytes:         atomic.LoadInt64(c.bytes),
+		Errors:        lo.Assign(c.errors),
+	}
+	c.mu.Unlock()
+
+	log.Printf("Crawl budget exceeded, %d dirs are left for the next crawl", len(cp.Pending))
+	if err := fileutil.WriteJSON(c.checkpointPath(), cp); err != nil {
+		return xerrors.Errorf("crawl checkpoint write error: %w", err)
+	}
+	return nil
+}
@@ -3,82 +3,337 @@ package crawler
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
 	"encoding/hex"
 	"encoding/xml"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/samber/lo"
+	"golang.org/x/net/html"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 	"golang.org/x/xerrors"
 
 	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/status"
 	"github.com/h7hac9/trivy-java-db/pkg/types"
 )
 
-const mavenRepoURL = "https://repo.maven.apache.org/maven2/"
+const (
+	mavenRepoURL = "https://repo.maven.apache.org/maven2/"
+
+	// maxChecksumSize is the max size of `*.sha1` and `*.md5` files.
+	// Checksum files with additional data are still much smaller.
+	maxChecksumSize = 1024
+	// defaultMaxBodySize is the default max size of other responses, e.g. dir listings of huge groups.
+	defaultMaxBodySize = 64 << 20
+	// shardDepth is the depth of the dirs assigned to shards.
+	// Artifact dirs are at least at this depth, since they are below their group dir.
+	shardDepth = 2
+)
 
 type Crawler struct {
 	dir  string
 	http *retryablehttp.Client
 
-	rootUrl         string
-	wg              sync.WaitGroup
-	urlCh           chan string
-	limit           *semaphore.Weighted
+	rootUrl       string
+	wg            sync.WaitGroup
+	urlCh         chan string
+	limit         *semaphore.Weighted
+	artifactLimit int64
+	md5           bool
+	signatures    bool
+	gradleModules bool
+	poms          bool
+	httpCache     *httpCache
+
+	coverageThreshold float64
+	coverageWarnOnly  bool
+
+	shard, shards int
+
+	// maxDuration and maxRequests are the budget of Crawl. Dirs not visited within it are saved in the checkpoint.
+	maxDuration time.Duration
+	maxRequests int64
+	budgeted    bool
+	deadline    time.Time
+
+	// groupURLs are the dirs of the groups of CrawlGroups. Other dirs without maven-metadata.xml aren't descended into.
+	groupURLs map[string]bool
+
+	status *status.Reporter
+	// expectedArtifacts is the number of artifacts of the last complete crawl, reported as the total in the status.
+	expectedArtifacts int
+
+	// visited is updated by the HTTP loop, which may still run when Crawl returns on error.
+	visited int64
+	// requests and bytes are shared with the HTTP transport.
+	requests *int64
+	bytes    *int64
+
+	// mu guards the counters of the crawl report below.
+	mu              *sync.Mutex
 	wrongSHA1Values []string
+	errors          map[string]int
+	groups          map[string]struct{}
+	artifacts       int
+	versions        int
+	// pending are the dirs not visited because the budget ran out.
+	pending []string
+}
+
+// Stats is a summary of the crawl.
+type Stats struct {
+	VisitedURLs int
+	WrongSHA1   int
+	// CachedResponses is the number of responses reused from the HTTP cache.
+	CachedResponses int
+	Groups          int
+	Artifacts       int
+	Versions        int
+	Bytes           int64
+	// Errors counts skipped files by error type.
+	Errors map[string]int
+	// Partial is set when Crawl stopped at its budget. The next Crawl resumes from the checkpoint.
+	Partial bool
 }
 
 type Option struct {
-	Limit    int64
-	RootUrl  string
-	CacheDir string
+	Limit int64
+	// ArtifactLimit is the number of version dirs of one artifact checked in parallel. Defaults to 1.
+	ArtifactLimit int64
+	RootUrl       string
+	CacheDir      string
+	// MD5 enables fetching `*.jar.md5` files in addition to `*.jar.sha1` files.
+	MD5 bool
+	// Signatures enables fetching `*.jar.asc` files to record signing keys.
+	Signatures bool
+	// GradleModules enables fetching Gradle module metadata (`*.module` files) to index variant jars listed there.
+	GradleModules bool
+	// Poms enables indexing `*.pom` files of artifacts without archives, e.g. BOMs and parent poms.
+	Poms bool
+	// HTTPCache enables storing responses in `<CacheDir>/http` to send conditional requests in later crawls.
+	HTTPCache bool
+	// CoverageThreshold fails the crawl when groups or artifacts drop by more than this percentage
+	// compared to the last complete crawl. 0 disables the check.
+	CoverageThreshold float64
+	// CoverageWarnOnly logs drops over CoverageThreshold instead of failing the crawl.
+	CoverageWarnOnly bool
+	// MaxBodySize fails the crawl on larger responses. Defaults to 64 MiB.
+	// `*.sha1` and `*.md5` files over 1 KiB are skipped as malformed.
+	MaxBodySize int64
+	// Status receives the progress of the crawl.
+	Status *status.Reporter
+	// Shard and Shards split the repository into `Shards` disjoint parts and crawl only part `Shard` (1-based).
+	// The whole repository is crawled if Shards is 0.
+	Shard, Shards int
+	// MaxDuration and MaxRequests stop Crawl after this time or number of HTTP requests (0 for no limit).
+	// Dirs which aren't visited yet are saved in a checkpoint in the cache dir, and the next Crawl resumes from there.
+	// Artifacts being crawled are finished, so the budget may be exceeded a little.
+	MaxDuration time.Duration
+	MaxRequests int64
 }
 
 func NewCrawler(opt Option) Crawler {
 	client := retryablehttp.NewClient()
 	client.RetryMax = 10
 	client.Logger = nil
+	client.ErrorHandler = giveUp
 
 	if opt.RootUrl == "" {
 		opt.RootUrl = mavenRepoURL
 	}
+	if opt.ArtifactLimit < 1 {
+		opt.ArtifactLimit = 1
+	}
+	if opt.MaxBodySize == 0 {
+		opt.MaxBodySize = defaultMaxBodySize
+	}
 
-	indexDir := filepath.Join(opt.CacheDir, "indexes")
+	indexDir := fileutil.AbsPath(filepath.Join(opt.CacheDir, "indexes"))
 	log.Printf("Index dir %s", indexDir)
 
+	// Requests and bytes are counted below the HTTP cache to only count the network.
+	requests, bytes := new(int64), new(int64)
+	client.HTTPClient.Transport = countingTransport{
+		next:        client.HTTPClient.Transport,
+		requests:    requests,
+		bytes:       bytes,
+		maxBodySize: opt.MaxBodySize,
+	}
+	var cache *httpCache
+	if opt.HTTPCache {
+		cache = &httpCache{
+			dir:  fileutil.AbsPath(filepath.Join(opt.CacheDir, "http")),
+			next: client.HTTPClient.Transport,
+		}
+		client.HTTPClient.Transport = cache
+	}
+
+	var expectedArtifacts int
+	if opt.Status != nil {
+		if last, err := lastCompleteReport(opt.CacheDir); err != nil {
+			log.Printf("Unable to estimate the crawl size: %s", err)
+		} else if last != nil {
+			expectedArtifacts = last.Artifacts
+		}
+	}
+
 	return Crawler{
 		dir:  indexDir,
 		http: client,
 
-		rootUrl: opt.RootUrl,
-		urlCh:   make(chan string, opt.Limit*10),
-		limit:   semaphore.NewWeighted(opt.Limit),
+		rootUrl:       opt.RootUrl,
+		urlCh:         make(chan string, opt.Limit*10),
+		limit:         semaphore.NewWeighted(opt.Limit),
+		artifactLimit: opt.ArtifactLimit,
+		md5:           opt.MD5,
+		signatures:    opt.Signatures,
+		gradleModules: opt.GradleModules,
+		poms:          opt.Poms,
+		httpCache:     cache,
+
+		coverageThreshold: opt.CoverageThreshold,
+		coverageWarnOnly:  opt.CoverageWarnOnly,
+		shard:             opt.Shard,
+		shards:            opt.Shards,
+		maxDuration:       opt.MaxDuration,
+		maxRequests:       opt.MaxRequests,
+
+		status:            opt.Status,
+		expectedArtifacts: expectedArtifacts,
+
+		requests: requests,
+		bytes:    bytes,
+		mu:       &sync.Mutex{},
+		errors:   make(map[string]int),
+		groups:   make(map[string]struct{}),
 	}
 }
 
+// Crawl saves indexes of all artifacts in the repository and writes the crawl report.
+// It resumes the crawl of the checkpoint left by a crawl which stopped at its budget.
 func (c *Crawler) Crawl(ctx context.Context) error {
-	log.Println("Crawl maven repository and save indexes")
+	start := time.Now()
+	rootURLs := []string{c.rootUrl}
+	cp, err := c.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		log.Printf("Resume the crawl started at %s from %d dirs", cp.StartedAt.Format(time.RFC3339), len(cp.Pending))
+		c.restore(cp)
+		start, rootURLs = cp.StartedAt, cp.Pending
+	} else {
+		log.Println("Crawl maven repository and save indexes")
+	}
+
+	c.budgeted = true
+	if c.maxDuration > 0 {
+		c.deadline = time.Now().Add(c.maxDuration)
+	}
+	err = c.crawl(ctx, rootURLs...)
+	if err == nil {
+		err = c.saveCheckpoint(start)
+	}
+	return c.finish(start, c.rootUrl, err)
+}
+
+// outOfBudget reports whether Crawl ran out of its time or request budget.
+func (c *Crawler) outOfBudget() bool {
+	if !c.budgeted {
+		return false
+	}
+	return (!c.deadline.IsZero() && time.Now().After(c.deadline)) ||
+		(c.maxRequests > 0 && atomic.LoadInt64(c.requests) >= c.maxRequests)
+}
+
+// CrawlGroups saves indexes of the artifacts of `groupIDs` only, e.g. to pick up emergency releases right away.
+// Index files of other groups are kept, and subgroups aren't crawled, since they are other groups.
+// The crawl report isn't written, since it covers the whole repository.
+func (c *Crawler) CrawlGroups(ctx context.Context, groupIDs []string) error {
+	log.Printf("Crawl groups %s and save indexes", strings.Join(groupIDs, ", "))
+	c.groupURLs = make(map[string]bool)
+	for _, groupID := range groupIDs {
+		c.groupURLs[c.groupURL(groupID)] = true
+	}
+	// The last complete crawl doesn't tell how many artifacts the groups have
+	c.expectedArtifacts = 0
+	return c.crawl(ctx, lo.Keys(c.groupURLs)...)
+}
+
+// inShard reports whether `url` belongs to the shard of the crawl.
+// Dirs are assigned to shards by the hash of their path at shardDepth, e.g. `org/apache/`, which spreads huge
+// top-level dirs like `org/` across shards. Shallower dirs are listed by all shards.
+func (c *Crawler) inShard(url string) bool {
+	if c.shards == 0 {
+		return true
+	}
+	rel := strings.TrimPrefix(url, c.rootUrl)
+	if strings.Count(rel, "/") != shardDepth {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(rel))
+	return int(h.Sum32()%uint32(c.shards)) == c.shard-1
+}
+
+// groupURL returns the dir of `groupID` in the repository.
+func (c *Crawler) groupURL(groupID string) string {
+	dirs := lo.Map(strings.Split(groupID, "."), func(dir string, _ int) string { return url.PathEscape(dir) })
+	return c.rootUrl + strings.Join(dirs, "/") + "/"
+}
+
+// childURL returns the URL of the file or dir `name` listed in the dir at `dirURL`.
+// Names are escaped, so that coordinates with spaces, `#`, `?`, `%` or non-ASCII characters aren't fetched from wrong URLs.
+func childURL(dirURL, name string) string {
+	if dir := strings.TrimSuffix(name, "/"); dir != name {
+		return dirURL + url.PathEscape(dir) + "/"
+	}
+	return dirURL + url.PathEscape(name)
+}
+
+func (c *Crawler) crawl(ctx context.Context, rootURLs ...string) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	errCh := make(chan error)
 	defer close(errCh)
 
-	// Add a root url
-	c.urlCh <- c.rootUrl
-	c.wg.Add(1)
+	// Add root urls
+	c.wg.Add(len(rootURLs))
+	go func() {
+		for _, url := range rootURLs {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				c.urlCh <- url
+			}
+		}
+	}()
 
+	// urlCh is closed when all URLs are visited, or on the first error.
+	var closeOnce sync.Once
+	closeURLs := func() { closeOnce.Do(func() { close(c.urlCh) }) }
 	go func() {
 		c.wg.Wait()
-		close(c.urlCh)
+		closeURLs()
 	}()
 
 	crawlDone := make(chan struct{})
@@ -87,11 +342,17 @@ func (c *Crawler) Crawl(ctx context.Context) error {
 	go func() {
 		defer func() { crawlDone <- struct{}{} }()
 
-		var count int
 		for url := range c.urlCh {
-			count++
-			if count%1000 == 0 {
-				log.Printf("Count: %d", count)
+			if c.outOfBudget() {
+				// Dirs listed by running visits keep coming until they finish
+				c.mu.Lock()
+				c.pending = append(c.pending, url)
+				c.mu.Unlock()
+				c.wg.Done()
+				continue
+			}
+			if visited := atomic.AddInt64(&c.visited, 1); visited%1000 == 0 {
+				log.Printf("Count: %d", visited)
 			}
 			if err := c.limit.Acquire(ctx, 1); err != nil {
 				errCh <- xerrors.Errorf("semaphore acquire error: %w", err)
@@ -115,7 +376,7 @@ loop:
 			break loop
 		case err := <-errCh:
 			cancel() // Stop all running Visit functions to avoid writing to closed c.urlCh.
-			close(c.urlCh)
+			closeURLs()
 			return err
 
 		}
@@ -130,6 +391,50 @@ loop:
 	return nil
 }
 
+// Stats returns the summary of the last Crawl.
+func (c *Crawler) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := Stats{
+		VisitedURLs: int(atomic.LoadInt64(&c.visited)),
+		WrongSHA1:   len(c.wrongSHA1Values),
+		Groups:      len(c.groups),
+		Artifacts:   c.artifacts,
+		Versions:    c.versions,
+		Bytes:       atomic.LoadInt64(c.bytes),
+		Errors:      lo.Assign(c.errors),
+		Partial:     len(c.pending) > 0,
+	}
+	if c.httpCache != nil {
+		stats.CachedResponses = int(atomic.LoadInt64(&c.httpCache.hits))
+	}
+	return stats
+}
+
+// GroupExists checks if the repository has a dir for `groupID`.
+// It's used to find dependency-confusion targets without crawling the group.
+func (c *Crawler) GroupExists(ctx context.Context, groupID string) (bool, error) {
+	url := c.groupURL(groupID)
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false, xerrors.Errorf("unable to new HTTP request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, xerrors.Errorf("http head error (%s): %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, xerrors.Errorf("unexpected status code (%s): %d", url, resp.StatusCode)
+	}
+}
+
 func (c *Crawler) Visit(ctx context.Context, url string) error {
 	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -144,6 +449,7 @@ func (c *Crawler) Visit(ctx context.Context, url string) error {
 	// There are cases when url doesn't exist
 	// e.g. https://repo.maven.apache.org/maven2/io/springboot/ai/spring-ai-anthropic/
 	if resp.StatusCode != http.StatusOK {
+		c.countError(errNotFound)
 		return nil
 	}
 
@@ -179,7 +485,14 @@ func (c *Crawler) Visit(ctx context.Context, url string) error {
 			return nil
 		}
 	}
+	if c.groupURLs != nil && !c.groupURLs[url] {
+		// Subgroup of a crawled group
+		return nil
+	}
 
+	children = lo.Filter(children, func(child string, _ int) bool {
+		return c.inShard(childURL(url, child))
+	})
 	c.wg.Add(len(children))
 
 	go func() {
@@ -189,7 +502,7 @@ func (c *Crawler) Visit(ctx context.Context, url string) error {
 			case <-ctx.Done():
 				return
 			default:
-				c.urlCh <- url + child
+				c.urlCh <- childURL(url, child)
 			}
 		}
 	}()
@@ -198,102 +511,271 @@ func (c *Crawler) Visit(ctx context.Context, url string) error {
 }
 
 func (c *Crawler) crawlSHA1(ctx context.Context, baseURL string, meta *Metadata, dirs []string) error {
-	var foundVersions []Version
 	// Check each version dir to find links to `*.jar.sha1` files.
-	for _, dir := range dirs {
-		dirURL := baseURL + dir
-		sha1Urls, err := c.sha1Urls(ctx, dirURL)
-		if err != nil {
-			return xerrors.Errorf("unable to get list of sha1 files from %q: %s", dirURL, err)
+	// Version dirs are checked in parallel up to the artifact limit.
+	// Results are kept in the order of dirs, so indexes don't depend on the order of responses.
+	results := make([][]Version, len(dirs))
+	sem := semaphore.NewWeighted(c.artifactLimit)
+	g, ctx := errgroup.WithContext(ctx)
+	for i, dir := range dirs {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			if gerr := g.Wait(); gerr != nil {
+				return gerr
+			}
+			return xerrors.Errorf("semaphore acquire error: %w", err)
 		}
-
-		// Remove the `/` suffix to correctly compare file versions with version from directory name.
-		dirVersion := strings.TrimSuffix(dir, "/")
-		var dirVersionSha1 []byte
-		var versions []Version
-		for _, sha1Url := range sha1Urls {
-			sha1, err := c.fetchSHA1(ctx, sha1Url)
+		i, dir := i, dir
+		g.Go(func() error {
+			defer sem.Release(1)
+			versions, err := c.versionDirVersions(ctx, baseURL, meta, dir)
 			if err != nil {
-				return xerrors.Errorf("unable to fetch sha1: %s", err)
-			}
-			if ver := versionFromSha1URL(meta.ArtifactID, sha1Url); ver != "" && len(sha1) != 0 {
-				// Save sha1 for the file where the version is equal to the version from the directory name in order to remove duplicates later
-				// Avoid overwriting dirVersion when inserting versions into the database (sha1 is uniq blob)
-				// e.g. `cudf-0.14-cuda10-1.jar.sha1` should not overwrite `cudf-0.14.jar.sha1`
-				// https://repo.maven.apache.org/maven2/ai/rapids/cudf/0.14/
-				if ver == dirVersion {
-					dirVersionSha1 = sha1
-				} else {
-					versions = append(versions, Version{
-						Version: ver,
-						SHA1:    sha1,
-					})
-				}
+				return err
 			}
-		}
-		// Remove duplicates of dirVersionSha1
-		versions = lo.Filter(versions, func(v Version, _ int) bool {
-			return !bytes.Equal(v.SHA1, dirVersionSha1)
+			results[i] = versions
+			return nil
 		})
-
-		if dirVersionSha1 != nil {
-			versions = append(versions, Version{
-				Version: dirVersion,
-				SHA1:    dirVersionSha1,
-			})
-		}
-
-		foundVersions = append(foundVersions, versions...)
 	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	foundVersions := lo.Flatten(results)
 
 	if len(foundVersions) == 0 {
 		return nil
 	}
 
-	index := &Index{
+	return c.writeIndex(&Index{
 		GroupID:     meta.GroupID,
 		ArtifactID:  meta.ArtifactID,
 		Versions:    foundVersions,
 		ArchiveType: types.JarType,
+	})
+}
+
+// versionDirVersions returns versions of the archives in the version dir `dir` of the artifact at `baseURL`.
+func (c *Crawler) versionDirVersions(ctx context.Context, baseURL string, meta *Metadata, dir string) ([]Version, error) {
+	dirURL := childURL(baseURL, dir)
+	archives, module, err := c.archiveFiles(ctx, dirURL)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to get list of sha1 files from %q: %s", dirURL, err)
+	}
+
+	// Remove the `/` suffix to correctly compare file versions with version from directory name.
+	dirVersion := strings.TrimSuffix(dir, "/")
+	var dirVersionIndex *Version
+	var versions []Version
+	// Save sha1 for the file where the version is equal to the version from the directory name in order to remove duplicates later
+	// Avoid overwriting dirVersion when inserting versions into the database (sha1 is uniq blob)
+	// e.g. `cudf-0.14-cuda10-1.jar.sha1` should not overwrite `cudf-0.14.jar.sha1`
+	// https://repo.maven.apache.org/maven2/ai/rapids/cudf/0.14/
+	addVersion := func(version Version) {
+		if strings.HasPrefix(version.Version, dirVersion+"-") {
+			version.Classifier = strings.TrimPrefix(version.Version, dirVersion+"-")
+			version.Platform = nativePlatform(version.Classifier)
+		}
+		if version.Version == dirVersion {
+			dirVersionIndex = &version
+		} else {
+			versions = append(versions, version)
+		}
+	}
+	for _, archive := range archives {
+		var sha1, md5 []byte
+		if archive.sha1 {
+			if sha1, err = c.fetchChecksum(ctx, archive.url+".sha1"); err != nil {
+				return nil, xerrors.Errorf("unable to fetch sha1: %s", err)
+			}
+		}
+		if archive.md5 {
+			if md5, err = c.fetchChecksum(ctx, archive.url+".md5"); err != nil {
+				return nil, xerrors.Errorf("unable to fetch md5: %s", err)
+			}
+		}
+		ver := versionFromArchiveURL(meta.ArtifactID, archive.url)
+		if ver == "" || (len(sha1) == 0 && len(md5) == 0) {
+			continue
+		}
+		version := Version{
+			Version: ver,
+			SHA1:    sha1,
+			MD5:     md5,
+			Size:    archive.size,
+			Signed:  archive.asc,
+		}
+		if archive.archiveType != types.JarType {
+			version.ArchiveType = archive.archiveType
+		}
+		if archive.asc && c.signatures {
+			if version.SigningKey, err = c.fetchSigningKey(ctx, archive.url+".asc"); err != nil {
+				return nil, xerrors.Errorf("unable to fetch signature: %s", err)
+			}
+		}
+		addVersion(version)
+	}
+
+	if module != "" {
+		moduleVersions, err := c.gradleModuleVersions(ctx, childURL(dirURL, module), meta.ArtifactID, archives)
+		if err != nil {
+			return nil, xerrors.Errorf("unable to fetch gradle module: %s", err)
+		}
+		for _, version := range moduleVersions {
+			addVersion(version)
+		}
+	}
+
+	if dirVersionIndex != nil {
+		// Remove duplicates of dirVersion
+		versions = lo.Filter(versions, func(v Version, _ int) bool {
+			if len(dirVersionIndex.SHA1) != 0 {
+				return !bytes.Equal(v.SHA1, dirVersionIndex.SHA1)
+			}
+			return len(v.SHA1) != 0 || !bytes.Equal(v.MD5, dirVersionIndex.MD5)
+		})
+		versions = append(versions, *dirVersionIndex)
 	}
-	fileName := fmt.Sprintf("%s.json", index.ArtifactID)
-	filePath := filepath.Join(c.dir, index.GroupID, fileName)
+	return versions, nil
+}
+
+// writeIndex saves `index` into the cache dir, where the builder reads it.
+func (c *Crawler) writeIndex(index *Index) error {
+	fileName := fileutil.ShortName(fmt.Sprintf("%s.json", index.ArtifactID))
+	filePath := filepath.Join(c.dir, fileutil.ShortName(index.GroupID), fileName)
 	if err := fileutil.WriteJSON(filePath, index); err != nil {
 		return xerrors.Errorf("json write error: %w", err)
 	}
+	c.countIndex(index)
 	return nil
 }
 
-func (c *Crawler) sha1Urls(ctx context.Context, url string) ([]string, error) {
+// archiveFile is a jar or klib found in a version dir.
+// `sha1`, `md5` and `asc` report which checksum and signature files are published next to it.
+type archiveFile struct {
+	url         string
+	size        int64
+	archiveType types.ArchiveType
+	sha1        bool
+	md5         bool
+	asc         bool
+}
+
+// archiveFiles lists jars in the version dir at `url`.
+// It also returns the name of the Gradle module file when GradleModules is enabled and the file is published.
+func (c *Crawler) archiveFiles(ctx context.Context, url string) ([]archiveFile, string, error) {
 	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, xerrors.Errorf("unable to new HTTP request: %w", err)
+		return nil, "", xerrors.Errorf("unable to new HTTP request: %w", err)
 	}
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, xerrors.Errorf("http get error (%s): %w", url, err)
+		return nil, "", xerrors.Errorf("http get error (%s): %w", url, err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	d, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return nil, xerrors.Errorf("can't create new goquery doc: %w", err)
+		return nil, "", xerrors.Errorf("can't create new goquery doc: %w", err)
 	}
 
 	// Version dir may contain multiple `*jar.sha1` files.
 	// e.g. https://repo1.maven.org/maven2/org/jasypt/jasypt/1.9.3/
 	// We need to take all links.
-	var sha1URLs []string
+	var links []string
+	sizes := make(map[string]int64)
 	d.Find("a").Each(func(i int, selection *goquery.Selection) {
 		link := linkFromSelection(selection)
-		// Don't include sources, test, javadocs, scaladoc files
-		if strings.HasSuffix(link, ".jar.sha1") && !strings.HasSuffix(link, "sources.jar.sha1") &&
-			!strings.HasSuffix(link, "test.jar.sha1") && !strings.HasSuffix(link, "tests.jar.sha1") &&
-			!strings.HasSuffix(link, "javadoc.jar.sha1") && !strings.HasSuffix(link, "scaladoc.jar.sha1") {
-			sha1URLs = append(sha1URLs, url+link)
-		}
+		links = append(links, link)
+		sizes[link] = sizeFromSelection(selection)
 	})
-	return sha1URLs, nil
+
+	var archives []archiveFile
+	var module string
+	found := make(map[string]int)
+	for _, link := range links {
+		var name string
+		switch {
+		case c.gradleModules && strings.HasSuffix(link, ".module"):
+			module = link
+			continue
+		case strings.HasSuffix(link, ".sha1") && c.fileType(strings.TrimSuffix(link, ".sha1")) != "":
+			name = strings.TrimSuffix(link, ".sha1")
+		case c.md5 && strings.HasSuffix(link, ".md5") && c.fileType(strings.TrimSuffix(link, ".md5")) != "":
+			// Ancient artifacts only have md5 files
+			// e.g. https://repo.maven.apache.org/maven2/ant/ant/1.5.1/
+			name = strings.TrimSuffix(link, ".md5")
+		default:
+			continue
+		}
+		if skipArchive(name) {
+			continue
+		}
+
+		i, ok := found[name]
+		if !ok {
+			i = len(archives)
+			found[name] = i
+			_, asc := sizes[name+".asc"]
+			archives = append(archives, archiveFile{
+				url:         childURL(url, name),
+				size:        sizes[name],
+				archiveType: c.fileType(name),
+				asc:         asc,
+			})
+		}
+		if strings.HasSuffix(link, ".sha1") {
+			archives[i].sha1 = true
+		} else {
+			archives[i].md5 = true
+		}
+	}
+
+	// Poms are only indexed for artifacts without archives (packaging=pom)
+	if lo.ContainsBy(archives, func(archive archiveFile) bool { return archive.archiveType != types.PomType }) {
+		archives = lo.Reject(archives, func(archive archiveFile, _ int) bool { return archive.archiveType == types.PomType })
+	}
+	return archives, module, nil
+}
+
+// fileType returns the type of the file `name` to index, or an empty string for other files.
+func (c *Crawler) fileType(name string) types.ArchiveType {
+	if c.poms && path.Ext(name) == ".pom" {
+		return types.PomType
+	}
+	return archiveType(name)
+}
+
+// archiveType returns the type of the archive file `name`, or an empty string for other files.
+// Kotlin Multiplatform libraries publish `*.klib` files for native targets.
+func archiveType(name string) types.ArchiveType {
+	switch path.Ext(name) {
+	case ".jar":
+		return types.JarType
+	case ".klib":
+		return types.KlibType
+	}
+	return ""
+}
+
+// nativeOSes are the OS names used in classifiers of jars with native libraries.
+// Kotlin/Native targets append the architecture without a separator, e.g. `iosarm64`, `mingwx64`.
+var nativeOSes = []string{"linux", "windows", "osx", "macos", "android", "ios", "mingw", "freebsd", "solaris", "aix"}
+
+// nativePlatform returns the platform part of `classifier` starting at the OS name, or an empty string for other classifiers.
+// e.g. `linux-x86_64` (netty-tcnative), `natives-windows-x86` (lwjgl), `macosx-arm64` (javacpp)
+func nativePlatform(classifier string) string {
+	parts := strings.Split(classifier, "-")
+	for i, part := range parts {
+		if lo.SomeBy(nativeOSes, func(os string) bool { return strings.HasPrefix(part, os) }) {
+			return strings.Join(parts[i:], "-")
+		}
+	}
+	return ""
+}
+
+// skipArchive reports jars that are not used at runtime: sources, test, javadocs, scaladoc files.
+func skipArchive(name string) bool {
+	return strings.HasSuffix(name, "sources.jar") || strings.HasSuffix(name, "test.jar") ||
+		strings.HasSuffix(name, "tests.jar") || strings.HasSuffix(name, "javadoc.jar") ||
+		strings.HasSuffix(name, "scaladoc.jar")
 }
 
 func (c *Crawler) parseMetadata(ctx context.Context, url string) (*Metadata, error) {
@@ -316,12 +798,13 @@ func (c *Crawler) parseMetadata(ctx context.Context, url string) (*Metadata, err
 	// There are cases when metadata.xml file doesn't exist
 	// e.g. https://repo.maven.apache.org/maven2/io/springboot/ai/spring-ai-vertex-ai-gemini-spring-boot-starter/maven-metadata.xml
 	if resp.StatusCode != http.StatusOK {
+		c.countError(errMissingMetadata)
 		return nil, nil
 	}
 
 	var meta Metadata
 	if err = xml.NewDecoder(resp.Body).Decode(&meta); err != nil {
-		return nil, xerrors.Errorf("%s decode error: %w", url, err)
+		return nil, withKind(ErrCorrupt, xerrors.Errorf("%s decode error: %w", url, err))
 	}
 	// Skip metadata without `GroupID` and ArtifactID` fields
 	// e.g. https://repo.maven.apache.org/maven2/at/molindo/maven-metadata.xml
@@ -337,7 +820,8 @@ func (c *Crawler) parseMetadata(ctx context.Context, url string) (*Metadata, err
 	return &meta, nil
 }
 
-func (c *Crawler) fetchSHA1(ctx context.Context, url string) ([]byte, error) {
+// fetchChecksum fetches a `*.sha1` or `*.md5` file and decodes the hex digest from it.
+func (c *Crawler) fetchChecksum(ctx context.Context, url string) ([]byte, error) {
 	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, xerrors.Errorf("unable to new HTTP request: %w", err)
@@ -352,44 +836,94 @@ func (c *Crawler) fetchSHA1(ctx context.Context, url string) ([]byte, error) {
 	// But file doesn't exist
 	// e.g. https://repo.maven.apache.org/maven2/com/adobe/aem/uber-jar/6.4.8.2/uber-jar-6.4.8.2-sources.jar.sha1
 	if resp.StatusCode == http.StatusNotFound {
+		c.countError(errMissingChecksum)
 		return nil, nil // TODO add special error for this
 	}
 
-	sha1, err := io.ReadAll(resp.Body)
+	// Some mirrors return HTML error pages with 200
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		log.Printf("Malformed checksum %s: HTML response", url)
+		c.countError(errMalformedChecksum)
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxChecksumSize+1))
 	if err != nil {
-		return nil, xerrors.Errorf("can't read sha1 %s: %w", url, err)
+		return nil, xerrors.Errorf("can't read checksum %s: %w", url, err)
+	}
+	if len(body) > maxChecksumSize {
+		log.Printf("Malformed checksum %s: more than %d bytes", url, maxChecksumSize)
+		c.countError(errMalformedChecksum)
+		return nil, nil
 	}
 
 	// there are empty xxx.jar.sha1 files. Skip them.
 	// e.g. https://repo.maven.apache.org/maven2/org/wso2/msf4j/msf4j-swagger/2.5.2/msf4j-swagger-2.5.2.jar.sha1
 	// https://repo.maven.apache.org/maven2/org/wso2/carbon/analytics/org.wso2.carbon.permissions.rest.api/2.0.248/org.wso2.carbon.permissions.rest.api-2.0.248.jar.sha1
-	if len(sha1) == 0 {
+	if len(body) == 0 {
 		return nil, nil
 	}
 	// there are xxx.jar.sha1 files with additional data. e.g.:
 	// https://repo.maven.apache.org/maven2/aspectj/aspectjrt/1.5.2a/aspectjrt-1.5.2a.jar.sha1
 	// https://repo.maven.apache.org/maven2/xerces/xercesImpl/2.9.0/xercesImpl-2.9.0.jar.sha1
-	var sha1b []byte
-	for _, s := range strings.Split(strings.TrimSpace(string(sha1)), " ") {
-		sha1b, err = hex.DecodeString(s)
-		if err == nil {
-			break
+	// Only hex strings of the digest length are taken, so garbage like `cafe` isn't stored as a checksum.
+	size := sha1.Size
+	if path.Ext(url) == ".md5" {
+		size = md5.Size
+	}
+	for _, s := range strings.Split(strings.TrimSpace(string(body)), " ") {
+		if digest, err := hex.DecodeString(s); err == nil && len(digest) == size {
+			return digest, nil
 		}
 	}
-	if len(sha1b) == 0 {
-		c.wrongSHA1Values = append(c.wrongSHA1Values, fmt.Sprintf("%s (%s)", url, err))
-		return nil, nil
+	c.mu.Lock()
+	c.wrongSHA1Values = append(c.wrongSHA1Values, fmt.Sprintf("%s (no %d-byte hex digest)", url, size))
+	c.errors[errWrongSHA1]++
+	c.mu.Unlock()
+	return nil, nil
+}
+
+// fetchSigningKey fetches a `*.asc` file and returns the fingerprint of the signing key.
+// Signatures that can't be parsed are logged and skipped.
+func (c *Crawler) fetchSigningKey(ctx context.Context, url string) (string, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", xerrors.Errorf("unable to new HTTP request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", xerrors.Errorf("http get error (%s): %w", url, err)
 	}
-	return sha1b, nil
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil
+	}
+
+	asc, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", xerrors.Errorf("can't read signature %s: %w", url, err)
+	}
+
+	key, err := signingKey(asc)
+	if err != nil {
+		log.Printf("Unable to parse signature %s: %s", url, err)
+		c.countError(errInvalidSignature)
+		return "", nil
+	}
+	return key, nil
 }
 
-func versionFromSha1URL(artifactId, sha1URL string) string {
-	ss := strings.Split(sha1URL, "/")
+func versionFromArchiveURL(artifactId, archiveURL string) string {
+	ss := strings.Split(archiveURL, "/")
 	fileName := ss[len(ss)-1]
+	if unescaped, err := url.PathUnescape(fileName); err == nil {
+		fileName = unescaped
+	}
 	if !strings.HasPrefix(fileName, artifactId) {
 		return ""
 	}
-	return strings.TrimSuffix(strings.TrimPrefix(fileName, artifactId+"-"), ".jar.sha1")
+	return strings.TrimSuffix(strings.TrimPrefix(fileName, artifactId+"-"), path.Ext(fileName))
 }
 
 // linkFromSelection returns the link from goquery.Selection.
@@ -403,7 +937,34 @@ func linkFromSelection(selection *goquery.Selection) string {
 	link := selection.Text()
 	// maven uses `.../` suffix for dirs and `...` suffix for files.
 	if href, ok := selection.Attr("href"); ok && (strings.HasSuffix(link, ".../") || (strings.HasSuffix(link, "..."))) {
+		// Unlike the text, `href` is escaped
 		link = href
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			link = unescaped
+		}
 	}
 	return link
 }
+
+// sizeFromSelection returns the file size listed after the link.
+// Maven prints the modification time and the size in bytes after each file link.
+// e.g. `<a href="abbot-0.12.3.jar" title="abbot-0.12.3.jar">abbot-0.12.3.jar</a>     2005-09-20 05:44    689791`
+// 0 is returned when the size can't be parsed.
+func sizeFromSelection(selection *goquery.Selection) int64 {
+	if len(selection.Nodes) == 0 {
+		return 0
+	}
+	next := selection.Nodes[0].NextSibling
+	if next == nil || next.Type != html.TextNode {
+		return 0
+	}
+	fields := strings.Fields(next.Data)
+	if len(fields) == 0 {
+		return 0
+	}
+	size, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
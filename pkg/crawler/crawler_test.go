@@ -2,22 +2,36 @@ package crawler_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
 )
 
 func TestCrawl(t *testing.T) {
 	tests := []struct {
-		name       string
-		fileNames  map[string]string
-		goldenPath string
-		filePath   string
+		name          string
+		fileNames     map[string]string
+		md5           bool
+		signatures    bool
+		gradleModules bool
+		poms          bool
+		artifactLimit int64
+		goldenPath    string
+		filePath      string
 	}{
 		{
 			name: "happy path",
@@ -38,6 +52,130 @@ func TestCrawl(t *testing.T) {
 			goldenPath: "testdata/golden/abbot.json",
 			filePath:   "indexes/abbot/abbot.json",
 		},
+		{
+			name: "version dirs in parallel",
+			fileNames: map[string]string{
+				"/maven2/":                                              "testdata/index.html",
+				"/maven2/abbot/":                                        "testdata/abbot.html",
+				"/maven2/abbot/abbot/":                                  "testdata/abbot_abbot.html",
+				"/maven2/abbot/abbot/maven-metadata.xml":                "testdata/maven-metadata.xml",
+				"/maven2/abbot/abbot/0.12.3/":                           "testdata/abbot_abbot_0.12.3.html",
+				"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":      "testdata/abbot-0.12.3.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/":                           "testdata/abbot_abbot_0.13.0.html",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":      "testdata/abbot-0.13.0.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1": "testdata/abbot-0.13.0-copy.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/":                            "testdata/abbot_abbot_1.4.0.html",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":        "testdata/abbot-1.4.0.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":   "testdata/abbot-1.4.0-lite.jar.sha1",
+			},
+			artifactLimit: 3,
+			goldenPath:    "testdata/golden/abbot.json",
+			filePath:      "indexes/abbot/abbot.json",
+		},
+		{
+			name: "with md5",
+			fileNames: map[string]string{
+				"/maven2/":                                              "testdata/index.html",
+				"/maven2/abbot/":                                        "testdata/abbot.html",
+				"/maven2/abbot/abbot/":                                  "testdata/abbot_abbot.html",
+				"/maven2/abbot/abbot/maven-metadata.xml":                "testdata/maven-metadata.xml",
+				"/maven2/abbot/abbot/0.12.3/":                           "testdata/abbot_abbot_0.12.3.html",
+				"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":      "testdata/abbot-0.12.3.jar.sha1",
+				"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.md5":       "testdata/abbot-0.12.3.jar.md5",
+				"/maven2/abbot/abbot/0.13.0/":                           "testdata/abbot_abbot_0.13.0.html",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":      "testdata/abbot-0.13.0.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1": "testdata/abbot-0.13.0-copy.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/":                            "testdata/abbot_abbot_1.4.0.html",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":        "testdata/abbot-1.4.0.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":   "testdata/abbot-1.4.0-lite.jar.sha1",
+			},
+			md5:        true,
+			goldenPath: "testdata/golden/abbot-md5.json",
+			filePath:   "indexes/abbot/abbot.json",
+		},
+		{
+			name: "with signatures",
+			fileNames: map[string]string{
+				"/maven2/":                                              "testdata/index.html",
+				"/maven2/abbot/":                                        "testdata/abbot.html",
+				"/maven2/abbot/abbot/":                                  "testdata/abbot_abbot.html",
+				"/maven2/abbot/abbot/maven-metadata.xml":                "testdata/maven-metadata.xml",
+				"/maven2/abbot/abbot/0.12.3/":                           "testdata/abbot_abbot_0.12.3.html",
+				"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":      "testdata/abbot-0.12.3.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/":                           "testdata/abbot_abbot_0.13.0.html",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":      "testdata/abbot-0.13.0.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1": "testdata/abbot-0.13.0-copy.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/":                            "testdata/abbot_abbot_1.4.0.html",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":        "testdata/abbot-1.4.0.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.asc":         "testdata/abbot-1.4.0.jar.asc",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":   "testdata/abbot-1.4.0-lite.jar.sha1",
+			},
+			signatures: true,
+			goldenPath: "testdata/golden/abbot-signatures.json",
+			filePath:   "indexes/abbot/abbot.json",
+		},
+		{
+			name: "with gradle modules",
+			fileNames: map[string]string{
+				"/maven2/":                                              "testdata/index.html",
+				"/maven2/abbot/":                                        "testdata/abbot.html",
+				"/maven2/abbot/abbot/":                                  "testdata/abbot_abbot.html",
+				"/maven2/abbot/abbot/maven-metadata.xml":                "testdata/maven-metadata.xml",
+				"/maven2/abbot/abbot/0.12.3/":                           "testdata/abbot_abbot_0.12.3.html",
+				"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":      "testdata/abbot-0.12.3.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/":                           "testdata/abbot_abbot_0.13.0.html",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":      "testdata/abbot-0.13.0.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1": "testdata/abbot-0.13.0-copy.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/":                            "testdata/abbot_abbot_1.4.0.html",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":        "testdata/abbot-1.4.0.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":   "testdata/abbot-1.4.0-lite.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.module":          "testdata/abbot-1.4.0.module",
+			},
+			gradleModules: true,
+			goldenPath:    "testdata/golden/abbot-gradle.json",
+			filePath:      "indexes/abbot/abbot.json",
+		},
+		{
+			name: "with klibs and native jars",
+			fileNames: map[string]string{
+				"/maven2/":                                                            "testdata/index.html",
+				"/maven2/abbot/":                                                      "testdata/abbot.html",
+				"/maven2/abbot/abbot/":                                                "testdata/abbot_abbot.html",
+				"/maven2/abbot/abbot/maven-metadata.xml":                              "testdata/maven-metadata.xml",
+				"/maven2/abbot/abbot/0.12.3/":                                         "testdata/abbot_abbot_0.12.3.html",
+				"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":                    "testdata/abbot-0.12.3.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/":                                         "testdata/abbot_abbot_0.13.0.html",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":                    "testdata/abbot-0.13.0.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1":               "testdata/abbot-0.13.0-copy.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/":                                          "testdata/abbot_abbot_1.4.0-klib.html",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":                      "testdata/abbot-1.4.0.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":                 "testdata/abbot-1.4.0-lite.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-iosarm64.klib.sha1":            "testdata/abbot-1.4.0-iosarm64.klib.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-natives-linux-x86_64.jar.sha1": "testdata/abbot-1.4.0-natives-linux-x86_64.jar.sha1",
+			},
+			goldenPath: "testdata/golden/abbot-klib.json",
+			filePath:   "indexes/abbot/abbot.json",
+		},
+		{
+			name: "with poms",
+			fileNames: map[string]string{
+				"/maven2/":                                              "testdata/index.html",
+				"/maven2/abbot/":                                        "testdata/abbot.html",
+				"/maven2/abbot/abbot/":                                  "testdata/abbot_abbot.html",
+				"/maven2/abbot/abbot/maven-metadata.xml":                "testdata/maven-metadata.xml",
+				"/maven2/abbot/abbot/0.12.3/":                           "testdata/abbot_abbot_0.12.3-pom.html",
+				"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.pom.sha1":      "testdata/abbot-0.12.3.pom.sha1",
+				"/maven2/abbot/abbot/0.13.0/":                           "testdata/abbot_abbot_0.13.0.html",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":      "testdata/abbot-0.13.0.jar.sha1",
+				"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1": "testdata/abbot-0.13.0-copy.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/":                            "testdata/abbot_abbot_1.4.0.html",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":        "testdata/abbot-1.4.0.jar.sha1",
+				"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":   "testdata/abbot-1.4.0-lite.jar.sha1",
+			},
+			poms:       true,
+			goldenPath: "testdata/golden/abbot-pom.json",
+			filePath:   "indexes/abbot/abbot.json",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -53,9 +191,14 @@ func TestCrawl(t *testing.T) {
 
 			tmpDir := t.TempDir()
 			cl := crawler.NewCrawler(crawler.Option{
-				RootUrl:  ts.URL + "/maven2/",
-				Limit:    1,
-				CacheDir: tmpDir,
+				RootUrl:       ts.URL + "/maven2/",
+				Limit:         1,
+				ArtifactLimit: tt.artifactLimit,
+				CacheDir:      tmpDir,
+				MD5:           tt.md5,
+				Signatures:    tt.signatures,
+				GradleModules: tt.gradleModules,
+				Poms:          tt.poms,
 			})
 
 			err := cl.Crawl(context.Background())
@@ -68,7 +211,555 @@ func TestCrawl(t *testing.T) {
 			assert.NoError(t, err)
 
 			assert.JSONEq(t, string(want), string(got))
+
+			b, err := os.ReadFile(filepath.Join(tmpDir, crawler.ReportFile))
+			require.NoError(t, err)
+			var report types.CrawlReport
+			require.NoError(t, json.Unmarshal(b, &report))
+			assert.True(t, report.Complete)
+			assert.Equal(t, ts.URL+"/maven2/", report.RepositoryURL)
+			assert.Equal(t, 1, report.Groups)
+			assert.Equal(t, 1, report.Artifacts)
+			assert.Positive(t, report.Bytes)
 		})
 	}
 
 }
+
+func TestCrawlGroups(t *testing.T) {
+	fileNames := map[string]string{
+		"/maven2/abbot/abbot/":                                  "testdata/abbot_abbot.html",
+		"/maven2/abbot/abbot/maven-metadata.xml":                "testdata/maven-metadata.xml",
+		"/maven2/abbot/abbot/0.12.3/":                           "testdata/abbot_abbot_0.12.3.html",
+		"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":      "testdata/abbot-0.12.3.jar.sha1",
+		"/maven2/abbot/abbot/0.13.0/":                           "testdata/abbot_abbot_0.13.0.html",
+		"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":      "testdata/abbot-0.13.0.jar.sha1",
+		"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1": "testdata/abbot-0.13.0-copy.jar.sha1",
+		"/maven2/abbot/abbot/1.4.0/":                            "testdata/abbot_abbot_1.4.0.html",
+		"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":        "testdata/abbot-1.4.0.jar.sha1",
+		"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":   "testdata/abbot-1.4.0-lite.jar.sha1",
+	}
+	var subgroupVisited int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/maven2/abbot/":
+			_, _ = w.Write([]byte(`<a href="../">../</a><a href="abbot/">abbot/</a><a href="sub/">sub/</a>`))
+			return
+		case "/maven2/abbot/sub/":
+			atomic.AddInt64(&subgroupVisited, 1)
+			_, _ = w.Write([]byte(`<a href="../">../</a><a href="tool/">tool/</a>`))
+			return
+		}
+		fileName, ok := fileNames[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, fileName)
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	otherPath := filepath.Join(tmpDir, "indexes", "other", "other.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(otherPath), os.ModePerm))
+	require.NoError(t, os.WriteFile(otherPath, []byte(`{}`), 0600))
+
+	cl := crawler.NewCrawler(crawler.Option{
+		RootUrl:  ts.URL + "/maven2/",
+		Limit:    1,
+		CacheDir: tmpDir,
+	})
+	require.NoError(t, cl.CrawlGroups(context.Background(), []string{"abbot"}))
+	assert.Equal(t, 1, cl.Stats().Artifacts)
+
+	got, err := os.ReadFile(filepath.Join(tmpDir, "indexes/abbot/abbot.json"))
+	require.NoError(t, err)
+	want, err := os.ReadFile("testdata/golden/abbot.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+
+	// The subgroup dir is listed, but not descended into
+	assert.Equal(t, int64(1), atomic.LoadInt64(&subgroupVisited))
+	assert.FileExists(t, otherPath)
+	assert.NoFileExists(t, filepath.Join(tmpDir, crawler.ReportFile))
+}
+
+func TestGroupExists(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/maven2/abbot/abbot/":
+			w.WriteHeader(http.StatusOK)
+		case "/maven2/broken/":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cl := crawler.NewCrawler(crawler.Option{
+		RootUrl:  ts.URL + "/maven2/",
+		Limit:    1,
+		CacheDir: t.TempDir(),
+	})
+
+	tests := []struct {
+		name      string
+		groupID   string
+		want      bool
+		assertErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "exists",
+			groupID:   "abbot.abbot",
+			want:      true,
+			assertErr: assert.NoError,
+		},
+		{
+			name:      "not found",
+			groupID:   "com.example.internal",
+			want:      false,
+			assertErr: assert.NoError,
+		},
+		{
+			name:      "unexpected status",
+			groupID:   "broken",
+			want:      false,
+			assertErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cl.GroupExists(context.Background(), tt.groupID)
+			tt.assertErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCrawlHTTPCache(t *testing.T) {
+	fileNames := map[string]string{
+		"/maven2/":                                              "testdata/index.html",
+		"/maven2/abbot/":                                        "testdata/abbot.html",
+		"/maven2/abbot/abbot/":                                  "testdata/abbot_abbot.html",
+		"/maven2/abbot/abbot/maven-metadata.xml":                "testdata/maven-metadata.xml",
+		"/maven2/abbot/abbot/0.12.3/":                           "testdata/abbot_abbot_0.12.3.html",
+		"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":      "testdata/abbot-0.12.3.jar.sha1",
+		"/maven2/abbot/abbot/0.13.0/":                           "testdata/abbot_abbot_0.13.0.html",
+		"/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":      "testdata/abbot-0.13.0.jar.sha1",
+		"/maven2/abbot/abbot/0.13.0/abbot-0.13.0-copy.jar.sha1": "testdata/abbot-0.13.0-copy.jar.sha1",
+		"/maven2/abbot/abbot/1.4.0/":                            "testdata/abbot_abbot_1.4.0.html",
+		"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":        "testdata/abbot-1.4.0.jar.sha1",
+		"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1":   "testdata/abbot-1.4.0-lite.jar.sha1",
+	}
+	// http.ServeFile sets Last-Modified and answers conditional requests with 304.
+	var served int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileName, ok := fileNames[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt64(&served, 1)
+		http.ServeFile(w, r, fileName)
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	want, err := os.ReadFile("testdata/golden/abbot.json")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		cl := crawler.NewCrawler(crawler.Option{
+			RootUrl:   ts.URL + "/maven2/",
+			Limit:     1,
+			CacheDir:  tmpDir,
+			HTTPCache: true,
+		})
+		require.NoError(t, cl.Crawl(context.Background()))
+		// All files served in the first crawl are reused by the second one.
+		if i == 0 {
+			assert.Equal(t, 0, cl.Stats().CachedResponses)
+		} else {
+			assert.Equal(t, int(atomic.LoadInt64(&served)/2), cl.Stats().CachedResponses)
+		}
+
+		got, err := os.ReadFile(filepath.Join(tmpDir, "indexes/abbot/abbot.json"))
+		require.NoError(t, err)
+		assert.JSONEq(t, string(want), string(got))
+	}
+}
+
+func TestCrawlCoverage(t *testing.T) {
+	tests := []struct {
+		name         string
+		threshold    float64
+		warnOnly     bool
+		lastReport   bool
+		wantErr      string
+		wantComplete bool
+	}{
+		{
+			name:         "no last report",
+			threshold:    10,
+			wantComplete: true,
+		},
+		{
+			name:       "artifacts dropped",
+			threshold:  10,
+			lastReport: true,
+			wantErr:    "artifacts dropped by 90.0% (10 -> 1)",
+		},
+		{
+			name:         "drop under threshold",
+			threshold:    95,
+			lastReport:   true,
+			wantComplete: true,
+		},
+		{
+			name:         "warn only",
+			threshold:    10,
+			warnOnly:     true,
+			lastReport:   true,
+			wantComplete: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fileName, ok := map[string]string{
+					"/maven2/":                                         "testdata/index.html",
+					"/maven2/abbot/":                                   "testdata/abbot.html",
+					"/maven2/abbot/abbot/":                             "testdata/abbot_abbot.html",
+					"/maven2/abbot/abbot/maven-metadata.xml":           "testdata/maven-metadata.xml",
+					"/maven2/abbot/abbot/0.12.3/":                      "testdata/abbot_abbot_0.12.3.html",
+					"/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1": "testdata/abbot-0.12.3.jar.sha1",
+				}[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				http.ServeFile(w, r, fileName)
+			}))
+			defer ts.Close()
+
+			tmpDir := t.TempDir()
+			if tt.lastReport {
+				b, err := json.Marshal(types.CrawlReport{Complete: true, Groups: 1, Artifacts: 10})
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(filepath.Join(tmpDir, crawler.CompleteReportFile), b, 0o644))
+			}
+
+			cl := crawler.NewCrawler(crawler.Option{
+				RootUrl:           ts.URL + "/maven2/",
+				Limit:             1,
+				CacheDir:          tmpDir,
+				CoverageThreshold: tt.threshold,
+				CoverageWarnOnly:  tt.warnOnly,
+			})
+			err := cl.Crawl(context.Background())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(tmpDir, crawler.ReportFile))
+			require.NoError(t, err)
+			var report types.CrawlReport
+			require.NoError(t, json.Unmarshal(b, &report))
+			assert.Equal(t, tt.wantComplete, report.Complete)
+
+			// Only complete crawls replace the last complete report
+			b, err = os.ReadFile(filepath.Join(tmpDir, crawler.CompleteReportFile))
+			if tt.wantComplete {
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(b, &report))
+				assert.Equal(t, 1, report.Artifacts)
+			} else if tt.lastReport {
+				require.NoError(t, err)
+				require.NoError(t, json.Unmarshal(b, &report))
+				assert.Equal(t, 10, report.Artifacts)
+			}
+		})
+	}
+}
+
+func TestCrawlMalformedChecksums(t *testing.T) {
+	fileNames := map[string]string{
+		"/maven2/":                                            "testdata/index.html",
+		"/maven2/abbot/":                                      "testdata/abbot.html",
+		"/maven2/abbot/abbot/":                                "testdata/abbot_abbot.html",
+		"/maven2/abbot/abbot/maven-metadata.xml":              "testdata/maven-metadata.xml",
+		"/maven2/abbot/abbot/0.12.3/":                         "testdata/abbot_abbot_0.12.3.html",
+		"/maven2/abbot/abbot/0.13.0/":                         "testdata/abbot_abbot_0.13.0.html",
+		"/maven2/abbot/abbot/1.4.0/":                          "testdata/abbot_abbot_1.4.0.html",
+		"/maven2/abbot/abbot/1.4.0/abbot-1.4.0.jar.sha1":      "testdata/abbot-1.4.0.jar.sha1",
+		"/maven2/abbot/abbot/1.4.0/abbot-1.4.0-lite.jar.sha1": "testdata/abbot-1.4.0-lite.jar.sha1",
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/maven2/abbot/abbot/0.12.3/abbot-0.12.3.jar.sha1":
+			// Error page of a mirror
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html><body>Service Unavailable</body></html>"))
+			return
+		case "/maven2/abbot/abbot/0.13.0/abbot-0.13.0.jar.sha1":
+			// Hex, but not a sha1
+			_, _ = w.Write([]byte("cafe"))
+			return
+		}
+		fileName, ok := fileNames[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, fileName)
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	cl := crawler.NewCrawler(crawler.Option{
+		RootUrl:  ts.URL + "/maven2/",
+		Limit:    1,
+		CacheDir: tmpDir,
+	})
+	require.NoError(t, cl.Crawl(context.Background()))
+
+	stats := cl.Stats()
+	assert.Equal(t, 1, stats.WrongSHA1)
+	assert.Equal(t, 1, stats.Errors["malformed_checksum"])
+
+	b, err := os.ReadFile(filepath.Join(tmpDir, "indexes/abbot/abbot.json"))
+	require.NoError(t, err)
+	var index crawler.Index
+	require.NoError(t, json.Unmarshal(b, &index))
+	assert.Equal(t, []string{"1.4.0-lite", "1.4.0"}, lo.Map(index.Versions, func(v crawler.Version, _ int) string {
+		return v.Version
+	}))
+}
+
+func TestCrawlShards(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/maven2/":
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<a href="g%d/">g%d/</a>`, i, i)
+			}
+		case strings.Count(r.URL.Path, "/") == 3:
+			// Group dirs with one artifact dir each, which aren't found
+			fmt.Fprint(w, `<a href="../">../</a><a href="artifact/">artifact/</a>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	const shards = 3
+	for shard := 1; shard <= shards; shard++ {
+		tmpDir := t.TempDir()
+		cl := crawler.NewCrawler(crawler.Option{
+			RootUrl:  ts.URL + "/maven2/",
+			Limit:    1,
+			CacheDir: tmpDir,
+			Shard:    shard,
+			Shards:   shards,
+		})
+		require.NoError(t, cl.Crawl(context.Background()))
+
+		b, err := os.ReadFile(filepath.Join(tmpDir, crawler.ReportFile))
+		require.NoError(t, err)
+		var report types.CrawlReport
+		require.NoError(t, json.Unmarshal(b, &report))
+		assert.Equal(t, fmt.Sprintf("%d/%d", shard, shards), report.Shard)
+	}
+
+	// Shallow dirs are listed by all shards, and artifact dirs by exactly one
+	assert.Equal(t, shards, requests["/maven2/"])
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, shards, requests[fmt.Sprintf("/maven2/g%d/", i)])
+		assert.Equal(t, 1, requests[fmt.Sprintf("/maven2/g%d/artifact/", i)])
+	}
+}
+
+func TestCrawlBudget(t *testing.T) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/maven2/":
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(w, `<a href="g%d/">g%d/</a>`, i, i)
+			}
+		case strings.Count(r.URL.Path, "/") == 3:
+			fmt.Fprint(w, `<a href="../">../</a><a href="artifact/">artifact/</a>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	var report types.CrawlReport
+	var runs int
+	for ; runs < 21 && !report.Complete; runs++ {
+		cl := crawler.NewCrawler(crawler.Option{
+			RootUrl:     ts.URL + "/maven2/",
+			Limit:       1,
+			CacheDir:    tmpDir,
+			MaxRequests: 5,
+		})
+		require.NoError(t, cl.Crawl(context.Background()))
+
+		b, err := os.ReadFile(filepath.Join(tmpDir, crawler.ReportFile))
+		require.NoError(t, err)
+		report = types.CrawlReport{}
+		require.NoError(t, json.Unmarshal(b, &report))
+		assert.Equal(t, !report.Complete, report.Partial)
+		assert.Equal(t, report.Partial, cl.Stats().Partial)
+		_, err = os.Stat(filepath.Join(tmpDir, "crawl-checkpoint.json"))
+		assert.Equal(t, report.Partial, err == nil)
+	}
+
+	// Each run resumes the previous one, so every dir is listed once and counted in the last report
+	require.True(t, report.Complete)
+	assert.Greater(t, runs, 1)
+	assert.Equal(t, 21, report.VisitedURLs)
+	assert.Equal(t, 1, requests["/maven2/"])
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 1, requests[fmt.Sprintf("/maven2/g%d/", i)])
+		assert.Equal(t, 1, requests[fmt.Sprintf("/maven2/g%d/artifact/", i)])
+	}
+}
+
+func TestCrawlExoticCoordinates(t *testing.T) {
+	// Paths of the requests are unescaped by the server
+	listings := map[string]string{
+		"/maven2/":                        `<a href="org/">org/</a>`,
+		"/maven2/org/":                    `<a href="../">../</a><a href="caf%C3%A9/">café/</a>`,
+		"/maven2/org/café/":               `<a href="../">../</a><a href="a%20b%23c%3F%25/">a b#c?%/</a>`,
+		"/maven2/org/café/a b#c?%/":       `<a href="../">../</a><a href="maven-metadata.xml">maven-metadata.xml</a><a href="1.0%2B%C3%BC/">1.0+ü/</a>`,
+		"/maven2/org/café/a b#c?%/1.0+ü/": `<a href="../">../</a><a href="a%20b%23c%3F%25-1.0%2B%C3%BC.jar.sha1">a b#c?%-1.0+ü.jar.s...</a>`,
+		"/maven2/org/café/a b#c?%/maven-metadata.xml": `<metadata><groupId>org.café</groupId><artifactId>a b#c?%</artifactId>` +
+			`<versioning><versions><version>1.0+ü</version></versions></versioning></metadata>`,
+		"/maven2/org/café/a b#c?%/1.0+ü/a b#c?%-1.0+ü.jar.sha1": "0123456789abcdef0123456789abcdef01234567",
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := listings[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	cl := crawler.NewCrawler(crawler.Option{
+		RootUrl:  ts.URL + "/maven2/",
+		Limit:    1,
+		CacheDir: tmpDir,
+	})
+	require.NoError(t, cl.Crawl(context.Background()))
+	assert.Equal(t, 1, cl.Stats().Artifacts)
+
+	b, err := os.ReadFile(filepath.Join(tmpDir, "indexes", "org.café", "a b#c?%.json"))
+	require.NoError(t, err)
+	var got crawler.Index
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, "org.café", got.GroupID)
+	assert.Equal(t, "a b#c?%", got.ArtifactID)
+	require.Len(t, got.Versions, 1)
+	assert.Equal(t, "1.0+ü", got.Versions[0].Version)
+}
+
+func TestCrawlMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/index.html")
+	}))
+	defer ts.Close()
+
+	cl := crawler.NewCrawler(crawler.Option{
+		RootUrl:     ts.URL + "/maven2/",
+		Limit:       1,
+		CacheDir:    t.TempDir(),
+		MaxBodySize: 10,
+	})
+	assert.ErrorContains(t, cl.Crawl(context.Background()), "exceeds 10 bytes")
+}
+
+func TestCrawlCorruptMetadata(t *testing.T) {
+	fileNames := map[string]string{
+		"/maven2/":             "testdata/index.html",
+		"/maven2/abbot/":       "testdata/abbot.html",
+		"/maven2/abbot/abbot/": "testdata/abbot_abbot.html",
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/maven2/abbot/abbot/maven-metadata.xml" {
+			_, _ = w.Write([]byte("<metadata><groupId>abbot"))
+			return
+		}
+		fileName, ok := fileNames[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, fileName)
+	}))
+	defer ts.Close()
+
+	cl := crawler.NewCrawler(crawler.Option{
+		RootUrl:  ts.URL + "/maven2/",
+		Limit:    1,
+		CacheDir: t.TempDir(),
+	})
+	assert.ErrorIs(t, cl.Crawl(context.Background()), crawler.ErrCorrupt)
+}
+
+func TestGiveUp(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://repo.example.com/maven2/", nil)
+	tests := []struct {
+		name       string
+		statusCode int
+		wantErr    string
+		assertKind assert.ErrorAssertionFunc
+	}{
+		{
+			name:       "rate limited",
+			statusCode: http.StatusTooManyRequests,
+			wantErr:    "giving up after 11 attempt(s): status code 429",
+			assertKind: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.ErrorIs(t, err, crawler.ErrRateLimited)
+			},
+		},
+		{
+			name:       "server error",
+			statusCode: http.StatusServiceUnavailable,
+			wantErr:    "giving up after 11 attempt(s): status code 503",
+			assertKind: func(t assert.TestingT, err error, _ ...any) bool {
+				return assert.NotErrorIs(t, err, crawler.ErrRateLimited)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.statusCode,
+				Body:       io.NopCloser(strings.NewReader("slow down")),
+				Request:    req,
+			}
+			got, err := crawler.GiveUp(resp, nil, 11)
+			assert.Nil(t, got)
+			assert.ErrorContains(t, err, tt.wantErr)
+			tt.assertKind(t, err)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"golang.org/x/xerrors"
+)
+
+// Errors returned by the crawler can be checked against these kinds with errors.Is.
+var (
+	// ErrRateLimited is returned when the repository still answers 429 Too Many Requests after all retries.
+	// Callers may retry the crawl later, e.g. with a lower ArtifactLimit.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrCorrupt is returned for repository files which can't be parsed, e.g. malformed maven-metadata.xml.
+	ErrCorrupt = errors.New("corrupt data")
+)
+
+// kindError marks `err` with one of the kinds above.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// giveUp is called by the HTTP client when retries are exhausted. Unlike the default handler, it reports the last
+// status code, so that rate limiting can be told apart from other failures.
+func giveUp(resp *http.Response, err error, numTries int) (*http.Response, error) {
+	if resp == nil {
+		return nil, xerrors.Errorf("giving up after %d attempt(s): %w", numTries, err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+
+	err = xerrors.Errorf("%s %s giving up after %d attempt(s): status code %d", resp.Request.Method, resp.Request.URL, numTries, resp.StatusCode)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, withKind(ErrRateLimited, err)
+	}
+	return nil, err
+}
@@ -0,0 +1,12 @@
+package crawler
+
+// UnregisterSource removes a source registered by a test, so that tests can run several times.
+func UnregisterSource(name string) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	delete(sources, name)
+}
+
+const CompleteReportFile = completeReportFile
+
+var GiveUp = giveUp
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// gradleModule is the part of Gradle module metadata we need.
+// cf. https://github.com/gradle/gradle/blob/master/platforms/documentation/docs/src/docs/design/gradle-module-metadata-latest-specification.md
+type gradleModule struct {
+	Variants []struct {
+		Files []gradleFile `json:"files"`
+	} `json:"variants"`
+}
+
+type gradleFile struct {
+	Name string `json:"name"`
+	// URL is relative to the module file.
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+	SHA1 string `json:"sha1"`
+	MD5  string `json:"md5"`
+}
+
+// gradleModuleVersions returns versions of variant jars and klibs listed in the Gradle module file at `url`.
+// Jars already found in the version dir (`archives`) are skipped, as well as files of other modules (`available-at` variants and relative URLs).
+// Checksums are taken from the module file, so no checksum files are fetched. md5 is only kept if MD5 is enabled.
+func (c *Crawler) gradleModuleVersions(ctx context.Context, url, artifactID string, archives []archiveFile) ([]Version, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to new HTTP request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("http get error (%s): %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+
+	var module gradleModule
+	if err = json.NewDecoder(resp.Body).Decode(&module); err != nil {
+		// Broken module files shouldn't stop the crawl, the jars from the dir listing are still indexed.
+		log.Printf("Wrong gradle module %s: %s", url, err)
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, archive := range archives {
+		seen[path.Base(archive.url)] = true
+	}
+
+	var versions []Version
+	for _, variant := range module.Variants {
+		for _, f := range variant.Files {
+			// Variants share files, e.g. `apiElements` and `runtimeElements` both list the main jar.
+			if seen[f.URL] || strings.Contains(f.URL, "/") || archiveType(f.URL) == "" || skipArchive(f.URL) {
+				continue
+			}
+			seen[f.URL] = true
+
+			ver := versionFromArchiveURL(artifactID, f.URL)
+			sha1, _ := hex.DecodeString(f.SHA1)
+			var md5 []byte
+			if c.md5 {
+				md5, _ = hex.DecodeString(f.MD5)
+			}
+			if ver == "" || (len(sha1) == 0 && len(md5) == 0) {
+				continue
+			}
+			version := Version{
+				Version: ver,
+				SHA1:    sha1,
+				MD5:     md5,
+				Size:    f.Size,
+			}
+			if t := archiveType(f.URL); t != types.JarType {
+				version.ArchiveType = t
+			}
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,165 @@
+package crawler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// httpCacheVersion is bumped when the layout of the HTTP cache changes.
+// Entries of other versions are ignored, so that a crawl can resume from a cache written by another crawler version.
+const httpCacheVersion = 1
+
+// httpCache is a content-addressable store of HTTP responses.
+// The body of each response is stored under the sha256 of its URL, next to an entry file with the validators of the response.
+// Later crawls send conditional requests and reuse the stored body when the server answers `304 Not Modified`.
+type httpCache struct {
+	dir  string
+	next http.RoundTripper
+	// hits is the number of responses served from the cache.
+	hits int64
+}
+
+// httpCacheEntry is the integrity metadata of a stored body.
+type httpCacheEntry struct {
+	Version      int
+	URL          string
+	StatusCode   int
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	// SHA256 is the digest of the stored body. Bodies that don't match it are fetched again.
+	SHA256 string
+}
+
+func (c *httpCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.next.RoundTrip(req)
+	}
+
+	key := httpCacheKey(req.URL.String())
+	entry, body := c.load(key, req.URL.String())
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		_ = resp.Body.Close()
+		atomic.AddInt64(&c.hits, 1)
+		// `cache gc` keeps entries used within its retention
+		now := time.Now()
+		_ = os.Chtimes(c.path(key)+".json", now, now)
+		resp.StatusCode = entry.StatusCode
+		resp.Status = http.StatusText(entry.StatusCode)
+		resp.ContentLength = int64(len(body))
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	case resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""):
+		b, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, xerrors.Errorf("can't read %s: %w", req.URL, err)
+		}
+		sum := sha256.Sum256(b)
+		if err = c.store(key, b, httpCacheEntry{
+			Version:      httpCacheVersion,
+			URL:          req.URL.String(),
+			StatusCode:   resp.StatusCode,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			SHA256:       hex.EncodeToString(sum[:]),
+		}); err != nil {
+			return nil, xerrors.Errorf("http cache error: %w", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		return resp, nil
+	}
+	return resp, nil
+}
+
+// load returns the entry and the body stored for `url`.
+// A nil entry is returned when nothing is stored or the stored body is broken.
+func (c *httpCache) load(key, url string) (*httpCacheEntry, []byte) {
+	b, err := os.ReadFile(c.path(key) + ".json")
+	if err != nil {
+		return nil, nil
+	}
+	var entry httpCacheEntry
+	if err = json.Unmarshal(b, &entry); err != nil || entry.Version != httpCacheVersion || entry.URL != url {
+		return nil, nil
+	}
+	body, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, nil
+	}
+	if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != entry.SHA256 {
+		return nil, nil
+	}
+	return &entry, body
+}
+
+// store saves the body before the entry, so that an entry never points to a missing body.
+// Both files are renamed into place, so parallel crawls never read partial files.
+func (c *httpCache) store(key string, body []byte, entry httpCacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return xerrors.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err = writeFileAtomic(c.path(key), body); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path(key)+".json", b)
+}
+
+// path returns the path of the body for `key`. Keys are spread over dirs by their first two characters.
+func (c *httpCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+func httpCacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFileAtomic(filePath string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return xerrors.Errorf("unable to create a directory: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return xerrors.Errorf("unable to create a temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		return xerrors.Errorf("failed to save a file: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return xerrors.Errorf("failed to save a file: %w", err)
+	}
+	if err = os.Rename(f.Name(), filePath); err != nil {
+		return xerrors.Errorf("failed to rename a file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/status"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+const (
+	// ReportFile is the name of the crawl report in the cache dir.
+	ReportFile = "crawl-report.json"
+	// completeReportFile is a copy of the report of the last complete crawl, used by the coverage check.
+	completeReportFile = "crawl-report-complete.json"
+)
+
+// Types of errors counted in the crawl report. Files with these errors are skipped.
+const (
+	errNotFound            = "not_found"
+	errMissingMetadata     = "missing_metadata"
+	errMissingChecksum     = "missing_checksum"
+	errWrongSHA1           = "wrong_sha1"
+	errWrongChecksumLength = "wrong_checksum_length"
+	errMalformedChecksum   = "malformed_checksum"
+	errInvalidSignature    = "invalid_signature"
+)
+
+// shardName returns the shard of the crawl, e.g. `3/10`, or an empty string for crawls of the whole repository.
+func (c *Crawler) shardName() string {
+	if c.shards == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", c.shard, c.shards)
+}
+
+// lastCompleteReport reads the report of the last complete crawl in `cacheDir`. It returns nil if there is none.
+func lastCompleteReport(cacheDir string) (*types.CrawlReport, error) {
+	b, err := os.ReadFile(filepath.Join(cacheDir, completeReportFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, xerrors.Errorf("unable to read the last complete crawl report: %w", err)
+	}
+	var last types.CrawlReport
+	if err = json.Unmarshal(b, &last); err != nil {
+		return nil, xerrors.Errorf("unable to decode the last complete crawl report: %w", err)
+	}
+	return &last, nil
+}
+
+// countError counts a skipped file for the crawl report.
+func (c *Crawler) countError(errType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[errType]++
+}
+
+// countIndex counts the written index for the crawl report and the status.
+func (c *Crawler) countIndex(index *Index) {
+	c.mu.Lock()
+	c.groups[index.GroupID] = struct{}{}
+	c.artifacts++
+	c.versions += len(index.Versions)
+	artifacts := c.artifacts
+	c.mu.Unlock()
+
+	c.status.Update(status.Crawling, index.GroupID, artifacts, c.expectedArtifacts)
+}
+
+// finish writes the crawl report into the cache dir and returns `err` of the crawl.
+func (c *Crawler) finish(start time.Time, repositoryURL string, err error) error {
+	stats := c.Stats()
+	finished := time.Now()
+	report := types.CrawlReport{
+		RepositoryURL: repositoryURL,
+		Shard:         c.shardName(),
+		StartedAt:     start.UTC(),
+		FinishedAt:    finished.UTC(),
+		Duration:      finished.Sub(start).Round(time.Second).String(),
+		Complete:      err == nil && !stats.Partial,
+		VisitedURLs:   stats.VisitedURLs,
+		Groups:        stats.Groups,
+		Artifacts:     stats.Artifacts,
+		Versions:      stats.Versions,
+		Bytes:         stats.Bytes,
+		Errors:        stats.Errors,
+		Partial:       stats.Partial,
+	}
+	// The coverage of a partial crawl is checked when its last part completes
+	if report.Complete && c.coverageThreshold > 0 {
+		err = c.checkCoverage(report)
+		report.Complete = err == nil
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	cacheDir := filepath.Dir(c.dir)
+	werr := fileutil.WriteJSON(filepath.Join(cacheDir, ReportFile), report)
+	if werr == nil && report.Complete {
+		werr = fileutil.WriteJSON(filepath.Join(cacheDir, completeReportFile), report)
+	}
+	if werr != nil {
+		if err != nil {
+			log.Printf("Unable to write the crawl report: %s", werr)
+			return err
+		}
+		return xerrors.Errorf("crawl report write error: %w", werr)
+	}
+	return err
+}
+
+// checkCoverage compares the counts of `report` with the last complete crawl.
+// Partial crawls, e.g. caused by mirror outages, finish without errors but find fewer groups and artifacts.
+func (c *Crawler) checkCoverage(report types.CrawlReport) error {
+	last, err := lastCompleteReport(filepath.Dir(c.dir))
+	if err != nil {
+		return err
+	} else if last == nil {
+		log.Println("No complete crawl report to check coverage")
+		return nil
+	}
+
+	for _, count := range []struct {
+		name      string
+		last, cur int
+	}{
+		{name: "groups", last: last.Groups, cur: report.Groups},
+		{name: "artifacts", last: last.Artifacts, cur: report.Artifacts},
+	} {
+		if count.last == 0 {
+			continue
+		}
+		drop := float64(count.last-count.cur) * 100 / float64(count.last)
+		if drop <= c.coverageThreshold {
+			continue
+		}
+		msg := fmt.Sprintf("%s dropped by %.1f%% (%d -> %d) since the crawl finished at %s",
+			count.name, drop, count.last, count.cur, last.FinishedAt.Format(time.RFC3339))
+		if c.coverageWarnOnly {
+			log.Printf("Coverage warning: %s", msg)
+			continue
+		}
+		return xerrors.Errorf("coverage check error: %s", msg)
+	}
+	return nil
+}
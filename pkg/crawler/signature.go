@@ -0,0 +1,188 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	signaturePacketTag = 2
+
+	issuerKeyIDSubpacket       = 16
+	issuerFingerprintSubpacket = 33
+)
+
+// signingKey parses an ASCII armored detached PGP signature (`*.asc` file)
+// and returns the fingerprint of the key used to sign the file.
+// Old signatures don't contain the issuer fingerprint. The issuer key ID is returned for them.
+func signingKey(asc []byte) (string, error) {
+	packet, err := dearmor(asc)
+	if err != nil {
+		return "", xerrors.Errorf("armor decode error: %w", err)
+	}
+
+	body, err := signaturePacketBody(packet)
+	if err != nil {
+		return "", xerrors.Errorf("packet error: %w", err)
+	}
+
+	switch body[0] {
+	case 3:
+		// https://www.rfc-editor.org/rfc/rfc4880#section-5.2.2
+		if len(body) < 15 {
+			return "", xerrors.New("v3 signature is too short")
+		}
+		return strings.ToUpper(hex.EncodeToString(body[7:15])), nil
+	case 4:
+		// https://www.rfc-editor.org/rfc/rfc4880#section-5.2.3
+		if len(body) < 4 {
+			return "", xerrors.New("v4 signature is too short")
+		}
+		var keyID string
+		rest := body[4:]
+		// hashed and unhashed subpackets
+		for i := 0; i < 2; i++ {
+			if len(rest) < 2 {
+				return "", xerrors.New("signature is too short")
+			}
+			n := int(binary.BigEndian.Uint16(rest))
+			if len(rest) < 2+n {
+				return "", xerrors.New("wrong subpackets length")
+			}
+			fingerprint, id := issuerFromSubpackets(rest[2 : 2+n])
+			if fingerprint != "" {
+				return fingerprint, nil
+			} else if keyID == "" {
+				keyID = id
+			}
+			rest = rest[2+n:]
+		}
+		if keyID == "" {
+			return "", xerrors.New("issuer not found")
+		}
+		return keyID, nil
+	default:
+		return "", xerrors.Errorf("unsupported signature version: %d", body[0])
+	}
+}
+
+// dearmor decodes the base64 body of ASCII armored data.
+// https://www.rfc-editor.org/rfc/rfc4880#section-6.2
+func dearmor(asc []byte) ([]byte, error) {
+	var data strings.Builder
+	var inBody bool
+	scanner := bufio.NewScanner(bytes.NewReader(asc))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "-----BEGIN PGP SIGNATURE"):
+			inBody = true
+		case !inBody:
+		case strings.HasPrefix(line, "-----END"), strings.HasPrefix(line, "="):
+			// `=` starts the checksum line
+			return base64.StdEncoding.DecodeString(data.String())
+		case line == "", strings.Contains(line, ": "):
+			// Skip armor headers. e.g. `Version: GnuPG v1`
+		default:
+			data.WriteString(line)
+		}
+	}
+	return nil, xerrors.New("armored signature not found")
+}
+
+// signaturePacketBody returns the body of the first packet if it is a signature packet.
+// https://www.rfc-editor.org/rfc/rfc4880#section-4.2
+func signaturePacketBody(packet []byte) ([]byte, error) {
+	if len(packet) < 2 || packet[0]&0x80 == 0 {
+		return nil, xerrors.New("invalid packet header")
+	}
+
+	var tag, length, offset int
+	if packet[0]&0x40 == 0 {
+		// Old format
+		tag = int(packet[0]>>2) & 0x0f
+		switch packet[0] & 0x03 {
+		case 0:
+			length, offset = int(packet[1]), 2
+		case 1:
+			if len(packet) < 3 {
+				return nil, xerrors.New("invalid packet length")
+			}
+			length, offset = int(binary.BigEndian.Uint16(packet[1:])), 3
+		case 2:
+			if len(packet) < 5 {
+				return nil, xerrors.New("invalid packet length")
+			}
+			length, offset = int(binary.BigEndian.Uint32(packet[1:])), 5
+		default:
+			length, offset = len(packet)-1, 1
+		}
+	} else {
+		// New format
+		tag = int(packet[0] & 0x3f)
+		var n int
+		length, n = subpacketLength(packet[1:])
+		if n == 0 {
+			return nil, xerrors.New("invalid packet length")
+		}
+		offset = 1 + n
+	}
+
+	if tag != signaturePacketTag {
+		return nil, xerrors.Errorf("unexpected packet tag: %d", tag)
+	}
+	if length < 1 || len(packet) < offset+length {
+		return nil, xerrors.New("truncated packet")
+	}
+	return packet[offset : offset+length], nil
+}
+
+// issuerFromSubpackets returns the issuer fingerprint and the issuer key ID from signature subpackets.
+// https://www.rfc-editor.org/rfc/rfc4880#section-5.2.3.1
+func issuerFromSubpackets(subpackets []byte) (fingerprint, keyID string) {
+	for len(subpackets) > 0 {
+		length, n := subpacketLength(subpackets)
+		if n == 0 || length == 0 || len(subpackets) < n+length {
+			return fingerprint, keyID
+		}
+		// The high bit is the "critical" flag
+		typ := subpackets[n] & 0x7f
+		data := subpackets[n+1 : n+length]
+		switch {
+		case typ == issuerFingerprintSubpacket && len(data) > 1:
+			// The first octet is the key version
+			fingerprint = strings.ToUpper(hex.EncodeToString(data[1:]))
+		case typ == issuerKeyIDSubpacket && len(data) == 8:
+			keyID = strings.ToUpper(hex.EncodeToString(data))
+		}
+		subpackets = subpackets[n+length:]
+	}
+	return fingerprint, keyID
+}
+
+// subpacketLength decodes a new format length and returns it with the number of octets it takes.
+// 0 octets means the length can't be decoded.
+func subpacketLength(b []byte) (int, int) {
+	switch {
+	case len(b) == 0:
+		return 0, 0
+	case b[0] < 192:
+		return int(b[0]), 1
+	case b[0] < 255:
+		if len(b) < 2 {
+			return 0, 0
+		}
+		return (int(b[0])-192)<<8 + int(b[1]) + 192, 2
+	default:
+		if len(b) < 5 {
+			return 0, 0
+		}
+		return int(binary.BigEndian.Uint32(b[1:])), 5
+	}
+}
@@ -0,0 +1,45 @@
+package crawler
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		ascFile   string
+		asc       string
+		want      string
+		assertErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "issuer fingerprint",
+			ascFile:   "testdata/abbot-1.4.0.jar.asc",
+			want:      "0E2A32EB96F37927C04BB5F6401804DBC635ED84",
+			assertErr: assert.NoError,
+		},
+		{
+			name:      "not armored",
+			asc:       "<html>Not Found</html>",
+			assertErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asc := []byte(tt.asc)
+			if tt.ascFile != "" {
+				var err error
+				asc, err = os.ReadFile(tt.ascFile)
+				require.NoError(t, err)
+			}
+
+			got, err := signingKey(asc)
+			tt.assertErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// Source discovers artifacts in a custom catalog (e.g. an internal repository or a database dump).
+// Sources are compiled in and registered with RegisterSource, and crawled with Crawler.CrawlSource.
+// Indexes from sources are saved to the cache dir like the ones crawled from Maven Central.
+type Source interface {
+	// ListGroups returns all groupIDs in the catalog.
+	ListGroups(ctx context.Context) ([]string, error)
+	// ListVersions returns artifacts of `groupID` with their versions.
+	ListVersions(ctx context.Context, groupID string) ([]SourceArtifact, error)
+	// FetchChecksums returns raw digests of the archive. At least one of Version.SHA1 and Version.MD5 must be set.
+	// Versions with digests of the wrong length are skipped.
+	FetchChecksums(ctx context.Context, groupID, artifactID, version string) (Version, error)
+}
+
+// SourceArtifact is an artifact listed by a Source.
+type SourceArtifact struct {
+	ArtifactID string
+	Versions   []string
+	// ArchiveType is types.JarType if empty.
+	ArchiveType types.ArchiveType
+}
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = map[string]func() Source{}
+)
+
+// RegisterSource makes a source available by `name`, usually from init() of the source package.
+// It panics if `name` is already registered.
+func RegisterSource(name string, factory func() Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	if _, ok := sources[name]; ok {
+		panic("crawler: RegisterSource called twice for source " + name)
+	}
+	sources[name] = factory
+}
+
+// LookupSource returns a new instance of the registered source.
+func LookupSource(name string) (Source, error) {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	factory, ok := sources[name]
+	if !ok {
+		return nil, xerrors.Errorf("unknown source: %q (available: %v)", name, sourceNames())
+	}
+	return factory(), nil
+}
+
+// Sources returns the sorted names of registered sources.
+func Sources() []string {
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	return sourceNames()
+}
+
+func sourceNames() []string {
+	var names []string
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CrawlSource saves indexes of all artifacts listed by `src` and writes the crawl report.
+func (c *Crawler) CrawlSource(ctx context.Context, src Source) error {
+	start := time.Now()
+	return c.finish(start, "", c.crawlSource(ctx, src))
+}
+
+func (c *Crawler) crawlSource(ctx context.Context, src Source) error {
+	log.Println("Crawl source and save indexes")
+	groups, err := src.ListGroups(ctx)
+	if err != nil {
+		return xerrors.Errorf("list groups error: %w", err)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		for _, groupID := range groups {
+			artifacts, err := src.ListVersions(ctx, groupID)
+			if err != nil {
+				return xerrors.Errorf("list versions error (%s): %w", groupID, err)
+			}
+			for _, artifact := range artifacts {
+				if err = c.limit.Acquire(ctx, 1); err != nil {
+					return xerrors.Errorf("semaphore acquire error: %w", err)
+				}
+				groupID, artifact := groupID, artifact
+				g.Go(func() error {
+					defer c.limit.Release(1)
+					return c.crawlSourceArtifact(ctx, src, groupID, artifact)
+				})
+			}
+		}
+		return nil
+	})
+	if err = g.Wait(); err != nil {
+		return err
+	}
+	log.Println("Crawl completed")
+	return nil
+}
+
+func (c *Crawler) crawlSourceArtifact(ctx context.Context, src Source, groupID string, artifact SourceArtifact) error {
+	var versions []Version
+	for _, ver := range artifact.Versions {
+		v, err := src.FetchChecksums(ctx, groupID, artifact.ArtifactID, ver)
+		if err != nil {
+			return xerrors.Errorf("fetch checksums error (%s:%s:%s): %w", groupID, artifact.ArtifactID, ver, err)
+		}
+		if len(v.SHA1) == 0 && len(v.MD5) == 0 {
+			log.Printf("No checksums for %s:%s:%s", groupID, artifact.ArtifactID, ver)
+			c.countError(errMissingChecksum)
+			continue
+		}
+		if (len(v.SHA1) != 0 && len(v.SHA1) != sha1.Size) || (len(v.MD5) != 0 && len(v.MD5) != md5.Size) {
+			log.Printf("Wrong checksum length for %s:%s:%s (sha1: %d bytes, md5: %d bytes)", groupID, artifact.ArtifactID, ver, len(v.SHA1), len(v.MD5))
+			c.countError(errWrongChecksumLength)
+			continue
+		}
+		v.Version = ver
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+
+	archiveType := artifact.ArchiveType
+	if archiveType == "" {
+		archiveType = types.JarType
+	}
+	return c.writeIndex(&Index{
+		GroupID:     groupID,
+		ArtifactID:  artifact.ArtifactID,
+		Versions:    versions,
+		ArchiveType: archiveType,
+	})
+}
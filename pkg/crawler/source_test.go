@@ -0,0 +1,103 @@
+package crawler_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/crawler"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+type fakeSource struct{}
+
+func (fakeSource) ListGroups(_ context.Context) ([]string, error) {
+	return []string{"com.example"}, nil
+}
+
+func (fakeSource) ListVersions(_ context.Context, _ string) ([]crawler.SourceArtifact, error) {
+	return []crawler.SourceArtifact{
+		{ArtifactID: "lib", Versions: []string{"1.0", "1.1", "1.2"}},
+		{ArtifactID: "app", Versions: []string{"2.0"}, ArchiveType: types.WarType},
+	}, nil
+}
+
+func (fakeSource) FetchChecksums(_ context.Context, _, artifactID, version string) (crawler.Version, error) {
+	switch version {
+	case "1.1":
+		return crawler.Version{}, nil // not published yet
+	case "1.2":
+		return crawler.Version{SHA1: []byte("not a sha1")}, nil // wrong length
+	}
+	return crawler.Version{SHA1: fakeSHA1(artifactID + version)}, nil
+}
+
+func fakeSHA1(s string) []byte {
+	sum := sha1.Sum([]byte(s))
+	return sum[:]
+}
+
+func TestRegisterSource(t *testing.T) {
+	crawler.RegisterSource("fake", func() crawler.Source { return fakeSource{} })
+	t.Cleanup(func() { crawler.UnregisterSource("fake") })
+	assert.Contains(t, crawler.Sources(), "fake")
+
+	src, err := crawler.LookupSource("fake")
+	require.NoError(t, err)
+	assert.Equal(t, fakeSource{}, src)
+
+	assert.Panics(t, func() {
+		crawler.RegisterSource("fake", func() crawler.Source { return fakeSource{} })
+	})
+
+	_, err = crawler.LookupSource("missing")
+	assert.ErrorContains(t, err, `unknown source: "missing"`)
+}
+
+func TestCrawlSource(t *testing.T) {
+	tmpDir := t.TempDir()
+	c := crawler.NewCrawler(crawler.Option{
+		Limit:    2,
+		CacheDir: tmpDir,
+	})
+	err := c.CrawlSource(context.Background(), fakeSource{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		filePath string
+		want     crawler.Index
+	}{
+		{
+			filePath: "indexes/com.example/lib.json",
+			want: crawler.Index{
+				GroupID:     "com.example",
+				ArtifactID:  "lib",
+				Versions:    []crawler.Version{{Version: "1.0", SHA1: fakeSHA1("lib1.0")}},
+				ArchiveType: types.JarType,
+			},
+		},
+		{
+			filePath: "indexes/com.example/app.json",
+			want: crawler.Index{
+				GroupID:     "com.example",
+				ArtifactID:  "app",
+				Versions:    []crawler.Version{{Version: "2.0", SHA1: fakeSHA1("app2.0")}},
+				ArchiveType: types.WarType,
+			},
+		},
+	}
+	for _, tt := range tests {
+		b, err := os.ReadFile(filepath.Join(tmpDir, tt.filePath))
+		require.NoError(t, err)
+
+		var got crawler.Index
+		require.NoError(t, json.Unmarshal(b, &got))
+		assert.Equal(t, tt.want, got, tt.filePath)
+	}
+}
@@ -0,0 +1,51 @@
+package crawler
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/xerrors"
+)
+
+// countingTransport counts requests sent and bytes of response bodies read from the network, and limits the size of each body.
+// Reading a body over the limit fails instead of the request, so oversized responses aren't retried.
+type countingTransport struct {
+	next        http.RoundTripper
+	requests    *int64
+	bytes       *int64
+	maxBodySize int64
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(t.requests, 1)
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingReader{
+		ReadCloser: resp.Body,
+		url:        req.URL.String(),
+		bytes:      t.bytes,
+		max:        t.maxBodySize,
+	}
+	return resp, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	url   string
+	bytes *int64
+	read  int64
+	max   int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.bytes, int64(n))
+	r.read += int64(n)
+	if r.max > 0 && r.read > r.max {
+		return n, xerrors.Errorf("response body of %s exceeds %d bytes", r.url, r.max)
+	}
+	return n, err
+}
@@ -23,5 +23,16 @@ type Index struct {
 }
 type Version struct {
 	Version string
-	SHA1    []byte
+	SHA1    []byte `json:",omitempty"`
+	MD5     []byte `json:",omitempty"`
+	Size    int64  `json:",omitempty"`
+	// Signed shows that a detached PGP signature (`*.asc` file) is published for the file.
+	Signed     bool   `json:",omitempty"`
+	SigningKey string `json:",omitempty"`
+	// ArchiveType overrides Index.ArchiveType, e.g. for `*.klib` files published next to jars.
+	ArchiveType types.ArchiveType `json:",omitempty"`
+	// Classifier is the suffix after the version dir name, e.g. `linux-x86_64` for `netty-tcnative-2.0.61.Final-linux-x86_64.jar`.
+	Classifier string `json:",omitempty"`
+	// Platform is set for classifiers of native jars.
+	Platform string `json:",omitempty"`
 }
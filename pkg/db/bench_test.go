@@ -0,0 +1,65 @@
+package db_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+const benchIndexes = 10000
+
+// BenchmarkSchema compares lookup latency and DB size of the normalized and flat schemas.
+// e.g. go test ./pkg/db -run=^$ -bench=Schema
+func BenchmarkSchema(b *testing.B) {
+	for _, flat := range []bool{false, true} {
+		name := "normalized"
+		if flat {
+			name = "flat"
+		}
+		b.Run(name, func(b *testing.B) {
+			dbPath := filepath.Join(b.TempDir(), "trivy-java.db")
+			dbc, err := db.New(filepath.Dir(dbPath), &types.DBConfig{
+				SqliteDBConfig: &types.SqliteDBConfig{DBPath: dbPath, Flat: flat},
+			})
+			require.NoError(b, err)
+			defer dbc.Close()
+			require.NoError(b, dbc.Init())
+
+			var indexes []types.Index
+			for i := 0; i < benchIndexes; i++ {
+				digest := sha1.Sum([]byte(fmt.Sprint(i)))
+				indexes = append(indexes, types.Index{
+					GroupID:     fmt.Sprintf("org.example.group%d", i%100),
+					ArtifactID:  fmt.Sprintf("artifact%d", i%1000),
+					Version:     fmt.Sprintf("1.0.%d", i),
+					SHA1:        digest[:],
+					ArchiveType: types.JarType,
+				})
+			}
+			require.NoError(b, dbc.InsertIndexes(indexes))
+			require.NoError(b, dbc.VacuumDB())
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				digest := sha1.Sum([]byte(fmt.Sprint(i % benchIndexes)))
+				if _, _, err = dbc.SelectIndexBySha1OrMd5(hex.EncodeToString(digest[:])); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			// ResetTimer drops custom metrics, so report the size at the end.
+			fi, err := os.Stat(dbPath)
+			require.NoError(b, err)
+			b.ReportMetric(float64(fi.Size()), "db-bytes")
+		})
+	}
+}
@@ -1,49 +1,613 @@
 package db
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/h7hac9/trivy-java-db/pkg/types"
 	"golang.org/x/xerrors"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/h7hac9/trivy-java-db/pkg/version"
 )
 
 const (
 	dbFileName    = "trivy-java.db"
 	SchemaVersion = 1
+	// FlatSchemaVersion is written to the metadata of DBs with the flat schema.
+	// Trivy only reads the normalized schema, so the version must never match SchemaVersion.
+	FlatSchemaVersion = 1001
+
+	// dayFormat is the format of days in the `daily_stats` table
+	dayFormat = "2006-01-02"
+	// timestampFormat is the format of timestamps such as `removed_at` of indexes. MySQL returns DATETIME columns in this format.
+	timestampFormat = "2006-01-02 15:04:05"
+
+	// Names of the `db_info` rows. Row counts are named `rows.<table>`.
+	dbInfoSchemaVersion = "schema_version"
+	dbInfoBuiltAt       = "built_at"
+	dbInfoVacuumedAt    = "vacuumed_at"
+	dbInfoRowsPrefix    = "rows."
 )
 
+// digestColumns are the columns of indexes storing the digests of each algorithm.
+// Supporting another algorithm takes a column here and in the migration column lists.
+var digestColumns = map[types.HashAlgorithm]string{
+	types.SHA1: "sha1",
+	types.MD5:  "md5",
+}
+
+// digestColumn returns the column storing digests of `alg`.
+func digestColumn(alg types.HashAlgorithm) (string, error) {
+	column, ok := digestColumns[alg]
+	if !ok {
+		return "", xerrors.Errorf("unsupported hash algorithm: %s", alg)
+	}
+	return column, nil
+}
+
 type DB interface {
 	Init() error
 	Close() error
 	VacuumDB() error
+	StartBuild(builtAt time.Time) (int, error)
 	InsertIndexes(indexes []types.Index) error
+	MarkRemovedIndexes(removedAt time.Time, indexes []types.Index) (int, error)
+	CompactArtifacts() (int, error)
+	UpdateArtifactPriorities(priorities []types.ArtifactPriority) error
+	InsertPopularity(popularity []types.Popularity) error
+	// Methods selecting a single row return ErrNotFound if there is no such row.
+	// The slice returned by other Select* methods is empty in that case.
 	SelectIndexBySha1(sha1 string) (types.Index, error)
+	SelectIndexBySha1OrMd5(digest string) (types.Index, types.MatchType, error)
+	SelectIndexByDigest(digest types.Digest) (types.Index, error)
 	SelectIndexByArtifactIDAndGroupID(artifactID, groupID string) (types.Index, error)
+	SelectVersionsByArtifactIDAndGroupID(artifactID, groupID string) ([]types.Index, error)
+	SelectIndexesByGAVs(gavs []types.GAV) ([]types.Index, error)
 	SelectIndexesByArtifactIDAndFileType(artifactID, version string, fileType types.ArchiveType) ([]types.Index, error)
+	SelectUnsignedArtifacts(groupID string) ([]types.Index, error)
+	SelectScalaArtifacts(groupID, baseArtifactID, scalaVersion string) ([]types.Artifact, error)
+	SelectArtifactsInNamespace(namespace string) ([]types.Artifact, error)
+	SearchArtifactsFTS(query string) ([]types.Artifact, error)
+	SelectCollisions() ([]types.Collision, error)
+	SelectConflictingGAVs() ([]types.Conflict, error)
+	SelectChangelog(from, to int) (types.Changelog, error)
+	UpdateDailyStats(builtAt time.Time, repositories []string) error
+	SelectDailyStats() ([]types.DailyStats, error)
+	ReplaceQuarantine(indexes []types.QuarantinedIndex) error
+	ReplaceDeprecations(deprecations []types.Deprecation) error
+	SelectDeprecation(groupID, artifactID string) (types.Deprecation, error)
+	SelectArtifactsToEnrich(limit int) ([]types.Artifact, error)
+	InsertEnrichment(enrichment types.Enrichment) error
+	SelectEnrichment(groupID, artifactID string) (types.Enrichment, error)
+	SelectQuarantine() ([]types.QuarantinedIndex, error)
+	InsertLabels(labels []types.Label) error
+	// DeleteLabels returns the number of deleted labels.
+	DeleteLabels(labels []types.Label) (int, error)
+	// SelectLabels returns the labels of the artifact in alphabetical order.
+	SelectLabels(groupID, artifactID string) ([]string, error)
+	// SelectLabeledArtifacts returns the artifacts with the label, or all labels of all artifacts if `label` is empty.
+	SelectLabeledArtifacts(label string) ([]types.Label, error)
+	UpdateDBInfo(schemaVersion int, builtAt time.Time) error
+	SelectDBInfo() (types.DBInfo, error)
+	// ScanIndexes calls `fn` with the indexes of each artifact, e.g. to copy the DB into another backend.
+	// Scanning stops at the first error of `fn`, which is returned.
+	ScanIndexes(fn func(indexes []types.Index) error) error
+}
+
+// scalaArtifactRegexp matches the Scala binary version suffix of cross-built artifacts, e.g. `cats-core_2.13`, `cats-core_3`.
+var scalaArtifactRegexp = regexp.MustCompile(`^(.+)_(2\.\d+(?:\.\d+)?|3)$`)
+
+// splitScalaVersion splits `artifactID` into the base artifact id and the Scala binary version.
+// The version is empty for artifacts which aren't cross-built.
+func splitScalaVersion(artifactID string) (string, string) {
+	if m := scalaArtifactRegexp.FindStringSubmatch(artifactID); m != nil {
+		return m[1], m[2]
+	}
+	return artifactID, ""
+}
+
+// backfillScalaVersions fills the Scala columns of `table` rows inserted before they were added.
+func backfillScalaVersions(client *sql.DB, table string) error {
+	rows, err := client.Query(fmt.Sprintf("SELECT DISTINCT artifact_id FROM %s WHERE base_artifact_id IS NULL", table))
+	if err != nil {
+		return xerrors.Errorf("select artifacts error: %w", err)
+	}
+	var artifactIDs []string
+	for rows.Next() {
+		var artifactID string
+		if err = rows.Scan(&artifactID); err != nil {
+			rows.Close()
+			return xerrors.Errorf("scan row error: %w", err)
+		}
+		artifactIDs = append(artifactIDs, artifactID)
+	}
+	rows.Close()
+	if len(artifactIDs) == 0 {
+		return nil
+	}
+
+	tx, err := client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, artifactID := range artifactIDs {
+		base, scalaVersion := splitScalaVersion(artifactID)
+		if _, err = tx.Exec(fmt.Sprintf("UPDATE %s SET base_artifact_id = ?, scala_version = ? WHERE artifact_id = ? AND base_artifact_id IS NULL", table),
+			base, scalaVersion, artifactID); err != nil {
+			return xerrors.Errorf("unable to update '%s' table: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// idLookup selects the columns compared with group and artifact ids by GAV lookups.
+// Case-insensitive lookups compare the normalized shadow columns, so that the unique index on the original ids keeps its semantics.
+type idLookup struct {
+	groupColumn    string
+	artifactColumn string
+	normalize      bool
+}
+
+func newIDLookup(caseInsensitive bool) idLookup {
+	if caseInsensitive {
+		return idLookup{groupColumn: "normalized_group_id", artifactColumn: "normalized_artifact_id", normalize: true}
+	}
+	return idLookup{groupColumn: "group_id", artifactColumn: "artifact_id"}
+}
+
+// values returns the values compared with the columns.
+func (l idLookup) values(groupID, artifactID string) (string, string) {
+	if l.normalize {
+		return normalizeID(groupID), normalizeID(artifactID)
+	}
+	return groupID, artifactID
+}
+
+// normalizeID returns the value of the normalized shadow column of a group or artifact id.
+func normalizeID(id string) string {
+	return strings.ToLower(id)
+}
+
+// CanonicalID returns `id` as stored by builds. Ids from maven-metadata.xml may carry surrounding whitespace,
+// which older builds stored as separate artifacts.
+func CanonicalID(id string) string {
+	return strings.TrimSpace(id)
+}
+
+// selectNonCanonicalArtifacts returns the distinct group and artifact ids selected by `query` which aren't canonical.
+func selectNonCanonicalArtifacts(client *sql.DB, query string) ([][2]string, error) {
+	rows, err := client.Query(query)
+	if err != nil {
+		return nil, xerrors.Errorf("select artifacts error: %w", err)
+	}
+	defer rows.Close()
+
+	var ids [][2]string
+	for rows.Next() {
+		var groupID, artifactID string
+		if err = rows.Scan(&groupID, &artifactID); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		if groupID != CanonicalID(groupID) || artifactID != CanonicalID(artifactID) {
+			ids = append(ids, [2]string{groupID, artifactID})
+		}
+	}
+	return ids, rows.Err()
+}
+
+// backfillNormalizedIDs fills the normalized shadow columns of `table` rows inserted before they were added.
+func backfillNormalizedIDs(client *sql.DB, table string) error {
+	rows, err := client.Query(fmt.Sprintf("SELECT DISTINCT group_id, artifact_id FROM %s WHERE normalized_group_id IS NULL", table))
+	if err != nil {
+		return xerrors.Errorf("select ids error: %w", err)
+	}
+	var ids [][2]string
+	for rows.Next() {
+		var groupID, artifactID string
+		if err = rows.Scan(&groupID, &artifactID); err != nil {
+			rows.Close()
+			return xerrors.Errorf("scan row error: %w", err)
+		}
+		ids = append(ids, [2]string{groupID, artifactID})
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return nil
+	}
+
+	tx, err := client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		if _, err = tx.Exec(fmt.Sprintf("UPDATE %s SET normalized_group_id = ?, normalized_artifact_id = ? WHERE group_id = ? AND artifact_id = ? AND normalized_group_id IS NULL", table),
+			normalizeID(id[0]), normalizeID(id[1]), id[0], id[1]); err != nil {
+			return xerrors.Errorf("unable to update '%s' table: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// groupCollisions groups rows sorted by sha1 into collisions.
+func groupCollisions(indexes []types.Index) []types.Collision {
+	var collisions []types.Collision
+	for _, index := range indexes {
+		if n := len(collisions); n > 0 && bytes.Equal(collisions[n-1].SHA1, index.SHA1) {
+			collisions[n-1].Indexes = append(collisions[n-1].Indexes, index)
+			continue
+		}
+		collisions = append(collisions, types.Collision{
+			SHA1:    index.SHA1,
+			Indexes: []types.Index{index},
+		})
+	}
+	return collisions
 }
 
-func path(cacheDir string) string {
+// groupConflicts groups rows sorted by GAV into conflicts.
+func groupConflicts(indexes []types.Index) []types.Conflict {
+	var conflicts []types.Conflict
+	for _, index := range indexes {
+		if n := len(conflicts); n > 0 && conflicts[n-1].GroupID == index.GroupID && conflicts[n-1].ArtifactID == index.ArtifactID &&
+			conflicts[n-1].Version == index.Version && conflicts[n-1].ArchiveType == index.ArchiveType {
+			conflicts[n-1].Indexes = append(conflicts[n-1].Indexes, index)
+			continue
+		}
+		conflicts = append(conflicts, types.Conflict{
+			GroupID:     index.GroupID,
+			ArtifactID:  index.ArtifactID,
+			Version:     index.Version,
+			ArchiveType: index.ArchiveType,
+			Indexes:     []types.Index{index},
+		})
+	}
+	return conflicts
+}
+
+// Path returns the DB file path in `cacheDir`. The file name is the one Trivy expects.
+func Path(cacheDir string) string {
 	return filepath.Join(cacheDir, dbFileName)
 }
 
 func Reset(cacheDir string) error {
-	return os.RemoveAll(path(cacheDir))
+	return os.RemoveAll(Path(cacheDir))
 }
 
 func New(cacheDir string, conf *types.DBConfig) (DB, error) {
-	dbPath := path(cacheDir)
+	dbPath := Path(cacheDir)
 	dbDir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dbDir, 0700); err != nil {
 		return nil, xerrors.Errorf("failed to mkdir: %w", err)
 	}
 
+	var dbc DB
+	var err error
 	switch {
+	case conf.SqliteDBConfig != nil && conf.SqliteDBConfig.Flat:
+		dbc, err = NewSqliteFlat(conf.SqliteDBConfig)
 	case conf.SqliteDBConfig != nil:
-		return NewSqlite(conf.SqliteDBConfig.DBPath)
+		dbc, err = NewSqlite(conf.SqliteDBConfig)
 	case conf.MysqlDBConfig != nil:
-		return NewMysql(conf.MysqlDBConfig.DBConnectURL)
+		dbc, err = NewMysql(conf.MysqlDBConfig)
 	default:
 		return nil, fmt.Errorf("no db config found")
 	}
+	if err != nil {
+		return nil, err
+	}
+	return Instrument(dbc, conf), nil
+}
+
+// setPool configures the connection pool of `client`. Zero values keep the defaults of database/sql.
+func setPool(client *sql.DB, maxOpenConns, maxIdleConns int, connMaxLifetime time.Duration) {
+	if maxOpenConns > 0 {
+		client.SetMaxOpenConns(maxOpenConns)
+	}
+	if maxIdleConns > 0 {
+		client.SetMaxIdleConns(maxIdleConns)
+	}
+	if connMaxLifetime > 0 {
+		client.SetConnMaxLifetime(connMaxLifetime)
+	}
+}
+
+// validateDigests rejects indexes with truncated or oversized digests before they are inserted.
+// They are usually caused by broken cache files, and would never match the digests of real jars.
+func validateDigests(indexes []types.Index) error {
+	for _, index := range indexes {
+		for _, d := range index.Digests() {
+			if len(d.Value) != d.Algorithm.Size() {
+				return withKind(ErrCorrupt, xerrors.Errorf("invalid %s length of %s:%s:%s: %d bytes", d.Algorithm, index.GroupID, index.ArtifactID, index.Version, len(d.Value)))
+			}
+		}
+	}
+	return nil
+}
+
+// selectIndexBySha1OrMd5 parses `digest` and looks it up with SelectIndexByDigest.
+// It returns ErrNotFound if no index is found.
+func selectIndexBySha1OrMd5(dbc DB, digest string) (types.Index, types.MatchType, error) {
+	d, err := types.ParseDigest(digest)
+	if err != nil {
+		return types.Index{}, "", xerrors.Errorf("digest parse error: %w", err)
+	}
+	index, err := dbc.SelectIndexByDigest(d)
+	if err != nil {
+		return index, "", err
+	}
+	return index, types.MatchType(d.Algorithm), nil
+}
+
+// crawledArtifact holds the digests of the versions of an artifact found in a repository by the last crawl.
+type crawledArtifact struct {
+	groupID    string
+	artifactID string
+	repository string
+	digests    map[string]struct{}
+}
+
+// crawledArtifacts groups `indexes` by artifact and repository in the order of `indexes`.
+func crawledArtifacts(indexes []types.Index) []*crawledArtifact {
+	var artifacts []*crawledArtifact
+	byKey := make(map[[3]string]*crawledArtifact)
+	for _, index := range indexes {
+		key := [3]string{index.GroupID, index.ArtifactID, index.Repository}
+		artifact, ok := byKey[key]
+		if !ok {
+			artifact = &crawledArtifact{
+				groupID:    index.GroupID,
+				artifactID: index.ArtifactID,
+				repository: index.Repository,
+				digests:    make(map[string]struct{}),
+			}
+			byKey[key] = artifact
+			artifacts = append(artifacts, artifact)
+		}
+		for _, d := range index.Digests() {
+			artifact.digests[d.String()] = struct{}{}
+		}
+	}
+	return artifacts
+}
+
+// storedIndex is an index row of a crawled artifact. Backends fill the columns identifying the row.
+type storedIndex struct {
+	rowID      int64
+	artifactID int64
+	version    string
+	sha1       []byte
+	md5        []byte
+	removed    bool
+}
+
+// diff returns the stored indexes which are no longer crawled, and the removed ones which are crawled again.
+// Rows are compared by sha1, or by md5 if they have no sha1.
+func (artifact *crawledArtifact) diff(stored []storedIndex) (removed, restored []storedIndex) {
+	for _, s := range stored {
+		d := types.Digest{Algorithm: types.SHA1, Value: s.sha1}
+		if len(s.sha1) == 0 {
+			d = types.Digest{Algorithm: types.MD5, Value: s.md5}
+		}
+		_, crawled := artifact.digests[d.String()]
+		if !crawled && !s.removed {
+			removed = append(removed, s)
+		} else if crawled && s.removed {
+			restored = append(restored, s)
+		}
+	}
+	return removed, restored
+}
+
+// dbInfoValues returns the `db_info` rows for a build at `builtAt`, counting the rows of `tables` in `tx`.
+// `table` returns the name of a table in queries.
+func dbInfoValues(tx *sql.Tx, tables []string, table func(string) string, schemaVersion int, builtAt time.Time) (map[string]string, error) {
+	values := map[string]string{
+		dbInfoSchemaVersion: strconv.Itoa(schemaVersion),
+		dbInfoBuiltAt:       builtAt.UTC().Format(timestampFormat),
+	}
+	for _, t := range tables {
+		var n int64
+		if err := tx.QueryRow("SELECT COUNT(*) FROM " + table(t)).Scan(&n); err != nil {
+			return nil, xerrors.Errorf("count '%s' rows error: %w", t, err)
+		}
+		values[dbInfoRowsPrefix+t] = strconv.FormatInt(n, 10)
+	}
+	return values, nil
+}
+
+// scanArtifactIndexes calls `fn` with the indexes of each artifact in `rows`, which must hold the rows of an artifact
+// consecutively. Rows have the columns of types.Index from group_id to generation, and the repository.
+func scanArtifactIndexes(rows *sql.Rows, fn func(indexes []types.Index) error) error {
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err := rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation, &index.Repository); err != nil {
+			return xerrors.Errorf("scan row error: %w", err)
+		}
+		if len(indexes) > 0 && (indexes[0].GroupID != index.GroupID || indexes[0].ArtifactID != index.ArtifactID) {
+			if err := fn(indexes); err != nil {
+				return err
+			}
+			indexes = nil
+		}
+		indexes = append(indexes, index)
+	}
+	if err := rows.Err(); err != nil {
+		return xerrors.Errorf("rows error: %w", err)
+	}
+	if len(indexes) > 0 {
+		return fn(indexes)
+	}
+	return nil
+}
+
+// selectDBInfo runs the `query` selecting the name and value of all `db_info` rows and parses them.
+func selectDBInfo(client *sql.DB, query string) (types.DBInfo, error) {
+	info := types.DBInfo{RowCounts: map[string]int64{}}
+	rows, err := client.Query(query)
+	if err != nil {
+		return info, xerrors.Errorf("select db info error: %w", err)
+	}
+	defer rows.Close()
+
+	var found bool
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			return info, xerrors.Errorf("scan row error: %w", err)
+		}
+		found = true
+		switch {
+		case name == dbInfoSchemaVersion:
+			info.SchemaVersion, err = strconv.Atoi(value)
+		case name == dbInfoBuiltAt:
+			info.BuiltAt, err = time.Parse(timestampFormat, value)
+		case name == dbInfoVacuumedAt:
+			info.VacuumedAt, err = parseTimestamp(sql.NullString{String: value, Valid: true})
+		case strings.HasPrefix(name, dbInfoRowsPrefix):
+			info.RowCounts[strings.TrimPrefix(name, dbInfoRowsPrefix)], err = strconv.ParseInt(value, 10, 64)
+		}
+		if err != nil {
+			return info, withKind(ErrCorrupt, xerrors.Errorf("invalid db info %s=%q: %w", name, value, err))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return info, xerrors.Errorf("rows error: %w", err)
+	}
+	if !found {
+		return info, ErrNotFound
+	}
+	return info, nil
+}
+
+// parseTimestamp parses a nullable timestamp column, e.g. `removed_at` of indexes. It returns nil for NULL.
+func parseTimestamp(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(timestampFormat, s.String)
+	if err != nil {
+		return nil, withKind(ErrCorrupt, xerrors.Errorf("timestamp parse error: %w", err))
+	}
+	return &t, nil
+}
+
+// selectArtifactsToEnrich runs the `query` listing artifacts without enrichment, adding LIMIT unless `limit` is 0.
+func selectArtifactsToEnrich(client *sql.DB, query string, limit int) ([]types.Artifact, error) {
+	var args []any
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := client.Query(query, args...)
+	if err != nil {
+		return nil, xerrors.Errorf("select artifacts error: %w", err)
+	}
+	defer rows.Close()
+
+	var artifacts []types.Artifact
+	for rows.Next() {
+		var a types.Artifact
+		if err = rows.Scan(&a.GroupID, &a.ArtifactID); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
+// scanLabels scans rows of group ids, artifact ids and labels.
+func scanLabels(rows *sql.Rows) ([]types.Label, error) {
+	defer rows.Close()
+
+	var labels []types.Label
+	for rows.Next() {
+		var l types.Label
+		if err := rows.Scan(&l.GroupID, &l.ArtifactID, &l.Label); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
+
+// selectEnrichment scans the enrichment of an artifact.
+func selectEnrichment(row *sql.Row) (types.Enrichment, error) {
+	var e types.Enrichment
+	var fetchedAt sql.NullString
+	err := row.Scan(&e.GroupID, &e.ArtifactID, &e.DefaultVersion, &e.Homepage, &e.Description, &e.ProjectURL, &fetchedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, ErrNotFound
+	} else if err != nil {
+		return e, xerrors.Errorf("select enrichment error: %w", err)
+	}
+	t, err := parseTimestamp(fetchedAt)
+	if err != nil {
+		return e, err
+	}
+	if t != nil {
+		e.FetchedAt = *t
+	}
+	return e, nil
+}
+
+// sortByVersion sorts `indexes` in Maven version order, the oldest first.
+func sortByVersion(indexes []types.Index) {
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return version.Compare(indexes[i].Version, indexes[j].Version) < 0
+	})
+}
+
+// sortByGAV sorts `indexes` by GAV and classifier.
+func sortByGAV(indexes []types.Index) {
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := indexes[i], indexes[j]
+		if a.GroupID != b.GroupID {
+			return a.GroupID < b.GroupID
+		}
+		if a.ArtifactID != b.ArtifactID {
+			return a.ArtifactID < b.ArtifactID
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Classifier < b.Classifier
+	})
+}
+
+// gavChunkSize is the number of GAVs looked up by one query.
+// 3 bind variables per GAV keep queries under the default limit of 999 variables of old SQLite versions.
+const gavChunkSize = 300
+
+// gavChunks splits unique `gavs` into chunks of gavChunkSize.
+// It returns the placeholders of each row in the chunk, e.g. `(?, ?, ?), (?, ?, ?)`, and the bind variables.
+func gavChunks(gavs []types.GAV, l idLookup) ([]string, [][]any) {
+	gavs = lo.Map(gavs, func(gav types.GAV, _ int) types.GAV {
+		gav.GroupID, gav.ArtifactID = l.values(gav.GroupID, gav.ArtifactID)
+		return gav
+	})
+
+	var placeholders []string
+	var args [][]any
+	for _, chunk := range lo.Chunk(lo.Uniq(gavs), gavChunkSize) {
+		placeholders = append(placeholders, strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(chunk)), ", "))
+		args = append(args, lo.FlatMap(chunk, func(gav types.GAV, _ int) []any {
+			return []any{gav.GroupID, gav.ArtifactID, gav.Version}
+		}))
+	}
+	return placeholders, args
 }
@@ -1,12 +1,21 @@
 package db_test
 
 import (
+	"bytes"
+	"database/sql"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/h7hac9/trivy-java-db/pkg/db"
 	"github.com/h7hac9/trivy-java-db/pkg/dbtest"
 	"github.com/h7hac9/trivy-java-db/pkg/types"
 
@@ -17,13 +26,17 @@ var (
 	jstlSha1b, _            = hex.DecodeString("9c581de633e94be1e7a955bd4e8292f16e554387")
 	javaxServlet10Sha1b, _  = hex.DecodeString("5d4ae7a8a17a33e01283e76e0dff66c4bce6456a")
 	javaxServlet110Sha1b, _ = hex.DecodeString("bca201e52333629c59e459e874e5ecd8f9899e15")
-	bundlesSha1b, _         = hex.DecodeString("b65e1196b26baeeec951fef2fefd4357")
+	bundlesSha1b, _         = hex.DecodeString("b65e1196b26baeeec951fef2fefd4357b1e0a2c3")
+	tcnativeSha1b, _        = hex.DecodeString("6a1f0e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7")
+	javaxServlet10Md5b, _   = hex.DecodeString("4d4b9a1f4e5a09a1a1c2f0e0c3a7b2d1")
+	legacyMd5b, _           = hex.DecodeString("0f3e9c7a5b1d2e4f6a8c0b2d4e6f8a1c")
 
 	indexJstl = types.Index{
 		GroupID:     "jstl",
 		ArtifactID:  "jstl",
 		Version:     "1.0",
 		SHA1:        jstlSha1b,
+		Size:        20682,
 		ArchiveType: types.JarType,
 	}
 	indexJavaxServlet10 = types.Index{
@@ -31,6 +44,7 @@ var (
 		ArtifactID:  "jstl",
 		Version:     "1.0",
 		SHA1:        javaxServlet10Sha1b,
+		MD5:         javaxServlet10Md5b,
 		ArchiveType: types.JarType,
 	}
 	indexJavaxServlet11 = types.Index{
@@ -38,8 +52,26 @@ var (
 		ArtifactID:  "jstl",
 		Version:     "1.1.0",
 		SHA1:        javaxServlet110Sha1b,
+		Signed:      true,
+		SigningKey:  "0E2A32EB96F37927C04BB5F6401804DBC635ED84",
 		ArchiveType: types.JarType,
 	}
+	indexLegacy = types.Index{
+		GroupID:     "jstl",
+		ArtifactID:  "jstl",
+		Version:     "0.9",
+		MD5:         legacyMd5b,
+		ArchiveType: types.JarType,
+	}
+	indexTcnative = types.Index{
+		GroupID:     "io.netty",
+		ArtifactID:  "netty-tcnative-boringssl-static",
+		Version:     "2.0.61.Final-linux-x86_64",
+		SHA1:        tcnativeSha1b,
+		ArchiveType: types.JarType,
+		Classifier:  "linux-x86_64",
+		Platform:    "linux-x86_64",
+	}
 	indexBundles = types.Index{
 		GroupID:     "org.apache.geronimo.bundles",
 		ArtifactID:  "jstl",
@@ -49,6 +81,10 @@ var (
 	}
 )
 
+func assertNotFound(t assert.TestingT, err error, msgAndArgs ...any) bool {
+	return assert.ErrorIs(t, err, db.ErrNotFound, msgAndArgs...)
+}
+
 func TestSelectIndexBySha1(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -62,11 +98,17 @@ func TestSelectIndexBySha1(t *testing.T) {
 			want:      indexJstl,
 			assertErr: assert.NoError,
 		},
+		{
+			name:      "native classifier",
+			sha1:      "6a1f0e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7",
+			want:      indexTcnative,
+			assertErr: assert.NoError,
+		},
 		{
 			name:      "wrong sha1",
 			sha1:      "1111111111111111111111111111111111111111",
 			want:      types.Index{},
-			assertErr: assert.NoError,
+			assertErr: assertNotFound,
 		},
 	}
 	for _, tt := range tests {
@@ -74,6 +116,7 @@ func TestSelectIndexBySha1(t *testing.T) {
 			dbc, err := dbtest.InitDB(t, []types.Index{
 				indexJstl,
 				indexJavaxServlet10,
+				indexTcnative,
 			})
 			require.NoError(t, err)
 
@@ -84,6 +127,362 @@ func TestSelectIndexBySha1(t *testing.T) {
 	}
 }
 
+func TestSelectIndexBySha1OrMd5(t *testing.T) {
+	tests := []struct {
+		name          string
+		digest        string
+		want          types.Index
+		wantMatchType types.MatchType
+		assertErr     assert.ErrorAssertionFunc
+	}{
+		{
+			name:          "sha1",
+			digest:        "5d4ae7a8a17a33e01283e76e0dff66c4bce6456a",
+			want:          indexJavaxServlet10,
+			wantMatchType: types.SHA1Match,
+			assertErr:     assert.NoError,
+		},
+		{
+			name:          "md5",
+			digest:        "4d4b9a1f4e5a09a1a1c2f0e0c3a7b2d1",
+			want:          indexJavaxServlet10,
+			wantMatchType: types.MD5Match,
+			assertErr:     assert.NoError,
+		},
+		{
+			name:          "md5 only",
+			digest:        "0f3e9c7a5b1d2e4f6a8c0b2d4e6f8a1c",
+			want:          indexLegacy,
+			wantMatchType: types.MD5Match,
+			assertErr:     assert.NoError,
+		},
+		{
+			name:      "wrong md5",
+			digest:    "11111111111111111111111111111111",
+			want:      types.Index{},
+			assertErr: assertNotFound,
+		},
+		{
+			name:      "wrong digest length",
+			digest:    "1111",
+			want:      types.Index{},
+			assertErr: assert.Error,
+		},
+		{
+			name:          "sha1 with algorithm",
+			digest:        "SHA1:5d4ae7a8a17a33e01283e76e0dff66c4bce6456a",
+			want:          indexJavaxServlet10,
+			wantMatchType: types.SHA1Match,
+			assertErr:     assert.NoError,
+		},
+		{
+			name:      "algorithm with wrong length",
+			digest:    "md5:5d4ae7a8a17a33e01283e76e0dff66c4bce6456a",
+			want:      types.Index{},
+			assertErr: assert.Error,
+		},
+		{
+			name:      "unsupported algorithm",
+			digest:    "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			want:      types.Index{},
+			assertErr: assert.Error,
+		},
+		{
+			name:      "unknown algorithm",
+			digest:    "crc32:11111111",
+			want:      types.Index{},
+			assertErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexLegacy,
+			})
+			require.NoError(t, err)
+
+			got, gotMatchType, err := dbc.SelectIndexBySha1OrMd5(tt.digest)
+			tt.assertErr(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantMatchType, gotMatchType)
+		})
+	}
+}
+
+func TestSelectIndexByDigest(t *testing.T) {
+	tests := []struct {
+		name      string
+		digest    types.Digest
+		want      types.Index
+		assertErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "sha1",
+			digest:    types.Digest{Algorithm: types.SHA1, Value: javaxServlet10Sha1b},
+			want:      indexJavaxServlet10,
+			assertErr: assert.NoError,
+		},
+		{
+			name:      "md5",
+			digest:    types.Digest{Algorithm: types.MD5, Value: legacyMd5b},
+			want:      indexLegacy,
+			assertErr: assert.NoError,
+		},
+		{
+			name:      "not found",
+			digest:    types.Digest{Algorithm: types.SHA1, Value: bundlesSha1b},
+			want:      types.Index{},
+			assertErr: assertNotFound,
+		},
+		{
+			name:      "unsupported algorithm",
+			digest:    types.Digest{Algorithm: types.SHA512, Value: make([]byte, 64)},
+			want:      types.Index{},
+			assertErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		for _, flat := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s (flat: %t)", tt.name, flat), func(t *testing.T) {
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, []types.Index{
+					indexJstl,
+					indexJavaxServlet10,
+					indexLegacy,
+				})
+				require.NoError(t, err)
+
+				got, err := dbc.SelectIndexByDigest(tt.digest)
+				tt.assertErr(t, err)
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	}
+}
+
+func TestMarkRemovedIndexes(t *testing.T) {
+	removedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		crawled     []types.Index
+		want        int
+		wantRemoved []string
+	}{
+		{
+			name:        "all versions crawled",
+			crawled:     []types.Index{indexJstl, indexLegacy},
+			want:        0,
+			wantRemoved: nil,
+		},
+		{
+			name:        "md5-only version removed",
+			crawled:     []types.Index{indexJstl},
+			want:        1,
+			wantRemoved: []string{"0.9"},
+		},
+		{
+			name:        "sha1 version removed",
+			crawled:     []types.Index{indexLegacy},
+			want:        1,
+			wantRemoved: []string{"1.0"},
+		},
+		{
+			name: "other repository",
+			crawled: []types.Index{
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0", SHA1: jstlSha1b, Repository: "internal"},
+			},
+			want:        0,
+			wantRemoved: nil,
+		},
+	}
+	for _, tt := range tests {
+		for _, flat := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s (flat: %t)", tt.name, flat), func(t *testing.T) {
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, []types.Index{
+					indexJstl,
+					indexLegacy,
+					indexJavaxServlet10,
+				})
+				require.NoError(t, err)
+
+				got, err := dbc.MarkRemovedIndexes(removedAt, tt.crawled)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+
+				var gotRemoved []string
+				for _, d := range []types.Digest{
+					{Algorithm: types.SHA1, Value: jstlSha1b},
+					{Algorithm: types.MD5, Value: legacyMd5b},
+					{Algorithm: types.SHA1, Value: javaxServlet10Sha1b},
+				} {
+					index, err := dbc.SelectIndexByDigest(d)
+					require.NoError(t, err)
+					if index.RemovedAt != nil {
+						assert.Equal(t, removedAt, *index.RemovedAt)
+						gotRemoved = append(gotRemoved, index.Version)
+					}
+				}
+				assert.Equal(t, tt.wantRemoved, gotRemoved)
+
+				// Crawling all versions again unmarks them
+				_, err = dbc.MarkRemovedIndexes(removedAt, []types.Index{indexJstl, indexLegacy})
+				require.NoError(t, err)
+				index, err := dbc.SelectIndexByDigest(types.Digest{Algorithm: types.MD5, Value: legacyMd5b})
+				require.NoError(t, err)
+				assert.Nil(t, index.RemovedAt)
+			})
+		}
+	}
+}
+
+func TestCompactArtifacts(t *testing.T) {
+	jstl11Sha1b, _ := hex.DecodeString("0123456789abcdef0123456789abcdef01234567")
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat: %t", flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat, FTS: !flat}, []types.Index{
+				indexJstl,
+				indexLegacy,
+				// Merged into jstl:jstl
+				{GroupID: "jstl ", ArtifactID: "jstl", Version: "1.1", SHA1: jstl11Sha1b, ArchiveType: types.JarType},
+				// Dropped, since jstl:jstl:0.9 has the same md5
+				{GroupID: "jstl\n", ArtifactID: "jstl", Version: "0.9", MD5: legacyMd5b, ArchiveType: types.JarType},
+				// Renamed
+				{GroupID: " org.apache.geronimo.bundles", ArtifactID: "jstl", Version: "1.2_1", SHA1: bundlesSha1b, ArchiveType: types.JarType},
+			})
+			require.NoError(t, err)
+
+			got, err := dbc.CompactArtifacts()
+			require.NoError(t, err)
+			assert.Equal(t, 3, got)
+
+			versions, err := dbc.SelectVersionsByArtifactIDAndGroupID("jstl", "jstl")
+			require.NoError(t, err)
+			var gotVersions []string
+			for _, index := range versions {
+				gotVersions = append(gotVersions, index.Version)
+			}
+			assert.ElementsMatch(t, []string{"0.9", "1.0", "1.1"}, gotVersions)
+
+			index, err := dbc.SelectIndexBySha1(hex.EncodeToString(bundlesSha1b))
+			require.NoError(t, err)
+			assert.Equal(t, "org.apache.geronimo.bundles", index.GroupID)
+
+			if !flat {
+				artifacts, err := dbc.SearchArtifactsFTS("geronimo")
+				require.NoError(t, err)
+				assert.Equal(t, []types.Artifact{{GroupID: "org.apache.geronimo.bundles", ArtifactID: "jstl"}}, artifacts)
+			}
+
+			got, err = dbc.CompactArtifacts()
+			require.NoError(t, err)
+			assert.Zero(t, got)
+		})
+	}
+}
+
+func TestSelectDeprecation(t *testing.T) {
+	deprecations := []types.Deprecation{
+		{GroupID: "javax.servlet", Replacement: "jakarta.servlet", Reason: "moved to Jakarta EE"},
+		{GroupID: "javax.servlet", ArtifactID: "jstl", Replacement: "jakarta.servlet.jsp.jstl:jakarta.servlet.jsp.jstl-api"},
+		{GroupID: "jstl", ArtifactID: "jstl", Reason: "superseded"},
+		{GroupID: "jstl", ArtifactID: "jstl", Reason: "abandoned"},
+	}
+	tests := []struct {
+		name       string
+		groupID    string
+		artifactID string
+		want       types.Deprecation
+		assertErr  assert.ErrorAssertionFunc
+	}{
+		{
+			name:       "artifact",
+			groupID:    "javax.servlet",
+			artifactID: "jstl",
+			want:       deprecations[1],
+			assertErr:  assert.NoError,
+		},
+		{
+			name:       "group",
+			groupID:    "javax.servlet",
+			artifactID: "servlet-api",
+			want:       deprecations[0],
+			assertErr:  assert.NoError,
+		},
+		{
+			name:       "later entry overrides",
+			groupID:    "jstl",
+			artifactID: "jstl",
+			want:       deprecations[3],
+			assertErr:  assert.NoError,
+		},
+		{
+			name:       "not deprecated",
+			groupID:    "io.netty",
+			artifactID: "netty-tcnative",
+			want:       types.Deprecation{},
+			assertErr:  assertNotFound,
+		},
+	}
+	for _, tt := range tests {
+		for _, flat := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s (flat: %t)", tt.name, flat), func(t *testing.T) {
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, nil)
+				require.NoError(t, err)
+				// The second feed replaces the first one
+				require.NoError(t, dbc.ReplaceDeprecations([]types.Deprecation{{GroupID: "io.netty"}}))
+				require.NoError(t, dbc.ReplaceDeprecations(deprecations))
+
+				got, err := dbc.SelectDeprecation(tt.groupID, tt.artifactID)
+				tt.assertErr(t, err)
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	labels := []types.Label{
+		{GroupID: "io.netty", ArtifactID: "netty-tcnative", Label: "approved"},
+		{GroupID: "io.netty", ArtifactID: "netty-tcnative", Label: "internal"},
+		{GroupID: "jstl", ArtifactID: "jstl", Label: "banned"},
+		{GroupID: "javax.servlet", ArtifactID: "jstl", Label: "approved"},
+	}
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat: %t", flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, nil)
+			require.NoError(t, err)
+			require.NoError(t, dbc.InsertLabels(labels))
+			// Attached labels are skipped
+			require.NoError(t, dbc.InsertLabels(labels[:1]))
+
+			got, err := dbc.SelectLabels("io.netty", "netty-tcnative")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"approved", "internal"}, got)
+
+			got, err = dbc.SelectLabels("io.netty", "netty-handler")
+			require.NoError(t, err)
+			assert.Empty(t, got)
+
+			approved, err := dbc.SelectLabeledArtifacts("approved")
+			require.NoError(t, err)
+			assert.Equal(t, []types.Label{labels[0], labels[3]}, approved)
+
+			all, err := dbc.SelectLabeledArtifacts("")
+			require.NoError(t, err)
+			assert.Equal(t, []types.Label{labels[0], labels[1], labels[3], labels[2]}, all)
+
+			n, err := dbc.DeleteLabels([]types.Label{labels[0], {GroupID: "jstl", ArtifactID: "jstl", Label: "approved"}})
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+
+			got, err = dbc.SelectLabels("io.netty", "netty-tcnative")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"internal"}, got)
+		})
+	}
+}
+
 func TestSelectIndexByArtifactIDAndGroupID(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -104,14 +503,14 @@ func TestSelectIndexByArtifactIDAndGroupID(t *testing.T) {
 			groupID:    "javax.servlet",
 			artifactID: "wrong",
 			want:       types.Index{},
-			assertErr:  assert.NoError,
+			assertErr:  assertNotFound,
 		},
 		{
 			name:       "wrong GroupID",
 			groupID:    "wrong",
 			artifactID: "jstl",
 			want:       types.Index{},
-			assertErr:  assert.NoError,
+			assertErr:  assertNotFound,
 		},
 	}
 	for _, tt := range tests {
@@ -129,6 +528,220 @@ func TestSelectIndexByArtifactIDAndGroupID(t *testing.T) {
 	}
 }
 
+func TestSelectVersionsByArtifactIDAndGroupID(t *testing.T) {
+	indexJstl12RC1 := indexJstl
+	indexJstl12RC1.Version = "1.2-rc1"
+	indexJstl12RC1.SHA1 = javaxServlet110Sha1b
+	indexJstl12 := indexJstl
+	indexJstl12.Version = "1.2"
+	indexJstl12.SHA1 = bundlesSha1b
+	indexJstl110 := indexJstl
+	indexJstl110.Version = "1.10"
+	indexJstl110.SHA1 = tcnativeSha1b
+
+	tests := []struct {
+		name       string
+		groupID    string
+		artifactID string
+		want       []types.Index
+	}{
+		{
+			name:       "maven version order",
+			groupID:    "jstl",
+			artifactID: "jstl",
+			want: []types.Index{
+				indexLegacy,
+				indexJstl,
+				indexJstl12RC1,
+				indexJstl12,
+				indexJstl110,
+			},
+		},
+		{
+			name:       "wrong ArtifactID",
+			groupID:    "jstl",
+			artifactID: "wrong",
+		},
+	}
+	for _, flat := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s (flat: %t)", tt.name, flat), func(t *testing.T) {
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, []types.Index{
+					indexJstl110,
+					indexJstl,
+					indexJstl12,
+					indexLegacy,
+					indexJstl12RC1,
+					indexJavaxServlet10,
+				})
+				require.NoError(t, err)
+
+				got, err := dbc.SelectVersionsByArtifactIDAndGroupID(tt.artifactID, tt.groupID)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	}
+}
+
+func TestExoticCoordinates(t *testing.T) {
+	// "café" is NFC in the first index and NFD in the second one, so they are different artifacts
+	indexes := []types.Index{
+		{GroupID: "org.caf\u00e9", ArtifactID: "lib", Version: "1.0", SHA1: bytes.Repeat([]byte{1}, 20), ArchiveType: types.JarType},
+		{GroupID: "org.cafe\u0301", ArtifactID: "lib", Version: "1.0", SHA1: bytes.Repeat([]byte{2}, 20), ArchiveType: types.JarType},
+		{GroupID: "io.fun", ArtifactID: "rocket-\U0001F680", Version: "1.0+b\u00fcild", SHA1: bytes.Repeat([]byte{3}, 20), ArchiveType: types.JarType},
+		{GroupID: "com.example", ArtifactID: "a b#c?d%e", Version: "\u65e5\u672c-1", SHA1: bytes.Repeat([]byte{4}, 20), ArchiveType: types.JarType},
+	}
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat: %t", flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, indexes)
+			require.NoError(t, err)
+
+			for _, want := range indexes {
+				got, err := dbc.SelectIndexBySha1(hex.EncodeToString(want.SHA1))
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+
+			var gavs []types.GAV
+			for _, index := range indexes {
+				gavs = append(gavs, types.GAV{GroupID: index.GroupID, ArtifactID: index.ArtifactID, Version: index.Version})
+			}
+			got, err := dbc.SelectIndexesByGAVs(gavs)
+			require.NoError(t, err)
+			assert.Len(t, got, len(indexes))
+		})
+	}
+}
+
+func TestSelectIndexesByGAVs(t *testing.T) {
+	// Unknown GAVs push known ones into later chunks
+	var unknown []types.GAV
+	for i := 0; i < 400; i++ {
+		unknown = append(unknown, types.GAV{GroupID: "org.example", ArtifactID: "unknown", Version: fmt.Sprint(i)})
+	}
+
+	tests := []struct {
+		name string
+		gavs []types.GAV
+		want []types.Index
+	}{
+		{
+			name: "happy path",
+			gavs: []types.GAV{
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0"},
+				{GroupID: "javax.servlet", ArtifactID: "jstl", Version: "1.1.0"},
+				{GroupID: "javax.servlet", ArtifactID: "jstl", Version: "1.0"},
+			},
+			want: []types.Index{
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+				indexJstl,
+			},
+		},
+		{
+			name: "several chunks",
+			gavs: append(append([]types.GAV{
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0"},
+			}, unknown...), types.GAV{GroupID: "org.apache.geronimo.bundles", ArtifactID: "jstl", Version: "1.2_1"}),
+			want: []types.Index{
+				indexJstl,
+				indexBundles,
+			},
+		},
+		{
+			name: "duplicated GAVs",
+			gavs: []types.GAV{
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0"},
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0"},
+			},
+			want: []types.Index{
+				indexJstl,
+			},
+		},
+		{
+			name: "wrong version",
+			gavs: []types.GAV{
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "2.0"},
+			},
+		},
+		{
+			name: "no GAVs",
+		},
+	}
+	for _, flat := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s (flat: %t)", tt.name, flat), func(t *testing.T) {
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, []types.Index{
+					indexJstl,
+					indexJavaxServlet10,
+					indexJavaxServlet11,
+					indexBundles,
+				})
+				require.NoError(t, err)
+
+				got, err := dbc.SelectIndexesByGAVs(tt.gavs)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	}
+}
+
+func TestCaseInsensitiveLookup(t *testing.T) {
+	indexUpper := indexJstl
+	indexUpper.GroupID = "JSTL"
+	indexUpper.SHA1 = javaxServlet110Sha1b
+
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		groupID         string
+		artifactID      string
+		want            []types.Index
+	}{
+		{
+			name:       "case-sensitive",
+			groupID:    "jstl",
+			artifactID: "jstl",
+			want:       []types.Index{indexJstl},
+		},
+		{
+			name:       "case-sensitive with other case",
+			groupID:    "Jstl",
+			artifactID: "JSTL",
+		},
+		{
+			name:            "case-insensitive",
+			caseInsensitive: true,
+			groupID:         "Jstl",
+			artifactID:      "JSTL",
+			want:            []types.Index{indexJstl, indexUpper},
+		},
+	}
+	for _, flat := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s (flat: %t)", tt.name, flat), func(t *testing.T) {
+				// ids differing only in case are still stored as different artifacts
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat, CaseInsensitive: tt.caseInsensitive}, []types.Index{
+					indexJstl,
+					indexUpper,
+					indexJavaxServlet10,
+				})
+				require.NoError(t, err)
+
+				got, err := dbc.SelectIndexesByGAVs([]types.GAV{{GroupID: tt.groupID, ArtifactID: tt.artifactID, Version: "1.0"}})
+				require.NoError(t, err)
+				assert.ElementsMatch(t, tt.want, got)
+
+				versions, err := dbc.SelectVersionsByArtifactIDAndGroupID(tt.artifactID, tt.groupID)
+				require.NoError(t, err)
+				assert.ElementsMatch(t, tt.want, versions)
+			})
+		}
+	}
+}
+
 func TestSelectIndexesByArtifactIDAndFileType(t *testing.T) {
 	var tests = []struct {
 		name        string
@@ -191,3 +804,1041 @@ func TestSelectIndexesByArtifactIDAndFileType(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectUnsignedArtifacts(t *testing.T) {
+	var tests = []struct {
+		name        string
+		groupID     string
+		wantIndexes []types.Index
+	}{
+		{
+			name:    "all groups",
+			groupID: "",
+			wantIndexes: []types.Index{
+				indexJavaxServlet10,
+				indexJstl,
+				indexBundles,
+			},
+		},
+		{
+			name:    "one group",
+			groupID: "javax.servlet",
+			wantIndexes: []types.Index{
+				indexJavaxServlet10,
+			},
+		},
+		{
+			name:    "wrong GroupID",
+			groupID: "wrong",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+				indexBundles,
+			})
+			require.NoError(t, err)
+
+			gotIndexes, err := dbc.SelectUnsignedArtifacts(tt.groupID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIndexes, gotIndexes)
+		})
+	}
+}
+
+func TestSelectScalaArtifacts(t *testing.T) {
+	scalaIndex := func(artifactID string, n byte) types.Index {
+		return types.Index{
+			GroupID:     "org.typelevel",
+			ArtifactID:  artifactID,
+			Version:     "2.10.0",
+			SHA1:        bytes.Repeat([]byte{n}, 20),
+			ArchiveType: types.JarType,
+		}
+	}
+	indexes := []types.Index{
+		scalaIndex("cats-core_2.12", 1),
+		scalaIndex("cats-core_2.13", 2),
+		scalaIndex("cats-core_3", 3),
+		scalaIndex("cats-kernel_3", 4),
+		indexJstl,
+	}
+	var tests = []struct {
+		name           string
+		groupID        string
+		baseArtifactID string
+		scalaVersion   string
+		want           []types.Artifact
+	}{
+		{
+			name:           "all scala versions",
+			baseArtifactID: "cats-core",
+			want: []types.Artifact{
+				{GroupID: "org.typelevel", ArtifactID: "cats-core_2.12", ScalaVersion: "2.12"},
+				{GroupID: "org.typelevel", ArtifactID: "cats-core_2.13", ScalaVersion: "2.13"},
+				{GroupID: "org.typelevel", ArtifactID: "cats-core_3", ScalaVersion: "3"},
+			},
+		},
+		{
+			name:           "one scala version",
+			groupID:        "org.typelevel",
+			baseArtifactID: "cats-core",
+			scalaVersion:   "3",
+			want: []types.Artifact{
+				{GroupID: "org.typelevel", ArtifactID: "cats-core_3", ScalaVersion: "3"},
+			},
+		},
+		{
+			name:           "not cross-built",
+			baseArtifactID: "jstl",
+			want: []types.Artifact{
+				{GroupID: "jstl", ArtifactID: "jstl"},
+			},
+		},
+		{
+			name:           "wrong GroupID",
+			groupID:        "wrong",
+			baseArtifactID: "cats-core",
+		},
+	}
+	for _, flat := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s flat=%t", tt.name, flat), func(t *testing.T) {
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, indexes)
+				require.NoError(t, err)
+
+				got, err := dbc.SelectScalaArtifacts(tt.groupID, tt.baseArtifactID, tt.scalaVersion)
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			})
+		}
+	}
+}
+
+func TestSelectArtifactsInNamespace(t *testing.T) {
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat=%t", flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+				indexBundles,
+			})
+			require.NoError(t, err)
+
+			// subgroups are included, but not groups sharing the prefix, e.g. `javax.servlet` for `javax.serv`
+			got, err := dbc.SelectArtifactsInNamespace("org.apache")
+			require.NoError(t, err)
+			assert.Equal(t, []types.Artifact{{GroupID: "org.apache.geronimo.bundles", ArtifactID: "jstl"}}, got)
+
+			got, err = dbc.SelectArtifactsInNamespace("javax.serv")
+			require.NoError(t, err)
+			assert.Empty(t, got)
+
+			got, err = dbc.SelectArtifactsInNamespace("javax.servlet")
+			require.NoError(t, err)
+			assert.Equal(t, []types.Artifact{{GroupID: "javax.servlet", ArtifactID: "jstl"}}, got)
+		})
+	}
+}
+
+func TestSearchArtifactsFTS(t *testing.T) {
+	var tests = []struct {
+		name          string
+		query         string
+		wantArtifacts []types.Artifact
+		assertErr     assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "artifact id",
+			query: "jstl",
+			wantArtifacts: []types.Artifact{
+				{GroupID: "jstl", ArtifactID: "jstl"},
+				{GroupID: "javax.servlet", ArtifactID: "jstl"},
+				{GroupID: "org.apache.geronimo.bundles", ArtifactID: "jstl"},
+			},
+			assertErr: assert.NoError,
+		},
+		{
+			name:  "part of group id",
+			query: "geronimo",
+			wantArtifacts: []types.Artifact{
+				{GroupID: "org.apache.geronimo.bundles", ArtifactID: "jstl"},
+			},
+			assertErr: assert.NoError,
+		},
+		{
+			name:  "prefix",
+			query: "serv*",
+			wantArtifacts: []types.Artifact{
+				{GroupID: "javax.servlet", ArtifactID: "jstl"},
+			},
+			assertErr: assert.NoError,
+		},
+		{
+			name:      "no match",
+			query:     "wrong",
+			assertErr: assert.NoError,
+		},
+		{
+			name:      "invalid query",
+			query:     "AND",
+			assertErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{FTS: true}, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+				indexBundles,
+			})
+			require.NoError(t, err)
+
+			gotArtifacts, err := dbc.SearchArtifactsFTS(tt.query)
+			tt.assertErr(t, err)
+			assert.Equal(t, tt.wantArtifacts, gotArtifacts)
+		})
+	}
+}
+
+func TestSearchArtifactsFTSDownloads(t *testing.T) {
+	fooSha1b, _ := hex.DecodeString("1111111111111111111111111111111111111111")
+	barSha1b, _ := hex.DecodeString("2222222222222222222222222222222222222222")
+	dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{FTS: true}, []types.Index{
+		{GroupID: "com.foo", ArtifactID: "lib", Version: "1.0", SHA1: fooSha1b, ArchiveType: types.JarType},
+		{GroupID: "com.bar", ArtifactID: "lib", Version: "1.0", SHA1: barSha1b, ArchiveType: types.JarType},
+	})
+	require.NoError(t, err)
+	require.NoError(t, dbc.InsertPopularity([]types.Popularity{{GroupID: "com.bar", ArtifactID: "lib", Downloads: 100}}))
+	require.NoError(t, dbc.InsertEnrichment(types.Enrichment{
+		GroupID:     "com.foo",
+		ArtifactID:  "lib",
+		Description: "Foo library",
+		ProjectURL:  "https://foo.example.com",
+		FetchedAt:   time.Now(),
+	}))
+
+	// both artifacts match equally well, so the most downloaded one is first
+	got, err := dbc.SearchArtifactsFTS("lib")
+	require.NoError(t, err)
+	assert.Equal(t, []types.Artifact{
+		{GroupID: "com.bar", ArtifactID: "lib", Downloads: 100},
+		{GroupID: "com.foo", ArtifactID: "lib", Description: "Foo library", ProjectURL: "https://foo.example.com"},
+	}, got)
+}
+
+func TestUpdateArtifactPriorities(t *testing.T) {
+	withPriority := func(index types.Index, priority int) types.Index {
+		index.Priority = priority
+		return index
+	}
+	var tests = []struct {
+		name        string
+		priorities  []types.ArtifactPriority
+		wantIndexes []types.Index
+	}{
+		{
+			name: "canonical group first",
+			priorities: []types.ArtifactPriority{
+				{GroupID: "jstl", Priority: 10},
+			},
+			wantIndexes: []types.Index{
+				withPriority(indexJstl, 10),
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+			},
+		},
+		{
+			name: "artifact overrides group",
+			priorities: []types.ArtifactPriority{
+				{GroupID: "jstl", Priority: 10},
+				{GroupID: "javax.servlet", Priority: 5},
+				{GroupID: "javax.servlet", ArtifactID: "jstl", Priority: 20},
+			},
+			wantIndexes: []types.Index{
+				withPriority(indexJavaxServlet10, 20),
+				withPriority(indexJavaxServlet11, 20),
+				withPriority(indexJstl, 10),
+			},
+		},
+		{
+			name: "unknown artifact",
+			priorities: []types.ArtifactPriority{
+				{GroupID: "wrong", Priority: 10},
+			},
+			wantIndexes: []types.Index{
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+				indexJstl,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+				indexBundles,
+			})
+			require.NoError(t, err)
+
+			err = dbc.UpdateArtifactPriorities(tt.priorities)
+			require.NoError(t, err)
+
+			gotIndexes, err := dbc.SelectIndexesByArtifactIDAndFileType("jstl", "1.0", types.JarType)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIndexes, gotIndexes)
+		})
+	}
+}
+
+func TestInsertPopularity(t *testing.T) {
+	withDownloads := func(index types.Index, downloads int64) types.Index {
+		index.Downloads = downloads
+		return index
+	}
+	withPriority := func(index types.Index, priority int) types.Index {
+		index.Priority = priority
+		return index
+	}
+	var tests = []struct {
+		name        string
+		priorities  []types.ArtifactPriority
+		popularity  []types.Popularity
+		wantIndexes []types.Index
+	}{
+		{
+			name: "most downloaded first",
+			popularity: []types.Popularity{
+				{GroupID: "jstl", ArtifactID: "jstl", Downloads: 100},
+				{GroupID: "javax.servlet", ArtifactID: "jstl", Downloads: 10},
+			},
+			wantIndexes: []types.Index{
+				withDownloads(indexJstl, 100),
+				withDownloads(indexJavaxServlet10, 10),
+				withDownloads(indexJavaxServlet11, 10),
+			},
+		},
+		{
+			name: "later entry overrides",
+			popularity: []types.Popularity{
+				{GroupID: "jstl", ArtifactID: "jstl", Downloads: 100},
+				{GroupID: "jstl", ArtifactID: "jstl", Downloads: 1},
+				{GroupID: "javax.servlet", ArtifactID: "jstl", Downloads: 10},
+			},
+			wantIndexes: []types.Index{
+				withDownloads(indexJavaxServlet10, 10),
+				withDownloads(indexJavaxServlet11, 10),
+				withDownloads(indexJstl, 1),
+			},
+		},
+		{
+			name: "priority wins over downloads",
+			priorities: []types.ArtifactPriority{
+				{GroupID: "javax.servlet", Priority: 10},
+			},
+			popularity: []types.Popularity{
+				{GroupID: "jstl", ArtifactID: "jstl", Downloads: 100},
+			},
+			wantIndexes: []types.Index{
+				withPriority(indexJavaxServlet10, 10),
+				withPriority(indexJavaxServlet11, 10),
+				withDownloads(indexJstl, 100),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+				indexBundles,
+			})
+			require.NoError(t, err)
+
+			err = dbc.UpdateArtifactPriorities(tt.priorities)
+			require.NoError(t, err)
+
+			err = dbc.InsertPopularity(tt.popularity)
+			require.NoError(t, err)
+
+			gotIndexes, err := dbc.SelectIndexesByArtifactIDAndFileType("jstl", "1.0", types.JarType)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIndexes, gotIndexes)
+		})
+	}
+}
+
+func TestSelectCollisions(t *testing.T) {
+	relocated := indexJstl
+	relocated.GroupID = "org.glassfish.web"
+	relocated.ArtifactID = "jstl"
+	relocated.Version = "1.0.0"
+
+	var tests = []struct {
+		name    string
+		indexes []types.Index
+		want    []types.Collision
+	}{
+		{
+			name: "relocated artifact",
+			indexes: []types.Index{
+				indexJstl,
+				relocated,
+				indexJavaxServlet10,
+			},
+			want: []types.Collision{
+				{
+					SHA1: jstlSha1b,
+					Indexes: []types.Index{
+						{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0", SHA1: jstlSha1b},
+						{GroupID: "org.glassfish.web", ArtifactID: "jstl", Version: "1.0.0", SHA1: jstlSha1b},
+					},
+				},
+			},
+		},
+		{
+			name: "same GAV twice",
+			indexes: []types.Index{
+				indexJstl,
+				indexJstl,
+				indexJavaxServlet10,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc, err := dbtest.InitDB(t, tt.indexes)
+			require.NoError(t, err)
+
+			got, err := dbc.SelectCollisions()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSelectConflictingGAVs(t *testing.T) {
+	central := indexJstl
+	central.Repository = "central"
+	internal := indexJstl
+	internal.SHA1 = bytes.Repeat([]byte{1}, 20)
+	internal.Repository = "internal"
+
+	want := []types.Conflict{
+		{
+			GroupID:     "jstl",
+			ArtifactID:  "jstl",
+			Version:     "1.0",
+			ArchiveType: types.JarType,
+			Indexes: []types.Index{
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0", ArchiveType: types.JarType, SHA1: jstlSha1b, Repository: "central"},
+				{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0", ArchiveType: types.JarType, SHA1: internal.SHA1, Repository: "internal"},
+			},
+		},
+	}
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat=%t", flat), func(t *testing.T) {
+			// javax.servlet:jstl:1.0 has the same artifact id and version, but another group
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, []types.Index{
+				internal,
+				central,
+				indexJavaxServlet10,
+				indexLegacy,
+			})
+			require.NoError(t, err)
+
+			got, err := dbc.SelectConflictingGAVs()
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestFlatSchema(t *testing.T) {
+	indexes := []types.Index{
+		indexJstl,
+		indexJavaxServlet10,
+		indexJavaxServlet11,
+		indexLegacy,
+		indexBundles,
+	}
+	normalized, err := dbtest.InitDB(t, indexes)
+	require.NoError(t, err)
+	flat, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: true}, indexes)
+	require.NoError(t, err)
+
+	priorities := []types.ArtifactPriority{{GroupID: "javax.servlet", Priority: 10}}
+	require.NoError(t, normalized.UpdateArtifactPriorities(priorities))
+	require.NoError(t, flat.UpdateArtifactPriorities(priorities))
+
+	for _, digest := range []string{
+		"9c581de633e94be1e7a955bd4e8292f16e554387",
+		"0f3e9c7a5b1d2e4f6a8c0b2d4e6f8a1c",
+		"0000000000000000000000000000000000000000",
+	} {
+		want, wantMatch, wantErr := normalized.SelectIndexBySha1OrMd5(digest)
+		got, gotMatch, gotErr := flat.SelectIndexBySha1OrMd5(digest)
+		assert.Equal(t, wantErr, gotErr, digest)
+		assert.Equal(t, want, got, digest)
+		assert.Equal(t, wantMatch, gotMatch, digest)
+	}
+
+	want, err := normalized.SelectIndexesByArtifactIDAndFileType("jstl", "1.0", types.JarType)
+	require.NoError(t, err)
+	got, err := flat.SelectIndexesByArtifactIDAndFileType("jstl", "1.0", types.JarType)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	want, err = normalized.SelectUnsignedArtifacts("jstl")
+	require.NoError(t, err)
+	got, err = flat.SelectUnsignedArtifacts("jstl")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestExplain(t *testing.T) {
+	dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Explain: true}, []types.Index{
+		indexJstl,
+		indexJavaxServlet10,
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	_, err = dbc.SelectIndexesByArtifactIDAndFileType("jstl", "1.0", types.JarType)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "Query plan:")
+	assert.Contains(t, buf.String(), "indices_artifact_version_idx")
+}
+
+func TestAsOf(t *testing.T) {
+	tmpDir := t.TempDir()
+	conf := types.SqliteDBConfig{DBPath: filepath.Join(tmpDir, "trivy-java.db")}
+
+	// Two builds into the same DB
+	for i, index := range []types.Index{indexJstl, indexJavaxServlet10} {
+		dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &conf})
+		require.NoError(t, err)
+		require.NoError(t, dbc.Init())
+
+		generation, err := dbc.StartBuild(time.Date(2026, 1, i+1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Equal(t, i+1, generation)
+
+		require.NoError(t, dbc.InsertIndexes([]types.Index{indexJstl, index}))
+		require.NoError(t, dbc.Close())
+	}
+
+	withGeneration := func(index types.Index, generation int) types.Index {
+		index.Generation = generation
+		return index
+	}
+	var tests = []struct {
+		name        string
+		asOf        int
+		wantIndexes []types.Index
+	}{
+		{
+			name: "latest",
+			wantIndexes: []types.Index{
+				withGeneration(indexJavaxServlet10, 2),
+				withGeneration(indexJstl, 1),
+			},
+		},
+		{
+			name: "first build",
+			asOf: 1,
+			wantIndexes: []types.Index{
+				withGeneration(indexJstl, 1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := conf
+			conf.AsOf = tt.asOf
+			dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &conf})
+			require.NoError(t, err)
+			defer dbc.Close()
+
+			gotIndexes, err := dbc.SelectIndexesByArtifactIDAndFileType("jstl", "1.0", types.JarType)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIndexes, gotIndexes)
+
+			// both indexes are unsigned and ordered by group id
+			gotIndexes, err = dbc.SelectUnsignedArtifacts("")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIndexes, gotIndexes)
+		})
+	}
+}
+
+func TestSelectChangelog(t *testing.T) {
+	tmpDir := t.TempDir()
+	conf := types.SqliteDBConfig{DBPath: filepath.Join(tmpDir, "trivy-java.db")}
+	dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &conf})
+	require.NoError(t, err)
+	defer dbc.Close()
+	require.NoError(t, dbc.Init())
+
+	for _, indexes := range [][]types.Index{
+		{indexJstl},
+		{indexJavaxServlet10},
+		{indexJavaxServlet11, indexBundles},
+	} {
+		_, err = dbc.StartBuild(time.Now())
+		require.NoError(t, err)
+		require.NoError(t, dbc.InsertIndexes(indexes))
+	}
+
+	var tests = []struct {
+		name string
+		from int
+		to   int
+		want types.Changelog
+	}{
+		{
+			name: "second build",
+			from: 1,
+			to:   2,
+			want: types.Changelog{
+				NewGroups:    []string{"javax.servlet"},
+				NewArtifacts: []types.Artifact{{GroupID: "javax.servlet", ArtifactID: "jstl"}},
+				NewVersions: []types.Index{
+					{GroupID: "javax.servlet", ArtifactID: "jstl", Version: "1.0", SHA1: javaxServlet10Sha1b, ArchiveType: types.JarType, Generation: 2},
+				},
+			},
+		},
+		{
+			name: "up to latest",
+			from: 2,
+			want: types.Changelog{
+				NewGroups:    []string{"org.apache.geronimo.bundles"},
+				NewArtifacts: []types.Artifact{{GroupID: "org.apache.geronimo.bundles", ArtifactID: "jstl"}},
+				NewVersions: []types.Index{
+					{GroupID: "javax.servlet", ArtifactID: "jstl", Version: "1.1.0", SHA1: javaxServlet110Sha1b, ArchiveType: types.JarType, Generation: 3},
+					{GroupID: "org.apache.geronimo.bundles", ArtifactID: "jstl", Version: "1.2_1", SHA1: bundlesSha1b, ArchiveType: types.JarType, Generation: 3},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dbc.SelectChangelog(tt.from, tt.to)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUpdateDailyStats(t *testing.T) {
+	withRepository := func(index types.Index, repository string) types.Index {
+		index.Repository = repository
+		return index
+	}
+	day1 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	builds := []struct {
+		builtAt time.Time
+		indexes []types.Index
+	}{
+		{builtAt: day1, indexes: []types.Index{withRepository(indexJstl, "central"), withRepository(indexJavaxServlet10, "central")}},
+		// a new version of a known artifact, added to the same day
+		{builtAt: day1.Add(time.Hour), indexes: []types.Index{withRepository(indexJavaxServlet11, "central")}},
+		// the crawler found nothing new in central
+		{builtAt: day2, indexes: []types.Index{withRepository(indexBundles, "internal")}},
+	}
+	want := []types.DailyStats{
+		{Day: "2026-01-01", Repository: "central", NewArtifacts: 2, NewIndexes: 3},
+		{Day: "2026-01-01", Repository: "internal"},
+		{Day: "2026-01-02", Repository: "central"},
+		{Day: "2026-01-02", Repository: "internal", NewArtifacts: 1, NewIndexes: 1},
+	}
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat=%t", flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, nil)
+			require.NoError(t, err)
+
+			for _, b := range builds {
+				_, err = dbc.StartBuild(b.builtAt)
+				require.NoError(t, err)
+				require.NoError(t, dbc.InsertIndexes(b.indexes))
+				require.NoError(t, dbc.UpdateDailyStats(b.builtAt, []string{"central", "internal"}))
+			}
+
+			got, err := dbc.SelectDailyStats()
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestInitMigratesOldSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "trivy-java.db")
+
+	// tables as created by the first release
+	old, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	for _, stmt := range []string{
+		"CREATE TABLE artifacts(id INTEGER PRIMARY KEY, group_id TEXT, artifact_id TEXT)",
+		"CREATE TABLE indices(artifact_id INTEGER, version TEXT, sha1 BLOB, archive_type TEXT, foreign key (artifact_id) references artifacts(id))",
+		"CREATE UNIQUE INDEX artifacts_idx ON artifacts(artifact_id, group_id)",
+		"CREATE INDEX indices_artifact_idx ON indices(artifact_id)",
+		"CREATE UNIQUE INDEX indices_sha1_idx ON indices(sha1)",
+		"INSERT INTO artifacts(group_id, artifact_id) VALUES ('org.typelevel', 'cats-core_2.13')",
+	} {
+		_, err = old.Exec(stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, old.Close())
+
+	dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: dbPath}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = dbc.Close() })
+
+	// Init twice to check the migration is idempotent
+	require.NoError(t, dbc.Init())
+	require.NoError(t, dbc.Init())
+
+	_, err = dbc.StartBuild(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.NoError(t, dbc.InsertIndexes([]types.Index{indexJstl}))
+
+	got, err := dbc.SelectIndexBySha1("9c581de633e94be1e7a955bd4e8292f16e554387")
+	require.NoError(t, err)
+	want := indexJstl
+	want.Generation = 1
+	assert.Equal(t, want, got)
+
+	// artifacts inserted before the scala columns existed are backfilled
+	artifacts, err := dbc.SelectScalaArtifacts("", "cats-core", "2.13")
+	require.NoError(t, err)
+	assert.Equal(t, []types.Artifact{{GroupID: "org.typelevel", ArtifactID: "cats-core_2.13", ScalaVersion: "2.13"}}, artifacts)
+
+	// artifacts inserted before the normalized columns existed are backfilled
+	ci, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: dbPath, CaseInsensitive: true}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ci.Close() })
+	got, err = ci.SelectIndexByArtifactIDAndGroupID("JSTL", "JSTL")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// the covering index replaces the old index on artifact_id
+	conn, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	defer conn.Close()
+	var indexNames []string
+	rows, err := conn.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'indices' AND sql IS NOT NULL ORDER BY name")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		indexNames = append(indexNames, name)
+	}
+	assert.Equal(t, []string{"indices_artifact_version_idx", "indices_md5_gav_idx", "indices_md5_idx", "indices_sha1_idx"}, indexNames)
+}
+
+func TestReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "trivy-java.db")
+
+	_, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: dbPath, ReadOnly: true}})
+	require.ErrorContains(t, err, "db not found")
+	assert.NoFileExists(t, dbPath)
+
+	dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: dbPath}})
+	require.NoError(t, err)
+	require.NoError(t, dbc.Init())
+	require.NoError(t, dbc.InsertIndexes([]types.Index{indexJstl}))
+	require.NoError(t, dbc.Close())
+
+	dbc, err = db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &types.SqliteDBConfig{DBPath: dbPath, ReadOnly: true}})
+	require.NoError(t, err)
+	defer dbc.Close()
+
+	got, err := dbc.SelectIndexBySha1("9c581de633e94be1e7a955bd4e8292f16e554387")
+	require.NoError(t, err)
+	assert.Equal(t, indexJstl, got)
+	assert.Error(t, dbc.InsertIndexes([]types.Index{indexJavaxServlet10}))
+}
+
+func TestInsertIndexesMD5OnlyAcrossBuilds(t *testing.T) {
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat=%t", flat), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			conf := types.SqliteDBConfig{DBPath: filepath.Join(tmpDir, "trivy-java.db"), Flat: flat}
+
+			// Two builds appending the same md5-only index
+			for i := 0; i < 2; i++ {
+				dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &conf})
+				require.NoError(t, err)
+				require.NoError(t, dbc.Init())
+				_, err = dbc.StartBuild(time.Date(2026, 1, i+1, 0, 0, 0, 0, time.UTC))
+				require.NoError(t, err)
+				require.NoError(t, dbc.InsertIndexes([]types.Index{indexLegacy}))
+				require.NoError(t, dbc.Close())
+			}
+
+			dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &conf})
+			require.NoError(t, err)
+			defer dbc.Close()
+
+			want := indexLegacy
+			want.Generation = 1
+			got, err := dbc.SelectIndexesByArtifactIDAndFileType("jstl", "0.9", types.JarType)
+			require.NoError(t, err)
+			assert.Equal(t, []types.Index{want}, got)
+
+			collisions, err := dbc.SelectCollisions()
+			require.NoError(t, err)
+			assert.Empty(t, collisions)
+		})
+	}
+}
+
+func TestInsertIndexesInvalidDigests(t *testing.T) {
+	truncatedSHA1 := indexJstl
+	truncatedSHA1.SHA1 = jstlSha1b[:19]
+	oversizedMD5 := indexJavaxServlet10
+	oversizedMD5.MD5 = append(javaxServlet10Md5b, 0)
+
+	tests := []struct {
+		name    string
+		index   types.Index
+		wantErr string
+	}{
+		{
+			name:    "truncated sha1",
+			index:   truncatedSHA1,
+			wantErr: "invalid sha1 length of jstl:jstl:1.0: 19 bytes",
+		},
+		{
+			name:    "oversized md5",
+			index:   oversizedMD5,
+			wantErr: "invalid md5 length of javax.servlet:jstl:1.0: 17 bytes",
+		},
+	}
+	for _, flat := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s (flat: %t)", tt.name, flat), func(t *testing.T) {
+				dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, nil)
+				require.NoError(t, err)
+
+				err = dbc.InsertIndexes([]types.Index{indexLegacy, tt.index})
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.ErrorIs(t, err, db.ErrCorrupt)
+
+				// nothing of the batch is inserted
+				got, err := dbc.SelectVersionsByArtifactIDAndGroupID("jstl", "jstl")
+				require.NoError(t, err)
+				assert.Empty(t, got)
+			})
+		}
+	}
+}
+
+func TestReplaceQuarantine(t *testing.T) {
+	first := []types.QuarantinedIndex{
+		{Index: types.Index{GroupID: "org.example", ArtifactID: "broken", Version: "1.0.1", SHA1: []byte{0, 1, 2}, Repository: "central"}, Reason: "sha1 of 3 bytes"},
+	}
+	second := []types.QuarantinedIndex{
+		{Index: types.Index{GroupID: "org.example", ArtifactID: "broken", Version: "", MD5: []byte{0, 1}, Repository: "internal"}, Reason: "empty version"},
+	}
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat=%t", flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, dbc.ReplaceQuarantine(first))
+			require.NoError(t, dbc.ReplaceQuarantine(second))
+
+			// Only the quarantine of the last build is kept
+			got, err := dbc.SelectQuarantine()
+			require.NoError(t, err)
+			assert.Equal(t, second, got)
+		})
+	}
+}
+
+func TestDBInfo(t *testing.T) {
+	builtAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		flat       bool
+		wantCounts map[string]int64
+	}{
+		{
+			flat: false,
+			wantCounts: map[string]int64{
+				"artifacts": 2, "indices": 3, "builds": 0, "collisions": 0, "popularity": 0,
+				"daily_stats": 0, "quarantine": 0, "deprecations": 0, "enrichments": 0, "labels": 0,
+			},
+		},
+		{
+			flat: true,
+			wantCounts: map[string]int64{
+				"gavs": 3, "builds": 0, "collisions": 0, "popularity": 0,
+				"daily_stats": 0, "quarantine": 0, "deprecations": 0, "enrichments": 0, "labels": 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("flat=%t", tt.flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: tt.flat}, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+			})
+			require.NoError(t, err)
+
+			_, err = dbc.SelectDBInfo()
+			assert.ErrorIs(t, err, db.ErrNotFound)
+
+			require.NoError(t, dbc.UpdateDBInfo(db.SchemaVersion, builtAt))
+			got, err := dbc.SelectDBInfo()
+			require.NoError(t, err)
+			assert.Equal(t, types.DBInfo{
+				SchemaVersion: db.SchemaVersion,
+				BuiltAt:       builtAt,
+				RowCounts:     tt.wantCounts,
+			}, got)
+
+			// The vacuum time is kept by the next update
+			require.NoError(t, dbc.VacuumDB())
+			require.NoError(t, dbc.UpdateDBInfo(db.SchemaVersion, builtAt.Add(time.Hour)))
+			got, err = dbc.SelectDBInfo()
+			require.NoError(t, err)
+			assert.Equal(t, builtAt.Add(time.Hour), got.BuiltAt)
+			require.NotNil(t, got.VacuumedAt)
+			assert.WithinDuration(t, time.Now(), *got.VacuumedAt, time.Minute)
+		})
+	}
+}
+
+func TestScanIndexes(t *testing.T) {
+	for _, flat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flat=%t", flat), func(t *testing.T) {
+			dbc, err := dbtest.InitDBWithConfig(t, types.SqliteDBConfig{Flat: flat}, []types.Index{
+				indexJstl,
+				indexJavaxServlet10,
+				indexJavaxServlet11,
+			})
+			require.NoError(t, err)
+
+			var got [][]string
+			err = dbc.ScanIndexes(func(indexes []types.Index) error {
+				var gavs []string
+				for _, index := range indexes {
+					gavs = append(gavs, fmt.Sprintf("%s:%s:%s", index.GroupID, index.ArtifactID, index.Version))
+				}
+				got = append(got, gavs)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, [][]string{
+				{"jstl:jstl:1.0"},
+				{"javax.servlet:jstl:1.0", "javax.servlet:jstl:1.1.0"},
+			}, got)
+
+			// Scanning stops at the first error
+			errStop := errors.New("stop")
+			var calls int
+			err = dbc.ScanIndexes(func(indexes []types.Index) error {
+				calls++
+				return errStop
+			})
+			assert.ErrorIs(t, err, errStop)
+			assert.Equal(t, 1, calls)
+		})
+	}
+}
+
+func TestVacuumModes(t *testing.T) {
+	tests := []struct {
+		mode           types.VacuumMode
+		wantAutoVacuum int
+		wantVacuumed   bool
+	}{
+		{mode: "", wantAutoVacuum: 0, wantVacuumed: true},
+		{mode: types.VacuumIncremental, wantAutoVacuum: 2, wantVacuumed: true},
+		{mode: types.VacuumNone, wantAutoVacuum: 0, wantVacuumed: false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "trivy-java.db")
+			dbc, err := db.NewSqlite(&types.SqliteDBConfig{DBPath: path, Vacuum: tt.mode})
+			require.NoError(t, err)
+			defer dbc.Close()
+			require.NoError(t, dbc.Init())
+			require.NoError(t, dbc.InsertIndexes([]types.Index{indexJstl, indexJavaxServlet10}))
+			require.NoError(t, dbc.UpdateDBInfo(db.SchemaVersion, time.Now()))
+			require.NoError(t, dbc.VacuumDB())
+
+			info, err := dbc.SelectDBInfo()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVacuumed, info.VacuumedAt != nil)
+
+			client, err := sql.Open("sqlite", path)
+			require.NoError(t, err)
+			defer client.Close()
+			var autoVacuum int
+			require.NoError(t, client.QueryRow("PRAGMA auto_vacuum").Scan(&autoVacuum))
+			assert.Equal(t, tt.wantAutoVacuum, autoVacuum)
+			// The tables are analyzed in all modes
+			var stats int
+			require.NoError(t, client.QueryRow("SELECT COUNT(*) FROM sqlite_stat1").Scan(&stats))
+			assert.NotZero(t, stats)
+		})
+	}
+
+	_, err := db.NewSqlite(&types.SqliteDBConfig{DBPath: filepath.Join(t.TempDir(), "trivy-java.db"), Vacuum: "weekly"})
+	assert.ErrorContains(t, err, "unknown vacuum mode")
+}
+
+func TestStageSqlite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trivy-java.db")
+	conf := &types.SqliteDBConfig{DBPath: path, BusyTimeout: time.Second}
+	dbc, err := db.NewSqlite(conf)
+	require.NoError(t, err)
+	require.NoError(t, dbc.Init())
+	require.NoError(t, dbc.InsertIndexes([]types.Index{indexJstl}))
+	require.NoError(t, dbc.Close())
+
+	publish, err := db.StageSqlite(conf)
+	require.NoError(t, err)
+	assert.Equal(t, path+".tmp", conf.DBPath)
+	staged, err := db.NewSqlite(conf)
+	require.NoError(t, err)
+	require.NoError(t, staged.InsertIndexes([]types.Index{indexJavaxServlet10}))
+
+	// Writes go to the copy until it's published
+	reader, err := db.NewSqlite(&types.SqliteDBConfig{DBPath: path, ReadOnly: true})
+	require.NoError(t, err)
+	defer reader.Close()
+	_, err = reader.SelectIndexBySha1(hex.EncodeToString(javaxServlet10Sha1b))
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	require.NoError(t, staged.Close())
+	require.NoError(t, publish())
+	assert.Equal(t, path, conf.DBPath)
+	assert.NoFileExists(t, path+".tmp")
+
+	dbc, err = db.NewSqlite(&types.SqliteDBConfig{DBPath: path, ReadOnly: true})
+	require.NoError(t, err)
+	defer dbc.Close()
+	for _, sha1 := range [][]byte{jstlSha1b, javaxServlet10Sha1b} {
+		_, err = dbc.SelectIndexBySha1(hex.EncodeToString(sha1))
+		assert.NoError(t, err)
+	}
+}
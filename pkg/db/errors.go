@@ -0,0 +1,70 @@
+package db
+
+import (
+	"errors"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// Errors returned by DB methods can be checked against these kinds with errors.Is.
+var (
+	// ErrNotFound is returned by lookups of a single index when there is no matching index.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a unique constraint, e.g. while another build writes to the DB.
+	ErrConflict = errors.New("conflict")
+	// ErrCorrupt is returned for data which can't be stored or read back, e.g. digests of a wrong length.
+	ErrCorrupt = errors.New("corrupt data")
+)
+
+const (
+	sqliteConstraintCheck      = 275
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+
+	mysqlDuplicateEntry = 1062
+)
+
+// kindError marks `err` with one of the kinds above, keeping the driver error available to errors.As.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// sqliteError is implemented by errors of the sqlite driver. Its codes are the extended result codes.
+type sqliteError interface {
+	Code() int
+}
+
+// classifyWriteError marks constraint violations of the sqlite and MySQL drivers as ErrConflict or ErrCorrupt.
+func classifyWriteError(err error) error {
+	var sqliteErr sqliteError
+	var mysqlErr *mysqldriver.MySQLError
+	switch {
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqliteConstraintPrimaryKey, sqliteConstraintUnique:
+			return withKind(ErrConflict, err)
+		case sqliteConstraintCheck:
+			return withKind(ErrCorrupt, err)
+		}
+	case errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry:
+		return withKind(ErrConflict, err)
+	}
+	return err
+}
@@ -0,0 +1,297 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// latencyBuckets are the upper bounds of the latency histograms.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// LatencyHistogram counts calls of a DB method by latency.
+type LatencyHistogram struct {
+	Count int64
+	// Sum is the total latency in seconds.
+	Sum float64
+	// Buckets are cumulative like Prometheus histograms. The last bucket is `+Inf`.
+	Buckets []LatencyBucket
+}
+
+// LatencyBucket counts calls taking at most LE.
+type LatencyBucket struct {
+	LE    string
+	Count int64
+}
+
+// Instrumented wraps a DB, logs calls slower than the threshold and records latency histograms of each method.
+// The histograms are written to the metrics file when the DB is closed.
+type Instrumented struct {
+	DB
+	slowThreshold time.Duration
+	metricsPath   string
+
+	mu         sync.Mutex
+	histograms map[string]*LatencyHistogram
+}
+
+// Instrument wraps `dbc` when the config enables slow query logging or query metrics.
+func Instrument(dbc DB, conf *types.DBConfig) DB {
+	if conf.SlowQueryThreshold == 0 && conf.QueryMetricsPath == "" {
+		return dbc
+	}
+	return &Instrumented{
+		DB:            dbc,
+		slowThreshold: conf.SlowQueryThreshold,
+		metricsPath:   conf.QueryMetricsPath,
+		histograms:    make(map[string]*LatencyHistogram),
+	}
+}
+
+// observe records the latency of `method` called at `start`. `args` describe the call in the slow query log.
+func (i *Instrumented) observe(method string, start time.Time, args ...any) {
+	elapsed := time.Since(start)
+	if i.slowThreshold > 0 && elapsed >= i.slowThreshold {
+		log.Printf("Slow query: %s%v took %s", method, args, elapsed.Round(time.Microsecond))
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	h, ok := i.histograms[method]
+	if !ok {
+		h = &LatencyHistogram{Buckets: make([]LatencyBucket, len(latencyBuckets)+1)}
+		for j, le := range latencyBuckets {
+			h.Buckets[j].LE = le.String()
+		}
+		h.Buckets[len(latencyBuckets)].LE = "+Inf"
+		i.histograms[method] = h
+	}
+	h.Count++
+	h.Sum += elapsed.Seconds()
+	for j, le := range latencyBuckets {
+		if elapsed <= le {
+			h.Buckets[j].Count++
+		}
+	}
+	h.Buckets[len(latencyBuckets)].Count++
+}
+
+// Metrics returns a copy of the latency histograms by method.
+func (i *Instrumented) Metrics() map[string]LatencyHistogram {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	metrics := make(map[string]LatencyHistogram, len(i.histograms))
+	for method, h := range i.histograms {
+		metrics[method] = LatencyHistogram{
+			Count:   h.Count,
+			Sum:     h.Sum,
+			Buckets: append([]LatencyBucket(nil), h.Buckets...),
+		}
+	}
+	return metrics
+}
+
+// Close closes the DB and writes the metrics file.
+func (i *Instrumented) Close() error {
+	err := i.DB.Close()
+	if i.metricsPath == "" {
+		return err
+	}
+	if werr := fileutil.WriteJSON(i.metricsPath, i.Metrics()); werr != nil {
+		log.Printf("Unable to write query metrics: %s", werr)
+		if err == nil {
+			err = xerrors.Errorf("query metrics write error: %w", werr)
+		}
+	}
+	return err
+}
+
+func (i *Instrumented) InsertIndexes(indexes []types.Index) error {
+	defer i.observe("InsertIndexes", time.Now(), fmt.Sprintf("%d indexes", len(indexes)))
+	return i.DB.InsertIndexes(indexes)
+}
+
+func (i *Instrumented) MarkRemovedIndexes(removedAt time.Time, indexes []types.Index) (int, error) {
+	defer i.observe("MarkRemovedIndexes", time.Now(), fmt.Sprintf("%d indexes", len(indexes)))
+	return i.DB.MarkRemovedIndexes(removedAt, indexes)
+}
+
+func (i *Instrumented) CompactArtifacts() (int, error) {
+	defer i.observe("CompactArtifacts", time.Now())
+	return i.DB.CompactArtifacts()
+}
+
+func (i *Instrumented) UpdateArtifactPriorities(priorities []types.ArtifactPriority) error {
+	defer i.observe("UpdateArtifactPriorities", time.Now(), fmt.Sprintf("%d priorities", len(priorities)))
+	return i.DB.UpdateArtifactPriorities(priorities)
+}
+
+func (i *Instrumented) InsertPopularity(popularity []types.Popularity) error {
+	defer i.observe("InsertPopularity", time.Now(), fmt.Sprintf("%d entries", len(popularity)))
+	return i.DB.InsertPopularity(popularity)
+}
+
+func (i *Instrumented) SelectIndexBySha1(sha1 string) (types.Index, error) {
+	defer i.observe("SelectIndexBySha1", time.Now(), sha1)
+	return i.DB.SelectIndexBySha1(sha1)
+}
+
+func (i *Instrumented) SelectIndexBySha1OrMd5(digest string) (types.Index, types.MatchType, error) {
+	defer i.observe("SelectIndexBySha1OrMd5", time.Now(), digest)
+	return i.DB.SelectIndexBySha1OrMd5(digest)
+}
+
+func (i *Instrumented) SelectIndexByDigest(digest types.Digest) (types.Index, error) {
+	defer i.observe("SelectIndexByDigest", time.Now(), digest.String())
+	return i.DB.SelectIndexByDigest(digest)
+}
+
+func (i *Instrumented) SelectIndexByArtifactIDAndGroupID(artifactID, groupID string) (types.Index, error) {
+	defer i.observe("SelectIndexByArtifactIDAndGroupID", time.Now(), artifactID, groupID)
+	return i.DB.SelectIndexByArtifactIDAndGroupID(artifactID, groupID)
+}
+
+func (i *Instrumented) SelectVersionsByArtifactIDAndGroupID(artifactID, groupID string) ([]types.Index, error) {
+	defer i.observe("SelectVersionsByArtifactIDAndGroupID", time.Now(), artifactID, groupID)
+	return i.DB.SelectVersionsByArtifactIDAndGroupID(artifactID, groupID)
+}
+
+func (i *Instrumented) SelectIndexesByGAVs(gavs []types.GAV) ([]types.Index, error) {
+	defer i.observe("SelectIndexesByGAVs", time.Now(), fmt.Sprintf("%d GAVs", len(gavs)))
+	return i.DB.SelectIndexesByGAVs(gavs)
+}
+
+func (i *Instrumented) SelectIndexesByArtifactIDAndFileType(artifactID, version string, fileType types.ArchiveType) ([]types.Index, error) {
+	defer i.observe("SelectIndexesByArtifactIDAndFileType", time.Now(), artifactID, version, fileType)
+	return i.DB.SelectIndexesByArtifactIDAndFileType(artifactID, version, fileType)
+}
+
+func (i *Instrumented) SelectUnsignedArtifacts(groupID string) ([]types.Index, error) {
+	defer i.observe("SelectUnsignedArtifacts", time.Now(), groupID)
+	return i.DB.SelectUnsignedArtifacts(groupID)
+}
+
+func (i *Instrumented) SelectScalaArtifacts(groupID, baseArtifactID, scalaVersion string) ([]types.Artifact, error) {
+	defer i.observe("SelectScalaArtifacts", time.Now(), groupID, baseArtifactID, scalaVersion)
+	return i.DB.SelectScalaArtifacts(groupID, baseArtifactID, scalaVersion)
+}
+
+func (i *Instrumented) SelectArtifactsInNamespace(namespace string) ([]types.Artifact, error) {
+	defer i.observe("SelectArtifactsInNamespace", time.Now(), namespace)
+	return i.DB.SelectArtifactsInNamespace(namespace)
+}
+
+func (i *Instrumented) SearchArtifactsFTS(query string) ([]types.Artifact, error) {
+	defer i.observe("SearchArtifactsFTS", time.Now(), query)
+	return i.DB.SearchArtifactsFTS(query)
+}
+
+func (i *Instrumented) SelectCollisions() ([]types.Collision, error) {
+	defer i.observe("SelectCollisions", time.Now())
+	return i.DB.SelectCollisions()
+}
+
+func (i *Instrumented) SelectConflictingGAVs() ([]types.Conflict, error) {
+	defer i.observe("SelectConflictingGAVs", time.Now())
+	return i.DB.SelectConflictingGAVs()
+}
+
+func (i *Instrumented) SelectChangelog(from, to int) (types.Changelog, error) {
+	defer i.observe("SelectChangelog", time.Now(), from, to)
+	return i.DB.SelectChangelog(from, to)
+}
+
+func (i *Instrumented) UpdateDailyStats(builtAt time.Time, repositories []string) error {
+	defer i.observe("UpdateDailyStats", time.Now(), builtAt.Format(time.RFC3339))
+	return i.DB.UpdateDailyStats(builtAt, repositories)
+}
+
+func (i *Instrumented) SelectDailyStats() ([]types.DailyStats, error) {
+	defer i.observe("SelectDailyStats", time.Now())
+	return i.DB.SelectDailyStats()
+}
+
+func (i *Instrumented) ReplaceQuarantine(indexes []types.QuarantinedIndex) error {
+	defer i.observe("ReplaceQuarantine", time.Now(), fmt.Sprintf("%d indexes", len(indexes)))
+	return i.DB.ReplaceQuarantine(indexes)
+}
+
+func (i *Instrumented) SelectQuarantine() ([]types.QuarantinedIndex, error) {
+	defer i.observe("SelectQuarantine", time.Now())
+	return i.DB.SelectQuarantine()
+}
+
+func (i *Instrumented) InsertLabels(labels []types.Label) error {
+	defer i.observe("InsertLabels", time.Now(), fmt.Sprintf("%d labels", len(labels)))
+	return i.DB.InsertLabels(labels)
+}
+
+func (i *Instrumented) DeleteLabels(labels []types.Label) (int, error) {
+	defer i.observe("DeleteLabels", time.Now(), fmt.Sprintf("%d labels", len(labels)))
+	return i.DB.DeleteLabels(labels)
+}
+
+func (i *Instrumented) SelectLabels(groupID, artifactID string) ([]string, error) {
+	defer i.observe("SelectLabels", time.Now(), groupID, artifactID)
+	return i.DB.SelectLabels(groupID, artifactID)
+}
+
+func (i *Instrumented) SelectLabeledArtifacts(label string) ([]types.Label, error) {
+	defer i.observe("SelectLabeledArtifacts", time.Now(), label)
+	return i.DB.SelectLabeledArtifacts(label)
+}
+
+func (i *Instrumented) ReplaceDeprecations(deprecations []types.Deprecation) error {
+	defer i.observe("ReplaceDeprecations", time.Now(), fmt.Sprintf("%d deprecations", len(deprecations)))
+	return i.DB.ReplaceDeprecations(deprecations)
+}
+
+func (i *Instrumented) SelectDeprecation(groupID, artifactID string) (types.Deprecation, error) {
+	defer i.observe("SelectDeprecation", time.Now(), groupID, artifactID)
+	return i.DB.SelectDeprecation(groupID, artifactID)
+}
+
+func (i *Instrumented) SelectArtifactsToEnrich(limit int) ([]types.Artifact, error) {
+	defer i.observe("SelectArtifactsToEnrich", time.Now(), limit)
+	return i.DB.SelectArtifactsToEnrich(limit)
+}
+
+func (i *Instrumented) InsertEnrichment(enrichment types.Enrichment) error {
+	defer i.observe("InsertEnrichment", time.Now(), enrichment.GroupID, enrichment.ArtifactID)
+	return i.DB.InsertEnrichment(enrichment)
+}
+
+func (i *Instrumented) SelectEnrichment(groupID, artifactID string) (types.Enrichment, error) {
+	defer i.observe("SelectEnrichment", time.Now(), groupID, artifactID)
+	return i.DB.SelectEnrichment(groupID, artifactID)
+}
+
+func (i *Instrumented) UpdateDBInfo(schemaVersion int, builtAt time.Time) error {
+	defer i.observe("UpdateDBInfo", time.Now())
+	return i.DB.UpdateDBInfo(schemaVersion, builtAt)
+}
+
+func (i *Instrumented) SelectDBInfo() (types.DBInfo, error) {
+	defer i.observe("SelectDBInfo", time.Now())
+	return i.DB.SelectDBInfo()
+}
+
+func (i *Instrumented) ScanIndexes(fn func(indexes []types.Index) error) error {
+	defer i.observe("ScanIndexes", time.Now())
+	return i.DB.ScanIndexes(fn)
+}
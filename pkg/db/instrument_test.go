@@ -0,0 +1,61 @@
+package db_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func TestInstrument(t *testing.T) {
+	tmpDir := t.TempDir()
+	metricsPath := filepath.Join(tmpDir, "metrics.json")
+	dbc, err := db.New(tmpDir, &types.DBConfig{
+		SqliteDBConfig:     &types.SqliteDBConfig{DBPath: filepath.Join(tmpDir, "trivy-java.db")},
+		SlowQueryThreshold: time.Nanosecond,
+		QueryMetricsPath:   metricsPath,
+	})
+	require.NoError(t, err)
+	require.IsType(t, &db.Instrumented{}, dbc)
+
+	require.NoError(t, dbc.Init())
+	require.NoError(t, dbc.InsertIndexes([]types.Index{indexJstl}))
+	for i := 0; i < 2; i++ {
+		got, err := dbc.SelectIndexBySha1("9c581de633e94be1e7a955bd4e8292f16e554387")
+		require.NoError(t, err)
+		assert.Equal(t, "jstl", got.GroupID)
+	}
+	require.NoError(t, dbc.Close())
+
+	b, err := os.ReadFile(metricsPath)
+	require.NoError(t, err)
+	var metrics map[string]db.LatencyHistogram
+	require.NoError(t, json.Unmarshal(b, &metrics))
+
+	require.Contains(t, metrics, "InsertIndexes")
+	assert.Equal(t, int64(1), metrics["InsertIndexes"].Count)
+
+	h := metrics["SelectIndexBySha1"]
+	assert.Equal(t, int64(2), h.Count)
+	assert.Greater(t, h.Sum, 0.0)
+	last := h.Buckets[len(h.Buckets)-1]
+	assert.Equal(t, "+Inf", last.LE)
+	assert.Equal(t, int64(2), last.Count)
+}
+
+func TestInstrumentDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbc, err := db.New(tmpDir, &types.DBConfig{
+		SqliteDBConfig: &types.SqliteDBConfig{DBPath: filepath.Join(tmpDir, "trivy-java.db")},
+	})
+	require.NoError(t, err)
+	defer dbc.Close()
+	assert.IsType(t, &db.Sqlite{}, dbc)
+}
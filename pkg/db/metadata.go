@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
 )
 
 const metadataFile = "metadata.json"
@@ -20,6 +22,10 @@ type Metadata struct {
 	NextUpdate   time.Time
 	UpdatedAt    time.Time
 	DownloadedAt time.Time // This field will be filled after downloading.
+	// CrawlReports are the reports of the crawls of the cache dirs, proving that each crawl covered the whole repository.
+	CrawlReports []types.CrawlReport `json:",omitempty"`
+	// CacheDigest is the digest of the cache dirs, feeds and options of the build. See builder.CacheDigest.
+	CacheDigest string `json:",omitempty"`
 }
 
 func NewMetadata(cacheDir string) Client {
@@ -43,7 +49,11 @@ func (c *Client) Get() (Metadata, error) {
 	return metadata, nil
 }
 
+// Update saves `meta`. NextUpdate must be after UpdatedAt, otherwise consumers would consider the DB stale right away.
 func (c *Client) Update(meta Metadata) error {
+	if !meta.NextUpdate.After(meta.UpdatedAt) {
+		return xerrors.Errorf("NextUpdate (%s) must be after UpdatedAt (%s)", meta.NextUpdate, meta.UpdatedAt)
+	}
 	if err := os.MkdirAll(filepath.Dir(c.path), 0744); err != nil {
 		return xerrors.Errorf("mkdir error: %w", err)
 	}
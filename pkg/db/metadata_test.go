@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+)
+
+func TestMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	client := db.NewMetadata(tmpDir)
+
+	want := db.Metadata{
+		Version:    db.SchemaVersion,
+		NextUpdate: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, client.Update(want))
+
+	got, err := client.Get()
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// Trivy reads these fields from metadata.json
+	b, err := os.ReadFile(filepath.Join(tmpDir, "metadata.json"))
+	require.NoError(t, err)
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(b, &fields))
+	assert.Equal(t, map[string]any{
+		"Version":      float64(1),
+		"NextUpdate":   "2026-01-04T00:00:00Z",
+		"UpdatedAt":    "2026-01-01T00:00:00Z",
+		"DownloadedAt": "0001-01-01T00:00:00Z",
+	}, fields)
+}
+
+func TestMetadataUpdateNextUpdateBeforeUpdatedAt(t *testing.T) {
+	client := db.NewMetadata(t.TempDir())
+	err := client.Update(db.Metadata{
+		Version:    db.SchemaVersion,
+		NextUpdate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	assert.ErrorContains(t, err, "must be after UpdatedAt")
+}
@@ -2,40 +2,441 @@ package db
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/hex"
 	"errors"
-	_ "github.com/go-sql-driver/mysql"
+	"fmt"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/h7hac9/trivy-java-db/pkg/types"
+	"github.com/samber/lo"
 	"golang.org/x/xerrors"
+	"log"
+	"net"
+	"regexp"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+const mysqlRetryWait = time.Second
+
 type Mysql struct {
-	client *sql.DB
+	client  *sql.DB
+	explain bool
+	retries int
+	asOf    int
+	lookup  idLookup
+
+	// schema and tablePrefix qualify the table names, see sql.
+	schema      string
+	tablePrefix string
+	tables      *strings.Replacer
+	// utf8mb4 creates and converts tables with the 4-byte utf8mb4 charset and the binary collation.
+	utf8mb4 bool
+	// compat creates tables which TiDB and SingleStore accept.
+	compat bool
+
+	// generation is the current build, set by StartBuild.
+	generation int
+
+	// readers are read replicas used for selects in round-robin. Selects use client if empty.
+	readers []*sql.DB
+	next    uint64
 }
 
-func NewMysql(dbConnectURL string) (*Mysql, error) {
+func NewMysql(conf *types.MysqlDBConfig) (*Mysql, error) {
+	mysql := &Mysql{explain: conf.Explain, retries: conf.Retries, asOf: conf.AsOf, lookup: newIDLookup(conf.CaseInsensitive), utf8mb4: conf.UTF8MB4, compat: conf.Compat}
+	if err := mysql.setTables(conf.Schema, conf.TablePrefix); err != nil {
+		return nil, err
+	}
+
 	var err error
-	db, err := sql.Open("mysql", dbConnectURL)
+	if mysql.client, err = mysql.open(conf.DBConnectURL, conf); err != nil {
+		return nil, err
+	}
+	for _, url := range conf.ReadConnectURLs {
+		reader, err := mysql.open(url, conf)
+		if err != nil {
+			_ = mysql.Close()
+			return nil, xerrors.Errorf("read replica error: %w", err)
+		}
+		mysql.readers = append(mysql.readers, reader)
+	}
+	return mysql, nil
+}
+
+const (
+	// mysqlCharset is the charset of tables created by the first release. It only stores 3-byte utf8 characters.
+	mysqlCharset = "charset=utf8"
+	// mysqlUTF8MB4Collation compares coordinates byte by byte, like SQLite does.
+	mysqlUTF8MB4Collation = "utf8mb4_bin"
+)
+
+// mysqlTables are the tables referred to as `{table}` in queries.
+var mysqlTables = []string{"artifacts", "indices", "builds", "collisions", "popularity", "daily_stats", "quarantine", "deprecations", "enrichments", "labels", "db_info"}
+
+// mysqlNameRegexp matches schema names and table prefixes. They are put into queries without quoting.
+var mysqlNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+// setTables sets the schema and the prefix of the table names used by queries, and the charset of created tables.
+func (mysql *Mysql) setTables(schema, tablePrefix string) error {
+	for _, name := range []string{schema, tablePrefix} {
+		if !mysqlNameRegexp.MatchString(name) {
+			return xerrors.Errorf("invalid schema or table prefix %q: only letters, digits and '_' are allowed", name)
+		}
+	}
+	mysql.schema = schema
+	mysql.tablePrefix = tablePrefix
+
+	oldnew := []string{"{charset}", mysqlCharset}
+	if mysql.utf8mb4 {
+		oldnew = []string{"{charset}", "charset=utf8mb4 COLLATE " + mysqlUTF8MB4Collation}
+	}
+	for _, table := range mysqlTables {
+		oldnew = append(oldnew, "{"+table+"}", mysql.table(table))
+	}
+	mysql.tables = strings.NewReplacer(oldnew...)
+	return nil
+}
+
+// tableName returns the name of `table` with the table prefix.
+func (mysql *Mysql) tableName(table string) string {
+	return mysql.tablePrefix + table
+}
+
+// table returns the name of `table` qualified with the schema.
+func (mysql *Mysql) table(table string) string {
+	if mysql.schema == "" {
+		return mysql.tableName(table)
+	}
+	return mysql.schema + "." + mysql.tableName(table)
+}
+
+// sql replaces `{table}` references in `query` with the qualified table names and `{charset}` with the table options,
+// so that the tables can live in a shared database with a prefix and in another schema than the one of the connect URL.
+func (mysql *Mysql) sql(query string) string {
+	return mysql.tables.Replace(query)
+}
+
+// open opens and pings the DB at `dbConnectURL` with the timeout and the pool settings of `conf`.
+func (mysql *Mysql) open(dbConnectURL string, conf *types.MysqlDBConfig) (*sql.DB, error) {
+	dsn, err := mysqlDSN(dbConnectURL, conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, xerrors.Errorf("can't open %s db: %w", dbConnectURL, err)
 	}
+	setPool(db, conf.MaxOpenConns, conf.MaxIdleConns, conf.ConnMaxLifetime)
+	if err = mysql.retry(db.Ping); err != nil {
+		_ = db.Close()
+		return nil, xerrors.Errorf("can't connect to mysql: %w", err)
+	}
+	return db, nil
+}
+
+// mysqlDSN sets `timeout` into the DSN. Timeouts in the DSN are kept when `timeout` is not set.
+func mysqlDSN(dbConnectURL string, timeout time.Duration) (string, error) {
+	if timeout == 0 {
+		return dbConnectURL, nil
+	}
+	cfg, err := mysqldriver.ParseDSN(dbConnectURL)
+	if err != nil {
+		return "", xerrors.Errorf("invalid mysql DSN: %w", err)
+	}
+	cfg.Timeout = timeout
+	cfg.ReadTimeout = timeout
+	cfg.WriteTimeout = timeout
+	return cfg.FormatDSN(), nil
+}
+
+// reader returns the next read replica, or the primary when there are no replicas.
+// Methods running several queries call it once, since replicas may lag behind each other.
+func (mysql *Mysql) reader() *sql.DB {
+	if len(mysql.readers) == 0 {
+		return mysql.client
+	}
+	return mysql.readers[(atomic.AddUint64(&mysql.next, 1)-1)%uint64(len(mysql.readers))]
+}
+
+// retry calls `fn` again with exponential backoff while it fails with a connection error.
+// database/sql replaces broken connections, so the next attempt reconnects.
+func (mysql *Mysql) retry(fn func() error) error {
+	wait := mysqlRetryWait
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil || i >= mysql.retries || !isConnError(err) {
+			return err
+		}
+		log.Printf("MySQL connection error, retrying in %s: %s", wait, err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
 
-	return &Mysql{client: db}, nil
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysqldriver.ErrInvalidConn) || errors.As(err, &netErr)
 }
 
 func (mysql *Mysql) Init() error {
-	if _, err := mysql.client.Exec("CREATE TABLE IF NOT EXISTS artifacts(id INTEGER AUTO_INCREMENT PRIMARY KEY, group_id varchar(255), artifact_id varchar(255), CONSTRAINT artifacts_idx UNIQUE (artifact_id, group_id)) engine=InnoDB DEFAULT charset=utf8"); err != nil {
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {artifacts}(id INTEGER AUTO_INCREMENT PRIMARY KEY, group_id varchar(255), artifact_id varchar(255), normalized_group_id varchar(255), normalized_artifact_id varchar(255), base_artifact_id varchar(255), scala_version varchar(16), priority INTEGER NOT NULL DEFAULT 0, CONSTRAINT artifacts_idx UNIQUE (artifact_id, group_id), INDEX artifacts_base_idx(base_artifact_id, scala_version), INDEX artifacts_normalized_idx(normalized_artifact_id, normalized_group_id)) engine=InnoDB DEFAULT {charset}")); err != nil {
 		return xerrors.Errorf("failed to create 'artifacts' table: %w", err)
 	}
 
-	if _, err := mysql.client.Exec("CREATE TABLE IF NOT EXISTS indices(artifact_id INTEGER, version varchar(255), sha1 blob, archive_type varchar(255), foreign key (artifact_id) references artifacts(id), CONSTRAINT indices_sha1_idx UNIQUE (sha1(255)), INDEX indices_artifact_idx(artifact_id))engine=InnoDB DEFAULT charset=utf8"); err != nil {
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {builds}(generation INTEGER AUTO_INCREMENT PRIMARY KEY, built_at DATETIME)")); err != nil {
+		return xerrors.Errorf("failed to create 'builds' table: %w", err)
+	}
+
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {collisions}(sha1 varbinary(20), group_id varchar(255), artifact_id varchar(255), version varchar(255), CONSTRAINT collisions_idx UNIQUE (sha1, group_id, artifact_id, version(250))) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'collisions' table: %w", err)
+	}
+
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {popularity}(group_id varchar(255), artifact_id varchar(255), downloads BIGINT NOT NULL DEFAULT 0, CONSTRAINT popularity_idx UNIQUE (artifact_id, group_id)) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'popularity' table: %w", err)
+	}
+
+	indicesDDL := mysqlIndicesDDL
+	if mysql.compat {
+		indicesDDL = mysqlCompatIndicesDDL
+	}
+	if _, err := mysql.client.Exec(mysql.sql(indicesDDL)); err != nil {
 		return xerrors.Errorf("failed to create 'artifacts' table: %w", err)
 	}
+
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {daily_stats}(day varchar(10), repository varchar(255), new_artifacts INTEGER NOT NULL DEFAULT 0, new_indexes INTEGER NOT NULL DEFAULT 0, PRIMARY KEY (day, repository)) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'daily_stats' table: %w", err)
+	}
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {quarantine}(group_id varchar(255), artifact_id varchar(255), version TEXT, sha1 varbinary(255), md5 varbinary(255), repository varchar(1024) NOT NULL DEFAULT '', reason varchar(255)) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'quarantine' table: %w", err)
+	}
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {deprecations}(group_id varchar(255), artifact_id varchar(255) NOT NULL DEFAULT '', replacement varchar(255), reason varchar(1024), PRIMARY KEY (group_id, artifact_id)) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'deprecations' table: %w", err)
+	}
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {enrichments}(group_id varchar(255), artifact_id varchar(255), default_version varchar(255), homepage varchar(1024), description TEXT, project_url varchar(1024), fetched_at DATETIME, PRIMARY KEY (group_id, artifact_id)) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'enrichments' table: %w", err)
+	}
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {labels}(group_id varchar(255), artifact_id varchar(255), label varchar(255), PRIMARY KEY (group_id, artifact_id, label)) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'labels' table: %w", err)
+	}
+	if _, err := mysql.client.Exec(mysql.sql("CREATE TABLE IF NOT EXISTS {db_info}(name varchar(255) PRIMARY KEY, value varchar(255) NOT NULL) engine=InnoDB DEFAULT {charset}")); err != nil {
+		return xerrors.Errorf("failed to create 'db_info' table: %w", err)
+	}
+
+	if err := mysql.migrate(); err != nil {
+		return xerrors.Errorf("failed to migrate tables: %w", err)
+	}
+	if mysql.utf8mb4 {
+		if err := mysql.migrateCharset(); err != nil {
+			return xerrors.Errorf("failed to convert tables to utf8mb4: %w", err)
+		}
+	}
+	if err := backfillScalaVersions(mysql.client, mysql.table("artifacts")); err != nil {
+		return xerrors.Errorf("failed to backfill scala versions: %w", err)
+	}
+	if err := backfillNormalizedIDs(mysql.client, mysql.table("artifacts")); err != nil {
+		return xerrors.Errorf("failed to backfill normalized ids: %w", err)
+	}
+	return nil
+}
+
+const (
+	mysqlIndicesDDL = "CREATE TABLE IF NOT EXISTS {indices}(artifact_id INTEGER, version varchar(255), sha1 binary(20), md5 blob, size BIGINT, signed BOOLEAN, signing_key varchar(64), archive_type varchar(255), classifier varchar(255) NOT NULL DEFAULT '', platform varchar(64) NOT NULL DEFAULT '', repository varchar(1024) NOT NULL DEFAULT '', generation INTEGER NOT NULL DEFAULT 0, removed_at DATETIME, foreign key (artifact_id) references {artifacts}(id), CONSTRAINT indices_sha1_idx UNIQUE (sha1), INDEX indices_md5_idx(md5(16)), INDEX indices_artifact_version_idx(artifact_id, version, archive_type))engine=InnoDB DEFAULT {charset}"
+	// mysqlCompatIndicesDDL creates `indices` on TiDB and SingleStore, which reject foreign keys and prefix indexes on blobs.
+	// md5 is stored as a fixed-size binary like sha1. sha1 can't be the primary key since md5-only indexes have no sha1.
+	mysqlCompatIndicesDDL = "CREATE TABLE IF NOT EXISTS {indices}(artifact_id INTEGER, version varchar(255), sha1 binary(20), md5 binary(16), size BIGINT, signed BOOLEAN, signing_key varchar(64), archive_type varchar(255), classifier varchar(255) NOT NULL DEFAULT '', platform varchar(64) NOT NULL DEFAULT '', repository varchar(1024) NOT NULL DEFAULT '', generation INTEGER NOT NULL DEFAULT 0, removed_at DATETIME, CONSTRAINT indices_sha1_idx UNIQUE (sha1), INDEX indices_md5_idx(md5), INDEX indices_artifact_version_idx(artifact_id, version, archive_type))engine=InnoDB DEFAULT {charset}"
+)
+
+// mysqlColumns are the columns added after the first release.
+// Tables created by older versions don't have them, so Init adds them.
+var mysqlColumns = []struct{ table, column, definition string }{
+	{"artifacts", "priority", "INTEGER NOT NULL DEFAULT 0"},
+	{"indices", "md5", "blob"},
+	{"indices", "size", "BIGINT"},
+	{"indices", "signed", "BOOLEAN"},
+	{"indices", "signing_key", "varchar(64)"},
+	{"indices", "generation", "INTEGER NOT NULL DEFAULT 0"},
+	{"artifacts", "base_artifact_id", "varchar(255)"},
+	{"artifacts", "scala_version", "varchar(16)"},
+	{"indices", "classifier", "varchar(255) NOT NULL DEFAULT ''"},
+	{"indices", "platform", "varchar(64) NOT NULL DEFAULT ''"},
+	{"indices", "repository", "varchar(1024) NOT NULL DEFAULT ''"},
+	{"artifacts", "normalized_group_id", "varchar(255)"},
+	{"artifacts", "normalized_artifact_id", "varchar(255)"},
+	{"indices", "removed_at", "DATETIME"},
+	{"enrichments", "description", "TEXT"},
+	{"enrichments", "project_url", "varchar(1024)"},
+}
+
+// mysqlIndexes are the indexes added after the first release.
+var mysqlIndexes = []struct{ table, index, columns string }{
+	{"indices", "indices_md5_idx", "md5(16)"},
+	{"indices", "indices_artifact_version_idx", "artifact_id, version, archive_type"},
+	{"artifacts", "artifacts_base_idx", "base_artifact_id, scala_version"},
+	{"artifacts", "artifacts_normalized_idx", "normalized_artifact_id, normalized_group_id"},
+}
+
+// migrate adds missing columns and indexes to tables created by older versions.
+// MySQL doesn't support `ADD COLUMN IF NOT EXISTS`, so it checks information_schema first.
+func (mysql *Mysql) migrate() error {
+	for _, c := range mysqlColumns {
+		var count int
+		if err := mysql.client.QueryRow("SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND COLUMN_NAME = ?",
+			mysql.schema, mysql.tableName(c.table), c.column).Scan(&count); err != nil {
+			return xerrors.Errorf("failed to check '%s.%s' column: %w", c.table, c.column, err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := mysql.client.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", mysql.table(c.table), c.column, c.definition)); err != nil {
+			return xerrors.Errorf("failed to add '%s.%s' column: %w", c.table, c.column, err)
+		}
+	}
+	for _, i := range mysqlIndexes {
+		var count int
+		if err := mysql.client.QueryRow("SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND INDEX_NAME = ?",
+			mysql.schema, mysql.tableName(i.table), i.index).Scan(&count); err != nil {
+			return xerrors.Errorf("failed to check '%s' index: %w", i.index, err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := mysql.client.Exec(fmt.Sprintf("CREATE INDEX %s ON %s(%s)", i.index, mysql.table(i.table), i.columns)); err != nil {
+			return xerrors.Errorf("failed to create '%s' index: %w", i.index, err)
+		}
+	}
+
+	if err := mysql.migrateSHA1Column(); err != nil {
+		return err
+	}
+
+	// indices_artifact_version_idx replaces the index on artifact_id only.
+	// It's dropped after the new index exists, since the foreign key needs an index on artifact_id.
+	var count int
+	if err := mysql.client.QueryRow("SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND INDEX_NAME = 'indices_artifact_idx'",
+		mysql.schema, mysql.tableName("indices")).Scan(&count); err != nil {
+		return xerrors.Errorf("failed to check 'indices_artifact_idx' index: %w", err)
+	}
+	if count > 0 {
+		if _, err := mysql.client.Exec(mysql.sql("DROP INDEX indices_artifact_idx ON {indices}")); err != nil {
+			return xerrors.Errorf("failed to drop 'indices_artifact_idx' index: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateSHA1Column converts the sha1 blob of tables created by older versions into binary(20).
+// The unique index on the blob only covered a prefix, so it's recreated on the whole column.
+func (mysql *Mysql) migrateSHA1Column() error {
+	var dataType string
+	if err := mysql.client.QueryRow("SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND COLUMN_NAME = 'sha1'",
+		mysql.schema, mysql.tableName("indices")).Scan(&dataType); err != nil {
+		return xerrors.Errorf("failed to check 'indices.sha1' column: %w", err)
+	}
+	if dataType == "binary" {
+		return nil
+	}
+
+	// binary(20) would pad shorter sha1s with zeros and reject longer ones
+	var invalid int
+	if err := mysql.client.QueryRow(mysql.sql("SELECT COUNT(*) FROM {indices} WHERE sha1 IS NOT NULL AND LENGTH(sha1) != 20")).Scan(&invalid); err != nil {
+		return xerrors.Errorf("failed to count invalid sha1s: %w", err)
+	}
+	if invalid > 0 {
+		return xerrors.Errorf("failed to convert 'indices.sha1' column: %d sha1s don't have 20 bytes, delete them and build again", invalid)
+	}
+	if _, err := mysql.client.Exec(mysql.sql("ALTER TABLE {indices} DROP INDEX indices_sha1_idx, MODIFY sha1 binary(20), ADD CONSTRAINT indices_sha1_idx UNIQUE (sha1)")); err != nil {
+		return xerrors.Errorf("failed to convert 'indices.sha1' column: %w", err)
+	}
+	return nil
+}
+
+// needsUTF8MB4 returns true if the coordinates of the index have characters outside the Basic Multilingual Plane,
+// e.g. emoji, which the 3-byte utf8 charset can't store.
+func needsUTF8MB4(index types.Index) bool {
+	return lo.SomeBy([]string{index.GroupID, index.ArtifactID, index.Version, index.Classifier}, func(s string) bool {
+		return strings.IndexFunc(s, func(r rune) bool { return r > 0xFFFF }) >= 0
+	})
+}
+
+// migrateCharset converts tables created with the 3-byte utf8 charset to utf8mb4 with the binary collation.
+// Artifact names with 4-byte characters can't be stored in utf8, and its case-insensitive collation makes ids differing only in case collide.
+func (mysql *Mysql) migrateCharset() error {
+	// utf8mb4 needs 4 bytes per character, so the full version doesn't fit into collisions_idx
+	var subPart sql.NullInt64
+	err := mysql.client.QueryRow("SELECT SUB_PART FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND INDEX_NAME = 'collisions_idx' AND COLUMN_NAME = 'version'",
+		mysql.schema, mysql.tableName("collisions")).Scan(&subPart)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return xerrors.Errorf("failed to check 'collisions_idx' index: %w", err)
+	}
+	if err == nil && !subPart.Valid {
+		if _, err = mysql.client.Exec(mysql.sql("ALTER TABLE {collisions} DROP INDEX collisions_idx, ADD CONSTRAINT collisions_idx UNIQUE (sha1, group_id, artifact_id, version(250))")); err != nil {
+			return xerrors.Errorf("failed to recreate 'collisions_idx' index: %w", err)
+		}
+	}
+
+	for _, table := range mysqlTables {
+		var collation sql.NullString
+		if err = mysql.client.QueryRow("SELECT TABLE_COLLATION FROM information_schema.TABLES WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?",
+			mysql.schema, mysql.tableName(table)).Scan(&collation); err != nil {
+			return xerrors.Errorf("failed to check '%s' collation: %w", table, err)
+		}
+		if collation.String == mysqlUTF8MB4Collation {
+			continue
+		}
+		log.Printf("Converting '%s' table to utf8mb4...", mysql.tableName(table))
+		if _, err = mysql.client.Exec(fmt.Sprintf("ALTER TABLE %s CONVERT TO CHARACTER SET utf8mb4 COLLATE %s", mysql.table(table), mysqlUTF8MB4Collation)); err != nil {
+			return xerrors.Errorf("failed to convert '%s' table: %w", table, err)
+		}
+	}
 	return nil
 }
 
+// logPlan logs the query plan of `query` on `db` when explain is enabled.
+func (mysql *Mysql) logPlan(db *sql.DB, query string, args ...any) {
+	if !mysql.explain {
+		return
+	}
+	rows, err := db.Query("EXPLAIN "+query, args...)
+	if err != nil {
+		log.Printf("Explain error: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		log.Printf("Explain error: %s", err)
+		return
+	}
+	plan := []string{strings.Join(columns, "\t")}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			log.Printf("Explain error: %s", err)
+			return
+		}
+		var fields []string
+		for _, v := range values {
+			fields = append(fields, v.String)
+		}
+		plan = append(plan, strings.Join(fields, "\t"))
+	}
+	log.Printf("Query plan:\n%s", strings.Join(plan, "\n"))
+}
+
 func (mysql *Mysql) Close() error {
+	for _, reader := range mysql.readers {
+		_ = reader.Close()
+	}
+	if mysql.client == nil {
+		return nil
+	}
 	return mysql.client.Close()
 }
 
@@ -43,10 +444,41 @@ func (mysql *Mysql) VacuumDB() error {
 	return nil
 }
 
+// StartBuild records a new build generation. Indexes inserted after that are tagged with it.
+// Indexes already in the DB keep the generation that introduced them.
+func (mysql *Mysql) StartBuild(builtAt time.Time) (int, error) {
+	res, err := mysql.client.Exec(mysql.sql("INSERT INTO {builds}(built_at) VALUES (?)"), builtAt)
+	if err != nil {
+		return 0, classifyWriteError(xerrors.Errorf("failed to insert to 'builds' table: %w", err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, xerrors.Errorf("last insert id error: %w", err)
+	}
+	mysql.generation = int(id)
+	return mysql.generation, nil
+}
+
 func (mysql *Mysql) InsertIndexes(indexes []types.Index) error {
+	return mysql.retry(func() error {
+		return mysql.insertIndexes(indexes)
+	})
+}
+
+func (mysql *Mysql) insertIndexes(indexes []types.Index) error {
 	if len(indexes) == 0 {
 		return nil
 	}
+	if err := validateDigests(indexes); err != nil {
+		return err
+	}
+	if !mysql.utf8mb4 {
+		// INSERT IGNORE turns "Incorrect string value" errors into warnings, and the rows would be lost or mangled
+		if index, ok := lo.Find(indexes, needsUTF8MB4); ok {
+			return xerrors.Errorf("%s:%s:%s has 4-byte characters, which utf8 tables can't store, use --mysql-utf8mb4",
+				index.GroupID, index.ArtifactID, index.Version)
+		}
+	}
 	tx, err := mysql.client.Begin()
 	if err != nil {
 		return err
@@ -58,91 +490,952 @@ func (mysql *Mysql) InsertIndexes(indexes []types.Index) error {
 	}
 
 	for _, index := range indexes {
-		_, err = tx.Exec(`
-			INSERT IGNORE INTO indices(artifact_id, version, sha1, archive_type)
+		if len(index.SHA1) == 0 {
+			// Rows without sha1 aren't deduplicated by indices_sha1_idx, and MySQL has no partial indexes.
+			if exists, err := mysql.md5IndexExists(tx, index); err != nil {
+				return xerrors.Errorf("insert error: %w", err)
+			} else if exists {
+				continue
+			}
+		}
+		res, err := tx.Exec(mysql.sql(`
+			INSERT IGNORE INTO {indices}(artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, repository, generation)
 			VALUES (
-			        (SELECT id FROM artifacts 
+			        (SELECT id FROM {artifacts} 
 			            WHERE group_id=? AND artifact_id=?), 
-			        ?, ?, ?
-			)`,
-			index.GroupID, index.ArtifactID, index.Version, index.SHA1, index.ArchiveType)
+			        ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+			)`),
+			index.GroupID, index.ArtifactID, index.Version, index.SHA1, index.MD5, index.Size, index.Signed, index.SigningKey, index.ArchiveType, index.Classifier, index.Platform, index.Repository, mysql.generation)
 		if err != nil {
-			return xerrors.Errorf("unable to insert to 'indices' table: %w", err)
+			return classifyWriteError(xerrors.Errorf("unable to insert to 'indices' table: %w", err))
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return xerrors.Errorf("rows affected error: %w", err)
+		} else if n == 0 && len(index.SHA1) != 0 {
+			if err = mysql.insertCollision(tx, index); err != nil {
+				return xerrors.Errorf("insert error: %w", err)
+			}
 		}
 	}
 
 	return tx.Commit()
 }
 
+// MarkRemovedIndexes sets `removed_at` of indexes which are missing from the crawled versions of their artifact.
+func (mysql *Mysql) MarkRemovedIndexes(removedAt time.Time, indexes []types.Index) (int, error) {
+	var count int
+	err := mysql.retry(func() error {
+		var err error
+		count, err = mysql.markRemovedIndexes(removedAt, indexes)
+		return err
+	})
+	return count, err
+}
+
+func (mysql *Mysql) markRemovedIndexes(removedAt time.Time, indexes []types.Index) (int, error) {
+	if len(indexes) == 0 {
+		return 0, nil
+	}
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	for _, artifact := range crawledArtifacts(indexes) {
+		rows, err := tx.Query(mysql.sql(`
+			SELECT i.artifact_id, i.version, i.sha1, i.md5, i.removed_at IS NOT NULL
+			FROM {indices} i
+			JOIN {artifacts} a ON a.id = i.artifact_id
+			WHERE a.group_id = ? AND a.artifact_id = ? AND i.repository = ?`),
+			artifact.groupID, artifact.artifactID, artifact.repository)
+		if err != nil {
+			return 0, xerrors.Errorf("failed to select indexes: %w", err)
+		}
+		var stored []storedIndex
+		for rows.Next() {
+			var s storedIndex
+			if err = rows.Scan(&s.artifactID, &s.version, &s.sha1, &s.md5, &s.removed); err != nil {
+				rows.Close()
+				return 0, xerrors.Errorf("scan error: %w", err)
+			}
+			stored = append(stored, s)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return 0, xerrors.Errorf("rows error: %w", err)
+		}
+
+		// `indices` has no row id, and md5-only rows have no unique key
+		removed, restored := artifact.diff(stored)
+		for _, s := range removed {
+			if _, err = tx.Exec(mysql.sql("UPDATE {indices} SET removed_at = ? WHERE artifact_id = ? AND version = ? AND sha1 <=> ? AND md5 <=> ?"),
+				removedAt.UTC().Format(timestampFormat), s.artifactID, s.version, s.sha1, s.md5); err != nil {
+				return 0, xerrors.Errorf("failed to mark removed index: %w", err)
+			}
+		}
+		for _, s := range restored {
+			if _, err = tx.Exec(mysql.sql("UPDATE {indices} SET removed_at = NULL WHERE artifact_id = ? AND version = ? AND sha1 <=> ? AND md5 <=> ?"),
+				s.artifactID, s.version, s.sha1, s.md5); err != nil {
+				return 0, xerrors.Errorf("failed to unmark removed index: %w", err)
+			}
+		}
+		count += len(removed)
+	}
+	return count, tx.Commit()
+}
+
+// md5IndexExists checks if `index` without sha1 was inserted by a previous build.
+func (mysql *Mysql) md5IndexExists(tx *sql.Tx, index types.Index) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(mysql.sql(`
+		SELECT EXISTS(SELECT 1 FROM {indices} i
+		              JOIN {artifacts} a ON a.id = i.artifact_id
+		              WHERE i.sha1 IS NULL AND a.group_id = ? AND a.artifact_id = ? AND i.version = ? AND i.md5 = ?)`),
+		index.GroupID, index.ArtifactID, index.Version, index.MD5).Scan(&exists)
+	if err != nil {
+		return false, xerrors.Errorf("select md5 index error: %w", err)
+	}
+	return exists, nil
+}
+
+// CompactArtifacts merges artifacts whose ids aren't canonical into the artifact with canonical ids.
+// Their indexes are moved to the kept artifact unless it already has the same index. Artifacts without a canonical
+// counterpart are renamed. It returns the number of compacted artifacts.
+func (mysql *Mysql) CompactArtifacts() (int, error) {
+	var n int
+	err := mysql.retry(func() error {
+		var err error
+		n, err = mysql.compactArtifacts()
+		return err
+	})
+	return n, err
+}
+
+func (mysql *Mysql) compactArtifacts() (int, error) {
+	artifacts, err := selectNonCanonicalArtifacts(mysql.client, mysql.sql("SELECT group_id, artifact_id FROM {artifacts}"))
+	if err != nil {
+		return 0, err
+	}
+	if len(artifacts) == 0 {
+		return 0, nil
+	}
+
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, a := range artifacts {
+		groupID, artifactID := CanonicalID(a[0]), CanonicalID(a[1])
+		var id int64
+		if err = tx.QueryRow(mysql.sql("SELECT id FROM {artifacts} WHERE group_id = ? AND artifact_id = ?"), a[0], a[1]).Scan(&id); err != nil {
+			return 0, xerrors.Errorf("select artifact error: %w", err)
+		}
+
+		var keptID int64
+		err = tx.QueryRow(mysql.sql("SELECT id FROM {artifacts} WHERE group_id = ? AND artifact_id = ?"), groupID, artifactID).Scan(&keptID)
+		if errors.Is(err, sql.ErrNoRows) {
+			base, scalaVersion := splitScalaVersion(artifactID)
+			if _, err = tx.Exec(mysql.sql(`
+				UPDATE {artifacts} SET group_id = ?, artifact_id = ?, normalized_group_id = ?, normalized_artifact_id = ?, base_artifact_id = ?, scala_version = ?
+				WHERE id = ?`),
+				groupID, artifactID, normalizeID(groupID), normalizeID(artifactID), base, scalaVersion, id); err != nil {
+				return 0, xerrors.Errorf("unable to rename artifact: %w", err)
+			}
+			continue
+		} else if err != nil {
+			return 0, xerrors.Errorf("select artifact error: %w", err)
+		}
+
+		// MySQL has no partial indexes, so md5-only indexes of the kept artifact don't block moving their duplicates
+		if _, err = tx.Exec(mysql.sql(`
+			DELETE d FROM {indices} d
+			JOIN {indices} k ON k.artifact_id = ? AND k.version = d.version AND k.md5 = d.md5 AND k.sha1 IS NULL
+			WHERE d.artifact_id = ? AND d.sha1 IS NULL`), keptID, id); err != nil {
+			return 0, xerrors.Errorf("unable to delete duplicate indexes: %w", err)
+		}
+		// Indexes left behind conflict with indexes of the kept artifact
+		if _, err = tx.Exec(mysql.sql("UPDATE IGNORE {indices} SET artifact_id = ? WHERE artifact_id = ?"), keptID, id); err != nil {
+			return 0, xerrors.Errorf("unable to move indexes: %w", err)
+		}
+		if _, err = tx.Exec(mysql.sql("DELETE FROM {indices} WHERE artifact_id = ?"), id); err != nil {
+			return 0, xerrors.Errorf("unable to delete duplicate indexes: %w", err)
+		}
+		if _, err = tx.Exec(mysql.sql(`
+			UPDATE {artifacts} k JOIN {artifacts} d ON d.id = ?
+			SET k.priority = GREATEST(k.priority, d.priority)
+			WHERE k.id = ?`), id, keptID); err != nil {
+			return 0, xerrors.Errorf("unable to update priority: %w", err)
+		}
+		if _, err = tx.Exec(mysql.sql("DELETE FROM {artifacts} WHERE id = ?"), id); err != nil {
+			return 0, xerrors.Errorf("unable to delete artifact: %w", err)
+		}
+	}
+	return len(artifacts), tx.Commit()
+}
+
 func (mysql *Mysql) insertArtifacts(tx *sql.Tx, indexes []types.Index) error {
-	query := `INSERT IGNORE INTO artifacts(group_id, artifact_id) VALUES `
-	query += strings.Repeat("(?, ?), ", len(indexes))
+	query := mysql.sql(`INSERT IGNORE INTO {artifacts}(group_id, artifact_id, normalized_group_id, normalized_artifact_id, base_artifact_id, scala_version) VALUES `)
+	query += strings.Repeat("(?, ?, ?, ?, ?, ?), ", len(indexes))
 	query = strings.TrimSuffix(query, ", ")
 
 	var values []any
 	for _, index := range indexes {
-		values = append(values, index.GroupID, index.ArtifactID)
+		base, scalaVersion := splitScalaVersion(index.ArtifactID)
+		values = append(values, index.GroupID, index.ArtifactID, normalizeID(index.GroupID), normalizeID(index.ArtifactID), base, scalaVersion)
 	}
 	if _, err := tx.Exec(query, values...); err != nil {
-		return xerrors.Errorf("unable to insert to 'artifacts' table: %w", err)
+		return classifyWriteError(xerrors.Errorf("unable to insert to 'artifacts' table: %w", err))
+	}
+	return nil
+}
+
+// insertCollision records `index` whose sha1 is already stored under another GAV.
+func (mysql *Mysql) insertCollision(tx *sql.Tx, index types.Index) error {
+	_, err := tx.Exec(mysql.sql(`
+		INSERT IGNORE INTO {collisions}(sha1, group_id, artifact_id, version)
+		SELECT ?, ?, ?, ? FROM DUAL
+		WHERE NOT EXISTS (SELECT 1 FROM {indices} i
+		                  JOIN {artifacts} a ON a.id = i.artifact_id
+		                  WHERE i.sha1 = ? AND a.group_id = ? AND a.artifact_id = ? AND i.version = ?)`),
+		index.SHA1, index.GroupID, index.ArtifactID, index.Version,
+		index.SHA1, index.GroupID, index.ArtifactID, index.Version)
+	if err != nil {
+		return xerrors.Errorf("unable to insert to 'collisions' table: %w", err)
 	}
 	return nil
 }
 
+func (mysql *Mysql) UpdateArtifactPriorities(priorities []types.ArtifactPriority) error {
+	return mysql.retry(func() error {
+		return mysql.updateArtifactPriorities(priorities)
+	})
+}
+
+func (mysql *Mysql) updateArtifactPriorities(priorities []types.ArtifactPriority) error {
+	if len(priorities) == 0 {
+		return nil
+	}
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range priorities {
+		_, err = tx.Exec(mysql.sql(`
+			UPDATE {artifacts} SET priority = ?
+			WHERE group_id = ? AND (? = '' OR artifact_id = ?)`),
+			p.Priority, p.GroupID, p.ArtifactID, p.ArtifactID)
+		if err != nil {
+			return xerrors.Errorf("unable to update priority of %s:%s: %w", p.GroupID, p.ArtifactID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (mysql *Mysql) InsertPopularity(popularity []types.Popularity) error {
+	return mysql.retry(func() error {
+		return mysql.insertPopularity(popularity)
+	})
+}
+
+func (mysql *Mysql) insertPopularity(popularity []types.Popularity) error {
+	if len(popularity) == 0 {
+		return nil
+	}
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range popularity {
+		_, err = tx.Exec(mysql.sql(`
+			INSERT INTO {popularity}(group_id, artifact_id, downloads) VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE downloads = VALUES(downloads)`),
+			p.GroupID, p.ArtifactID, p.Downloads)
+		if err != nil {
+			return xerrors.Errorf("unable to insert to 'popularity' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (mysql *Mysql) SelectIndexBySha1(sha1 string) (types.Index, error) {
 	var index types.Index
 	sha1b, err := hex.DecodeString(sha1)
 	if err != nil {
 		return index, xerrors.Errorf("sha1 decode error: %w", err)
 	}
-	row := mysql.client.QueryRow(`
-		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.archive_type 
-		FROM indices i
-		JOIN artifacts a ON a.id = i.artifact_id
-        WHERE i.sha1 = ?`,
-		sha1b)
-	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	row := mysql.reader().QueryRow(mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation 
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+        WHERE i.sha1 = ? AND (? = 0 OR i.generation <= ?)`),
+		sha1b, mysql.asOf, mysql.asOf)
+	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
 		return index, xerrors.Errorf("select index error: %w", err)
 	}
 	return index, nil
 }
 
+// SelectIndexBySha1OrMd5 looks up an index by a sha1 or md5 digest, depending on the digest length.
+// Md5 matches are reported with types.MD5Match since they are weaker than sha1 ones.
+func (mysql *Mysql) SelectIndexBySha1OrMd5(digest string) (types.Index, types.MatchType, error) {
+	return selectIndexBySha1OrMd5(mysql, digest)
+}
+
+// SelectIndexByDigest looks up an index by a digest of any algorithm stored in the DB.
+func (mysql *Mysql) SelectIndexByDigest(digest types.Digest) (types.Index, error) {
+	var index types.Index
+	var removedAt sql.NullString
+	column, err := digestColumn(digest.Algorithm)
+	if err != nil {
+		return index, err
+	}
+
+	query := mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation, i.removed_at
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		WHERE i.`) + column + ` = ? AND (? = 0 OR i.generation <= ?)`
+	reader := mysql.reader()
+	mysql.logPlan(reader, query, digest.Value, mysql.asOf, mysql.asOf)
+	row := reader.QueryRow(query, digest.Value, mysql.asOf, mysql.asOf)
+	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation, &removedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
+		return index, xerrors.Errorf("select index error: %w", err)
+	}
+	if index.RemovedAt, err = parseTimestamp(removedAt); err != nil {
+		return index, err
+	}
+	return index, nil
+}
+
 func (mysql *Mysql) SelectIndexByArtifactIDAndGroupID(artifactID, groupID string) (types.Index, error) {
 	var index types.Index
-	row := mysql.client.QueryRow(`
-		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.archive_type
-		FROM indices i 
-		JOIN artifacts a ON a.id = i.artifact_id
-        WHERE a.group_id = ? AND a.artifact_id = ?`,
-		groupID, artifactID)
-	err := row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	groupID, artifactID = mysql.lookup.values(groupID, artifactID)
+	row := mysql.reader().QueryRow(fmt.Sprintf(mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
+		FROM {indices} i 
+		JOIN {artifacts} a ON a.id = i.artifact_id
+        WHERE a.%s = ? AND a.%s = ? AND (? = 0 OR i.generation <= ?)`), mysql.lookup.groupColumn, mysql.lookup.artifactColumn),
+		groupID, artifactID, mysql.asOf, mysql.asOf)
+	err := row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
 		return index, xerrors.Errorf("select index error: %w", err)
 	}
 	return index, nil
 }
 
-// SelectIndexesByArtifactIDAndFileType returns all indexes for `artifactID` + `fileType` if `version` exists for them
+// SelectVersionsByArtifactIDAndGroupID returns indexes of all versions of the artifact in Maven version order, the oldest first.
+// Versions published with several archive types or classifiers have one index for each of them.
+func (mysql *Mysql) SelectVersionsByArtifactIDAndGroupID(artifactID, groupID string) ([]types.Index, error) {
+	groupID, artifactID = mysql.lookup.values(groupID, artifactID)
+	rows, err := mysql.reader().Query(fmt.Sprintf(mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		WHERE a.%s = ? AND a.%s = ? AND (? = 0 OR i.generation <= ?)
+		ORDER BY i.version, i.classifier`), mysql.lookup.groupColumn, mysql.lookup.artifactColumn),
+		groupID, artifactID, mysql.asOf, mysql.asOf)
+	if err != nil {
+		return nil, xerrors.Errorf("select versions error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	sortByVersion(indexes)
+	return indexes, nil
+}
+
+// SelectIndexesByGAVs returns indexes of `gavs` with chunked queries, so that large SBOMs are resolved in a few round trips.
+// GAVs without indexes are skipped. Indexes are sorted by GAV.
+func (mysql *Mysql) SelectIndexesByGAVs(gavs []types.GAV) ([]types.Index, error) {
+	var indexes []types.Index
+	placeholders, args := gavChunks(gavs, mysql.lookup)
+	for i := range placeholders {
+		rows, err := mysql.reader().Query(fmt.Sprintf(mysql.sql(`
+			SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
+			FROM {indices} i
+			JOIN {artifacts} a ON a.id = i.artifact_id
+			WHERE (a.%s, a.%s, i.version) IN (%s) AND (? = 0 OR i.generation <= ?)`), mysql.lookup.groupColumn, mysql.lookup.artifactColumn, placeholders[i]),
+			append(args[i], mysql.asOf, mysql.asOf)...)
+		if err != nil {
+			return nil, xerrors.Errorf("select indexes by GAVs error: %w", err)
+		}
+		for rows.Next() {
+			var index types.Index
+			if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+				rows.Close()
+				return nil, xerrors.Errorf("scan row error: %w", err)
+			}
+			indexes = append(indexes, index)
+		}
+		rows.Close()
+	}
+	sortByGAV(indexes)
+	return indexes, nil
+}
+
+// SelectIndexesByArtifactIDAndFileType returns all indexes for `artifactID` + `fileType` if `version` exists for them.
+// Canonical artifacts (with the highest priority) are listed first, then the most downloaded ones.
 func (mysql *Mysql) SelectIndexesByArtifactIDAndFileType(artifactID, version string, fileType types.ArchiveType) ([]types.Index, error) {
 	var indexes []types.Index
-	rows, err := mysql.client.Query(`
-		SELECT f_id.group_id, f_id.artifact_id, i.version, i.sha1, i.archive_type
-		FROM indices i
-		JOIN (SELECT a.id, a.group_id, a.artifact_id
-      	      FROM indices i
-        	  JOIN artifacts a on a.id = i.artifact_id
-      	      WHERE a.artifact_id = ? AND i.version = ? AND i.archive_type = ?) f_id ON f_id.id = i.artifact_id`,
-		artifactID, version, fileType)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	query := mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation, COALESCE(p.downloads, 0)
+		FROM {artifacts} a
+		JOIN {indices} i ON i.artifact_id = a.id
+		LEFT JOIN {popularity} p ON p.group_id = a.group_id AND p.artifact_id = a.artifact_id
+		WHERE a.artifact_id = ? AND (? = 0 OR i.generation <= ?)
+		  AND EXISTS (SELECT 1 FROM {indices} v WHERE v.artifact_id = a.id AND v.version = ? AND v.archive_type = ?
+		                                        AND (? = 0 OR v.generation <= ?))
+		ORDER BY a.priority DESC, COALESCE(p.downloads, 0) DESC, a.group_id, i.version`)
+	args := []any{artifactID, mysql.asOf, mysql.asOf, version, fileType, mysql.asOf, mysql.asOf}
+	reader := mysql.reader()
+	mysql.logPlan(reader, query, args...)
+	rows, err := reader.Query(query, args...)
+	if err != nil {
 		return nil, xerrors.Errorf("select indexes error: %w", err)
 	}
+	defer rows.Close()
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation, &index.Downloads); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// SelectUnsignedArtifacts returns indexes without a detached PGP signature.
+// All groups are checked when `groupID` is empty.
+func (mysql *Mysql) SelectUnsignedArtifacts(groupID string) ([]types.Index, error) {
+	var indexes []types.Index
+	rows, err := mysql.reader().Query(mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		WHERE NOT i.signed AND (? = '' OR a.group_id = ?) AND (? = 0 OR i.generation <= ?)
+		ORDER BY a.group_id, a.artifact_id, i.version`),
+		groupID, groupID, mysql.asOf, mysql.asOf)
+	if err != nil {
+		return nil, xerrors.Errorf("select unsigned indexes error: %w", err)
+	}
+	defer rows.Close()
 	for rows.Next() {
 		var index types.Index
-		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType); err != nil {
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// SelectScalaArtifacts returns artifacts cross-built from `baseArtifactID`, including `baseArtifactID` itself.
+// All groups are checked when `groupID` is empty, and all Scala versions when `scalaVersion` is empty.
+func (mysql *Mysql) SelectScalaArtifacts(groupID, baseArtifactID, scalaVersion string) ([]types.Artifact, error) {
+	var artifacts []types.Artifact
+	reader := mysql.reader()
+	query := mysql.sql(`
+		SELECT a.group_id, a.artifact_id, a.scala_version, COALESCE(p.downloads, 0)
+		FROM {artifacts} a
+		LEFT JOIN {popularity} p ON p.group_id = a.group_id AND p.artifact_id = a.artifact_id
+		WHERE a.base_artifact_id = ? AND (? = '' OR a.group_id = ?) AND (? = '' OR a.scala_version = ?)
+		ORDER BY a.group_id, a.scala_version`)
+	args := []any{baseArtifactID, groupID, groupID, scalaVersion, scalaVersion}
+	mysql.logPlan(reader, query, args...)
+	rows, err := reader.Query(query, args...)
+	if err != nil {
+		return nil, xerrors.Errorf("select scala artifacts error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID, &artifact.ScalaVersion, &artifact.Downloads); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// SelectArtifactsInNamespace returns artifacts of the `namespace` group and its subgroups, e.g. `com.example.internal` for `com.example`.
+// Subgroups are matched by the range [`namespace.`, `namespace/`), since '/' follows '.' in ASCII.
+func (mysql *Mysql) SelectArtifactsInNamespace(namespace string) ([]types.Artifact, error) {
+	var artifacts []types.Artifact
+	rows, err := mysql.reader().Query(mysql.sql(`
+		SELECT DISTINCT a.group_id, a.artifact_id
+		FROM {artifacts} a
+		WHERE a.group_id = ? OR (a.group_id >= ? AND a.group_id < ?)
+		ORDER BY a.group_id, a.artifact_id`),
+		namespace, namespace+".", namespace+"/")
+	if err != nil {
+		return nil, xerrors.Errorf("select artifacts in namespace error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// SearchArtifactsFTS is not supported, the full-text search index is only built for sqlite.
+func (mysql *Mysql) SearchArtifactsFTS(_ string) ([]types.Artifact, error) {
+	return nil, xerrors.New("full-text search is not supported by mysql")
+}
+
+// SelectCollisions returns sha1s shared by several GAVs.
+// The GAV stored in the DB is listed first in each collision.
+func (mysql *Mysql) SelectCollisions() ([]types.Collision, error) {
+	rows, err := mysql.reader().Query(mysql.sql(`
+		SELECT sha1, group_id, artifact_id, version FROM (
+			SELECT i.sha1, a.group_id, a.artifact_id, i.version, 1 AS stored
+			FROM {indices} i
+			JOIN {artifacts} a ON a.id = i.artifact_id
+			WHERE i.sha1 IN (SELECT sha1 FROM {collisions})
+			UNION ALL
+			SELECT sha1, group_id, artifact_id, version, 0 AS stored
+			FROM {collisions}
+		) c ORDER BY sha1, stored DESC, group_id, artifact_id, version`))
+	if err != nil {
+		return nil, xerrors.Errorf("select collisions error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.SHA1, &index.GroupID, &index.ArtifactID, &index.Version); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return groupCollisions(indexes), nil
+}
+
+// SelectConflictingGAVs returns GAVs stored with several sha1s, e.g. when the same GAV is published
+// in Maven Central and an internal repository with different content.
+func (mysql *Mysql) SelectConflictingGAVs() ([]types.Conflict, error) {
+	rows, err := mysql.reader().Query(mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.archive_type, i.sha1, i.repository
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		WHERE i.sha1 IS NOT NULL AND (i.artifact_id, i.version, i.archive_type) IN (
+			SELECT artifact_id, version, archive_type FROM {indices}
+			WHERE sha1 IS NOT NULL
+			GROUP BY artifact_id, version, archive_type
+			HAVING COUNT(*) > 1)
+		ORDER BY a.group_id, a.artifact_id, i.version, i.archive_type, i.repository`))
+	if err != nil {
+		return nil, xerrors.Errorf("select conflicting GAVs error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.ArchiveType, &index.SHA1, &index.Repository); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return groupConflicts(indexes), nil
+}
+
+// SelectChangelog returns groups, artifacts and versions introduced after the `from` generation up to the `to` one.
+// The latest generation is used if `to` is zero.
+func (mysql *Mysql) SelectChangelog(from, to int) (types.Changelog, error) {
+	var changelog types.Changelog
+	// All queries use the same replica so that they see the same builds.
+	reader := mysql.reader()
+	rows, err := reader.Query(mysql.sql(`
+		SELECT a.group_id
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		GROUP BY a.group_id
+		HAVING MIN(i.generation) > ? AND (? = 0 OR MIN(i.generation) <= ?)
+		ORDER BY a.group_id`),
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new groups error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var groupID string
+		if err = rows.Scan(&groupID); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewGroups = append(changelog.NewGroups, groupID)
+	}
+
+	rows, err = reader.Query(mysql.sql(`
+		SELECT a.group_id, a.artifact_id
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		GROUP BY a.group_id, a.artifact_id
+		HAVING MIN(i.generation) > ? AND (? = 0 OR MIN(i.generation) <= ?)
+		ORDER BY a.group_id, a.artifact_id`),
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new artifacts error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewArtifacts = append(changelog.NewArtifacts, artifact)
+	}
+
+	rows, err = reader.Query(mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.archive_type, i.generation
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		WHERE i.generation > ? AND (? = 0 OR i.generation <= ?)
+		ORDER BY a.group_id, a.artifact_id, i.version`),
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new versions error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType, &index.Generation); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewVersions = append(changelog.NewVersions, index)
+	}
+	return changelog, nil
+}
+
+// UpdateDailyStats adds artifacts and indexes of the current build to the stats of its day, for each of `repositories`.
+// Repositories without new indexes are recorded with zero counts.
+func (mysql *Mysql) UpdateDailyStats(builtAt time.Time, repositories []string) error {
+	return mysql.retry(func() error {
+		return mysql.updateDailyStats(builtAt, repositories)
+	})
+}
+
+func (mysql *Mysql) updateDailyStats(builtAt time.Time, repositories []string) error {
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, repository := range repositories {
+		var newArtifacts, newIndexes int
+		if err = tx.QueryRow(mysql.sql("SELECT COUNT(*) FROM {indices} WHERE generation = ? AND repository = ?"),
+			mysql.generation, repository).Scan(&newIndexes); err != nil {
+			return xerrors.Errorf("count new indexes error: %w", err)
+		}
+		// artifacts with indexes of previous builds aren't new
+		if err = tx.QueryRow(mysql.sql(`
+			SELECT COUNT(DISTINCT i.artifact_id)
+			FROM {indices} i
+			WHERE i.generation = ? AND i.repository = ?
+			  AND NOT EXISTS (SELECT 1 FROM {indices} o WHERE o.artifact_id = i.artifact_id AND o.generation < ?)`),
+			mysql.generation, repository, mysql.generation).Scan(&newArtifacts); err != nil {
+			return xerrors.Errorf("count new artifacts error: %w", err)
+		}
+		if _, err = tx.Exec(mysql.sql(`
+			INSERT INTO {daily_stats}(day, repository, new_artifacts, new_indexes) VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE new_artifacts = new_artifacts + VALUES(new_artifacts), new_indexes = new_indexes + VALUES(new_indexes)`),
+			builtAt.UTC().Format(dayFormat), repository, newArtifacts, newIndexes); err != nil {
+			return xerrors.Errorf("unable to insert to 'daily_stats' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectDailyStats returns the stats of all days, the oldest first.
+func (mysql *Mysql) SelectDailyStats() ([]types.DailyStats, error) {
+	rows, err := mysql.reader().Query(mysql.sql("SELECT day, repository, new_artifacts, new_indexes FROM {daily_stats} ORDER BY day, repository"))
+	if err != nil {
+		return nil, xerrors.Errorf("select daily stats error: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []types.DailyStats
+	for rows.Next() {
+		var s types.DailyStats
+		if err = rows.Scan(&s.Day, &s.Repository, &s.NewArtifacts, &s.NewIndexes); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// ReplaceQuarantine replaces quarantined indexes with the ones of the current build.
+func (mysql *Mysql) ReplaceQuarantine(indexes []types.QuarantinedIndex) error {
+	return mysql.retry(func() error {
+		return mysql.replaceQuarantine(indexes)
+	})
+}
+
+func (mysql *Mysql) replaceQuarantine(indexes []types.QuarantinedIndex) error {
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(mysql.sql("DELETE FROM {quarantine}")); err != nil {
+		return xerrors.Errorf("unable to clear 'quarantine' table: %w", err)
+	}
+	for _, index := range indexes {
+		if _, err = tx.Exec(mysql.sql("INSERT INTO {quarantine}(group_id, artifact_id, version, sha1, md5, repository, reason) VALUES (?, ?, ?, ?, ?, ?, ?)"),
+			index.GroupID, index.ArtifactID, index.Version, index.SHA1, index.MD5, index.Repository, index.Reason); err != nil {
+			return xerrors.Errorf("unable to insert to 'quarantine' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectQuarantine returns quarantined indexes of the last build.
+func (mysql *Mysql) SelectQuarantine() ([]types.QuarantinedIndex, error) {
+	rows, err := mysql.reader().Query(mysql.sql("SELECT group_id, artifact_id, version, sha1, md5, repository, reason FROM {quarantine} ORDER BY group_id, artifact_id, version"))
+	if err != nil {
+		return nil, xerrors.Errorf("select quarantine error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.QuarantinedIndex
+	for rows.Next() {
+		var index types.QuarantinedIndex
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Repository, &index.Reason); err != nil {
 			return nil, xerrors.Errorf("scan row error: %w", err)
 		}
 		indexes = append(indexes, index)
 	}
 	return indexes, nil
 }
+
+// ScanIndexes reads the indexes from a read replica, if any, with a single query.
+func (mysql *Mysql) ScanIndexes(fn func(indexes []types.Index) error) error {
+	rows, err := mysql.reader().Query(mysql.sql(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation, i.repository
+		FROM {indices} i
+		JOIN {artifacts} a ON a.id = i.artifact_id
+		WHERE ? = 0 OR i.generation <= ?
+		ORDER BY a.id`), mysql.asOf, mysql.asOf)
+	if err != nil {
+		return xerrors.Errorf("select indexes error: %w", err)
+	}
+	return scanArtifactIndexes(rows, fn)
+}
+
+// ReplaceDeprecations replaces the deprecated artifacts with the ones of the feed. Later entries override earlier ones.
+func (mysql *Mysql) ReplaceDeprecations(deprecations []types.Deprecation) error {
+	return mysql.retry(func() error {
+		return mysql.replaceDeprecations(deprecations)
+	})
+}
+
+func (mysql *Mysql) replaceDeprecations(deprecations []types.Deprecation) error {
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(mysql.sql("DELETE FROM {deprecations}")); err != nil {
+		return xerrors.Errorf("failed to clear 'deprecations' table: %w", err)
+	}
+	for _, d := range deprecations {
+		if _, err = tx.Exec(mysql.sql("REPLACE INTO {deprecations}(group_id, artifact_id, replacement, reason) VALUES (?, ?, ?, ?)"),
+			d.GroupID, d.ArtifactID, d.Replacement, d.Reason); err != nil {
+			return xerrors.Errorf("failed to insert to 'deprecations' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectDeprecation returns the deprecation of the artifact, or of its whole group.
+// It returns ErrNotFound if the artifact isn't deprecated.
+func (mysql *Mysql) SelectDeprecation(groupID, artifactID string) (types.Deprecation, error) {
+	var d types.Deprecation
+	err := mysql.reader().QueryRow(mysql.sql(`
+		SELECT group_id, artifact_id, replacement, reason FROM {deprecations}
+		WHERE group_id = ? AND artifact_id IN (?, '')
+		ORDER BY artifact_id DESC LIMIT 1`), groupID, artifactID).
+		Scan(&d.GroupID, &d.ArtifactID, &d.Replacement, &d.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, ErrNotFound
+	} else if err != nil {
+		return d, xerrors.Errorf("select deprecation error: %w", err)
+	}
+	return d, nil
+}
+
+// InsertLabels attaches the labels to their artifacts. Labels which are already attached are skipped.
+func (mysql *Mysql) InsertLabels(labels []types.Label) error {
+	return mysql.retry(func() error {
+		return mysql.insertLabels(labels)
+	})
+}
+
+func (mysql *Mysql) insertLabels(labels []types.Label) error {
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, l := range labels {
+		if _, err = tx.Exec(mysql.sql("INSERT IGNORE INTO {labels}(group_id, artifact_id, label) VALUES (?, ?, ?)"),
+			l.GroupID, l.ArtifactID, l.Label); err != nil {
+			return xerrors.Errorf("failed to insert to 'labels' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteLabels detaches the labels from their artifacts and returns the number of detached labels.
+func (mysql *Mysql) DeleteLabels(labels []types.Label) (int, error) {
+	var deleted int
+	err := mysql.retry(func() error {
+		var err error
+		deleted, err = mysql.deleteLabels(labels)
+		return err
+	})
+	return deleted, err
+}
+
+func (mysql *Mysql) deleteLabels(labels []types.Label) (int, error) {
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var deleted int
+	for _, l := range labels {
+		res, err := tx.Exec(mysql.sql("DELETE FROM {labels} WHERE group_id = ? AND artifact_id = ? AND label = ?"), l.GroupID, l.ArtifactID, l.Label)
+		if err != nil {
+			return 0, xerrors.Errorf("failed to delete from 'labels' table: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(n)
+	}
+	return deleted, tx.Commit()
+}
+
+// SelectLabels returns the labels of the artifact in alphabetical order.
+func (mysql *Mysql) SelectLabels(groupID, artifactID string) ([]string, error) {
+	rows, err := mysql.reader().Query(mysql.sql("SELECT group_id, artifact_id, label FROM {labels} WHERE group_id = ? AND artifact_id = ? ORDER BY label"), groupID, artifactID)
+	if err != nil {
+		return nil, xerrors.Errorf("select labels error: %w", err)
+	}
+	labels, err := scanLabels(rows)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(labels, func(l types.Label, _ int) string { return l.Label }), nil
+}
+
+// SelectLabeledArtifacts returns the artifacts with the label, or all labels of all artifacts if `label` is empty.
+func (mysql *Mysql) SelectLabeledArtifacts(label string) ([]types.Label, error) {
+	rows, err := mysql.reader().Query(mysql.sql(`
+		SELECT group_id, artifact_id, label FROM {labels}
+		WHERE ? = '' OR label = ?
+		ORDER BY group_id, artifact_id, label`), label, label)
+	if err != nil {
+		return nil, xerrors.Errorf("select labels error: %w", err)
+	}
+	return scanLabels(rows)
+}
+
+// SelectArtifactsToEnrich returns up to `limit` artifacts without enrichment, so interrupted runs resume where they stopped.
+// All of them are returned if `limit` is 0.
+func (mysql *Mysql) SelectArtifactsToEnrich(limit int) ([]types.Artifact, error) {
+	return selectArtifactsToEnrich(mysql.reader(), mysql.sql(`
+		SELECT a.group_id, a.artifact_id FROM {artifacts} a
+		LEFT JOIN {enrichments} e ON e.group_id = a.group_id AND e.artifact_id = a.artifact_id
+		WHERE e.group_id IS NULL
+		ORDER BY a.group_id, a.artifact_id`), limit)
+}
+
+// InsertEnrichment inserts or replaces the enrichment of an artifact.
+func (mysql *Mysql) InsertEnrichment(e types.Enrichment) error {
+	return mysql.retry(func() error {
+		if _, err := mysql.client.Exec(mysql.sql("REPLACE INTO {enrichments}(group_id, artifact_id, default_version, homepage, description, project_url, fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?)"),
+			e.GroupID, e.ArtifactID, e.DefaultVersion, e.Homepage, e.Description, e.ProjectURL, e.FetchedAt.UTC().Format(timestampFormat)); err != nil {
+			return xerrors.Errorf("failed to insert to 'enrichments' table: %w", err)
+		}
+		return nil
+	})
+}
+
+// SelectEnrichment returns the enrichment of an artifact, or ErrNotFound if it wasn't enriched yet.
+func (mysql *Mysql) SelectEnrichment(groupID, artifactID string) (types.Enrichment, error) {
+	return selectEnrichment(mysql.reader().QueryRow(mysql.sql(
+		"SELECT group_id, artifact_id, default_version, homepage, COALESCE(description, ''), COALESCE(project_url, ''), fetched_at FROM {enrichments} WHERE group_id = ? AND artifact_id = ?"),
+		groupID, artifactID))
+}
+
+// UpdateDBInfo replaces the schema version, the build time and the row counts in `db_info`.
+// MySQL DBs are never vacuumed, so there is no vacuum time.
+func (mysql *Mysql) UpdateDBInfo(schemaVersion int, builtAt time.Time) error {
+	return mysql.retry(func() error {
+		return mysql.updateDBInfo(schemaVersion, builtAt)
+	})
+}
+
+func (mysql *Mysql) updateDBInfo(schemaVersion int, builtAt time.Time) error {
+	tx, err := mysql.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables := lo.Without(mysqlTables, "db_info")
+	values, err := dbInfoValues(tx, tables, mysql.table, schemaVersion, builtAt)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(mysql.sql("DELETE FROM {db_info}")); err != nil {
+		return xerrors.Errorf("failed to clear 'db_info' table: %w", err)
+	}
+	for name, value := range values {
+		if _, err = tx.Exec(mysql.sql("INSERT INTO {db_info}(name, value) VALUES (?, ?)"), name, value); err != nil {
+			return xerrors.Errorf("failed to insert to 'db_info' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectDBInfo returns the info written by the last build, or ErrNotFound if no build wrote it.
+func (mysql *Mysql) SelectDBInfo() (types.DBInfo, error) {
+	return selectDBInfo(mysql.reader(), mysql.sql("SELECT name, value FROM {db_info}"))
+}
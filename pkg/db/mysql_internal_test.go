@@ -0,0 +1,156 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func TestMysqlDSN(t *testing.T) {
+	tests := []struct {
+		name         string
+		dbConnectURL string
+		timeout      time.Duration
+		wantTimeout  time.Duration
+		assertErr    assert.ErrorAssertionFunc
+	}{
+		{
+			name:         "timeout overrides DSN",
+			dbConnectURL: "user:pass@tcp(localhost:3306)/trivy?timeout=30s",
+			timeout:      5 * time.Second,
+			wantTimeout:  5 * time.Second,
+			assertErr:    assert.NoError,
+		},
+		{
+			name:         "zero timeout keeps DSN",
+			dbConnectURL: "user:pass@tcp(localhost:3306)/trivy?timeout=30s",
+			wantTimeout:  30 * time.Second,
+			assertErr:    assert.NoError,
+		},
+		{
+			name:         "invalid DSN",
+			dbConnectURL: "user:pass@tcp(localhost:3306)trivy",
+			timeout:      5 * time.Second,
+			assertErr:    assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := mysqlDSN(tt.dbConnectURL, tt.timeout)
+			tt.assertErr(t, err)
+			if err != nil {
+				return
+			}
+			cfg, err := mysqldriver.ParseDSN(dsn)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTimeout, cfg.Timeout)
+			if tt.timeout != 0 {
+				assert.Equal(t, tt.timeout, cfg.ReadTimeout)
+				assert.Equal(t, tt.timeout, cfg.WriteTimeout)
+			}
+		})
+	}
+}
+
+func TestMysqlTables(t *testing.T) {
+	query := "SELECT a.group_id FROM {indices} i JOIN {artifacts} a ON a.id = i.artifact_id WHERE i.sha1 IN (SELECT sha1 FROM {collisions})"
+	ddl := "CREATE TABLE IF NOT EXISTS {popularity}(group_id varchar(255)) engine=InnoDB DEFAULT {charset}"
+	tests := []struct {
+		name        string
+		schema      string
+		tablePrefix string
+		utf8mb4     bool
+		want        string
+		wantDDL     string
+		wantErr     string
+	}{
+		{
+			name:    "default",
+			want:    "SELECT a.group_id FROM indices i JOIN artifacts a ON a.id = i.artifact_id WHERE i.sha1 IN (SELECT sha1 FROM collisions)",
+			wantDDL: "CREATE TABLE IF NOT EXISTS popularity(group_id varchar(255)) engine=InnoDB DEFAULT charset=utf8",
+		},
+		{
+			name:    "utf8mb4",
+			utf8mb4: true,
+			want:    "SELECT a.group_id FROM indices i JOIN artifacts a ON a.id = i.artifact_id WHERE i.sha1 IN (SELECT sha1 FROM collisions)",
+			wantDDL: "CREATE TABLE IF NOT EXISTS popularity(group_id varchar(255)) engine=InnoDB DEFAULT charset=utf8mb4 COLLATE utf8mb4_bin",
+		},
+		{
+			name:        "table prefix",
+			tablePrefix: "tjdb_",
+			want:        "SELECT a.group_id FROM tjdb_indices i JOIN tjdb_artifacts a ON a.id = i.artifact_id WHERE i.sha1 IN (SELECT sha1 FROM tjdb_collisions)",
+			wantDDL:     "CREATE TABLE IF NOT EXISTS tjdb_popularity(group_id varchar(255)) engine=InnoDB DEFAULT charset=utf8",
+		},
+		{
+			name:        "schema and table prefix",
+			schema:      "shared",
+			tablePrefix: "tjdb_",
+			want:        "SELECT a.group_id FROM shared.tjdb_indices i JOIN shared.tjdb_artifacts a ON a.id = i.artifact_id WHERE i.sha1 IN (SELECT sha1 FROM shared.tjdb_collisions)",
+			wantDDL:     "CREATE TABLE IF NOT EXISTS shared.tjdb_popularity(group_id varchar(255)) engine=InnoDB DEFAULT charset=utf8",
+		},
+		{
+			name:        "invalid table prefix",
+			tablePrefix: "tjdb; DROP TABLE x; --",
+			wantErr:     "invalid schema or table prefix",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mysql := &Mysql{utf8mb4: tt.utf8mb4}
+			err := mysql.setTables(tt.schema, tt.tablePrefix)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, mysql.sql(query))
+			assert.Equal(t, tt.wantDDL, mysql.sql(ddl))
+		})
+	}
+}
+
+func TestIsConnError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad conn", err: driver.ErrBadConn, want: true},
+		{name: "wrapped bad conn", err: xerrors.Errorf("insert error: %w", driver.ErrBadConn), want: true},
+		{name: "invalid conn", err: mysqldriver.ErrInvalidConn, want: true},
+		{name: "net error", err: xerrors.Errorf("ping error: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), want: true},
+		{name: "query error", err: &mysqldriver.MySQLError{Number: 1064, Message: "syntax error"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isConnError(tt.err))
+		})
+	}
+}
+
+func TestNeedsUTF8MB4(t *testing.T) {
+	tests := []struct {
+		name  string
+		index types.Index
+		want  bool
+	}{
+		{name: "ascii", index: types.Index{GroupID: "jstl", ArtifactID: "jstl", Version: "1.2"}},
+		{name: "3-byte characters", index: types.Index{GroupID: "org.café", ArtifactID: "日本語", Version: "1.0"}},
+		{name: "emoji in artifact id", index: types.Index{GroupID: "io.fun", ArtifactID: "rocket-\U0001F680", Version: "1.0"}, want: true},
+		{name: "emoji in classifier", index: types.Index{GroupID: "io.fun", ArtifactID: "rocket", Version: "1.0", Classifier: "\U0001F680"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, needsUTF8MB4(tt.index))
+		})
+	}
+}
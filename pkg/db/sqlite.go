@@ -4,48 +4,266 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/h7hac9/trivy-java-db/pkg/types"
+	"github.com/samber/lo"
 	"golang.org/x/xerrors"
+	"io"
+	"log"
+	"os"
 	"strings"
+	"time"
 )
 
 type Sqlite struct {
-	client *sql.DB
-	dir    string
+	client  *sql.DB
+	dir     string
+	fts     bool
+	explain bool
+	asOf    int
+	lookup  idLookup
+	vacuum  types.VacuumMode
+
+	// generation is the current build, set by StartBuild.
+	generation int
 }
 
-func NewSqlite(dbPath string) (*Sqlite, error) {
+// sqliteDBInfoDDL creates the `db_info` table, which describes the DB to consumers without metadata.json.
+const sqliteDBInfoDDL = "CREATE TABLE IF NOT EXISTS db_info(name TEXT PRIMARY KEY, value TEXT NOT NULL)"
+
+// sqliteTables are the tables counted in `db_info`.
+var sqliteTables = []string{"artifacts", "indices", "builds", "collisions", "popularity", "daily_stats", "quarantine", "deprecations", "enrichments", "labels"}
+
+func NewSqlite(conf *types.SqliteDBConfig) (*Sqlite, error) {
 	var err error
 
-	db, err := sql.Open("sqlite", dbPath)
+	vacuum := conf.Vacuum
+	if vacuum == "" {
+		vacuum = types.VacuumFull
+	}
+	// Pragmas of the DSN are set on every connection
+	var params []string
+	switch vacuum {
+	case types.VacuumFull, types.VacuumNone:
+	case types.VacuumIncremental:
+		// auto_vacuum must be set before the first table is created
+		params = append(params, "_pragma=auto_vacuum(incremental)")
+	default:
+		return nil, xerrors.Errorf("unknown vacuum mode: %q", vacuum)
+	}
+	if conf.BusyTimeout > 0 {
+		params = append(params, fmt.Sprintf("_pragma=busy_timeout(%d)", conf.BusyTimeout.Milliseconds()))
+	}
+	if conf.ReadOnly {
+		// sqlite creates missing DB files, even in read-only mode
+		if _, err = os.Stat(conf.DBPath); err != nil {
+			return nil, xerrors.Errorf("db not found: %w", err)
+		}
+		params = append(params, "mode=ro")
+	}
+	dsn := conf.DBPath
+	if len(params) > 0 {
+		dsn = "file:" + conf.DBPath + "?" + strings.Join(params, "&")
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, xerrors.Errorf("can't open db: %w", err)
 	}
 
+	setPool(db, conf.MaxOpenConns, conf.MaxIdleConns, conf.ConnMaxLifetime)
+
 	if _, err := db.Exec("PRAGMA foreign_keys=true"); err != nil {
 		return nil, xerrors.Errorf("failed to enable 'foreign_keys': %w", err)
 	}
 
-	return &Sqlite{client: db, dir: dbPath}, nil
+	return &Sqlite{client: db, dir: conf.DBPath, fts: conf.FTS, explain: conf.Explain, asOf: conf.AsOf, lookup: newIDLookup(conf.CaseInsensitive), vacuum: vacuum}, nil
+}
+
+// StageSqlite points `conf` to a copy of its DB file, so that writes don't block readers of the DB, and returns a func
+// replacing the DB file with the copy and pointing `conf` back to it. The func must be called after the DB is closed.
+// Readers which opened the DB before keep reading the old file until they reopen it.
+func StageSqlite(conf *types.SqliteDBConfig) (func() error, error) {
+	path := conf.DBPath
+	staged := path + ".tmp"
+	if err := copySqliteFile(path, staged); err != nil {
+		return nil, err
+	}
+	conf.DBPath = staged
+	return func() error {
+		if err := os.Rename(staged, path); err != nil {
+			return xerrors.Errorf("failed to replace %s: %w", path, err)
+		}
+		conf.DBPath = path
+		return nil
+	}, nil
+}
+
+// copySqliteFile copies the DB at `src` to `dst`. `dst` is removed if `src` doesn't exist, so a new DB is created.
+func copySqliteFile(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return xerrors.Errorf("failed to remove %s: %w", dst, err)
+	}
+	in, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return xerrors.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return xerrors.Errorf("failed to create %s: %w", dst, err)
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return xerrors.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
 }
 
 func (sqlite *Sqlite) Init() error {
-	if _, err := sqlite.client.Exec("CREATE TABLE artifacts(id INTEGER PRIMARY KEY, group_id TEXT, artifact_id TEXT)"); err != nil {
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS artifacts(id INTEGER PRIMARY KEY, group_id TEXT, artifact_id TEXT, normalized_group_id TEXT, normalized_artifact_id TEXT, base_artifact_id TEXT, scala_version TEXT, priority INTEGER NOT NULL DEFAULT 0)"); err != nil {
 		return xerrors.Errorf("unable to create 'artifacts' table: %w", err)
 	}
-	if _, err := sqlite.client.Exec("CREATE TABLE indices(artifact_id INTEGER, version TEXT, sha1 BLOB, archive_type TEXT, foreign key (artifact_id) references artifacts(id))"); err != nil {
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS indices(artifact_id INTEGER, version TEXT, sha1 BLOB CHECK (length(sha1) = 20), md5 BLOB CHECK (length(md5) = 16), size INTEGER, signed BOOLEAN, signing_key TEXT, archive_type TEXT, classifier TEXT NOT NULL DEFAULT '', platform TEXT NOT NULL DEFAULT '', repository TEXT NOT NULL DEFAULT '', generation INTEGER NOT NULL DEFAULT 0, removed_at TEXT, foreign key (artifact_id) references artifacts(id))"); err != nil {
 		return xerrors.Errorf("unable to create 'indices' table: %w", err)
 	}
 
-	if _, err := sqlite.client.Exec("CREATE UNIQUE INDEX artifacts_idx ON artifacts(artifact_id, group_id)"); err != nil {
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS builds(generation INTEGER PRIMARY KEY, built_at TIMESTAMP)"); err != nil {
+		return xerrors.Errorf("unable to create 'builds' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS collisions(sha1 BLOB, group_id TEXT, artifact_id TEXT, version TEXT)"); err != nil {
+		return xerrors.Errorf("unable to create 'collisions' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS popularity(group_id TEXT, artifact_id TEXT, downloads INTEGER NOT NULL DEFAULT 0)"); err != nil {
+		return xerrors.Errorf("unable to create 'popularity' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS daily_stats(day TEXT, repository TEXT, new_artifacts INTEGER NOT NULL DEFAULT 0, new_indexes INTEGER NOT NULL DEFAULT 0, PRIMARY KEY (day, repository))"); err != nil {
+		return xerrors.Errorf("unable to create 'daily_stats' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS quarantine(group_id TEXT, artifact_id TEXT, version TEXT, sha1 BLOB, md5 BLOB, repository TEXT NOT NULL DEFAULT '', reason TEXT)"); err != nil {
+		return xerrors.Errorf("unable to create 'quarantine' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS deprecations(group_id TEXT, artifact_id TEXT NOT NULL DEFAULT '', replacement TEXT, reason TEXT, PRIMARY KEY (group_id, artifact_id))"); err != nil {
+		return xerrors.Errorf("unable to create 'deprecations' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS enrichments(group_id TEXT, artifact_id TEXT, default_version TEXT, homepage TEXT, description TEXT, project_url TEXT, fetched_at TEXT, PRIMARY KEY (group_id, artifact_id))"); err != nil {
+		return xerrors.Errorf("unable to create 'enrichments' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE TABLE IF NOT EXISTS labels(group_id TEXT, artifact_id TEXT, label TEXT, PRIMARY KEY (group_id, artifact_id, label))"); err != nil {
+		return xerrors.Errorf("unable to create 'labels' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec(sqliteDBInfoDDL); err != nil {
+		return xerrors.Errorf("unable to create 'db_info' table: %w", err)
+	}
+	if err := sqlite.migrate(sqliteColumns); err != nil {
+		return xerrors.Errorf("unable to migrate tables: %w", err)
+	}
+	if err := backfillScalaVersions(sqlite.client, "artifacts"); err != nil {
+		return xerrors.Errorf("unable to backfill scala versions: %w", err)
+	}
+	if err := backfillNormalizedIDs(sqlite.client, "artifacts"); err != nil {
+		return xerrors.Errorf("unable to backfill normalized ids: %w", err)
+	}
+
+	if _, err := sqlite.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS artifacts_idx ON artifacts(artifact_id, group_id)"); err != nil {
 		return xerrors.Errorf("unable to create 'artifacts_idx' index: %w", err)
 	}
-	if _, err := sqlite.client.Exec("CREATE INDEX indices_artifact_idx ON indices(artifact_id)"); err != nil {
-		return xerrors.Errorf("unable to create 'indices_artifact_idx' index: %w", err)
+	// Not unique: ids differing only in case are different artifacts
+	if _, err := sqlite.client.Exec("CREATE INDEX IF NOT EXISTS artifacts_normalized_idx ON artifacts(normalized_artifact_id, normalized_group_id)"); err != nil {
+		return xerrors.Errorf("unable to create 'artifacts_normalized_idx' index: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE INDEX IF NOT EXISTS artifacts_base_idx ON artifacts(base_artifact_id, scala_version)"); err != nil {
+		return xerrors.Errorf("unable to create 'artifacts_base_idx' index: %w", err)
 	}
-	if _, err := sqlite.client.Exec("CREATE UNIQUE INDEX indices_sha1_idx ON indices(sha1)"); err != nil {
+	if _, err := sqlite.client.Exec("CREATE INDEX IF NOT EXISTS indices_artifact_version_idx ON indices(artifact_id, version, archive_type)"); err != nil {
+		return xerrors.Errorf("unable to create 'indices_artifact_version_idx' index: %w", err)
+	}
+	// indices_artifact_version_idx replaces the index on artifact_id only
+	if _, err := sqlite.client.Exec("DROP INDEX IF EXISTS indices_artifact_idx"); err != nil {
+		return xerrors.Errorf("unable to drop 'indices_artifact_idx' index: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS indices_sha1_idx ON indices(sha1)"); err != nil {
 		return xerrors.Errorf("unable to create 'indices_sha1_idx' index: %w", err)
 	}
+	if _, err := sqlite.client.Exec("CREATE INDEX IF NOT EXISTS indices_md5_idx ON indices(md5)"); err != nil {
+		return xerrors.Errorf("unable to create 'indices_md5_idx' index: %w", err)
+	}
+	// Rows without sha1 aren't deduplicated by indices_sha1_idx, so builds appending to the DB added them again.
+	if _, err := sqlite.client.Exec(`
+		DELETE FROM indices WHERE sha1 IS NULL AND rowid NOT IN (
+			SELECT MIN(rowid) FROM indices WHERE sha1 IS NULL GROUP BY artifact_id, version, md5)`); err != nil {
+		return xerrors.Errorf("unable to delete duplicated md5 indexes: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS indices_md5_gav_idx ON indices(artifact_id, version, md5) WHERE sha1 IS NULL"); err != nil {
+		return xerrors.Errorf("unable to create 'indices_md5_gav_idx' index: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS collisions_idx ON collisions(sha1, group_id, artifact_id, version)"); err != nil {
+		return xerrors.Errorf("unable to create 'collisions_idx' index: %w", err)
+	}
+	if _, err := sqlite.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS popularity_idx ON popularity(artifact_id, group_id)"); err != nil {
+		return xerrors.Errorf("unable to create 'popularity_idx' index: %w", err)
+	}
+
+	if sqlite.fts {
+		if err := sqlite.initFTS(); err != nil {
+			return xerrors.Errorf("unable to create full-text search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// sqliteColumns are the columns added after the first release.
+// Builds append to an existing DB, so Init adds them to tables created by older versions.
+var sqliteColumns = []struct{ table, column, definition string }{
+	{"artifacts", "priority", "INTEGER NOT NULL DEFAULT 0"},
+	{"indices", "md5", "BLOB"},
+	{"indices", "size", "INTEGER"},
+	{"indices", "signed", "BOOLEAN"},
+	{"indices", "signing_key", "TEXT"},
+	{"indices", "generation", "INTEGER NOT NULL DEFAULT 0"},
+	{"artifacts", "base_artifact_id", "TEXT"},
+	{"artifacts", "scala_version", "TEXT"},
+	{"indices", "classifier", "TEXT NOT NULL DEFAULT ''"},
+	{"indices", "platform", "TEXT NOT NULL DEFAULT ''"},
+	{"indices", "repository", "TEXT NOT NULL DEFAULT ''"},
+	{"artifacts", "normalized_group_id", "TEXT"},
+	{"artifacts", "normalized_artifact_id", "TEXT"},
+	{"indices", "removed_at", "TEXT"},
+	{"enrichments", "description", "TEXT"},
+	{"enrichments", "project_url", "TEXT"},
+}
+
+// migrate adds missing `columns` to tables created by older versions.
+func (sqlite *Sqlite) migrate(columns []struct{ table, column, definition string }) error {
+	for _, c := range columns {
+		var count int
+		if err := sqlite.client.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.table, c.column).Scan(&count); err != nil {
+			return xerrors.Errorf("unable to check '%s.%s' column: %w", c.table, c.column, err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := sqlite.client.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return xerrors.Errorf("unable to add '%s.%s' column: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// initFTS creates the FTS5 table over artifact coordinates.
+// The table uses `artifacts` as external content, and the trigger keeps it in sync during the build.
+func (sqlite *Sqlite) initFTS() error {
+	if _, err := sqlite.client.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS artifacts_fts USING fts5(group_id, artifact_id, content='artifacts', content_rowid='id')"); err != nil {
+		return xerrors.Errorf("unable to create 'artifacts_fts' table: %w", err)
+	}
+	if _, err := sqlite.client.Exec(`
+		CREATE TRIGGER IF NOT EXISTS artifacts_fts_insert AFTER INSERT ON artifacts BEGIN
+			INSERT INTO artifacts_fts(rowid, group_id, artifact_id) VALUES (new.id, new.group_id, new.artifact_id);
+		END`); err != nil {
+		return xerrors.Errorf("unable to create 'artifacts_fts_insert' trigger: %w", err)
+	}
 	return nil
 }
 
@@ -53,25 +271,110 @@ func (sqlite *Sqlite) Dir() string {
 	return sqlite.dir
 }
 
+// VacuumDB reclaims free pages as configured by the vacuum mode and records the time in `db_info`.
+// The tables are analyzed afterwards for the query planner, even if vacuum is disabled.
 func (sqlite *Sqlite) VacuumDB() error {
-	if _, err := sqlite.client.Exec("VACUUM"); err != nil {
-		return xerrors.Errorf("vacuum database error: %w", err)
+	vacuumed, err := sqlite.vacuumDB()
+	if err != nil {
+		return err
+	}
+	if _, err = sqlite.client.Exec("ANALYZE"); err != nil {
+		return xerrors.Errorf("analyze database error: %w", err)
+	}
+	if !vacuumed {
+		return nil
+	}
+	// DBs built before `db_info` was added are vacuumed by compact without Init
+	if _, err = sqlite.client.Exec(sqliteDBInfoDDL); err != nil {
+		return xerrors.Errorf("unable to create 'db_info' table: %w", err)
+	}
+	if _, err = sqlite.client.Exec("INSERT INTO db_info(name, value) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET value = excluded.value",
+		dbInfoVacuumedAt, time.Now().UTC().Format(timestampFormat)); err != nil {
+		return xerrors.Errorf("unable to insert to 'db_info' table: %w", err)
 	}
 	return nil
 }
 
+// vacuumDB reclaims free pages and reports whether the vacuum mode isn't VacuumNone.
+func (sqlite *Sqlite) vacuumDB() (bool, error) {
+	switch sqlite.vacuum {
+	case types.VacuumNone:
+		return false, nil
+	case types.VacuumIncremental:
+		var autoVacuum int
+		if err := sqlite.client.QueryRow("PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+			return false, xerrors.Errorf("auto_vacuum error: %w", err)
+		}
+		// 2 is incremental. DBs created with another mode switch only with a full vacuum.
+		if autoVacuum == 2 {
+			if _, err := sqlite.client.Exec("PRAGMA incremental_vacuum"); err != nil {
+				return false, xerrors.Errorf("incremental vacuum error: %w", err)
+			}
+			return true, nil
+		}
+		log.Printf("Switching the DB to incremental vacuum with a full vacuum")
+	}
+	if _, err := sqlite.client.Exec("VACUUM"); err != nil {
+		return false, xerrors.Errorf("vacuum database error: %w", err)
+	}
+	return true, nil
+}
+
 func (sqlite *Sqlite) Close() error {
 	return sqlite.client.Close()
 }
 
+// logPlan logs the query plan of `query` when explain is enabled.
+func (sqlite *Sqlite) logPlan(query string, args ...any) {
+	if !sqlite.explain {
+		return
+	}
+	rows, err := sqlite.client.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		log.Printf("Explain error: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err = rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			log.Printf("Explain error: %s", err)
+			return
+		}
+		plan = append(plan, detail)
+	}
+	log.Printf("Query plan:\n%s", strings.Join(plan, "\n"))
+}
+
 //////////////////////////////////////
 // functions to interaction with DB //
 //////////////////////////////////////
 
+// StartBuild records a new build generation. Indexes inserted after that are tagged with it.
+// Indexes already in the DB keep the generation that introduced them.
+func (sqlite *Sqlite) StartBuild(builtAt time.Time) (int, error) {
+	res, err := sqlite.client.Exec("INSERT INTO builds(built_at) VALUES (?)", builtAt)
+	if err != nil {
+		return 0, classifyWriteError(xerrors.Errorf("unable to insert to 'builds' table: %w", err))
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, xerrors.Errorf("last insert id error: %w", err)
+	}
+	sqlite.generation = int(id)
+	return sqlite.generation, nil
+}
+
 func (sqlite *Sqlite) InsertIndexes(indexes []types.Index) error {
 	if len(indexes) == 0 {
 		return nil
 	}
+	if err := validateDigests(indexes); err != nil {
+		return err
+	}
 	tx, err := sqlite.client.Begin()
 	if err != nil {
 		return err
@@ -83,37 +386,226 @@ func (sqlite *Sqlite) InsertIndexes(indexes []types.Index) error {
 	}
 
 	for _, index := range indexes {
-		_, err = tx.Exec(`
-			INSERT INTO indices(artifact_id, version, sha1, archive_type)
+		res, err := tx.Exec(`
+			INSERT INTO indices(artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, repository, generation)
 			VALUES (
 			        (SELECT id FROM artifacts 
 			            WHERE group_id=? AND artifact_id=?), 
-			        ?, ?, ?
-			) ON CONFLICT(sha1) DO NOTHING`,
-			index.GroupID, index.ArtifactID, index.Version, index.SHA1, index.ArchiveType)
+			        ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+			) ON CONFLICT DO NOTHING`,
+			index.GroupID, index.ArtifactID, index.Version, index.SHA1, index.MD5, index.Size, index.Signed, index.SigningKey, index.ArchiveType, index.Classifier, index.Platform, index.Repository, sqlite.generation)
 		if err != nil {
-			return xerrors.Errorf("unable to insert to 'indices' table: %w", err)
+			return classifyWriteError(xerrors.Errorf("unable to insert to 'indices' table: %w", err))
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return xerrors.Errorf("rows affected error: %w", err)
+		} else if n == 0 && len(index.SHA1) != 0 { // md5-only conflicts are the same GAV
+			if err = sqlite.insertCollision(tx, index); err != nil {
+				return xerrors.Errorf("insert error: %w", err)
+			}
 		}
 	}
 
 	return tx.Commit()
 }
 
+// MarkRemovedIndexes sets `removed_at` of indexes which are missing from the crawled versions of their artifact.
+// `indexes` must hold all crawled versions of their artifacts. Only indexes built from the same repository are marked,
+// and marked indexes which are crawled again are unmarked. It returns the number of newly marked indexes.
+func (sqlite *Sqlite) MarkRemovedIndexes(removedAt time.Time, indexes []types.Index) (int, error) {
+	if len(indexes) == 0 {
+		return 0, nil
+	}
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	for _, artifact := range crawledArtifacts(indexes) {
+		rows, err := tx.Query(`
+			SELECT i.rowid, i.sha1, i.md5, i.removed_at IS NOT NULL
+			FROM indices i
+			JOIN artifacts a ON a.id = i.artifact_id
+			WHERE a.group_id = ? AND a.artifact_id = ? AND i.repository = ?`,
+			artifact.groupID, artifact.artifactID, artifact.repository)
+		if err != nil {
+			return 0, xerrors.Errorf("select indexes error: %w", err)
+		}
+		var stored []storedIndex
+		for rows.Next() {
+			var s storedIndex
+			if err = rows.Scan(&s.rowID, &s.sha1, &s.md5, &s.removed); err != nil {
+				rows.Close()
+				return 0, xerrors.Errorf("scan error: %w", err)
+			}
+			stored = append(stored, s)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return 0, xerrors.Errorf("rows error: %w", err)
+		}
+
+		removed, restored := artifact.diff(stored)
+		for _, s := range removed {
+			if _, err = tx.Exec("UPDATE indices SET removed_at = ? WHERE rowid = ?", removedAt.UTC().Format(timestampFormat), s.rowID); err != nil {
+				return 0, xerrors.Errorf("unable to mark removed index: %w", err)
+			}
+		}
+		for _, s := range restored {
+			if _, err = tx.Exec("UPDATE indices SET removed_at = NULL WHERE rowid = ?", s.rowID); err != nil {
+				return 0, xerrors.Errorf("unable to unmark removed index: %w", err)
+			}
+		}
+		count += len(removed)
+	}
+	return count, tx.Commit()
+}
+
+// CompactArtifacts merges artifacts whose ids aren't canonical into the artifact with canonical ids.
+// Their indexes are moved to the kept artifact unless it already has the same index. Artifacts without a canonical
+// counterpart are renamed. It returns the number of compacted artifacts.
+func (sqlite *Sqlite) CompactArtifacts() (int, error) {
+	artifacts, err := selectNonCanonicalArtifacts(sqlite.client, "SELECT group_id, artifact_id FROM artifacts")
+	if err != nil {
+		return 0, err
+	}
+	if len(artifacts) == 0 {
+		return 0, nil
+	}
+
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, a := range artifacts {
+		groupID, artifactID := CanonicalID(a[0]), CanonicalID(a[1])
+		var id int64
+		if err = tx.QueryRow("SELECT id FROM artifacts WHERE group_id = ? AND artifact_id = ?", a[0], a[1]).Scan(&id); err != nil {
+			return 0, xerrors.Errorf("select artifact error: %w", err)
+		}
+		if sqlite.fts {
+			if _, err = tx.Exec("INSERT INTO artifacts_fts(artifacts_fts, rowid, group_id, artifact_id) VALUES ('delete', ?, ?, ?)", id, a[0], a[1]); err != nil {
+				return 0, xerrors.Errorf("unable to delete from 'artifacts_fts' table: %w", err)
+			}
+		}
+
+		var keptID int64
+		err = tx.QueryRow("SELECT id FROM artifacts WHERE group_id = ? AND artifact_id = ?", groupID, artifactID).Scan(&keptID)
+		if errors.Is(err, sql.ErrNoRows) {
+			base, scalaVersion := splitScalaVersion(artifactID)
+			if _, err = tx.Exec(`
+				UPDATE artifacts SET group_id = ?, artifact_id = ?, normalized_group_id = ?, normalized_artifact_id = ?, base_artifact_id = ?, scala_version = ?
+				WHERE id = ?`,
+				groupID, artifactID, normalizeID(groupID), normalizeID(artifactID), base, scalaVersion, id); err != nil {
+				return 0, xerrors.Errorf("unable to rename artifact: %w", err)
+			}
+			if sqlite.fts {
+				if _, err = tx.Exec("INSERT INTO artifacts_fts(rowid, group_id, artifact_id) VALUES (?, ?, ?)", id, groupID, artifactID); err != nil {
+					return 0, xerrors.Errorf("unable to insert to 'artifacts_fts' table: %w", err)
+				}
+			}
+			continue
+		} else if err != nil {
+			return 0, xerrors.Errorf("select artifact error: %w", err)
+		}
+
+		// Indexes left behind conflict with indexes of the kept artifact
+		if _, err = tx.Exec("UPDATE OR IGNORE indices SET artifact_id = ? WHERE artifact_id = ?", keptID, id); err != nil {
+			return 0, xerrors.Errorf("unable to move indexes: %w", err)
+		}
+		if _, err = tx.Exec("DELETE FROM indices WHERE artifact_id = ?", id); err != nil {
+			return 0, xerrors.Errorf("unable to delete duplicate indexes: %w", err)
+		}
+		if _, err = tx.Exec("UPDATE artifacts SET priority = MAX(priority, (SELECT priority FROM artifacts WHERE id = ?)) WHERE id = ?", id, keptID); err != nil {
+			return 0, xerrors.Errorf("unable to update priority: %w", err)
+		}
+		if _, err = tx.Exec("DELETE FROM artifacts WHERE id = ?", id); err != nil {
+			return 0, xerrors.Errorf("unable to delete artifact: %w", err)
+		}
+	}
+	return len(artifacts), tx.Commit()
+}
+
 func (sqlite *Sqlite) insertArtifacts(tx *sql.Tx, indexes []types.Index) error {
-	query := `INSERT OR IGNORE INTO artifacts(group_id, artifact_id) VALUES `
-	query += strings.Repeat("(?, ?), ", len(indexes))
+	query := `INSERT OR IGNORE INTO artifacts(group_id, artifact_id, normalized_group_id, normalized_artifact_id, base_artifact_id, scala_version) VALUES `
+	query += strings.Repeat("(?, ?, ?, ?, ?, ?), ", len(indexes))
 	query = strings.TrimSuffix(query, ", ")
 
 	var values []any
 	for _, index := range indexes {
-		values = append(values, index.GroupID, index.ArtifactID)
+		base, scalaVersion := splitScalaVersion(index.ArtifactID)
+		values = append(values, index.GroupID, index.ArtifactID, normalizeID(index.GroupID), normalizeID(index.ArtifactID), base, scalaVersion)
 	}
 	if _, err := tx.Exec(query, values...); err != nil {
-		return xerrors.Errorf("unable to insert to 'artifacts' table: %w", err)
+		return classifyWriteError(xerrors.Errorf("unable to insert to 'artifacts' table: %w", err))
+	}
+	return nil
+}
+
+// insertCollision records `index` whose sha1 is already stored under another GAV.
+func (sqlite *Sqlite) insertCollision(tx *sql.Tx, index types.Index) error {
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO collisions(sha1, group_id, artifact_id, version)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM indices i
+		                  JOIN artifacts a ON a.id = i.artifact_id
+		                  WHERE i.sha1 = ? AND a.group_id = ? AND a.artifact_id = ? AND i.version = ?)`,
+		index.SHA1, index.GroupID, index.ArtifactID, index.Version,
+		index.SHA1, index.GroupID, index.ArtifactID, index.Version)
+	if err != nil {
+		return xerrors.Errorf("unable to insert to 'collisions' table: %w", err)
 	}
 	return nil
 }
 
+func (sqlite *Sqlite) UpdateArtifactPriorities(priorities []types.ArtifactPriority) error {
+	if len(priorities) == 0 {
+		return nil
+	}
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range priorities {
+		_, err = tx.Exec(`
+			UPDATE artifacts SET priority = ?
+			WHERE group_id = ? AND (? = '' OR artifact_id = ?)`,
+			p.Priority, p.GroupID, p.ArtifactID, p.ArtifactID)
+		if err != nil {
+			return xerrors.Errorf("unable to update priority of %s:%s: %w", p.GroupID, p.ArtifactID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (sqlite *Sqlite) InsertPopularity(popularity []types.Popularity) error {
+	if len(popularity) == 0 {
+		return nil
+	}
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range popularity {
+		_, err = tx.Exec(`
+			INSERT INTO popularity(group_id, artifact_id, downloads) VALUES (?, ?, ?)
+			ON CONFLICT(artifact_id, group_id) DO UPDATE SET downloads = excluded.downloads`,
+			p.GroupID, p.ArtifactID, p.Downloads)
+		if err != nil {
+			return xerrors.Errorf("unable to insert to 'popularity' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (sqlite *Sqlite) SelectIndexBySha1(sha1 string) (types.Index, error) {
 	var index types.Index
 	sha1b, err := hex.DecodeString(sha1)
@@ -121,53 +613,649 @@ func (sqlite *Sqlite) SelectIndexBySha1(sha1 string) (types.Index, error) {
 		return index, xerrors.Errorf("sha1 decode error: %w", err)
 	}
 	row := sqlite.client.QueryRow(`
-		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.archive_type 
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation 
 		FROM indices i
 		JOIN artifacts a ON a.id = i.artifact_id
-        WHERE i.sha1 = ?`,
-		sha1b)
-	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+        WHERE i.sha1 = ? AND (? = 0 OR i.generation <= ?)`,
+		sha1b, sqlite.asOf, sqlite.asOf)
+	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
 		return index, xerrors.Errorf("select index error: %w", err)
 	}
 	return index, nil
 }
 
+// SelectIndexBySha1OrMd5 looks up an index by a sha1 or md5 digest, depending on the digest length.
+// Md5 matches are reported with types.MD5Match since they are weaker than sha1 ones.
+func (sqlite *Sqlite) SelectIndexBySha1OrMd5(digest string) (types.Index, types.MatchType, error) {
+	return selectIndexBySha1OrMd5(sqlite, digest)
+}
+
+// SelectIndexByDigest looks up an index by a digest of any algorithm stored in the DB.
+func (sqlite *Sqlite) SelectIndexByDigest(digest types.Digest) (types.Index, error) {
+	var index types.Index
+	var removedAt sql.NullString
+	column, err := digestColumn(digest.Algorithm)
+	if err != nil {
+		return index, err
+	}
+
+	query := `
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation, i.removed_at
+		FROM indices i
+		JOIN artifacts a ON a.id = i.artifact_id
+		WHERE i.` + column + ` = ? AND (? = 0 OR i.generation <= ?)`
+	sqlite.logPlan(query, digest.Value, sqlite.asOf, sqlite.asOf)
+	row := sqlite.client.QueryRow(query, digest.Value, sqlite.asOf, sqlite.asOf)
+	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation, &removedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
+		return index, xerrors.Errorf("select index error: %w", err)
+	}
+	if index.RemovedAt, err = parseTimestamp(removedAt); err != nil {
+		return index, err
+	}
+	return index, nil
+}
+
 func (sqlite *Sqlite) SelectIndexByArtifactIDAndGroupID(artifactID, groupID string) (types.Index, error) {
 	var index types.Index
-	row := sqlite.client.QueryRow(`
-		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.archive_type
+	groupID, artifactID = sqlite.lookup.values(groupID, artifactID)
+	row := sqlite.client.QueryRow(fmt.Sprintf(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
 		FROM indices i 
 		JOIN artifacts a ON a.id = i.artifact_id
-        WHERE a.group_id = ? AND a.artifact_id = ?`,
-		groupID, artifactID)
-	err := row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+        WHERE a.%s = ? AND a.%s = ? AND (? = 0 OR i.generation <= ?)`, sqlite.lookup.groupColumn, sqlite.lookup.artifactColumn),
+		groupID, artifactID, sqlite.asOf, sqlite.asOf)
+	err := row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
 		return index, xerrors.Errorf("select index error: %w", err)
 	}
 	return index, nil
 }
 
-// SelectIndexesByArtifactIDAndFileType returns all indexes for `artifactID` + `fileType` if `version` exists for them
+// SelectVersionsByArtifactIDAndGroupID returns indexes of all versions of the artifact in Maven version order, the oldest first.
+// Versions published with several archive types or classifiers have one index for each of them.
+func (sqlite *Sqlite) SelectVersionsByArtifactIDAndGroupID(artifactID, groupID string) ([]types.Index, error) {
+	groupID, artifactID = sqlite.lookup.values(groupID, artifactID)
+	rows, err := sqlite.client.Query(fmt.Sprintf(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
+		FROM indices i
+		JOIN artifacts a ON a.id = i.artifact_id
+		WHERE a.%s = ? AND a.%s = ? AND (? = 0 OR i.generation <= ?)
+		ORDER BY i.version, i.classifier`, sqlite.lookup.groupColumn, sqlite.lookup.artifactColumn),
+		groupID, artifactID, sqlite.asOf, sqlite.asOf)
+	if err != nil {
+		return nil, xerrors.Errorf("select versions error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	sortByVersion(indexes)
+	return indexes, nil
+}
+
+// SelectIndexesByGAVs returns indexes of `gavs` with chunked queries, so that large SBOMs are resolved in a few round trips.
+// GAVs without indexes are skipped. Indexes are sorted by GAV.
+func (sqlite *Sqlite) SelectIndexesByGAVs(gavs []types.GAV) ([]types.Index, error) {
+	var indexes []types.Index
+	placeholders, args := gavChunks(gavs, sqlite.lookup)
+	for i := range placeholders {
+		rows, err := sqlite.client.Query(fmt.Sprintf(`
+			SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
+			FROM indices i
+			JOIN artifacts a ON a.id = i.artifact_id
+			WHERE (a.%s, a.%s, i.version) IN (VALUES %s) AND (? = 0 OR i.generation <= ?)`, sqlite.lookup.groupColumn, sqlite.lookup.artifactColumn, placeholders[i]),
+			append(args[i], sqlite.asOf, sqlite.asOf)...)
+		if err != nil {
+			return nil, xerrors.Errorf("select indexes by GAVs error: %w", err)
+		}
+		for rows.Next() {
+			var index types.Index
+			if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+				rows.Close()
+				return nil, xerrors.Errorf("scan row error: %w", err)
+			}
+			indexes = append(indexes, index)
+		}
+		rows.Close()
+	}
+	sortByGAV(indexes)
+	return indexes, nil
+}
+
+// SelectIndexesByArtifactIDAndFileType returns all indexes for `artifactID` + `fileType` if `version` exists for them.
+// Canonical artifacts (with the highest priority) are listed first, then the most downloaded ones.
 func (sqlite *Sqlite) SelectIndexesByArtifactIDAndFileType(artifactID, version string, fileType types.ArchiveType) ([]types.Index, error) {
+	var indexes []types.Index
+	query := `
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation, COALESCE(p.downloads, 0)
+		FROM artifacts a
+		JOIN indices i ON i.artifact_id = a.id
+		LEFT JOIN popularity p ON p.group_id = a.group_id AND p.artifact_id = a.artifact_id
+		WHERE a.artifact_id = ? AND (? = 0 OR i.generation <= ?)
+		  AND EXISTS (SELECT 1 FROM indices v WHERE v.artifact_id = a.id AND v.version = ? AND v.archive_type = ?
+		                                        AND (? = 0 OR v.generation <= ?))
+		ORDER BY a.priority DESC, COALESCE(p.downloads, 0) DESC, a.group_id, i.version`
+	args := []any{artifactID, sqlite.asOf, sqlite.asOf, version, fileType, sqlite.asOf, sqlite.asOf}
+	sqlite.logPlan(query, args...)
+	rows, err := sqlite.client.Query(query, args...)
+	if err != nil {
+		return nil, xerrors.Errorf("select indexes error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation, &index.Downloads); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// SelectUnsignedArtifacts returns indexes without a detached PGP signature.
+// All groups are checked when `groupID` is empty.
+func (sqlite *Sqlite) SelectUnsignedArtifacts(groupID string) ([]types.Index, error) {
 	var indexes []types.Index
 	rows, err := sqlite.client.Query(`
-		SELECT f_id.group_id, f_id.artifact_id, i.version, i.sha1, i.archive_type
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation
 		FROM indices i
-		JOIN (SELECT a.id, a.group_id, a.artifact_id
-      	      FROM indices i
-        	  JOIN artifacts a on a.id = i.artifact_id
-      	      WHERE a.artifact_id = ? AND i.version = ? AND i.archive_type = ?) f_id ON f_id.id = i.artifact_id`,
-		artifactID, version, fileType)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return nil, xerrors.Errorf("select indexes error: %w", err)
+		JOIN artifacts a ON a.id = i.artifact_id
+		WHERE NOT i.signed AND (? = '' OR a.group_id = ?) AND (? = 0 OR i.generation <= ?)
+		ORDER BY a.group_id, a.artifact_id, i.version`,
+		groupID, groupID, sqlite.asOf, sqlite.asOf)
+	if err != nil {
+		return nil, xerrors.Errorf("select unsigned indexes error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// SelectScalaArtifacts returns artifacts cross-built from `baseArtifactID`, including `baseArtifactID` itself.
+// All groups are checked when `groupID` is empty, and all Scala versions when `scalaVersion` is empty.
+func (sqlite *Sqlite) SelectScalaArtifacts(groupID, baseArtifactID, scalaVersion string) ([]types.Artifact, error) {
+	var artifacts []types.Artifact
+	query := `
+		SELECT a.group_id, a.artifact_id, a.scala_version, COALESCE(p.downloads, 0)
+		FROM artifacts a
+		LEFT JOIN popularity p ON p.group_id = a.group_id AND p.artifact_id = a.artifact_id
+		WHERE a.base_artifact_id = ? AND (? = '' OR a.group_id = ?) AND (? = '' OR a.scala_version = ?)
+		ORDER BY a.group_id, a.scala_version`
+	args := []any{baseArtifactID, groupID, groupID, scalaVersion, scalaVersion}
+	sqlite.logPlan(query, args...)
+	rows, err := sqlite.client.Query(query, args...)
+	if err != nil {
+		return nil, xerrors.Errorf("select scala artifacts error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID, &artifact.ScalaVersion, &artifact.Downloads); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// SelectArtifactsInNamespace returns artifacts of the `namespace` group and its subgroups, e.g. `com.example.internal` for `com.example`.
+// Subgroups are matched by the range [`namespace.`, `namespace/`), since '/' follows '.' in ASCII.
+func (sqlite *Sqlite) SelectArtifactsInNamespace(namespace string) ([]types.Artifact, error) {
+	var artifacts []types.Artifact
+	rows, err := sqlite.client.Query(`
+		SELECT DISTINCT a.group_id, a.artifact_id
+		FROM artifacts a
+		WHERE a.group_id = ? OR (a.group_id >= ? AND a.group_id < ?)
+		ORDER BY a.group_id, a.artifact_id`,
+		namespace, namespace+".", namespace+"/")
+	if err != nil {
+		return nil, xerrors.Errorf("select artifacts in namespace error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// SearchArtifactsFTS returns artifacts matching the FTS5 `query`, the best matches first.
+// Equally good matches are ordered by downloads.
+// e.g. `log4j`, `apache AND logging`, `spring*`
+// The DB must be built with the full-text search index.
+func (sqlite *Sqlite) SearchArtifactsFTS(query string) ([]types.Artifact, error) {
+	var artifacts []types.Artifact
+	rows, err := sqlite.client.Query(`
+		SELECT f.group_id, f.artifact_id, COALESCE(p.downloads, 0), COALESCE(e.description, ''), COALESCE(e.project_url, '')
+		FROM artifacts_fts f
+		LEFT JOIN popularity p ON p.group_id = f.group_id AND p.artifact_id = f.artifact_id
+		LEFT JOIN enrichments e ON e.group_id = f.group_id AND e.artifact_id = f.artifact_id
+		WHERE artifacts_fts MATCH ?
+		ORDER BY f.rank, COALESCE(p.downloads, 0) DESC`,
+		query)
+	if err != nil {
+		return nil, xerrors.Errorf("full-text search error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID, &artifact.Downloads, &artifact.Description, &artifact.ProjectURL); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// SelectCollisions returns sha1s shared by several GAVs.
+// The GAV stored in the DB is listed first in each collision.
+func (sqlite *Sqlite) SelectCollisions() ([]types.Collision, error) {
+	rows, err := sqlite.client.Query(`
+		SELECT sha1, group_id, artifact_id, version FROM (
+			SELECT i.sha1, a.group_id, a.artifact_id, i.version, 1 AS stored
+			FROM indices i
+			JOIN artifacts a ON a.id = i.artifact_id
+			WHERE i.sha1 IN (SELECT sha1 FROM collisions)
+			UNION ALL
+			SELECT sha1, group_id, artifact_id, version, 0 AS stored
+			FROM collisions
+		) ORDER BY sha1, stored DESC, group_id, artifact_id, version`)
+	if err != nil {
+		return nil, xerrors.Errorf("select collisions error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.SHA1, &index.GroupID, &index.ArtifactID, &index.Version); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return groupCollisions(indexes), nil
+}
+
+// SelectConflictingGAVs returns GAVs stored with several sha1s, e.g. when the same GAV is published
+// in Maven Central and an internal repository with different content.
+func (sqlite *Sqlite) SelectConflictingGAVs() ([]types.Conflict, error) {
+	rows, err := sqlite.client.Query(`
+		SELECT a.group_id, a.artifact_id, i.version, i.archive_type, i.sha1, i.repository
+		FROM indices i
+		JOIN artifacts a ON a.id = i.artifact_id
+		WHERE i.sha1 IS NOT NULL AND (i.artifact_id, i.version, i.archive_type) IN (
+			SELECT artifact_id, version, archive_type FROM indices
+			WHERE sha1 IS NOT NULL
+			GROUP BY artifact_id, version, archive_type
+			HAVING COUNT(*) > 1)
+		ORDER BY a.group_id, a.artifact_id, i.version, i.archive_type, i.repository`)
+	if err != nil {
+		return nil, xerrors.Errorf("select conflicting GAVs error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.ArchiveType, &index.SHA1, &index.Repository); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return groupConflicts(indexes), nil
+}
+
+// SelectChangelog returns groups, artifacts and versions introduced after the `from` generation up to the `to` one.
+// The latest generation is used if `to` is zero.
+func (sqlite *Sqlite) SelectChangelog(from, to int) (types.Changelog, error) {
+	var changelog types.Changelog
+	rows, err := sqlite.client.Query(`
+		SELECT a.group_id
+		FROM indices i
+		JOIN artifacts a ON a.id = i.artifact_id
+		GROUP BY a.group_id
+		HAVING MIN(i.generation) > ? AND (? = 0 OR MIN(i.generation) <= ?)
+		ORDER BY a.group_id`,
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new groups error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var groupID string
+		if err = rows.Scan(&groupID); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewGroups = append(changelog.NewGroups, groupID)
+	}
+
+	rows, err = sqlite.client.Query(`
+		SELECT a.group_id, a.artifact_id
+		FROM indices i
+		JOIN artifacts a ON a.id = i.artifact_id
+		GROUP BY a.group_id, a.artifact_id
+		HAVING MIN(i.generation) > ? AND (? = 0 OR MIN(i.generation) <= ?)
+		ORDER BY a.group_id, a.artifact_id`,
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new artifacts error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewArtifacts = append(changelog.NewArtifacts, artifact)
+	}
+
+	rows, err = sqlite.client.Query(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.archive_type, i.generation
+		FROM indices i
+		JOIN artifacts a ON a.id = i.artifact_id
+		WHERE i.generation > ? AND (? = 0 OR i.generation <= ?)
+		ORDER BY a.group_id, a.artifact_id, i.version`,
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new versions error: %w", err)
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var index types.Index
-		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType); err != nil {
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType, &index.Generation); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewVersions = append(changelog.NewVersions, index)
+	}
+	return changelog, nil
+}
+
+// UpdateDailyStats adds artifacts and indexes of the current build to the stats of its day, for each of `repositories`.
+// Repositories without new indexes are recorded with zero counts.
+func (sqlite *Sqlite) UpdateDailyStats(builtAt time.Time, repositories []string) error {
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, repository := range repositories {
+		var newArtifacts, newIndexes int
+		if err = tx.QueryRow("SELECT COUNT(*) FROM indices WHERE generation = ? AND repository = ?",
+			sqlite.generation, repository).Scan(&newIndexes); err != nil {
+			return xerrors.Errorf("count new indexes error: %w", err)
+		}
+		// artifacts with indexes of previous builds aren't new
+		if err = tx.QueryRow(`
+			SELECT COUNT(DISTINCT i.artifact_id)
+			FROM indices i
+			WHERE i.generation = ? AND i.repository = ?
+			  AND NOT EXISTS (SELECT 1 FROM indices o WHERE o.artifact_id = i.artifact_id AND o.generation < ?)`,
+			sqlite.generation, repository, sqlite.generation).Scan(&newArtifacts); err != nil {
+			return xerrors.Errorf("count new artifacts error: %w", err)
+		}
+		if _, err = tx.Exec(`
+			INSERT INTO daily_stats(day, repository, new_artifacts, new_indexes) VALUES (?, ?, ?, ?)
+			ON CONFLICT(day, repository) DO UPDATE SET new_artifacts = new_artifacts + excluded.new_artifacts,
+			                                           new_indexes = new_indexes + excluded.new_indexes`,
+			builtAt.UTC().Format(dayFormat), repository, newArtifacts, newIndexes); err != nil {
+			return xerrors.Errorf("unable to insert to 'daily_stats' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectDailyStats returns the stats of all days, the oldest first.
+func (sqlite *Sqlite) SelectDailyStats() ([]types.DailyStats, error) {
+	rows, err := sqlite.client.Query("SELECT day, repository, new_artifacts, new_indexes FROM daily_stats ORDER BY day, repository")
+	if err != nil {
+		return nil, xerrors.Errorf("select daily stats error: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []types.DailyStats
+	for rows.Next() {
+		var s types.DailyStats
+		if err = rows.Scan(&s.Day, &s.Repository, &s.NewArtifacts, &s.NewIndexes); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// ReplaceQuarantine replaces quarantined indexes with the ones of the current build.
+func (sqlite *Sqlite) ReplaceQuarantine(indexes []types.QuarantinedIndex) error {
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM quarantine"); err != nil {
+		return xerrors.Errorf("unable to clear 'quarantine' table: %w", err)
+	}
+	for _, index := range indexes {
+		if _, err = tx.Exec("INSERT INTO quarantine(group_id, artifact_id, version, sha1, md5, repository, reason) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			index.GroupID, index.ArtifactID, index.Version, index.SHA1, index.MD5, index.Repository, index.Reason); err != nil {
+			return xerrors.Errorf("unable to insert to 'quarantine' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectQuarantine returns quarantined indexes of the last build.
+func (sqlite *Sqlite) SelectQuarantine() ([]types.QuarantinedIndex, error) {
+	rows, err := sqlite.client.Query("SELECT group_id, artifact_id, version, sha1, md5, repository, reason FROM quarantine ORDER BY group_id, artifact_id, version")
+	if err != nil {
+		return nil, xerrors.Errorf("select quarantine error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.QuarantinedIndex
+	for rows.Next() {
+		var index types.QuarantinedIndex
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Repository, &index.Reason); err != nil {
 			return nil, xerrors.Errorf("scan row error: %w", err)
 		}
 		indexes = append(indexes, index)
 	}
 	return indexes, nil
 }
+
+func (sqlite *Sqlite) ScanIndexes(fn func(indexes []types.Index) error) error {
+	rows, err := sqlite.client.Query(`
+		SELECT a.group_id, a.artifact_id, i.version, i.sha1, i.md5, i.size, i.signed, i.signing_key, i.archive_type, i.classifier, i.platform, a.priority, i.generation, i.repository
+		FROM indices i
+		JOIN artifacts a ON a.id = i.artifact_id
+		WHERE ? = 0 OR i.generation <= ?
+		ORDER BY a.id`, sqlite.asOf, sqlite.asOf)
+	if err != nil {
+		return xerrors.Errorf("select indexes error: %w", err)
+	}
+	return scanArtifactIndexes(rows, fn)
+}
+
+// ReplaceDeprecations replaces the deprecated artifacts with the ones of the feed. Later entries override earlier ones.
+func (sqlite *Sqlite) ReplaceDeprecations(deprecations []types.Deprecation) error {
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM deprecations"); err != nil {
+		return xerrors.Errorf("unable to clear 'deprecations' table: %w", err)
+	}
+	for _, d := range deprecations {
+		if _, err = tx.Exec("INSERT OR REPLACE INTO deprecations(group_id, artifact_id, replacement, reason) VALUES (?, ?, ?, ?)",
+			d.GroupID, d.ArtifactID, d.Replacement, d.Reason); err != nil {
+			return xerrors.Errorf("unable to insert to 'deprecations' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SelectDeprecation returns the deprecation of the artifact, or of its whole group.
+// It returns ErrNotFound if the artifact isn't deprecated.
+func (sqlite *Sqlite) SelectDeprecation(groupID, artifactID string) (types.Deprecation, error) {
+	var d types.Deprecation
+	err := sqlite.client.QueryRow(`
+		SELECT group_id, artifact_id, replacement, reason FROM deprecations
+		WHERE group_id = ? AND artifact_id IN (?, '')
+		ORDER BY artifact_id DESC LIMIT 1`, groupID, artifactID).
+		Scan(&d.GroupID, &d.ArtifactID, &d.Replacement, &d.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, ErrNotFound
+	} else if err != nil {
+		return d, xerrors.Errorf("select deprecation error: %w", err)
+	}
+	return d, nil
+}
+
+// InsertLabels attaches the labels to their artifacts. Labels which are already attached are skipped.
+func (sqlite *Sqlite) InsertLabels(labels []types.Label) error {
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, l := range labels {
+		if _, err = tx.Exec("INSERT OR IGNORE INTO labels(group_id, artifact_id, label) VALUES (?, ?, ?)",
+			l.GroupID, l.ArtifactID, l.Label); err != nil {
+			return xerrors.Errorf("unable to insert to 'labels' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteLabels detaches the labels from their artifacts and returns the number of detached labels.
+func (sqlite *Sqlite) DeleteLabels(labels []types.Label) (int, error) {
+	tx, err := sqlite.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var deleted int
+	for _, l := range labels {
+		res, err := tx.Exec("DELETE FROM labels WHERE group_id = ? AND artifact_id = ? AND label = ?", l.GroupID, l.ArtifactID, l.Label)
+		if err != nil {
+			return 0, xerrors.Errorf("unable to delete from 'labels' table: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += int(n)
+	}
+	return deleted, tx.Commit()
+}
+
+// SelectLabels returns the labels of the artifact in alphabetical order.
+func (sqlite *Sqlite) SelectLabels(groupID, artifactID string) ([]string, error) {
+	rows, err := sqlite.client.Query("SELECT group_id, artifact_id, label FROM labels WHERE group_id = ? AND artifact_id = ? ORDER BY label", groupID, artifactID)
+	if err != nil {
+		return nil, xerrors.Errorf("select labels error: %w", err)
+	}
+	labels, err := scanLabels(rows)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(labels, func(l types.Label, _ int) string { return l.Label }), nil
+}
+
+// SelectLabeledArtifacts returns the artifacts with the label, or all labels of all artifacts if `label` is empty.
+func (sqlite *Sqlite) SelectLabeledArtifacts(label string) ([]types.Label, error) {
+	rows, err := sqlite.client.Query(`
+		SELECT group_id, artifact_id, label FROM labels
+		WHERE ? = '' OR label = ?
+		ORDER BY group_id, artifact_id, label`, label, label)
+	if err != nil {
+		return nil, xerrors.Errorf("select labels error: %w", err)
+	}
+	return scanLabels(rows)
+}
+
+// SelectArtifactsToEnrich returns up to `limit` artifacts without enrichment, so interrupted runs resume where they stopped.
+// All of them are returned if `limit` is 0.
+func (sqlite *Sqlite) SelectArtifactsToEnrich(limit int) ([]types.Artifact, error) {
+	return selectArtifactsToEnrich(sqlite.client, `
+		SELECT a.group_id, a.artifact_id FROM artifacts a
+		LEFT JOIN enrichments e ON e.group_id = a.group_id AND e.artifact_id = a.artifact_id
+		WHERE e.group_id IS NULL
+		ORDER BY a.group_id, a.artifact_id`, limit)
+}
+
+// InsertEnrichment inserts or replaces the enrichment of an artifact.
+func (sqlite *Sqlite) InsertEnrichment(e types.Enrichment) error {
+	if _, err := sqlite.client.Exec("INSERT OR REPLACE INTO enrichments(group_id, artifact_id, default_version, homepage, description, project_url, fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		e.GroupID, e.ArtifactID, e.DefaultVersion, e.Homepage, e.Description, e.ProjectURL, e.FetchedAt.UTC().Format(timestampFormat)); err != nil {
+		return xerrors.Errorf("unable to insert to 'enrichments' table: %w", err)
+	}
+	return nil
+}
+
+// SelectEnrichment returns the enrichment of an artifact, or ErrNotFound if it wasn't enriched yet.
+func (sqlite *Sqlite) SelectEnrichment(groupID, artifactID string) (types.Enrichment, error) {
+	return selectEnrichment(sqlite.client.QueryRow(
+		"SELECT group_id, artifact_id, default_version, homepage, COALESCE(description, ''), COALESCE(project_url, ''), fetched_at FROM enrichments WHERE group_id = ? AND artifact_id = ?",
+		groupID, artifactID))
+}
+
+// UpdateDBInfo replaces the schema version, the build time and the row counts in `db_info`.
+// The vacuum time is kept, since it's recorded by VacuumDB.
+func (sqlite *Sqlite) UpdateDBInfo(schemaVersion int, builtAt time.Time) error {
+	return updateSqliteDBInfo(sqlite.client, sqliteTables, schemaVersion, builtAt)
+}
+
+// SelectDBInfo returns the info written by the last build, or ErrNotFound if no build wrote it.
+func (sqlite *Sqlite) SelectDBInfo() (types.DBInfo, error) {
+	return selectDBInfo(sqlite.client, "SELECT name, value FROM db_info")
+}
+
+func updateSqliteDBInfo(client *sql.DB, tables []string, schemaVersion int, builtAt time.Time) error {
+	tx, err := client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	values, err := dbInfoValues(tx, tables, func(table string) string { return table }, schemaVersion, builtAt)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM db_info WHERE name <> ?", dbInfoVacuumedAt); err != nil {
+		return xerrors.Errorf("unable to clear 'db_info' table: %w", err)
+	}
+	for name, value := range values {
+		if _, err = tx.Exec("INSERT INTO db_info(name, value) VALUES (?, ?)", name, value); err != nil {
+			return xerrors.Errorf("unable to insert to 'db_info' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,677 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+// SqliteFlat stores GAV and digests in the single denormalized `gavs` table without the `artifacts.id` indirection.
+// It trades DB size for simpler bulk loading. The full-text search index is not supported.
+type SqliteFlat struct {
+	*Sqlite
+}
+
+func NewSqliteFlat(conf *types.SqliteDBConfig) (*SqliteFlat, error) {
+	if conf.FTS {
+		return nil, xerrors.New("full-text search is not supported by the flat schema")
+	}
+	sqlite, err := NewSqlite(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &SqliteFlat{Sqlite: sqlite}, nil
+}
+
+// sqliteFlatColumns are the columns added after the flat schema was introduced.
+var sqliteFlatColumns = []struct{ table, column, definition string }{
+	{"gavs", "base_artifact_id", "TEXT"},
+	{"gavs", "scala_version", "TEXT"},
+	{"gavs", "classifier", "TEXT NOT NULL DEFAULT ''"},
+	{"gavs", "platform", "TEXT NOT NULL DEFAULT ''"},
+	{"gavs", "repository", "TEXT NOT NULL DEFAULT ''"},
+	{"gavs", "normalized_group_id", "TEXT"},
+	{"gavs", "normalized_artifact_id", "TEXT"},
+	{"gavs", "removed_at", "TEXT"},
+	{"enrichments", "description", "TEXT"},
+	{"enrichments", "project_url", "TEXT"},
+}
+
+// sqliteFlatTables are the tables counted in `db_info`.
+var sqliteFlatTables = []string{"gavs", "builds", "collisions", "popularity", "daily_stats", "quarantine", "deprecations", "enrichments", "labels"}
+
+func (flat *SqliteFlat) Init() error {
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS gavs(group_id TEXT, artifact_id TEXT, normalized_group_id TEXT, normalized_artifact_id TEXT, base_artifact_id TEXT, scala_version TEXT, version TEXT, sha1 BLOB CHECK (length(sha1) = 20), md5 BLOB CHECK (length(md5) = 16), size INTEGER, signed BOOLEAN, signing_key TEXT, archive_type TEXT, classifier TEXT NOT NULL DEFAULT '', platform TEXT NOT NULL DEFAULT '', repository TEXT NOT NULL DEFAULT '', priority INTEGER NOT NULL DEFAULT 0, generation INTEGER NOT NULL DEFAULT 0, removed_at TEXT)"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS builds(generation INTEGER PRIMARY KEY, built_at TIMESTAMP)"); err != nil {
+		return xerrors.Errorf("unable to create 'builds' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS collisions(sha1 BLOB, group_id TEXT, artifact_id TEXT, version TEXT)"); err != nil {
+		return xerrors.Errorf("unable to create 'collisions' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS popularity(group_id TEXT, artifact_id TEXT, downloads INTEGER NOT NULL DEFAULT 0)"); err != nil {
+		return xerrors.Errorf("unable to create 'popularity' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS daily_stats(day TEXT, repository TEXT, new_artifacts INTEGER NOT NULL DEFAULT 0, new_indexes INTEGER NOT NULL DEFAULT 0, PRIMARY KEY (day, repository))"); err != nil {
+		return xerrors.Errorf("unable to create 'daily_stats' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS quarantine(group_id TEXT, artifact_id TEXT, version TEXT, sha1 BLOB, md5 BLOB, repository TEXT NOT NULL DEFAULT '', reason TEXT)"); err != nil {
+		return xerrors.Errorf("unable to create 'quarantine' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS deprecations(group_id TEXT, artifact_id TEXT NOT NULL DEFAULT '', replacement TEXT, reason TEXT, PRIMARY KEY (group_id, artifact_id))"); err != nil {
+		return xerrors.Errorf("unable to create 'deprecations' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS enrichments(group_id TEXT, artifact_id TEXT, default_version TEXT, homepage TEXT, description TEXT, project_url TEXT, fetched_at TEXT, PRIMARY KEY (group_id, artifact_id))"); err != nil {
+		return xerrors.Errorf("unable to create 'enrichments' table: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE TABLE IF NOT EXISTS labels(group_id TEXT, artifact_id TEXT, label TEXT, PRIMARY KEY (group_id, artifact_id, label))"); err != nil {
+		return xerrors.Errorf("unable to create 'labels' table: %w", err)
+	}
+	if _, err := flat.client.Exec(sqliteDBInfoDDL); err != nil {
+		return xerrors.Errorf("unable to create 'db_info' table: %w", err)
+	}
+	if err := flat.migrate(sqliteFlatColumns); err != nil {
+		return xerrors.Errorf("unable to migrate tables: %w", err)
+	}
+	if err := backfillScalaVersions(flat.client, "gavs"); err != nil {
+		return xerrors.Errorf("unable to backfill scala versions: %w", err)
+	}
+	if err := backfillNormalizedIDs(flat.client, "gavs"); err != nil {
+		return xerrors.Errorf("unable to backfill normalized ids: %w", err)
+	}
+
+	if _, err := flat.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS gavs_sha1_idx ON gavs(sha1)"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs_sha1_idx' index: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE INDEX IF NOT EXISTS gavs_md5_idx ON gavs(md5)"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs_md5_idx' index: %w", err)
+	}
+	// Rows without sha1 aren't deduplicated by gavs_sha1_idx
+	if _, err := flat.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS gavs_md5_gav_idx ON gavs(group_id, artifact_id, version, md5) WHERE sha1 IS NULL"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs_md5_gav_idx' index: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE INDEX IF NOT EXISTS gavs_artifact_idx ON gavs(artifact_id, group_id)"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs_artifact_idx' index: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE INDEX IF NOT EXISTS gavs_normalized_idx ON gavs(normalized_artifact_id, normalized_group_id)"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs_normalized_idx' index: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE INDEX IF NOT EXISTS gavs_version_idx ON gavs(artifact_id, version, archive_type, group_id)"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs_version_idx' index: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE INDEX IF NOT EXISTS gavs_base_idx ON gavs(base_artifact_id, scala_version)"); err != nil {
+		return xerrors.Errorf("unable to create 'gavs_base_idx' index: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS collisions_idx ON collisions(sha1, group_id, artifact_id, version)"); err != nil {
+		return xerrors.Errorf("unable to create 'collisions_idx' index: %w", err)
+	}
+	if _, err := flat.client.Exec("CREATE UNIQUE INDEX IF NOT EXISTS popularity_idx ON popularity(artifact_id, group_id)"); err != nil {
+		return xerrors.Errorf("unable to create 'popularity_idx' index: %w", err)
+	}
+	return nil
+}
+
+func (flat *SqliteFlat) InsertIndexes(indexes []types.Index) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	if err := validateDigests(indexes); err != nil {
+		return err
+	}
+	tx, err := flat.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, index := range indexes {
+		base, scalaVersion := splitScalaVersion(index.ArtifactID)
+		res, err := tx.Exec(`
+			INSERT INTO gavs(group_id, artifact_id, normalized_group_id, normalized_artifact_id, base_artifact_id, scala_version, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, repository, generation)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING`,
+			index.GroupID, index.ArtifactID, normalizeID(index.GroupID), normalizeID(index.ArtifactID), base, scalaVersion, index.Version, index.SHA1, index.MD5, index.Size, index.Signed, index.SigningKey, index.ArchiveType, index.Classifier, index.Platform, index.Repository, flat.generation)
+		if err != nil {
+			return classifyWriteError(xerrors.Errorf("unable to insert to 'gavs' table: %w", err))
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return xerrors.Errorf("rows affected error: %w", err)
+		} else if n == 0 && len(index.SHA1) != 0 { // md5-only conflicts are the same GAV
+			if err = flat.insertCollision(tx, index); err != nil {
+				return xerrors.Errorf("insert error: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertCollision records `index` whose sha1 is already stored under another GAV.
+func (flat *SqliteFlat) insertCollision(tx *sql.Tx, index types.Index) error {
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO collisions(sha1, group_id, artifact_id, version)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM gavs
+		                  WHERE sha1 = ? AND group_id = ? AND artifact_id = ? AND version = ?)`,
+		index.SHA1, index.GroupID, index.ArtifactID, index.Version,
+		index.SHA1, index.GroupID, index.ArtifactID, index.Version)
+	if err != nil {
+		return xerrors.Errorf("unable to insert to 'collisions' table: %w", err)
+	}
+	return nil
+}
+
+// CompactArtifacts merges rows whose ids aren't canonical into the artifact with canonical ids, dropping rows which
+// conflict with its rows. It returns the number of compacted artifacts.
+func (flat *SqliteFlat) CompactArtifacts() (int, error) {
+	artifacts, err := selectNonCanonicalArtifacts(flat.client, "SELECT DISTINCT group_id, artifact_id FROM gavs")
+	if err != nil {
+		return 0, err
+	}
+	if len(artifacts) == 0 {
+		return 0, nil
+	}
+
+	tx, err := flat.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, a := range artifacts {
+		groupID, artifactID := CanonicalID(a[0]), CanonicalID(a[1])
+		base, scalaVersion := splitScalaVersion(artifactID)
+		if _, err = tx.Exec(`
+			UPDATE OR IGNORE gavs SET group_id = ?, artifact_id = ?, normalized_group_id = ?, normalized_artifact_id = ?, base_artifact_id = ?, scala_version = ?
+			WHERE group_id = ? AND artifact_id = ?`,
+			groupID, artifactID, normalizeID(groupID), normalizeID(artifactID), base, scalaVersion, a[0], a[1]); err != nil {
+			return 0, xerrors.Errorf("unable to rename artifact: %w", err)
+		}
+		if _, err = tx.Exec("DELETE FROM gavs WHERE group_id = ? AND artifact_id = ?", a[0], a[1]); err != nil {
+			return 0, xerrors.Errorf("unable to delete duplicate indexes: %w", err)
+		}
+	}
+	return len(artifacts), tx.Commit()
+}
+
+func (flat *SqliteFlat) UpdateArtifactPriorities(priorities []types.ArtifactPriority) error {
+	if len(priorities) == 0 {
+		return nil
+	}
+	tx, err := flat.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range priorities {
+		_, err = tx.Exec(`
+			UPDATE gavs SET priority = ?
+			WHERE group_id = ? AND (? = '' OR artifact_id = ?)`,
+			p.Priority, p.GroupID, p.ArtifactID, p.ArtifactID)
+		if err != nil {
+			return xerrors.Errorf("unable to update priority of %s:%s: %w", p.GroupID, p.ArtifactID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (flat *SqliteFlat) SelectIndexBySha1(sha1 string) (types.Index, error) {
+	var index types.Index
+	sha1b, err := hex.DecodeString(sha1)
+	if err != nil {
+		return index, xerrors.Errorf("sha1 decode error: %w", err)
+	}
+	row := flat.client.QueryRow(`
+		SELECT group_id, artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, priority, generation
+		FROM gavs
+		WHERE sha1 = ? AND (? = 0 OR generation <= ?)`,
+		sha1b, flat.asOf, flat.asOf)
+	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
+		return index, xerrors.Errorf("select index error: %w", err)
+	}
+	return index, nil
+}
+
+// MarkRemovedIndexes sets `removed_at` of indexes which are missing from the crawled versions of their artifact.
+func (flat *SqliteFlat) MarkRemovedIndexes(removedAt time.Time, indexes []types.Index) (int, error) {
+	if len(indexes) == 0 {
+		return 0, nil
+	}
+	tx, err := flat.client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	for _, artifact := range crawledArtifacts(indexes) {
+		rows, err := tx.Query(`
+			SELECT rowid, sha1, md5, removed_at IS NOT NULL
+			FROM gavs
+			WHERE group_id = ? AND artifact_id = ? AND repository = ?`,
+			artifact.groupID, artifact.artifactID, artifact.repository)
+		if err != nil {
+			return 0, xerrors.Errorf("select indexes error: %w", err)
+		}
+		var stored []storedIndex
+		for rows.Next() {
+			var s storedIndex
+			if err = rows.Scan(&s.rowID, &s.sha1, &s.md5, &s.removed); err != nil {
+				rows.Close()
+				return 0, xerrors.Errorf("scan error: %w", err)
+			}
+			stored = append(stored, s)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return 0, xerrors.Errorf("rows error: %w", err)
+		}
+
+		removed, restored := artifact.diff(stored)
+		for _, s := range removed {
+			if _, err = tx.Exec("UPDATE gavs SET removed_at = ? WHERE rowid = ?", removedAt.UTC().Format(timestampFormat), s.rowID); err != nil {
+				return 0, xerrors.Errorf("unable to mark removed index: %w", err)
+			}
+		}
+		for _, s := range restored {
+			if _, err = tx.Exec("UPDATE gavs SET removed_at = NULL WHERE rowid = ?", s.rowID); err != nil {
+				return 0, xerrors.Errorf("unable to unmark removed index: %w", err)
+			}
+		}
+		count += len(removed)
+	}
+	return count, tx.Commit()
+}
+
+// SelectArtifactsToEnrich returns up to `limit` artifacts without enrichment. All of them are returned if `limit` is 0.
+func (flat *SqliteFlat) SelectArtifactsToEnrich(limit int) ([]types.Artifact, error) {
+	return selectArtifactsToEnrich(flat.client, `
+		SELECT DISTINCT g.group_id, g.artifact_id FROM gavs g
+		LEFT JOIN enrichments e ON e.group_id = g.group_id AND e.artifact_id = g.artifact_id
+		WHERE e.group_id IS NULL
+		ORDER BY g.group_id, g.artifact_id`, limit)
+}
+
+// SelectIndexBySha1OrMd5 looks up an index by a sha1 or md5 digest, depending on the digest length.
+func (flat *SqliteFlat) SelectIndexBySha1OrMd5(digest string) (types.Index, types.MatchType, error) {
+	return selectIndexBySha1OrMd5(flat, digest)
+}
+
+// SelectIndexByDigest looks up an index by a digest of any algorithm stored in the DB.
+func (flat *SqliteFlat) SelectIndexByDigest(digest types.Digest) (types.Index, error) {
+	var index types.Index
+	var removedAt sql.NullString
+	column, err := digestColumn(digest.Algorithm)
+	if err != nil {
+		return index, err
+	}
+
+	query := `
+		SELECT group_id, artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, priority, generation, removed_at
+		FROM gavs
+		WHERE ` + column + ` = ? AND (? = 0 OR generation <= ?)`
+	flat.logPlan(query, digest.Value, flat.asOf, flat.asOf)
+	row := flat.client.QueryRow(query, digest.Value, flat.asOf, flat.asOf)
+	err = row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation, &removedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
+		return index, xerrors.Errorf("select index error: %w", err)
+	}
+	if index.RemovedAt, err = parseTimestamp(removedAt); err != nil {
+		return index, err
+	}
+	return index, nil
+}
+
+func (flat *SqliteFlat) SelectIndexByArtifactIDAndGroupID(artifactID, groupID string) (types.Index, error) {
+	var index types.Index
+	groupID, artifactID = flat.lookup.values(groupID, artifactID)
+	row := flat.client.QueryRow(fmt.Sprintf(`
+		SELECT group_id, artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, priority, generation
+		FROM gavs
+		WHERE %s = ? AND %s = ? AND (? = 0 OR generation <= ?)`, flat.lookup.groupColumn, flat.lookup.artifactColumn),
+		groupID, artifactID, flat.asOf, flat.asOf)
+	err := row.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation)
+	if errors.Is(err, sql.ErrNoRows) {
+		return index, ErrNotFound
+	} else if err != nil {
+		return index, xerrors.Errorf("select index error: %w", err)
+	}
+	return index, nil
+}
+
+// SelectVersionsByArtifactIDAndGroupID returns indexes of all versions of the artifact in Maven version order, the oldest first.
+// Versions published with several archive types or classifiers have one index for each of them.
+func (flat *SqliteFlat) SelectVersionsByArtifactIDAndGroupID(artifactID, groupID string) ([]types.Index, error) {
+	groupID, artifactID = flat.lookup.values(groupID, artifactID)
+	rows, err := flat.client.Query(fmt.Sprintf(`
+		SELECT group_id, artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, priority, generation
+		FROM gavs
+		WHERE %s = ? AND %s = ? AND (? = 0 OR generation <= ?)
+		ORDER BY version, classifier`, flat.lookup.groupColumn, flat.lookup.artifactColumn),
+		groupID, artifactID, flat.asOf, flat.asOf)
+	if err != nil {
+		return nil, xerrors.Errorf("select versions error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	sortByVersion(indexes)
+	return indexes, nil
+}
+
+// SelectIndexesByGAVs returns indexes of `gavs` with chunked queries, so that large SBOMs are resolved in a few round trips.
+// GAVs without indexes are skipped. Indexes are sorted by GAV.
+func (flat *SqliteFlat) SelectIndexesByGAVs(gavs []types.GAV) ([]types.Index, error) {
+	var indexes []types.Index
+	placeholders, args := gavChunks(gavs, flat.lookup)
+	for i := range placeholders {
+		rows, err := flat.client.Query(fmt.Sprintf(`
+			SELECT group_id, artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, priority, generation
+			FROM gavs
+			WHERE (%s, %s, version) IN (VALUES %s) AND (? = 0 OR generation <= ?)`, flat.lookup.groupColumn, flat.lookup.artifactColumn, placeholders[i]),
+			append(args[i], flat.asOf, flat.asOf)...)
+		if err != nil {
+			return nil, xerrors.Errorf("select indexes by GAVs error: %w", err)
+		}
+		for rows.Next() {
+			var index types.Index
+			if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+				rows.Close()
+				return nil, xerrors.Errorf("scan row error: %w", err)
+			}
+			indexes = append(indexes, index)
+		}
+		rows.Close()
+	}
+	sortByGAV(indexes)
+	return indexes, nil
+}
+
+// SelectIndexesByArtifactIDAndFileType returns all indexes for `artifactID` + `fileType` if `version` exists for them.
+// Canonical artifacts (with the highest priority) are listed first, then the most downloaded ones.
+func (flat *SqliteFlat) SelectIndexesByArtifactIDAndFileType(artifactID, version string, fileType types.ArchiveType) ([]types.Index, error) {
+	var indexes []types.Index
+	query := `
+		SELECT g.group_id, g.artifact_id, g.version, g.sha1, g.md5, g.size, g.signed, g.signing_key, g.archive_type, g.classifier, g.platform, g.priority, g.generation, COALESCE(p.downloads, 0)
+		FROM gavs g
+		LEFT JOIN popularity p ON p.group_id = g.group_id AND p.artifact_id = g.artifact_id
+		WHERE g.artifact_id = ? AND (? = 0 OR g.generation <= ?)
+		  AND g.group_id IN (SELECT group_id FROM gavs
+		                     WHERE artifact_id = ? AND version = ? AND archive_type = ? AND (? = 0 OR generation <= ?))
+		ORDER BY g.priority DESC, COALESCE(p.downloads, 0) DESC, g.group_id, g.version`
+	args := []any{artifactID, flat.asOf, flat.asOf, artifactID, version, fileType, flat.asOf, flat.asOf}
+	flat.logPlan(query, args...)
+	rows, err := flat.client.Query(query, args...)
+	if err != nil {
+		return nil, xerrors.Errorf("select indexes error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation, &index.Downloads); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// SelectUnsignedArtifacts returns indexes without a detached PGP signature.
+// All groups are checked when `groupID` is empty.
+func (flat *SqliteFlat) SelectUnsignedArtifacts(groupID string) ([]types.Index, error) {
+	var indexes []types.Index
+	rows, err := flat.client.Query(`
+		SELECT group_id, artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, priority, generation
+		FROM gavs
+		WHERE NOT signed AND (? = '' OR group_id = ?) AND (? = 0 OR generation <= ?)
+		ORDER BY group_id, artifact_id, version`,
+		groupID, groupID, flat.asOf, flat.asOf)
+	if err != nil {
+		return nil, xerrors.Errorf("select unsigned indexes error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.MD5, &index.Size, &index.Signed, &index.SigningKey, &index.ArchiveType, &index.Classifier, &index.Platform, &index.Priority, &index.Generation); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func (flat *SqliteFlat) SelectScalaArtifacts(groupID, baseArtifactID, scalaVersion string) ([]types.Artifact, error) {
+	var artifacts []types.Artifact
+	query := `
+		SELECT DISTINCT g.group_id, g.artifact_id, g.scala_version, COALESCE(p.downloads, 0)
+		FROM gavs g
+		LEFT JOIN popularity p ON p.group_id = g.group_id AND p.artifact_id = g.artifact_id
+		WHERE g.base_artifact_id = ? AND (? = '' OR g.group_id = ?) AND (? = '' OR g.scala_version = ?)
+		ORDER BY g.group_id, g.scala_version`
+	args := []any{baseArtifactID, groupID, groupID, scalaVersion, scalaVersion}
+	flat.logPlan(query, args...)
+	rows, err := flat.client.Query(query, args...)
+	if err != nil {
+		return nil, xerrors.Errorf("select scala artifacts error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID, &artifact.ScalaVersion, &artifact.Downloads); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+func (flat *SqliteFlat) SelectArtifactsInNamespace(namespace string) ([]types.Artifact, error) {
+	var artifacts []types.Artifact
+	rows, err := flat.client.Query(`
+		SELECT DISTINCT group_id, artifact_id
+		FROM gavs
+		WHERE group_id = ? OR (group_id >= ? AND group_id < ?)
+		ORDER BY group_id, artifact_id`,
+		namespace, namespace+".", namespace+"/")
+	if err != nil {
+		return nil, xerrors.Errorf("select artifacts in namespace error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+func (flat *SqliteFlat) SearchArtifactsFTS(_ string) ([]types.Artifact, error) {
+	return nil, xerrors.New("full-text search is not supported by the flat schema")
+}
+
+func (flat *SqliteFlat) ScanIndexes(fn func(indexes []types.Index) error) error {
+	rows, err := flat.client.Query(`
+		SELECT group_id, artifact_id, version, sha1, md5, size, signed, signing_key, archive_type, classifier, platform, priority, generation, repository
+		FROM gavs
+		WHERE ? = 0 OR generation <= ?
+		ORDER BY group_id, artifact_id`, flat.asOf, flat.asOf)
+	if err != nil {
+		return xerrors.Errorf("select indexes error: %w", err)
+	}
+	return scanArtifactIndexes(rows, fn)
+}
+
+// SelectCollisions returns sha1s shared by several GAVs.
+// The GAV stored in the DB is listed first in each collision.
+func (flat *SqliteFlat) SelectCollisions() ([]types.Collision, error) {
+	rows, err := flat.client.Query(`
+		SELECT sha1, group_id, artifact_id, version FROM (
+			SELECT sha1, group_id, artifact_id, version, 1 AS stored
+			FROM gavs
+			WHERE sha1 IN (SELECT sha1 FROM collisions)
+			UNION ALL
+			SELECT sha1, group_id, artifact_id, version, 0 AS stored
+			FROM collisions
+		) ORDER BY sha1, stored DESC, group_id, artifact_id, version`)
+	if err != nil {
+		return nil, xerrors.Errorf("select collisions error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.SHA1, &index.GroupID, &index.ArtifactID, &index.Version); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return groupCollisions(indexes), nil
+}
+
+// SelectChangelog returns groups, artifacts and versions introduced after the `from` generation up to the `to` one.
+// The latest generation is used if `to` is zero.
+func (flat *SqliteFlat) SelectConflictingGAVs() ([]types.Conflict, error) {
+	rows, err := flat.client.Query(`
+		SELECT group_id, artifact_id, version, archive_type, sha1, repository
+		FROM gavs
+		WHERE sha1 IS NOT NULL AND (group_id, artifact_id, version, archive_type) IN (
+			SELECT group_id, artifact_id, version, archive_type FROM gavs
+			WHERE sha1 IS NOT NULL
+			GROUP BY group_id, artifact_id, version, archive_type
+			HAVING COUNT(*) > 1)
+		ORDER BY group_id, artifact_id, version, archive_type, repository`)
+	if err != nil {
+		return nil, xerrors.Errorf("select conflicting GAVs error: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []types.Index
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.ArchiveType, &index.SHA1, &index.Repository); err != nil {
+			return nil, xerrors.Errorf("scan row error: %w", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return groupConflicts(indexes), nil
+}
+
+func (flat *SqliteFlat) SelectChangelog(from, to int) (types.Changelog, error) {
+	var changelog types.Changelog
+	rows, err := flat.client.Query(`
+		SELECT group_id
+		FROM gavs
+		GROUP BY group_id
+		HAVING MIN(generation) > ? AND (? = 0 OR MIN(generation) <= ?)
+		ORDER BY group_id`,
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new groups error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var groupID string
+		if err = rows.Scan(&groupID); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewGroups = append(changelog.NewGroups, groupID)
+	}
+
+	rows, err = flat.client.Query(`
+		SELECT group_id, artifact_id
+		FROM gavs
+		GROUP BY group_id, artifact_id
+		HAVING MIN(generation) > ? AND (? = 0 OR MIN(generation) <= ?)
+		ORDER BY group_id, artifact_id`,
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new artifacts error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var artifact types.Artifact
+		if err = rows.Scan(&artifact.GroupID, &artifact.ArtifactID); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewArtifacts = append(changelog.NewArtifacts, artifact)
+	}
+
+	rows, err = flat.client.Query(`
+		SELECT group_id, artifact_id, version, sha1, archive_type, generation
+		FROM gavs
+		WHERE generation > ? AND (? = 0 OR generation <= ?)
+		ORDER BY group_id, artifact_id, version`,
+		from, to, to)
+	if err != nil {
+		return changelog, xerrors.Errorf("select new versions error: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index types.Index
+		if err = rows.Scan(&index.GroupID, &index.ArtifactID, &index.Version, &index.SHA1, &index.ArchiveType, &index.Generation); err != nil {
+			return changelog, xerrors.Errorf("scan row error: %w", err)
+		}
+		changelog.NewVersions = append(changelog.NewVersions, index)
+	}
+	return changelog, nil
+}
+
+func (flat *SqliteFlat) UpdateDailyStats(builtAt time.Time, repositories []string) error {
+	tx, err := flat.client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, repository := range repositories {
+		var newArtifacts, newIndexes int
+		if err = tx.QueryRow("SELECT COUNT(*) FROM gavs WHERE generation = ? AND repository = ?",
+			flat.generation, repository).Scan(&newIndexes); err != nil {
+			return xerrors.Errorf("count new indexes error: %w", err)
+		}
+		if err = tx.QueryRow(`
+			SELECT COUNT(*) FROM (
+				SELECT DISTINCT g.group_id, g.artifact_id
+				FROM gavs g
+				WHERE g.generation = ? AND g.repository = ?
+				  AND NOT EXISTS (SELECT 1 FROM gavs o WHERE o.group_id = g.group_id AND o.artifact_id = g.artifact_id AND o.generation < ?))`,
+			flat.generation, repository, flat.generation).Scan(&newArtifacts); err != nil {
+			return xerrors.Errorf("count new artifacts error: %w", err)
+		}
+		if _, err = tx.Exec(`
+			INSERT INTO daily_stats(day, repository, new_artifacts, new_indexes) VALUES (?, ?, ?, ?)
+			ON CONFLICT(day, repository) DO UPDATE SET new_artifacts = new_artifacts + excluded.new_artifacts,
+			                                           new_indexes = new_indexes + excluded.new_indexes`,
+			builtAt.UTC().Format(dayFormat), repository, newArtifacts, newIndexes); err != nil {
+			return xerrors.Errorf("unable to insert to 'daily_stats' table: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (flat *SqliteFlat) UpdateDBInfo(schemaVersion int, builtAt time.Time) error {
+	return updateSqliteDBInfo(flat.client, sqliteFlatTables, schemaVersion, builtAt)
+}
@@ -2,6 +2,7 @@ package dbtest
 
 import (
 	"github.com/h7hac9/trivy-java-db/pkg/types"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -10,9 +11,17 @@ import (
 )
 
 func InitDB(t *testing.T, indexes []types.Index) (db.DB, error) {
+	return InitDBWithConfig(t, types.SqliteDBConfig{}, indexes)
+}
+
+// InitDBWithConfig creates a sqlite DB in a temp dir using `conf`. DBPath is overwritten.
+func InitDBWithConfig(t *testing.T, conf types.SqliteDBConfig, indexes []types.Index) (db.DB, error) {
 	tmpDir := t.TempDir()
-	dbc, err := db.New(tmpDir)
+	conf.DBPath = filepath.Join(tmpDir, "trivy-java.db")
+	dbc, err := db.New(tmpDir, &types.DBConfig{SqliteDBConfig: &conf})
 	require.NoError(t, err)
+	// Windows can't remove the temp dir while the DB file is open
+	t.Cleanup(func() { _ = dbc.Close() })
 
 	err = dbc.Init()
 	require.NoError(t, err)
@@ -0,0 +1,220 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// partSuffix is appended to the destination while the download is incomplete.
+	partSuffix = ".part"
+	// etagSuffix is appended to a file to store the ETag it was downloaded with.
+	etagSuffix = ".etag"
+
+	defaultRetries = 5
+)
+
+// Result describes a download.
+type Result struct {
+	Path string
+	// Bytes is the number of bytes transferred by this download.
+	Bytes int64
+	// Resumed is set when an earlier partial download was continued.
+	Resumed bool
+	// NotModified is set when the file at Path already has the current ETag.
+	NotModified bool
+	ETag        string `json:",omitempty"`
+}
+
+type Option struct {
+	// Retries is the max number of times a broken transfer is resumed. Defaults to 5.
+	Retries int
+	// Backoff is the wait before the first retry, doubled for each following one. Defaults to 1 second.
+	Backoff time.Duration
+}
+
+type Downloader struct {
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+func NewDownloader(opt Option) Downloader {
+	if opt.Retries == 0 {
+		opt.Retries = defaultRetries
+	}
+	if opt.Backoff == 0 {
+		opt.Backoff = time.Second
+	}
+	return Downloader{
+		http:    &http.Client{},
+		retries: opt.Retries,
+		backoff: opt.Backoff,
+	}
+}
+
+// Download downloads `url` to `dst`.
+// The file is skipped with If-None-Match when `dst` was downloaded with the current ETag. Transfers are written to
+// `dst`.part, and broken or interrupted transfers are resumed with a Range request, as long as the ETag didn't change.
+func (d Downloader) Download(ctx context.Context, url, dst string) (Result, error) {
+	result := Result{Path: dst}
+	backoff := d.backoff
+	for attempt := 0; ; attempt++ {
+		done, err := d.get(ctx, url, dst, &result)
+		if err == nil && done {
+			return result, nil
+		}
+		var transferErr *transferError
+		if !errors.As(err, &transferErr) || attempt >= d.retries {
+			return result, err
+		}
+		log.Printf("Download of %s broke, resuming in %s: %s", url, backoff, err)
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// transferError is a network error which the next attempt may resume from.
+type transferError struct {
+	err error
+}
+
+func (e *transferError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transferError) Unwrap() error {
+	return e.err
+}
+
+// get makes one attempt at downloading `url`. It returns true when `dst` is complete.
+func (d Downloader) get(ctx context.Context, url, dst string, result *Result) (bool, error) {
+	part := dst + partSuffix
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, xerrors.Errorf("unable to new HTTP request: %w", err)
+	}
+
+	var offset int64
+	if info, err := os.Stat(part); err == nil && info.Size() > 0 {
+		// Without the ETag of the partial file, a changed file could be appended to it
+		if etag := readETag(part); etag != "" {
+			offset = info.Size()
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			req.Header.Set("If-Range", etag)
+		}
+	} else if _, err := os.Stat(dst); err == nil {
+		if etag := readETag(dst); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return false, &transferError{err: xerrors.Errorf("http get error (%s): %w", url, err)}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var f *os.File
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		result.NotModified = true
+		result.ETag = readETag(dst)
+		return true, nil
+	case http.StatusPartialContent:
+		if offset == 0 {
+			return false, xerrors.Errorf("unexpected partial content (%s)", url)
+		}
+		if f, err = os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return false, xerrors.Errorf("unable to open %s: %w", part, err)
+		}
+		result.Resumed = true
+	case http.StatusOK:
+		// The server ignored the range or the file changed since the partial download
+		if f, err = os.Create(part); err != nil {
+			return false, xerrors.Errorf("unable to create %s: %w", part, err)
+		}
+		if err = writeETag(part, resp.Header.Get("ETag")); err != nil {
+			_ = f.Close()
+			return false, err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is longer than the current file, so start over
+		if err = removeWithETag(part); err != nil {
+			return false, err
+		}
+		return false, &transferError{err: xerrors.Errorf("range not satisfiable (%s)", url)}
+	default:
+		return false, xerrors.Errorf("unexpected status code (%s): %d", url, resp.StatusCode)
+	}
+
+	n, err := io.Copy(f, resp.Body)
+	result.Bytes += n
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		return false, xerrors.Errorf("failed to save %s: %w", part, closeErr)
+	}
+	if err != nil {
+		return false, &transferError{err: xerrors.Errorf("can't read %s: %w", url, err)}
+	}
+
+	etag := readETag(part)
+	if err = os.Rename(part, dst); err != nil {
+		return false, xerrors.Errorf("failed to rename a file: %w", err)
+	}
+	if etag == "" {
+		// Drop the ETag of the replaced file
+		if err = removeFile(dst + etagSuffix); err != nil {
+			return false, err
+		}
+	} else if err = os.Rename(part+etagSuffix, dst+etagSuffix); err != nil {
+		return false, xerrors.Errorf("failed to rename a file: %w", err)
+	}
+	result.ETag = etag
+	return true, nil
+}
+
+func readETag(path string) string {
+	b, err := os.ReadFile(path + etagSuffix)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// writeETag stores `etag` next to `path`. Weak ETags aren't stored, since If-Range requires strong ones.
+func writeETag(path, etag string) error {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return removeFile(path + etagSuffix)
+	}
+	if err := os.WriteFile(path+etagSuffix, []byte(etag), 0644); err != nil {
+		return xerrors.Errorf("unable to write the ETag: %w", err)
+	}
+	return nil
+}
+
+func removeWithETag(path string) error {
+	if err := removeFile(path); err != nil {
+		return err
+	}
+	return removeFile(path + etagSuffix)
+}
+
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return xerrors.Errorf("unable to remove %s: %w", path, err)
+	}
+	return nil
+}
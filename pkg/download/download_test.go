@@ -0,0 +1,97 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/download"
+)
+
+// flakyServer serves `content` with `etag`, breaking the connection halfway through the first `breaks` transfers.
+func flakyServer(t *testing.T, content []byte, etag *string, breaks int) (*httptest.Server, *[]string) {
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", *etag)
+		if breaks > 0 && r.Header.Get("If-None-Match") == "" {
+			breaks--
+			w.Header().Set("Content-Length", "100")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(content[:50])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "trivy-java.db", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &ranges
+}
+
+func TestDownload(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	etag := `"v1"`
+	ts, ranges := flakyServer(t, content, &etag, 1)
+	dst := filepath.Join(t.TempDir(), "trivy-java.db")
+	d := download.NewDownloader(download.Option{Backoff: time.Millisecond})
+
+	// The broken transfer is resumed from the 50th byte
+	got, err := d.Download(context.Background(), ts.URL, dst)
+	require.NoError(t, err)
+	assert.Equal(t, download.Result{Path: dst, Bytes: 100, Resumed: true, ETag: `"v1"`}, got)
+	assert.Equal(t, []string{"", "bytes=50-"}, *ranges)
+	b, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, content, b)
+	assert.NoFileExists(t, dst+".part")
+
+	// Unchanged
+	got, err = d.Download(context.Background(), ts.URL, dst)
+	require.NoError(t, err)
+	assert.Equal(t, download.Result{Path: dst, NotModified: true, ETag: `"v1"`}, got)
+
+	// Changed
+	etag = `"v2"`
+	got, err = d.Download(context.Background(), ts.URL, dst)
+	require.NoError(t, err)
+	assert.Equal(t, download.Result{Path: dst, Bytes: 100, ETag: `"v2"`}, got)
+}
+
+func TestDownloadChangedDuringResume(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	etag := `"v2"`
+	ts, ranges := flakyServer(t, content, &etag, 0)
+	dst := filepath.Join(t.TempDir(), "trivy-java.db")
+	require.NoError(t, os.WriteFile(dst+".part", []byte("stale"), 0644))
+	require.NoError(t, os.WriteFile(dst+".part.etag", []byte(`"v1"`), 0644))
+
+	// If-Range makes the server send the whole new file
+	got, err := download.NewDownloader(download.Option{}).Download(context.Background(), ts.URL, dst)
+	require.NoError(t, err)
+	assert.Equal(t, download.Result{Path: dst, Bytes: 100, ETag: `"v2"`}, got)
+	assert.Equal(t, []string{"bytes=5-"}, *ranges)
+	b, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, content, b)
+}
+
+func TestDownloadRetriesExhausted(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	etag := `"v1"`
+	ts, _ := flakyServer(t, content, &etag, 3)
+	dst := filepath.Join(t.TempDir(), "trivy-java.db")
+
+	_, err := download.NewDownloader(download.Option{Retries: 1, Backoff: time.Millisecond}).Download(context.Background(), ts.URL, dst)
+	require.Error(t, err)
+	assert.NoFileExists(t, dst)
+	assert.FileExists(t, dst+".part")
+}
@@ -0,0 +1,233 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/xerrors"
+	"k8s.io/utils/clock"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+const (
+	depsDevURL  = "https://api.deps.dev"
+	defaultRate = 10
+)
+
+type Option struct {
+	// URL is the root of the deps.dev API. Defaults to https://api.deps.dev.
+	URL string
+	// Rate is the max number of requests per second. Defaults to 10.
+	Rate int
+	// Limit is the max number of artifacts enriched by one run. All artifacts are enriched if 0.
+	Limit int
+	// POMURL is the root of the Maven repository the POMs of default versions are fetched from, to store their
+	// description and project url. POMs aren't fetched if empty.
+	POMURL string
+}
+
+// Enricher fetches the default version and homepage of artifacts from deps.dev and stores them in the DB.
+// With POMURL, the description and project url of the default version are fetched from its POM as well.
+// Every artifact is stored as soon as it is fetched, and artifacts already stored are skipped,
+// so an interrupted run resumes where it stopped.
+type Enricher struct {
+	db    db.DB
+	http  *retryablehttp.Client
+	clock clock.Clock
+
+	url      string
+	pomURL   string
+	interval time.Duration
+	limit    int
+
+	stats Stats
+}
+
+// Stats is a summary of the enrichment.
+type Stats struct {
+	Artifacts int
+	// NotFound is the number of artifacts unknown to deps.dev.
+	NotFound int
+}
+
+func NewEnricher(dbc db.DB, opt Option) Enricher {
+	client := retryablehttp.NewClient()
+	client.RetryMax = 5
+	client.Logger = nil
+
+	if opt.URL == "" {
+		opt.URL = depsDevURL
+	}
+	if opt.Rate <= 0 {
+		opt.Rate = defaultRate
+	}
+	return Enricher{
+		db:    dbc,
+		http:  client,
+		clock: clock.RealClock{},
+
+		url:      strings.TrimSuffix(opt.URL, "/"),
+		pomURL:   strings.TrimSuffix(opt.POMURL, "/"),
+		interval: time.Second / time.Duration(opt.Rate),
+		limit:    opt.Limit,
+	}
+}
+
+func (e *Enricher) Enrich(ctx context.Context) error {
+	artifacts, err := e.db.SelectArtifactsToEnrich(e.limit)
+	if err != nil {
+		return xerrors.Errorf("failed to select artifacts: %w", err)
+	}
+	log.Printf("Artifacts to enrich: %d", len(artifacts))
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	wait := func() error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			return nil
+		}
+	}
+
+	for _, artifact := range artifacts {
+		enrichment, err := e.fetch(ctx, wait, artifact)
+		if err != nil {
+			return xerrors.Errorf("deps.dev error (%s:%s): %w", artifact.GroupID, artifact.ArtifactID, err)
+		}
+		if err = e.db.InsertEnrichment(enrichment); err != nil {
+			return xerrors.Errorf("failed to insert enrichment: %w", err)
+		}
+		e.stats.Artifacts++
+		if enrichment.DefaultVersion == "" {
+			e.stats.NotFound++
+		}
+	}
+	return nil
+}
+
+func (e *Enricher) Stats() Stats {
+	return e.stats
+}
+
+type packageResponse struct {
+	Versions []struct {
+		VersionKey struct {
+			Version string `json:"version"`
+		} `json:"versionKey"`
+		IsDefault bool `json:"isDefault"`
+	} `json:"versions"`
+}
+
+type versionResponse struct {
+	Links []struct {
+		Label string `json:"label"`
+		URL   string `json:"url"`
+	} `json:"links"`
+}
+
+// pom holds the fields of POM files stored in the enrichments.
+type pom struct {
+	Description string `xml:"description"`
+	URL         string `xml:"url"`
+}
+
+// fetch looks up the default version of `artifact`, and the homepage of that version.
+// Artifacts unknown to deps.dev are returned with empty fields, so that they aren't looked up again.
+func (e *Enricher) fetch(ctx context.Context, wait func() error, artifact types.Artifact) (types.Enrichment, error) {
+	enrichment := types.Enrichment{
+		GroupID:    artifact.GroupID,
+		ArtifactID: artifact.ArtifactID,
+		FetchedAt:  e.clock.Now().UTC(),
+	}
+	name := url.PathEscape(artifact.GroupID + ":" + artifact.ArtifactID)
+
+	var pkg packageResponse
+	if found, err := e.get(ctx, wait, fmt.Sprintf("%s/v3/systems/maven/packages/%s", e.url, name), jsonDecoder(&pkg)); err != nil || !found {
+		return enrichment, err
+	}
+	for _, v := range pkg.Versions {
+		if v.IsDefault {
+			enrichment.DefaultVersion = v.VersionKey.Version
+		}
+	}
+	if enrichment.DefaultVersion == "" {
+		return enrichment, nil
+	}
+
+	var ver versionResponse
+	versionURL := fmt.Sprintf("%s/v3/systems/maven/packages/%s/versions/%s", e.url, name, url.PathEscape(enrichment.DefaultVersion))
+	if _, err := e.get(ctx, wait, versionURL, jsonDecoder(&ver)); err != nil {
+		return enrichment, err
+	}
+	for _, link := range ver.Links {
+		if link.Label == "HOMEPAGE" {
+			enrichment.Homepage = link.URL
+		}
+	}
+
+	if e.pomURL == "" {
+		return enrichment, nil
+	}
+	// e.g. https://repo.maven.apache.org/maven2/jstl/jstl/1.2/jstl-1.2.pom
+	dirs := strings.Split(artifact.GroupID, ".")
+	for i, dir := range dirs {
+		dirs[i] = url.PathEscape(dir)
+	}
+	pomURL := fmt.Sprintf("%s/%s/%s/%s/%s", e.pomURL, strings.Join(dirs, "/"), url.PathEscape(artifact.ArtifactID), url.PathEscape(enrichment.DefaultVersion),
+		url.PathEscape(artifact.ArtifactID+"-"+enrichment.DefaultVersion+".pom"))
+	var p pom
+	if _, err := e.get(ctx, wait, pomURL, func(r io.Reader) error { return xml.NewDecoder(r).Decode(&p) }); err != nil {
+		return enrichment, err
+	}
+	// Descriptions are often indented over several lines
+	enrichment.Description = strings.Join(strings.Fields(p.Description), " ")
+	enrichment.ProjectURL = strings.TrimSpace(p.URL)
+	return enrichment, nil
+}
+
+func jsonDecoder(v any) func(r io.Reader) error {
+	return func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(v)
+	}
+}
+
+// get decodes the response of `url` with `decode` after waiting for the rate limit. It returns false on 404.
+func (e *Enricher) get(ctx context.Context, wait func() error, url string, decode func(r io.Reader) error) (bool, error) {
+	if err := wait(); err != nil {
+		return false, err
+	}
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, xerrors.Errorf("unable to new HTTP request: %w", err)
+	}
+	resp, err := e.http.Do(req)
+	if err != nil {
+		return false, xerrors.Errorf("http get error (%s): %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, xerrors.Errorf("unexpected status code (%s): %d", url, resp.StatusCode)
+	}
+	if err = decode(resp.Body); err != nil {
+		return false, xerrors.Errorf("decode error (%s): %w", url, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package enrich_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/db"
+	"github.com/h7hac9/trivy-java-db/pkg/dbtest"
+	"github.com/h7hac9/trivy-java-db/pkg/enrich"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestEnrich(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		switch r.URL.Path {
+		case "/v3/systems/maven/packages/jstl:jstl":
+			_, _ = w.Write([]byte(`{"versions":[
+				{"versionKey":{"version":"1.0"},"isDefault":false},
+				{"versionKey":{"version":"1.2"},"isDefault":true}]}`))
+		case "/v3/systems/maven/packages/jstl:jstl/versions/1.2":
+			_, _ = w.Write([]byte(`{"links":[
+				{"label":"SOURCE_REPO","url":"https://github.com/example/jstl"},
+				{"label":"HOMEPAGE","url":"https://jstl.example.com"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dbc, err := dbtest.InitDB(t, []types.Index{
+		{GroupID: "javax.servlet", ArtifactID: "jstl", Version: "1.0", ArchiveType: types.JarType},
+		{GroupID: "jstl", ArtifactID: "jstl", Version: "1.0", ArchiveType: types.JarType},
+		{GroupID: "jstl", ArtifactID: "jstl", Version: "1.2", ArchiveType: types.JarType},
+	})
+	require.NoError(t, err)
+
+	// The first run is interrupted after one artifact
+	e := enrich.NewEnricher(dbc, enrich.Option{URL: ts.URL, Rate: 1000, Limit: 1})
+	require.NoError(t, e.Enrich(context.Background()))
+	assert.Equal(t, enrich.Stats{Artifacts: 1, NotFound: 1}, e.Stats())
+
+	e = enrich.NewEnricher(dbc, enrich.Option{URL: ts.URL, Rate: 1000})
+	require.NoError(t, e.Enrich(context.Background()))
+	assert.Equal(t, enrich.Stats{Artifacts: 1}, e.Stats())
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+
+	got, err := dbc.SelectEnrichment("jstl", "jstl")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2", got.DefaultVersion)
+	assert.Equal(t, "https://jstl.example.com", got.Homepage)
+	assert.False(t, got.FetchedAt.IsZero())
+
+	got, err = dbc.SelectEnrichment("javax.servlet", "jstl")
+	require.NoError(t, err)
+	assert.Equal(t, "javax.servlet", got.GroupID)
+	assert.Empty(t, got.DefaultVersion)
+
+	_, err = dbc.SelectEnrichment("org.example", "unknown")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	// Everything is enriched
+	e = enrich.NewEnricher(dbc, enrich.Option{URL: ts.URL, Rate: 1000})
+	require.NoError(t, e.Enrich(context.Background()))
+	assert.Equal(t, enrich.Stats{}, e.Stats())
+	assert.Equal(t, int64(3), atomic.LoadInt64(&requests))
+}
+
+func TestEnrichPOM(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/systems/maven/packages/javax.servlet:jstl":
+			_, _ = w.Write([]byte(`{"versions":[{"versionKey":{"version":"1.2"},"isDefault":true}]}`))
+		case "/v3/systems/maven/packages/javax.servlet:jstl/versions/1.2":
+			_, _ = w.Write([]byte(`{"links":[]}`))
+		case "/maven2/javax/servlet/jstl/1.2/jstl-1.2.pom":
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <groupId>javax.servlet</groupId>
+  <artifactId>jstl</artifactId>
+  <version>1.2</version>
+  <description>
+    JavaServer Pages
+    Standard Tag Library
+  </description>
+  <url>https://jstl.example.com</url>
+  <scm>
+    <url>https://github.com/example/jstl</url>
+  </scm>
+</project>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	dbc, err := dbtest.InitDB(t, []types.Index{
+		{GroupID: "javax.servlet", ArtifactID: "jstl", Version: "1.2", ArchiveType: types.JarType},
+	})
+	require.NoError(t, err)
+
+	e := enrich.NewEnricher(dbc, enrich.Option{URL: ts.URL, Rate: 1000, POMURL: ts.URL + "/maven2/"})
+	require.NoError(t, e.Enrich(context.Background()))
+
+	got, err := dbc.SelectEnrichment("javax.servlet", "jstl")
+	require.NoError(t, err)
+	assert.Equal(t, "JavaServer Pages Standard Tag Library", got.Description)
+	assert.Equal(t, "https://jstl.example.com", got.ProjectURL)
+}
@@ -1,6 +1,8 @@
 package fileutil
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"golang.org/x/xerrors"
 	"io"
@@ -8,8 +10,15 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode/utf8"
 )
 
+// maxNameLength is the max length of a file name in the cache.
+// Most file systems limit a path component to 255 bytes, and Windows limits the whole path to 260 characters
+// unless long paths are enabled. Deep group IDs and long artifact IDs can exceed these limits.
+const maxNameLength = 100
+
 func Walk(root string, walkFn func(r io.Reader, path string) error) error {
 	if err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -78,3 +87,36 @@ func WriteJSON(filePath string, index interface{}) error {
 	}
 	return nil
 }
+
+// ShortName returns `name` if it fits in maxNameLength.
+// Otherwise, the name is cut and the hash of the full name is added to keep it unique.
+// The extension is kept.
+// e.g. `org.example.very.long.group.id....` => `org.example.very.long.grou-1a2b3c4d5e6f7a8b`
+func ShortName(name string) string {
+	if len(name) <= maxNameLength {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > maxNameLength/2 {
+		ext = ""
+	}
+	h := sha256.Sum256([]byte(name))
+	suffix := "-" + hex.EncodeToString(h[:8]) + ext
+
+	// Don't cut a multibyte character
+	n := maxNameLength - len(suffix)
+	for n > 0 && !utf8.RuneStart(name[n]) {
+		n--
+	}
+	return strings.TrimSuffix(name[:n], ".") + suffix
+}
+
+// AbsPath returns the absolute form of `path`.
+// Go adds the `\\?\` prefix to long absolute paths on Windows, but not to relative ones.
+func AbsPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,47 @@
+package fileutil_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+)
+
+func TestShortName(t *testing.T) {
+	long := "org.example." + strings.Repeat("very.long.group.", 10) + "id"
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "short name",
+			in:   "abbot.json",
+			want: "abbot.json",
+		},
+		{
+			name: "long name",
+			in:   long + ".json",
+			want: "org.example.very.long.group.very.long.group.very.long.group.very.long.group.ve-f6fb0cea47ffb0f0.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fileutil.ShortName(tt.in)
+			assert.Equal(t, tt.want, got)
+			assert.LessOrEqual(t, len(got), 100)
+		})
+	}
+
+	t.Run("unique", func(t *testing.T) {
+		assert.NotEqual(t, fileutil.ShortName(long+"1.json"), fileutil.ShortName(long+"2.json"))
+	})
+
+	t.Run("multibyte", func(t *testing.T) {
+		got := fileutil.ShortName(strings.Repeat("ä", 60))
+		assert.True(t, utf8.ValidString(got))
+	})
+}
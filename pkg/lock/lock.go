@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// File is the name of the lock file in the cache dir.
+const File = ".lock"
+
+// pollInterval is the interval of lock attempts while waiting.
+const pollInterval = time.Second
+
+// ErrLocked is returned when another process holds the lock.
+var ErrLocked = errors.New("another process holds the lock")
+
+// Lock is an advisory lock on a file. The OS releases it when the process exits, so crashed processes never leave stale locks.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire locks the file at `path`, creating it if needed.
+// If another process holds the lock, it fails with ErrLocked unless `wait` is set, in which case it waits until
+// the lock is released or `ctx` is done.
+func Acquire(ctx context.Context, path string, wait bool) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0744); err != nil {
+		return nil, xerrors.Errorf("mkdir error: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to open the lock file: %w", err)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for logged := false; ; logged = true {
+		locked, err := tryLock(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, xerrors.Errorf("unable to lock %s: %w", path, err)
+		} else if locked {
+			break
+		}
+
+		holder := readHolder(path)
+		if !wait {
+			_ = f.Close()
+			return nil, xerrors.Errorf("%s%s: %w", path, holder, ErrLocked)
+		}
+		if !logged {
+			log.Printf("Waiting for the lock on %s%s", path, holder)
+		}
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	// The pid is only informational, so errors are ignored
+	if err = f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release releases the lock. The lock file is kept, since removing it would let two processes lock different files.
+func (l *Lock) Release() error {
+	if err := unlock(l.f); err != nil {
+		_ = l.f.Close()
+		return xerrors.Errorf("unable to unlock: %w", err)
+	}
+	return l.f.Close()
+}
+
+// readHolder describes the process holding the lock at `path` by the pid written into the file.
+func readHolder(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	pid := strings.TrimSpace(string(b))
+	if pid == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (pid %s)", pid)
+}
@@ -0,0 +1,40 @@
+package lock_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/lock"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", lock.File)
+	l, err := lock.Acquire(context.Background(), path, false)
+	require.NoError(t, err)
+
+	// Locks are held by open files, so a second lock fails in the same process too
+	_, err = lock.Acquire(context.Background(), path, false)
+	require.ErrorIs(t, err, lock.ErrLocked)
+	assert.Contains(t, err.Error(), fmt.Sprintf("(pid %d)", os.Getpid()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = lock.Acquire(ctx, path, true)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A waiting process gets the lock once it's released
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		assert.NoError(t, l.Release())
+	}()
+	l, err = lock.Acquire(context.Background(), path, true)
+	require.NoError(t, err)
+	require.NoError(t, l.Release())
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/xerrors"
+)
+
+type Format string
+
+const (
+	// JSONFormat posts the Event as JSON.
+	JSONFormat Format = "json"
+	// SlackFormat posts a Slack incoming webhook message.
+	SlackFormat Format = "slack"
+)
+
+// Event is sent when a command completes.
+type Event struct {
+	Command  string
+	Success  bool
+	Error    string `json:",omitempty"`
+	Duration string
+	// Stats is a command specific summary, e.g. crawler.Stats or builder.Stats.
+	Stats any `json:",omitempty"`
+}
+
+type Option struct {
+	URLs   []string
+	Format Format
+}
+
+type Notifier struct {
+	urls   []string
+	format Format
+	http   *retryablehttp.Client
+}
+
+func NewNotifier(opt Option) Notifier {
+	client := retryablehttp.NewClient()
+	client.RetryMax = 3
+	client.Logger = nil
+
+	if opt.Format == "" {
+		opt.Format = JSONFormat
+	}
+
+	return Notifier{
+		urls:   opt.URLs,
+		format: opt.Format,
+		http:   client,
+	}
+}
+
+// NewEvent creates the event for `command` started at `start`. `err` is the command result.
+func NewEvent(command string, start time.Time, stats any, err error) Event {
+	e := Event{
+		Command:  command,
+		Success:  err == nil,
+		Duration: time.Since(start).Round(time.Second).String(),
+		Stats:    stats,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// Notify posts `e` to all webhooks. All webhooks are tried even if some of them fail.
+func (n Notifier) Notify(ctx context.Context, e Event) error {
+	if len(n.urls) == 0 {
+		return nil
+	}
+	body, err := n.payload(e)
+	if err != nil {
+		return err
+	}
+	return n.Post(ctx, body)
+}
+
+// Post posts the JSON `body` to all webhooks as is, e.g. for payloads other than events.
+// All webhooks are tried even if some of them fail.
+func (n Notifier) Post(ctx context.Context, body []byte) error {
+	var errs []error
+	for _, url := range n.urls {
+		if err := n.post(ctx, url, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return xerrors.Errorf("webhook errors: %v", errs)
+	}
+	return nil
+}
+
+func (n Notifier) payload(e Event) ([]byte, error) {
+	var v any = e
+	switch n.format {
+	case JSONFormat:
+	case SlackFormat:
+		v = map[string]string{"text": slackText(e)}
+	default:
+		return nil, xerrors.Errorf("unknown webhook format: %q", n.format)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, xerrors.Errorf("json marshal error: %w", err)
+	}
+	return b, nil
+}
+
+func slackText(e Event) string {
+	text := fmt.Sprintf(":white_check_mark: trivy-java-db %s succeeded in %s", e.Command, e.Duration)
+	if !e.Success {
+		text = fmt.Sprintf(":x: trivy-java-db %s failed after %s: %s", e.Command, e.Duration, e.Error)
+	}
+	if e.Stats != nil {
+		text += fmt.Sprintf("\n%+v", e.Stats)
+	}
+	return text
+}
+
+func (n Notifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return xerrors.Errorf("unable to new HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return xerrors.Errorf("http post error (%s): %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return xerrors.Errorf("http post error (%s): status %s", url, resp.Status)
+	}
+	return nil
+}
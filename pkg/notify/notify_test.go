@@ -0,0 +1,77 @@
+package notify_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/notify"
+)
+
+func TestNotify(t *testing.T) {
+	type stats struct {
+		Indexes int
+	}
+	tests := []struct {
+		name    string
+		format  notify.Format
+		event   notify.Event
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "json",
+			format: notify.JSONFormat,
+			event: notify.Event{
+				Command:  "build",
+				Success:  true,
+				Duration: "1m0s",
+				Stats:    stats{Indexes: 10},
+			},
+			want: `{"Command":"build","Success":true,"Duration":"1m0s","Stats":{"Indexes":10}}`,
+		},
+		{
+			name:   "slack failure",
+			format: notify.SlackFormat,
+			event: notify.Event{
+				Command:  "crawl",
+				Error:    "http get error",
+				Duration: "5s",
+			},
+			want: `{"text":":x: trivy-java-db crawl failed after 5s: http get error"}`,
+		},
+		{
+			name:    "unknown format",
+			format:  "xml",
+			wantErr: "unknown webhook format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				got = string(b)
+			}))
+			defer ts.Close()
+
+			n := notify.NewNotifier(notify.Option{
+				URLs:   []string{ts.URL},
+				Format: tt.format,
+			})
+			err := n.Notify(context.Background(), tt.event)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Package policy evaluates governance rules, e.g. banned groups, versions and labels, against looked up artifacts.
+package policy
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/samber/lo"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/h7hac9/trivy-java-db/pkg/version"
+)
+
+// Policy is a list of rules. An artifact violates the policy if it matches any rule.
+//
+//	rules:
+//	  - name: log4shell
+//	    groups: [org.apache.logging.log4j]
+//	    artifacts: [log4j-core]
+//	    versions: [">= 2.0-beta9, < 2.17.1"]
+//	  - name: banned
+//	    labels: [banned]
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule matches artifacts matching all of its non-empty fields.
+type Rule struct {
+	Name   string `yaml:"name"`
+	Reason string `yaml:"reason"`
+	// Groups and Artifacts are ids or patterns like `com.example.*`, see path.Match.
+	Groups    []string `yaml:"groups"`
+	Artifacts []string `yaml:"artifacts"`
+	// Versions are comma separated constraints like `>= 1.0, < 1.5` in Maven version order.
+	// A version matches if it satisfies all constraints of any entry.
+	Versions []string `yaml:"versions"`
+	// Labels match artifacts with any of the labels.
+	Labels []string `yaml:"labels"`
+}
+
+// Subject is an artifact the policy is evaluated against.
+type Subject struct {
+	GroupID    string
+	ArtifactID string
+	Version    string
+	Labels     []string
+}
+
+// Violation is a rule matched by a subject.
+type Violation struct {
+	Rule   string
+	Reason string `json:",omitempty"`
+}
+
+// operators are version constraint operators. Longer operators come first, so that `<=` isn't parsed as `<`.
+var operators = []string{"<=", ">=", "!=", "<", ">", "="}
+
+// Load reads the YAML policy file and validates its rules.
+func Load(filePath string) (*Policy, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to read the policy file: %w", err)
+	}
+	var p Policy
+	if err = yaml.Unmarshal(b, &p); err != nil {
+		return nil, xerrors.Errorf("unable to parse the policy file: %w", err)
+	}
+	if err = p.validate(); err != nil {
+		return nil, xerrors.Errorf("invalid policy file %s: %w", filePath, err)
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	for i, r := range p.Rules {
+		if r.Name == "" {
+			return xerrors.Errorf("rule %d has no name", i+1)
+		}
+		if len(r.Groups) == 0 && len(r.Artifacts) == 0 && len(r.Versions) == 0 && len(r.Labels) == 0 {
+			return xerrors.Errorf("rule %q matches all artifacts", r.Name)
+		}
+		for _, pattern := range append(r.Groups, r.Artifacts...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return xerrors.Errorf("rule %q has an invalid pattern %q: %w", r.Name, pattern, err)
+			}
+		}
+		for _, constraints := range r.Versions {
+			if _, err := satisfies("", constraints); err != nil {
+				return xerrors.Errorf("rule %q: %w", r.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// NeedsLabels returns true if any rule matches labels, so that callers only look them up when needed.
+func (p *Policy) NeedsLabels() bool {
+	return lo.SomeBy(p.Rules, func(r Rule) bool { return len(r.Labels) > 0 })
+}
+
+// Evaluate returns the rules matched by the subject in the order of the policy file.
+func (p *Policy) Evaluate(s Subject) []Violation {
+	var violations []Violation
+	for _, r := range p.Rules {
+		if r.matches(s) {
+			violations = append(violations, Violation{Rule: r.Name, Reason: r.Reason})
+		}
+	}
+	return violations
+}
+
+func (r Rule) matches(s Subject) bool {
+	if len(r.Groups) > 0 && !matchAny(r.Groups, s.GroupID) {
+		return false
+	}
+	if len(r.Artifacts) > 0 && !matchAny(r.Artifacts, s.ArtifactID) {
+		return false
+	}
+	if len(r.Versions) > 0 && !lo.SomeBy(r.Versions, func(constraints string) bool {
+		ok, _ := satisfies(s.Version, constraints)
+		return ok
+	}) {
+		return false
+	}
+	if len(r.Labels) > 0 && len(lo.Intersect(r.Labels, s.Labels)) == 0 {
+		return false
+	}
+	return true
+}
+
+func matchAny(patterns []string, s string) bool {
+	return lo.SomeBy(patterns, func(pattern string) bool {
+		ok, _ := path.Match(pattern, s)
+		return ok
+	})
+}
+
+// satisfies returns true if `v` satisfies all comma separated constraints. A constraint without operator means `=`.
+func satisfies(v, constraints string) (bool, error) {
+	ok := true
+	for _, c := range strings.Split(constraints, ",") {
+		c = strings.TrimSpace(c)
+		op, _ := lo.Find(operators, func(op string) bool { return strings.HasPrefix(c, op) })
+		want := strings.TrimSpace(strings.TrimPrefix(c, op))
+		if want == "" {
+			return false, xerrors.Errorf("invalid version constraint %q", constraints)
+		}
+
+		cmp := version.Compare(v, want)
+		switch op {
+		case "<":
+			ok = ok && cmp < 0
+		case "<=":
+			ok = ok && cmp <= 0
+		case ">":
+			ok = ok && cmp > 0
+		case ">=":
+			ok = ok && cmp >= 0
+		case "!=":
+			ok = ok && cmp != 0
+		default:
+			ok = ok && cmp == 0
+		}
+	}
+	return ok, nil
+}
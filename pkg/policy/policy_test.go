@@ -0,0 +1,114 @@
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/policy"
+)
+
+const policyYAML = `
+rules:
+  - name: log4shell
+    reason: CVE-2021-44228
+    groups: [org.apache.logging.log4j]
+    artifacts: [log4j-core]
+    versions: [">= 2.0-beta9, < 2.17.1", "1.2.17"]
+  - name: internal-namespace
+    groups: ["com.example.*"]
+  - name: banned
+    labels: [banned, blocked]
+`
+
+func TestEvaluate(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(filePath, []byte(policyYAML), 0o600))
+	p, err := policy.Load(filePath)
+	require.NoError(t, err)
+	assert.True(t, p.NeedsLabels())
+
+	tests := []struct {
+		name    string
+		subject policy.Subject
+		want    []policy.Violation
+	}{
+		{
+			name:    "vulnerable version",
+			subject: policy.Subject{GroupID: "org.apache.logging.log4j", ArtifactID: "log4j-core", Version: "2.14.1"},
+			want:    []policy.Violation{{Rule: "log4shell", Reason: "CVE-2021-44228"}},
+		},
+		{
+			name:    "exact version",
+			subject: policy.Subject{GroupID: "org.apache.logging.log4j", ArtifactID: "log4j-core", Version: "1.2.17"},
+			want:    []policy.Violation{{Rule: "log4shell", Reason: "CVE-2021-44228"}},
+		},
+		{
+			name:    "fixed version",
+			subject: policy.Subject{GroupID: "org.apache.logging.log4j", ArtifactID: "log4j-core", Version: "2.17.1"},
+		},
+		{
+			name:    "other artifact",
+			subject: policy.Subject{GroupID: "org.apache.logging.log4j", ArtifactID: "log4j-api", Version: "2.14.1"},
+		},
+		{
+			name:    "group pattern",
+			subject: policy.Subject{GroupID: "com.example.billing", ArtifactID: "client", Version: "1.0"},
+			want:    []policy.Violation{{Rule: "internal-namespace"}},
+		},
+		{
+			name:    "label and group",
+			subject: policy.Subject{GroupID: "com.example.billing", ArtifactID: "client", Version: "1.0", Labels: []string{"approved", "blocked"}},
+			want:    []policy.Violation{{Rule: "internal-namespace"}, {Rule: "banned"}},
+		},
+		{
+			name:    "no match",
+			subject: policy.Subject{GroupID: "jstl", ArtifactID: "jstl", Version: "1.2", Labels: []string{"approved"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.Evaluate(tt.subject))
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "no name",
+			yaml:    "rules:\n  - groups: [jstl]\n",
+			wantErr: "rule 1 has no name",
+		},
+		{
+			name:    "matches all",
+			yaml:    "rules:\n  - name: all\n",
+			wantErr: `rule "all" matches all artifacts`,
+		},
+		{
+			name:    "bad pattern",
+			yaml:    "rules:\n  - name: bad\n    groups: [\"com.[\"]\n",
+			wantErr: "invalid pattern",
+		},
+		{
+			name:    "bad constraint",
+			yaml:    "rules:\n  - name: bad\n    versions: [\">= 1.0, <\"]\n",
+			wantErr: "invalid version constraint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "policy.yaml")
+			require.NoError(t, os.WriteFile(filePath, []byte(tt.yaml), 0o600))
+			_, err := policy.Load(filePath)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Package purl converts indexes to and from Maven package URLs, e.g.
+// `pkg:maven/io.netty/netty-tcnative-boringssl-static@2.0.61.Final?classifier=linux-x86_64`.
+// See https://github.com/package-url/purl-spec for the format.
+package purl
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+const (
+	prefix = "pkg:maven/"
+
+	qualifierType       = "type"
+	qualifierClassifier = "classifier"
+)
+
+// PackageURL is a parsed Maven package URL.
+type PackageURL struct {
+	GroupID    string
+	ArtifactID string
+	// Version doesn't include the classifier.
+	Version    string
+	Classifier string
+	// ArchiveType is the `type` qualifier, defaulting to jar.
+	ArchiveType types.ArchiveType
+}
+
+// GAV returns the coordinates of the index. The DB stores the classifier in the version, as in the file name.
+func (p PackageURL) GAV() types.GAV {
+	version := p.Version
+	if p.Classifier != "" {
+		version += "-" + p.Classifier
+	}
+	return types.GAV{
+		GroupID:    p.GroupID,
+		ArtifactID: p.ArtifactID,
+		Version:    version,
+	}
+}
+
+// Matches reports whether `index` is the file of the package URL.
+// Group and artifact ids are compared case-insensitively, so indexes found by case-insensitive lookups match.
+func (p PackageURL) Matches(index types.Index) bool {
+	return strings.EqualFold(index.GroupID, p.GroupID) && strings.EqualFold(index.ArtifactID, p.ArtifactID) &&
+		index.Version == p.GAV().Version && index.ArchiveType == p.ArchiveType && index.Classifier == p.Classifier
+}
+
+// FromIndex returns the package URL of `index`.
+// The `type` qualifier is only set for archive types other than jar, and `classifier` for classified files.
+func FromIndex(index types.Index) string {
+	qualifiers := url.Values{}
+	if index.ArchiveType != "" && index.ArchiveType != types.JarType {
+		qualifiers.Set(qualifierType, string(index.ArchiveType))
+	}
+	version := index.Version
+	if index.Classifier != "" {
+		qualifiers.Set(qualifierClassifier, index.Classifier)
+		version = strings.TrimSuffix(version, "-"+index.Classifier)
+	}
+
+	s := prefix + url.PathEscape(index.GroupID) + "/" + url.PathEscape(index.ArtifactID)
+	if version != "" {
+		s += "@" + url.PathEscape(version)
+	}
+	if len(qualifiers) > 0 {
+		// Encode sorts the qualifiers by key, as the spec requires
+		s += "?" + qualifiers.Encode()
+	}
+	return s
+}
+
+// Parse parses a Maven package URL. Subpaths are ignored.
+func Parse(s string) (PackageURL, error) {
+	if !strings.HasPrefix(strings.ToLower(s), prefix) {
+		return PackageURL{}, xerrors.Errorf("not a Maven package URL: %s", s)
+	}
+	s = s[len(prefix):]
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = s[:i]
+	}
+
+	var qualifiers url.Values
+	if i := strings.Index(s, "?"); i >= 0 {
+		var err error
+		if qualifiers, err = url.ParseQuery(s[i+1:]); err != nil {
+			return PackageURL{}, xerrors.Errorf("invalid qualifiers: %w", err)
+		}
+		s = s[:i]
+	}
+
+	var version string
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		s, version = s[:i], s[i+1:]
+	}
+	namespace, name, ok := strings.Cut(s, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return PackageURL{}, xerrors.Errorf("package URL must have a group and artifact id: %s", s)
+	}
+
+	p := PackageURL{ArchiveType: types.JarType}
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&p.GroupID, namespace},
+		{&p.ArtifactID, name},
+		{&p.Version, version},
+	} {
+		v, err := url.PathUnescape(field.src)
+		if err != nil {
+			return PackageURL{}, xerrors.Errorf("invalid package URL: %w", err)
+		}
+		*field.dst = v
+	}
+	// Qualifier keys are case-insensitive
+	for k := range qualifiers {
+		switch strings.ToLower(k) {
+		case qualifierType:
+			p.ArchiveType = types.ArchiveType(qualifiers.Get(k))
+		case qualifierClassifier:
+			p.Classifier = qualifiers.Get(k)
+		}
+	}
+	return p, nil
+}
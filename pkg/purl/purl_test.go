@@ -0,0 +1,110 @@
+package purl_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/purl"
+	"github.com/h7hac9/trivy-java-db/pkg/types"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		index types.Index
+		want  string
+	}{
+		{
+			name:  "jar",
+			index: types.Index{GroupID: "jstl", ArtifactID: "jstl", Version: "1.2", ArchiveType: types.JarType},
+			want:  "pkg:maven/jstl/jstl@1.2",
+		},
+		{
+			name:  "aar",
+			index: types.Index{GroupID: "androidx.core", ArtifactID: "core", Version: "1.12.0", ArchiveType: types.AarType},
+			want:  "pkg:maven/androidx.core/core@1.12.0?type=aar",
+		},
+		{
+			name: "classifier",
+			index: types.Index{
+				GroupID:     "io.netty",
+				ArtifactID:  "netty-tcnative-boringssl-static",
+				Version:     "2.0.61.Final-linux-x86_64",
+				ArchiveType: types.JarType,
+				Classifier:  "linux-x86_64",
+			},
+			want: "pkg:maven/io.netty/netty-tcnative-boringssl-static@2.0.61.Final?classifier=linux-x86_64",
+		},
+		{
+			name: "klib with classifier",
+			index: types.Index{
+				GroupID:     "io.github.abbot",
+				ArtifactID:  "abbot",
+				Version:     "1.4.0-iosarm64",
+				ArchiveType: types.KlibType,
+				Classifier:  "iosarm64",
+			},
+			want: "pkg:maven/io.github.abbot/abbot@1.4.0?classifier=iosarm64&type=klib",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := purl.FromIndex(tt.index)
+			assert.Equal(t, tt.want, got)
+
+			p, err := purl.Parse(got)
+			require.NoError(t, err)
+			assert.True(t, p.Matches(tt.index))
+			assert.Equal(t, types.GAV{GroupID: tt.index.GroupID, ArtifactID: tt.index.ArtifactID, Version: tt.index.Version}, p.GAV())
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		purl    string
+		want    purl.PackageURL
+		wantErr string
+	}{
+		{
+			name: "qualifier keys are case-insensitive",
+			purl: "pkg:maven/org.apache.commons/commons-lang3@3.14.0?Classifier=sources#subpath",
+			want: purl.PackageURL{
+				GroupID:     "org.apache.commons",
+				ArtifactID:  "commons-lang3",
+				Version:     "3.14.0",
+				Classifier:  "sources",
+				ArchiveType: types.JarType,
+			},
+		},
+		{
+			name: "escaped version",
+			purl: "pkg:maven/jstl/jstl@1.2%2Bbuild",
+			want: purl.PackageURL{GroupID: "jstl", ArtifactID: "jstl", Version: "1.2+build", ArchiveType: types.JarType},
+		},
+		{
+			name:    "other type",
+			purl:    "pkg:npm/lodash@4.17.21",
+			wantErr: "not a Maven package URL",
+		},
+		{
+			name:    "no group id",
+			purl:    "pkg:maven/jstl@1.2",
+			wantErr: "must have a group and artifact id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := purl.Parse(tt.purl)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
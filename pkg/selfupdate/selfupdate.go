@@ -0,0 +1,264 @@
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/xerrors"
+)
+
+const (
+	defaultAPIURL = "https://api.github.com"
+
+	// checksumsAsset lists sha256 digests of all binaries in the `sha256sum` format.
+	checksumsAsset = "checksums.txt"
+	// signatureAsset is the base64 encoded ed25519 signature of checksumsAsset.
+	signatureAsset = checksumsAsset + ".sig"
+)
+
+// Release is the latest release and the assets used to update the running binary.
+type Release struct {
+	Version string
+	Binary  string
+	// SHA256 is the verified digest of the binary. It's only set by Update.
+	SHA256 string `json:",omitempty"`
+	// Updated is false when the running binary already has the latest version.
+	Updated bool
+}
+
+type Option struct {
+	// APIURL is the GitHub API root, e.g. of a GitHub Enterprise mirror. api.github.com is used by default.
+	APIURL string
+	// Repo is `owner/name` of the repository publishing the releases.
+	Repo string
+	// PublicKey verifies the signature of the checksums. The signature isn't verified when it's nil.
+	PublicKey ed25519.PublicKey
+}
+
+type Updater struct {
+	apiURL    string
+	repo      string
+	publicKey ed25519.PublicKey
+	http      *retryablehttp.Client
+}
+
+func NewUpdater(opt Option) Updater {
+	client := retryablehttp.NewClient()
+	client.RetryMax = 3
+	client.Logger = nil
+
+	if opt.APIURL == "" {
+		opt.APIURL = defaultAPIURL
+	}
+
+	return Updater{
+		apiURL:    strings.TrimSuffix(opt.APIURL, "/"),
+		repo:      opt.Repo,
+		publicKey: opt.PublicKey,
+		http:      client,
+	}
+}
+
+// ParsePublicKey decodes a base64 encoded ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, xerrors.Errorf("base64 decode error: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, xerrors.Errorf("wrong public key size: %d", len(b))
+	}
+	return b, nil
+}
+
+// BinaryName returns the name of the release asset for the platform.
+// e.g. `trivy-java-db_linux_amd64`, `trivy-java-db_windows_amd64.exe`
+func BinaryName(goos, goarch string) string {
+	name := fmt.Sprintf("trivy-java-db_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Update replaces the binary at `exePath` with the binary of the latest release unless `currentVersion` is the latest one.
+// The binary is only written after its checksum and the signature of the checksums are verified.
+func (u Updater) Update(ctx context.Context, currentVersion, exePath string) (Release, error) {
+	var latest githubRelease
+	if err := u.getJSON(ctx, fmt.Sprintf("%s/repos/%s/releases/latest", u.apiURL, u.repo), &latest); err != nil {
+		return Release{}, xerrors.Errorf("latest release error: %w", err)
+	}
+	release := Release{
+		Version: latest.TagName,
+		Binary:  BinaryName(runtime.GOOS, runtime.GOARCH),
+	}
+	if latest.TagName == currentVersion {
+		return release, nil
+	}
+
+	assets := make(map[string]string)
+	for _, asset := range latest.Assets {
+		assets[asset.Name] = asset.URL
+	}
+	for _, name := range []string{release.Binary, checksumsAsset} {
+		if _, ok := assets[name]; !ok {
+			return Release{}, xerrors.Errorf("release %s has no %s asset", latest.TagName, name)
+		}
+	}
+
+	checksums, err := u.download(ctx, assets[checksumsAsset])
+	if err != nil {
+		return Release{}, xerrors.Errorf("checksums download error: %w", err)
+	}
+	if u.publicKey != nil {
+		if _, ok := assets[signatureAsset]; !ok {
+			return Release{}, xerrors.Errorf("release %s has no %s asset", latest.TagName, signatureAsset)
+		}
+		sig, err := u.download(ctx, assets[signatureAsset])
+		if err != nil {
+			return Release{}, xerrors.Errorf("signature download error: %w", err)
+		}
+		if err = verifySignature(u.publicKey, checksums, sig); err != nil {
+			return Release{}, err
+		}
+	}
+	want, err := checksum(checksums, release.Binary)
+	if err != nil {
+		return Release{}, err
+	}
+
+	binary, err := u.download(ctx, assets[release.Binary])
+	if err != nil {
+		return Release{}, xerrors.Errorf("binary download error: %w", err)
+	}
+	sum := sha256.Sum256(binary)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return Release{}, xerrors.Errorf("checksum mismatch of %s: want %s, got %s", release.Binary, want, got)
+	}
+	release.SHA256 = want
+
+	if err = replaceFile(exePath, binary); err != nil {
+		return Release{}, xerrors.Errorf("binary replace error: %w", err)
+	}
+	release.Updated = true
+	return release, nil
+}
+
+func (u Updater) getJSON(ctx context.Context, url string, v any) error {
+	b, err := u.download(ctx, url)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return xerrors.Errorf("json decode error (%s): %w", url, err)
+	}
+	return nil
+}
+
+func (u Updater) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("unable to new HTTP request: %w", err)
+	}
+	resp, err := u.http.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("http get error (%s): %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("unexpected status code (%s): %d", url, resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, xerrors.Errorf("can't read %s: %w", url, err)
+	}
+	return b, nil
+}
+
+func verifySignature(publicKey ed25519.PublicKey, checksums, sig []byte) error {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return xerrors.Errorf("signature decode error: %w", err)
+	}
+	if !ed25519.Verify(publicKey, checksums, b) {
+		return xerrors.New("invalid signature of checksums")
+	}
+	return nil
+}
+
+// checksum returns the digest of `name` from `checksums` in the `sha256sum` format.
+// e.g. `9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08  trivy-java-db_linux_amd64`
+func checksum(checksums []byte, name string) (string, error) {
+	s := bufio.NewScanner(bytes.NewReader(checksums))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", xerrors.Errorf("no checksum of %s", name)
+}
+
+// replaceFile writes `b` next to `filePath` and renames it into place.
+// The running binary on Windows can be renamed but not overwritten, so it's moved aside first.
+func replaceFile(filePath string, b []byte) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return xerrors.Errorf("stat error: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.new")
+	if err != nil {
+		return xerrors.Errorf("unable to create a temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err = f.Write(b); err != nil {
+		_ = f.Close()
+		return xerrors.Errorf("failed to save a file: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return xerrors.Errorf("failed to save a file: %w", err)
+	}
+	if err = os.Chmod(f.Name(), info.Mode()); err != nil {
+		return xerrors.Errorf("chmod error: %w", err)
+	}
+
+	old := filePath + ".old"
+	if err = os.Rename(filePath, old); err != nil {
+		return xerrors.Errorf("failed to move the old binary: %w", err)
+	}
+	if err = os.Rename(f.Name(), filePath); err != nil {
+		// Restore the old binary
+		_ = os.Rename(old, filePath)
+		return xerrors.Errorf("failed to rename a file: %w", err)
+	}
+	// Windows doesn't allow removing the running binary. It's overwritten by the next update.
+	_ = os.Remove(old)
+	return nil
+}
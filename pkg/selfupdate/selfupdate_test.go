@@ -0,0 +1,130 @@
+package selfupdate_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/selfupdate"
+)
+
+func TestUpdate(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	binaryName := selfupdate.BinaryName(runtime.GOOS, runtime.GOARCH)
+	newBinary := []byte("new binary")
+	sum := sha256.Sum256(newBinary)
+	checksums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), binaryName)
+
+	tests := []struct {
+		name           string
+		currentVersion string
+		binary         []byte
+		signingKey     ed25519.PrivateKey
+		publicKey      ed25519.PublicKey
+		want           selfupdate.Release
+		wantBinary     string
+		wantErr        string
+	}{
+		{
+			name:           "happy path",
+			currentVersion: "v0.1.0",
+			binary:         newBinary,
+			signingKey:     privateKey,
+			publicKey:      publicKey,
+			want:           selfupdate.Release{Version: "v0.2.0", Binary: binaryName, SHA256: hex.EncodeToString(sum[:]), Updated: true},
+			wantBinary:     "new binary",
+		},
+		{
+			name:           "up to date",
+			currentVersion: "v0.2.0",
+			binary:         newBinary,
+			signingKey:     privateKey,
+			publicKey:      publicKey,
+			want:           selfupdate.Release{Version: "v0.2.0", Binary: binaryName},
+			wantBinary:     "old binary",
+		},
+		{
+			name:           "checksum mismatch",
+			currentVersion: "v0.1.0",
+			binary:         []byte("tampered binary"),
+			signingKey:     privateKey,
+			publicKey:      publicKey,
+			wantBinary:     "old binary",
+			wantErr:        "checksum mismatch",
+		},
+		{
+			name:           "wrong signing key",
+			currentVersion: "v0.1.0",
+			binary:         newBinary,
+			signingKey:     otherKey,
+			publicKey:      publicKey,
+			wantBinary:     "old binary",
+			wantErr:        "invalid signature",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts *httptest.Server
+			ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/repos/h7hac9/trivy-java-db/releases/latest":
+					_ = json.NewEncoder(w).Encode(map[string]any{
+						"tag_name": "v0.2.0",
+						"assets": []map[string]string{
+							{"name": binaryName, "browser_download_url": ts.URL + "/download/binary"},
+							{"name": "checksums.txt", "browser_download_url": ts.URL + "/download/checksums"},
+							{"name": "checksums.txt.sig", "browser_download_url": ts.URL + "/download/sig"},
+						},
+					})
+				case "/download/binary":
+					_, _ = w.Write(tt.binary)
+				case "/download/checksums":
+					_, _ = w.Write([]byte(checksums))
+				case "/download/sig":
+					_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(tt.signingKey, []byte(checksums)))))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer ts.Close()
+
+			exePath := filepath.Join(t.TempDir(), "trivy-java-db")
+			require.NoError(t, os.WriteFile(exePath, []byte("old binary"), 0o755))
+
+			u := selfupdate.NewUpdater(selfupdate.Option{
+				APIURL:    ts.URL,
+				Repo:      "h7hac9/trivy-java-db",
+				PublicKey: tt.publicKey,
+			})
+			got, err := u.Update(context.Background(), tt.currentVersion, exePath)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+
+			b, err := os.ReadFile(exePath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBinary, string(b))
+		})
+	}
+}
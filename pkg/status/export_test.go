@@ -0,0 +1,14 @@
+package status
+
+import (
+	"path/filepath"
+
+	"k8s.io/utils/clock"
+)
+
+// NewReporterWithClock is NewReporter with a fake clock for tests of the write throttling.
+func NewReporterWithClock(cacheDir, command string, c clock.Clock) *Reporter {
+	r := &Reporter{path: filepath.Join(cacheDir, File), clock: c}
+	r.start(command)
+	return r
+}
@@ -0,0 +1,148 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+	"k8s.io/utils/clock"
+
+	"github.com/h7hac9/trivy-java-db/pkg/fileutil"
+)
+
+// File is the name of the status file in the cache dir.
+const File = "status.json"
+
+// Phases of commands
+const (
+	Idle      = "idle"
+	Starting  = "starting"
+	Crawling  = "crawling"
+	Inserting = "inserting"
+	Finishing = "finishing"
+)
+
+// writeInterval throttles status writes of long-running phases.
+const writeInterval = time.Second
+
+// Status is the state of the crawl or build running in a cache dir.
+type Status struct {
+	// Command is the running command, or the last one if Phase is idle.
+	Command string
+	Phase   string
+	// Detail describes the current step, e.g. the group of the last crawled artifact.
+	Detail string `json:",omitempty"`
+	Done   int    `json:",omitempty"`
+	// Total is the expected value of Done. Crawls estimate it from the last complete crawl.
+	Total int `json:",omitempty"`
+	PID   int `json:",omitempty"`
+	// Error is the error of the last command.
+	Error     string `json:",omitempty"`
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Reporter writes the status of a command into the cache dir.
+// Methods of a nil Reporter do nothing, so crawls and builds can run without it.
+type Reporter struct {
+	path  string
+	clock clock.Clock
+
+	mu      sync.Mutex
+	status  Status
+	written time.Time
+}
+
+// NewReporter writes the start of `command` into the status file of `cacheDir`.
+func NewReporter(cacheDir, command string) *Reporter {
+	r := &Reporter{
+		path:  filepath.Join(cacheDir, File),
+		clock: clock.RealClock{},
+	}
+	r.start(command)
+	return r
+}
+
+func (r *Reporter) start(command string) {
+	now := r.clock.Now().UTC()
+	r.status = Status{
+		Command:   command,
+		Phase:     Starting,
+		PID:       os.Getpid(),
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	r.write()
+}
+
+// Update records the progress of `phase`. The status file is written at most once per second unless the phase changes.
+func (r *Reporter) Update(phase, detail string, done, total int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	changed := phase != r.status.Phase
+	r.status.Phase = phase
+	r.status.Detail = detail
+	r.status.Done = done
+	r.status.Total = total
+	r.status.UpdatedAt = now.UTC()
+	if changed || now.Sub(r.written) >= writeInterval {
+		r.write()
+	}
+}
+
+// Finish records the end of the command with its error.
+func (r *Reporter) Finish(err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Phase = Idle
+	r.status.Detail = ""
+	r.status.PID = 0
+	r.status.UpdatedAt = r.clock.Now().UTC()
+	if err != nil {
+		r.status.Error = err.Error()
+	}
+	r.write()
+}
+
+// write replaces the status file. It's renamed into place, so readers never see a partial file.
+// Errors are only logged, since the status is informational.
+func (r *Reporter) write() {
+	r.written = r.clock.Now()
+	tmp := r.path + ".tmp"
+	if err := fileutil.WriteJSON(tmp, r.status); err != nil {
+		log.Printf("Unable to write the status: %s", err)
+		return
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		log.Printf("Unable to write the status: %s", err)
+	}
+}
+
+// Read returns the status of `cacheDir`. It's idle without a command if nothing ran in the cache dir.
+func Read(cacheDir string) (Status, error) {
+	b, err := os.ReadFile(filepath.Join(cacheDir, File))
+	if errors.Is(err, os.ErrNotExist) {
+		return Status{Phase: Idle}, nil
+	} else if err != nil {
+		return Status{}, xerrors.Errorf("unable to read the status: %w", err)
+	}
+	var s Status
+	if err = json.Unmarshal(b, &s); err != nil {
+		return Status{}, xerrors.Errorf("unable to decode the status: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,73 @@
+package status_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/h7hac9/trivy-java-db/pkg/status"
+)
+
+func TestReporter(t *testing.T) {
+	dir := t.TempDir()
+	startedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := clocktesting.NewFakeClock(startedAt)
+	r := status.NewReporterWithClock(dir, "crawl", clock)
+
+	got, err := status.Read(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "crawl", got.Command)
+	assert.Equal(t, status.Starting, got.Phase)
+	assert.NotZero(t, got.PID)
+	assert.Equal(t, startedAt, got.StartedAt)
+
+	// A new phase is written at once
+	r.Update(status.Crawling, "jstl", 1, 10)
+	got, err = status.Read(dir)
+	require.NoError(t, err)
+	assert.Equal(t, status.Crawling, got.Phase)
+	assert.Equal(t, "jstl", got.Detail)
+	assert.Equal(t, 1, got.Done)
+	assert.Equal(t, 10, got.Total)
+
+	// Progress within the phase is throttled
+	r.Update(status.Crawling, "junit", 2, 10)
+	got, err = status.Read(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Done)
+
+	clock.Step(time.Second)
+	r.Update(status.Crawling, "junit", 3, 10)
+	got, err = status.Read(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, got.Done)
+	assert.Equal(t, "junit", got.Detail)
+	assert.Equal(t, startedAt.Add(time.Second), got.UpdatedAt)
+
+	r.Finish(errors.New("crawl error"))
+	got, err = status.Read(dir)
+	require.NoError(t, err)
+	assert.Equal(t, status.Idle, got.Phase)
+	assert.Equal(t, "crawl", got.Command)
+	assert.Equal(t, "crawl error", got.Error)
+	assert.Zero(t, got.PID)
+	assert.Empty(t, got.Detail)
+}
+
+func TestNilReporter(t *testing.T) {
+	var r *status.Reporter
+	assert.NotPanics(t, func() {
+		r.Update(status.Inserting, "", 1, 2)
+		r.Finish(nil)
+	})
+}
+
+func TestReadMissing(t *testing.T) {
+	got, err := status.Read(t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, status.Status{Phase: status.Idle}, got)
+}
@@ -0,0 +1,199 @@
+// Package torrent creates BitTorrent metainfo files, so that built DBs can be distributed peer-to-peer.
+// Only single-file torrents are supported. See BEP 3 for the format and BEP 19 for web seeds.
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	minPieceLength = 256 << 10
+	maxPieceLength = 16 << 20
+	// targetPieces keeps the metainfo small while pieces stay small enough to be verified and shared quickly.
+	targetPieces = 1500
+)
+
+type Option struct {
+	// Trackers are announce URLs. The first one is the primary tracker.
+	Trackers []string
+	// WebSeeds are HTTP URLs of the file, e.g. of the published DB. Peers fall back to them when there are no seeders.
+	WebSeeds []string
+	// PieceLength defaults to a power of two giving about 1500 pieces.
+	PieceLength int64
+	Comment     string
+	CreatedBy   string
+	// CreationDate is omitted if zero.
+	CreationDate time.Time
+}
+
+// Torrent is a created metainfo file.
+type Torrent struct {
+	Name string
+	// InfoHash is the hex encoded sha1 of the info dictionary, which identifies the torrent.
+	InfoHash string
+	Magnet   string
+	// MetaInfo is the content of the .torrent file.
+	MetaInfo []byte `json:"-"`
+}
+
+// Create hashes the file at `filePath` and returns its torrent.
+func Create(filePath string, opt Option) (Torrent, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return Torrent{}, xerrors.Errorf("unable to open %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return Torrent{}, xerrors.Errorf("stat error: %w", err)
+	}
+	pieceLength := opt.PieceLength
+	if pieceLength == 0 {
+		pieceLength = choosePieceLength(info.Size())
+	}
+
+	var pieces bytes.Buffer
+	buf := make([]byte, pieceLength)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sum := sha1.Sum(buf[:n])
+			pieces.Write(sum[:])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return Torrent{}, xerrors.Errorf("read error: %w", err)
+		}
+	}
+
+	name := filepath.Base(filePath)
+	infoDict := map[string]any{
+		"name":         name,
+		"length":       info.Size(),
+		"piece length": pieceLength,
+		"pieces":       pieces.Bytes(),
+	}
+	var encodedInfo bytes.Buffer
+	if err = encode(&encodedInfo, infoDict); err != nil {
+		return Torrent{}, err
+	}
+	infoHash := sha1.Sum(encodedInfo.Bytes())
+
+	meta := map[string]any{
+		"info": rawValue(encodedInfo.Bytes()),
+	}
+	if len(opt.Trackers) > 0 {
+		meta["announce"] = opt.Trackers[0]
+		// Each tracker is its own tier, so clients try all of them
+		var tiers []any
+		for _, tracker := range opt.Trackers {
+			tiers = append(tiers, []any{tracker})
+		}
+		meta["announce-list"] = tiers
+	}
+	if len(opt.WebSeeds) > 0 {
+		var seeds []any
+		for _, seed := range opt.WebSeeds {
+			seeds = append(seeds, seed)
+		}
+		meta["url-list"] = seeds
+	}
+	if opt.Comment != "" {
+		meta["comment"] = opt.Comment
+	}
+	if opt.CreatedBy != "" {
+		meta["created by"] = opt.CreatedBy
+	}
+	if !opt.CreationDate.IsZero() {
+		meta["creation date"] = opt.CreationDate.Unix()
+	}
+	var metaInfo bytes.Buffer
+	if err = encode(&metaInfo, meta); err != nil {
+		return Torrent{}, err
+	}
+
+	t := Torrent{
+		Name:     name,
+		InfoHash: hex.EncodeToString(infoHash[:]),
+		MetaInfo: metaInfo.Bytes(),
+	}
+	t.Magnet = magnet(t, opt)
+	return t, nil
+}
+
+// choosePieceLength returns the smallest power of two from 256 KiB giving at most targetPieces pieces, up to 16 MiB.
+func choosePieceLength(size int64) int64 {
+	length := int64(minPieceLength)
+	for length < maxPieceLength && size/length > targetPieces {
+		length *= 2
+	}
+	return length
+}
+
+func magnet(t Torrent, opt Option) string {
+	q := url.Values{}
+	q.Set("dn", t.Name)
+	for _, tracker := range opt.Trackers {
+		q.Add("tr", tracker)
+	}
+	for _, seed := range opt.WebSeeds {
+		q.Add("ws", seed)
+	}
+	return "magnet:?xt=urn:btih:" + t.InfoHash + "&" + q.Encode()
+}
+
+// rawValue is written as is, e.g. an already encoded dictionary.
+type rawValue []byte
+
+// encode writes `v` bencoded. Dictionary keys are sorted as the format requires.
+func encode(w *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case rawValue:
+		w.Write(v)
+	case string:
+		fmt.Fprintf(w, "%d:%s", len(v), v)
+	case []byte:
+		fmt.Fprintf(w, "%d:", len(v))
+		w.Write(v)
+	case int64:
+		fmt.Fprintf(w, "i%de", v)
+	case []any:
+		w.WriteByte('l')
+		for _, e := range v {
+			if err := encode(w, e); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('e')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		w.WriteByte('d')
+		for _, k := range keys {
+			_ = encode(w, k)
+			if err := encode(w, v[k]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('e')
+	default:
+		return xerrors.Errorf("unsupported bencode type: %T", v)
+	}
+	return nil
+}
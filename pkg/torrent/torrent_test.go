@@ -0,0 +1,53 @@
+package torrent_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/h7hac9/trivy-java-db/pkg/torrent"
+)
+
+func TestCreate(t *testing.T) {
+	content := []byte("trivy-java-db")
+	filePath := filepath.Join(t.TempDir(), "javadb.tar.gz")
+	require.NoError(t, os.WriteFile(filePath, content, 0644))
+
+	got, err := torrent.Create(filePath, torrent.Option{
+		Trackers:     []string{"udp://tracker.example.com:6969"},
+		WebSeeds:     []string{"https://mirror.example.com/javadb.tar.gz"},
+		PieceLength:  8,
+		CreationDate: time.Unix(1700000000, 0),
+	})
+	require.NoError(t, err)
+
+	piece1, piece2 := sha1.Sum(content[:8]), sha1.Sum(content[8:])
+	info := fmt.Sprintf("d6:lengthi13e4:name13:javadb.tar.gz12:piece lengthi8e6:pieces40:%s%se", piece1[:], piece2[:])
+	infoHash := sha1.Sum([]byte(info))
+	want := "d8:announce30:udp://tracker.example.com:6969" +
+		"13:announce-listll30:udp://tracker.example.com:6969ee" +
+		"13:creation datei1700000000e" +
+		"4:info" + info +
+		"8:url-listl40:https://mirror.example.com/javadb.tar.gzee"
+	assert.Equal(t, want, string(got.MetaInfo))
+	assert.Equal(t, hex.EncodeToString(infoHash[:]), got.InfoHash)
+	assert.Equal(t, "magnet:?xt=urn:btih:"+got.InfoHash+
+		"&dn=javadb.tar.gz&tr=udp%3A%2F%2Ftracker.example.com%3A6969&ws=https%3A%2F%2Fmirror.example.com%2Fjavadb.tar.gz", got.Magnet)
+}
+
+func TestCreateDefaultPieceLength(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "trivy-java.db")
+	require.NoError(t, os.WriteFile(filePath, make([]byte, 1<<20), 0644))
+
+	got, err := torrent.Create(filePath, torrent.Option{})
+	require.NoError(t, err)
+	// 4 pieces of 256 KiB
+	assert.Contains(t, string(got.MetaInfo), "12:piece lengthi262144e6:pieces80:")
+}
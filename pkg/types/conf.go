@@ -1,14 +1,77 @@
 package types
 
+import "time"
+
+// VacuumMode is how sqlite DBs reclaim the pages of deleted rows after builds.
+type VacuumMode string
+
+const (
+	// VacuumFull rebuilds the DB file, which needs temporary space of the DB size.
+	VacuumFull VacuumMode = "full"
+	// VacuumIncremental frees pages in place. DBs are created with incremental auto-vacuum for it.
+	VacuumIncremental VacuumMode = "incremental"
+	// VacuumNone keeps the free pages.
+	VacuumNone VacuumMode = "none"
+)
+
 type SqliteDBConfig struct {
 	DBPath string
+	// FTS enables the FTS5 full-text search index over artifact coordinates.
+	FTS bool
+	// Flat uses the denormalized schema with GAV and digests in one table.
+	Flat bool
+	// Explain logs query plans of lookups.
+	Explain bool
+	// AsOf limits lookups to indexes introduced up to this build generation. All indexes are used if zero.
+	AsOf int
+	// CaseInsensitive makes GAV lookups ignore the case of group and artifact ids, e.g. when build tools lowercase them.
+	CaseInsensitive bool
+	// ReadOnly opens an existing DB without write access. Opening fails if DBPath doesn't exist.
+	ReadOnly bool
+	// Vacuum is how VacuumDB reclaims free pages. Defaults to VacuumFull.
+	Vacuum VacuumMode
+	// BusyTimeout is how long queries wait for locks held by other processes before failing with SQLITE_BUSY,
+	// e.g. while a build writes to the DB. Queries fail immediately if zero.
+	BusyTimeout time.Duration
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure the connection pool. The defaults of database/sql are used if zero.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 type MysqlDBConfig struct {
 	DBConnectURL string
+	// Timeout sets connect, read and write timeouts. Timeouts from DBConnectURL are used if zero.
+	Timeout time.Duration
+	// Retries is the number of reconnect attempts on connection loss.
+	Retries int
+	// ReadConnectURLs are read replicas. Selects are spread across them in round-robin, writes go to DBConnectURL.
+	ReadConnectURLs []string
+	// Explain logs query plans of lookups.
+	Explain bool
+	// AsOf limits lookups to indexes introduced up to this build generation. All indexes are used if zero.
+	AsOf int
+	// CaseInsensitive makes GAV lookups ignore the case of group and artifact ids, e.g. when build tools lowercase them.
+	CaseInsensitive bool
+	// Schema is the database of the tables. The database of DBConnectURL is used if empty.
+	Schema string
+	// TablePrefix is prepended to all table names, e.g. for a database shared with other applications.
+	TablePrefix string
+	// UTF8MB4 creates tables with the utf8mb4 charset and the binary collation, and converts existing utf8 tables in Init.
+	UTF8MB4 bool
+	// Compat creates tables without foreign keys and blob prefix indexes, which TiDB and SingleStore reject.
+	Compat bool
+	// MaxOpenConns, MaxIdleConns and ConnMaxLifetime configure the connection pool. The defaults of database/sql are used if zero.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
 type DBConfig struct {
 	SqliteDBConfig *SqliteDBConfig
 	MysqlDBConfig  *MysqlDBConfig
+	// SlowQueryThreshold logs DB calls taking at least this long. Calls aren't logged if zero.
+	SlowQueryThreshold time.Duration
+	// QueryMetricsPath is the JSON file receiving latency histograms of DB calls when the DB is closed.
+	QueryMetricsPath string
 }
@@ -1,19 +1,274 @@
 package types
 
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
 type ArchiveType string
 
 const (
 	// types of files
 	JarType = "jar"
 	AarType = "aar"
+	WarType = "war"
+	// KlibType is a Kotlin/Native library of Kotlin Multiplatform projects.
+	KlibType = "klib"
+	// PomType is used for artifacts without archives, e.g. BOMs and parent poms.
+	PomType = "pom"
 
 	IndexesDir = "indexes"
 )
 
+// HashAlgorithm is a checksum algorithm of archives.
+type HashAlgorithm string
+
+const (
+	SHA1   HashAlgorithm = "sha1"
+	MD5    HashAlgorithm = "md5"
+	SHA256 HashAlgorithm = "sha256"
+	SHA512 HashAlgorithm = "sha512"
+)
+
+// hashAlgorithms are the known algorithms by digest length.
+var hashAlgorithms = map[int]HashAlgorithm{
+	20: SHA1,
+	16: MD5,
+	32: SHA256,
+	64: SHA512,
+}
+
+// Size returns the digest length in bytes. It's 0 for unknown algorithms.
+func (a HashAlgorithm) Size() int {
+	for size, alg := range hashAlgorithms {
+		if alg == a {
+			return size
+		}
+	}
+	return 0
+}
+
+// Digest is a checksum with the algorithm that produced it.
+type Digest struct {
+	Algorithm HashAlgorithm
+	Value     []byte
+}
+
+// ParseDigest parses `<algorithm>:<hex>`, e.g. `sha256:9f86...`.
+// The algorithm of a digest without prefix is guessed from its length.
+func ParseDigest(s string) (Digest, error) {
+	var alg HashAlgorithm
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		alg, s = HashAlgorithm(strings.ToLower(s[:i])), s[i+1:]
+	}
+	value, err := hex.DecodeString(s)
+	if err != nil {
+		return Digest{}, xerrors.Errorf("digest decode error: %w", err)
+	}
+	if alg == "" {
+		var ok bool
+		if alg, ok = hashAlgorithms[len(value)]; !ok {
+			return Digest{}, xerrors.Errorf("unknown digest length: %d", len(value))
+		}
+	}
+	if size := alg.Size(); size == 0 {
+		return Digest{}, xerrors.Errorf("unknown hash algorithm: %s", alg)
+	} else if len(value) != size {
+		return Digest{}, xerrors.Errorf("invalid %s length: %d bytes", alg, len(value))
+	}
+	return Digest{Algorithm: alg, Value: value}, nil
+}
+
+func (d Digest) String() string {
+	return string(d.Algorithm) + ":" + hex.EncodeToString(d.Value)
+}
+
+// MatchType shows which checksum was used to find an index.
+type MatchType string
+
+const (
+	SHA1Match = MatchType(SHA1)
+	// MD5Match is weaker than SHA1Match since md5 collisions are easy to produce.
+	MD5Match = MatchType(MD5)
+)
+
+type Artifact struct {
+	GroupID    string
+	ArtifactID string
+	// ScalaVersion is the Scala binary version of cross-built artifacts, e.g. `2.13` for `cats-core_2.13`.
+	ScalaVersion string `json:",omitempty"`
+	Downloads    int64
+	// Description and ProjectURL are filled only by SearchArtifactsFTS, from the enrichment of the artifact.
+	Description string `json:",omitempty"`
+	ProjectURL  string `json:",omitempty"`
+}
+
+// GAV is the coordinates of an artifact version.
+type GAV struct {
+	GroupID    string
+	ArtifactID string
+	Version    string
+}
+
+// ArtifactPriority is an entry of the ranking feed.
+// Empty ArtifactID means that the priority is applied to all artifacts of the group.
+type ArtifactPriority struct {
+	GroupID    string
+	ArtifactID string
+	Priority   int
+}
+
+// Popularity is an entry of the download statistics feed.
+type Popularity struct {
+	GroupID    string
+	ArtifactID string
+	Downloads  int64
+}
+
+// Collision is a sha1 published under several GAVs.
+// Only the first index is stored in the DB, others are recorded for reporting.
+type Collision struct {
+	SHA1    []byte
+	Indexes []Index
+}
+
+// Conflict is a GAV published with different sha1s, e.g. in Maven Central and an internal repository.
+// It's a dependency-confusion risk.
+type Conflict struct {
+	GroupID     string
+	ArtifactID  string
+	Version     string
+	ArchiveType ArchiveType
+	Indexes     []Index
+}
+
+// DailyStats counts artifacts and indexes added by builds on one day from one repository (cache dir).
+// A day without new indexes usually means that the crawler silently broke.
+type DailyStats struct {
+	// Day is formatted as `2006-01-02` in UTC.
+	Day          string
+	Repository   string
+	NewArtifacts int
+	NewIndexes   int
+}
+
+// DBInfo describes a DB from inside the DB, so consumers without metadata.json can validate what they received.
+type DBInfo struct {
+	SchemaVersion int
+	BuiltAt       time.Time
+	// VacuumedAt is nil if the DB was never vacuumed, e.g. for MySQL.
+	VacuumedAt *time.Time `json:",omitempty"`
+	// RowCounts are the numbers of rows of each table when the info was updated.
+	RowCounts map[string]int64
+}
+
+// Deprecation is an entry of the deprecation feed.
+// Empty ArtifactID means that the whole group is deprecated.
+type Deprecation struct {
+	GroupID    string
+	ArtifactID string
+	// Replacement is the suggested replacement, e.g. `jakarta.servlet:jakarta.servlet-api`.
+	Replacement string
+	Reason      string
+}
+
+// Label is a label attached to an artifact by operators, e.g. `approved` for an allowlist of artifacts.
+type Label struct {
+	GroupID    string
+	ArtifactID string
+	Label      string
+}
+
+// Enrichment is the metadata of an artifact fetched from deps.dev.
+// Fields are empty if deps.dev doesn't know the artifact.
+type Enrichment struct {
+	GroupID        string
+	ArtifactID     string
+	DefaultVersion string
+	Homepage       string
+	// Description and ProjectURL are the `<description>` and `<url>` of the POM of the default version.
+	// They are only fetched with POM enrichment.
+	Description string
+	ProjectURL  string
+	FetchedAt   time.Time
+}
+
+// QuarantinedIndex is an index which failed validation in build. It isn't inserted into the indexes.
+type QuarantinedIndex struct {
+	Index
+	Reason string
+}
+
+// CrawlReport is written into the cache dir at the end of a crawl and copied into the metadata by build.
+type CrawlReport struct {
+	// CacheDir is set by build.
+	CacheDir string `json:",omitempty"`
+	// RepositoryURL is empty for custom sources.
+	RepositoryURL string `json:",omitempty"`
+	// Shard is the shard of the repository covered by the crawl, e.g. `3/10`. Empty for crawls of the whole repository.
+	Shard      string `json:",omitempty"`
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Duration   string
+	// Complete is false when the crawl stopped on an error or at its budget, so the indexes may not cover the whole repository.
+	Complete bool
+	// Partial is set when the crawl stopped at its budget. The next crawl resumes from a checkpoint.
+	Partial     bool   `json:",omitempty"`
+	Error       string `json:",omitempty"`
+	VisitedURLs int
+	Groups      int
+	Artifacts   int
+	Versions    int
+	// Bytes is the size of all downloaded response bodies. Responses reused from the HTTP cache aren't counted.
+	Bytes int64
+	// Errors counts skipped files by error type, e.g. `wrong_sha1`.
+	Errors map[string]int `json:",omitempty"`
+}
+
+// Changelog lists GAVs added between two build generations.
+type Changelog struct {
+	NewGroups    []string
+	NewArtifacts []Artifact
+	NewVersions  []Index
+}
+
 type Index struct {
 	GroupID     string
 	ArtifactID  string
 	Version     string
 	SHA1        []byte
+	MD5         []byte
+	Size        int64
+	Signed      bool
+	SigningKey  string
 	ArchiveType ArchiveType
+	// Classifier is the suffix after the version in the file name, e.g. `linux-x86_64`.
+	Classifier string
+	// Platform is the OS and architecture of native classifiers, e.g. `linux-x86_64` for `natives-linux-x86_64`.
+	Platform string
+	// Priority is used to list canonical artifacts first when several groups publish the same artifactID.
+	Priority int
+	// Downloads is filled only by queries sorting by popularity.
+	Downloads int64
+	// Generation is the build that introduced the index.
+	Generation int
+	// Repository is the cache dir the index was built from. It's only read by SelectConflictingGAVs.
+	Repository string
+	// RemovedAt is set when a build no longer found the index in the repository. It's only read by SelectIndexByDigest.
+	RemovedAt *time.Time `json:",omitempty"`
+}
+
+// Digests returns the digests stored for the index.
+func (index Index) Digests() []Digest {
+	var digests []Digest
+	if len(index.SHA1) != 0 {
+		digests = append(digests, Digest{Algorithm: SHA1, Value: index.SHA1})
+	}
+	if len(index.MD5) != 0 {
+		digests = append(digests, Digest{Algorithm: MD5, Value: index.MD5})
+	}
+	return digests
 }
@@ -0,0 +1,147 @@
+// Package version compares Maven versions like Maven's ComparableVersion.
+package version
+
+import (
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+// qualifiers are well-known qualifiers in ascending order. Releases (an empty qualifier) are newer than pre-releases.
+// Unknown qualifiers are newer than all of them and compared lexically.
+var qualifiers = map[string]int{
+	"alpha":     0,
+	"beta":      1,
+	"milestone": 2,
+	"rc":        3,
+	"snapshot":  4,
+	"":          5,
+	"sp":        6,
+}
+
+// qualifierAliases are normalized before comparison.
+var qualifierAliases = map[string]string{
+	"a":       "alpha",
+	"b":       "beta",
+	"m":       "milestone",
+	"cr":      "rc",
+	"ga":      "",
+	"final":   "",
+	"release": "",
+}
+
+// item is a number or a qualifier of a version.
+type item struct {
+	number    *big.Int
+	qualifier string
+}
+
+// Compare returns -1, 0 or +1 when `a` is older than, equal to or newer than `b`.
+// e.g. 1.0-alpha < 1.0-rc1 < 1.0-SNAPSHOT < 1.0 = 1.0.0 = 1.0-ga < 1.0-sp < 1.0.1 < 1.10
+func Compare(a, b string) int {
+	ia, ib := parse(a), parse(b)
+	for i := 0; i < len(ia) || i < len(ib); i++ {
+		var x, y *item
+		if i < len(ia) {
+			x = &ia[i]
+		}
+		if i < len(ib) {
+			y = &ib[i]
+		}
+		if c := compareItems(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// parse splits `v` into items at `.` and `-` separators and at transitions between digits and letters.
+// Trailing items equal to zero or a release qualifier are removed, so `1.0.0` equals `1`.
+func parse(v string) []item {
+	var items []item
+	var token strings.Builder
+	flush := func() {
+		s := token.String()
+		token.Reset()
+		if s == "" {
+			return
+		}
+		if n, ok := new(big.Int).SetString(s, 10); ok {
+			items = append(items, item{number: n})
+			return
+		}
+		s = strings.ToLower(s)
+		if alias, ok := qualifierAliases[s]; ok {
+			s = alias
+		}
+		items = append(items, item{qualifier: s})
+	}
+
+	for i, r := range v {
+		switch {
+		case r == '.' || r == '-' || r == '_':
+			flush()
+		case i > 0 && token.Len() > 0 && unicode.IsDigit(r) != unicode.IsDigit(rune(v[i-1])):
+			flush()
+			token.WriteRune(r)
+		default:
+			token.WriteRune(r)
+		}
+	}
+	flush()
+
+	for len(items) > 0 && isNull(items[len(items)-1]) {
+		items = items[:len(items)-1]
+	}
+	return items
+}
+
+func isNull(it item) bool {
+	if it.number != nil {
+		return it.number.Sign() == 0
+	}
+	return it.qualifier == ""
+}
+
+// compareItems compares items at the same position. A nil item is a missing one, which equals zero and a release.
+func compareItems(x, y *item) int {
+	switch {
+	case x == nil && y == nil:
+		return 0
+	case x == nil:
+		return -compareItems(y, nil)
+	case y == nil:
+		if x.number != nil {
+			return x.number.Sign()
+		}
+		return compareQualifiers(x.qualifier, "")
+	case x.number != nil && y.number != nil:
+		return x.number.Cmp(y.number)
+	case x.number != nil:
+		// Numbers are newer than qualifiers, e.g. 1.0.1 > 1.0-sp
+		return 1
+	case y.number != nil:
+		return -1
+	}
+	return compareQualifiers(x.qualifier, y.qualifier)
+}
+
+func compareQualifiers(x, y string) int {
+	rx, okx := qualifiers[x]
+	ry, oky := qualifiers[y]
+	if !okx {
+		rx = len(qualifiers)
+	}
+	if !oky {
+		ry = len(qualifiers)
+	}
+	switch {
+	case rx < ry:
+		return -1
+	case rx > ry:
+		return 1
+	case okx:
+		return 0
+	}
+	return strings.Compare(x, y)
+}
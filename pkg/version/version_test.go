@@ -0,0 +1,40 @@
+package version_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/h7hac9/trivy-java-db/pkg/version"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.0", b: "1.0.0", want: 0},
+		{a: "1.0-ga", b: "1", want: 0},
+		{a: "1.0.Final", b: "1.0", want: 0},
+		{a: "1.2", b: "1.10", want: -1},
+		{a: "1.0-alpha-1", b: "1.0-beta-1", want: -1},
+		{a: "1.0-beta2", b: "1.0-rc1", want: -1},
+		{a: "1.0-RC1", b: "1.0-rc2", want: -1},
+		{a: "1.0-CR1", b: "1.0-rc1", want: 0},
+		{a: "1.0-SNAPSHOT", b: "1.0", want: -1},
+		{a: "1.0-rc1", b: "1.0-SNAPSHOT", want: -1},
+		{a: "1.0", b: "1.0-sp1", want: -1},
+		{a: "1.0-sp1", b: "1.0.1", want: -1},
+		{a: "1.0-sp1", b: "1.0-foo", want: -1},
+		{a: "1.0-bar", b: "1.0-foo", want: -1},
+		{a: "2.0.0.Final", b: "2.0.0.CR3", want: 1},
+		{a: "20230227", b: "20220320", want: 1},
+		{a: "1.0.99999999999999999999", b: "1.0.100000000000000000000", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.want, version.Compare(tt.a, tt.b))
+			assert.Equal(t, -tt.want, version.Compare(tt.b, tt.a))
+		})
+	}
+}